@@ -0,0 +1,201 @@
+package phantomjs
+
+// States reported in AXNode.States.
+const (
+	StateChecked  = "checked"
+	StateDisabled = "disabled"
+	StateExpanded = "expanded"
+	StateRequired = "required"
+	StateSelected = "selected"
+)
+
+// AXNode represents a node of a page's accessibility tree.
+type AXNode struct {
+	// ARIA role, from the role attribute or implied by the element, such as
+	// "button" or "heading". Text is reported with the role "text" and the
+	// root with the role "document".
+	Role string
+
+	// Accessible name, from aria-labelledby, aria-label, an associated label,
+	// alt text, the element's content or its title, in that order.
+	Name string
+
+	// Current value of form fields. Password values are not reported.
+	Value string
+
+	// Heading level, from 1 to 6. Zero for other roles.
+	Level int
+
+	// States that apply to the node, such as StateChecked.
+	States []string
+
+	// CSS selector of the element. Empty for text and the root.
+	Selector string
+
+	Children []*AXNode
+}
+
+// Walk calls fn for n and each of its descendants in document order. The
+// children of a node are skipped if fn returns false.
+func (n *AXNode) Walk(fn func(*AXNode) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// AccessibilityTree returns a simplified accessibility tree of the page,
+// computed from the DOM by an injected script. Elements without a role, such
+// as plain divs, are omitted and their descendants are attached to the
+// nearest ancestor with a role. Hidden elements are omitted.
+//
+// The tree approximates what assistive technology sees and is intended for
+// smoke checks, such as finding images without names:
+//
+//	tree.Walk(func(n *phantomjs.AXNode) bool {
+//		if n.Role == "img" && n.Name == "" {
+//			log.Printf("unnamed image: %s", n.Selector)
+//		}
+//		return true
+//	})
+func (p *WebPage) AccessibilityTree() (*AXNode, error) {
+	var root *AXNode
+	if err := p.evaluateInto(accessibilityScript, &root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// accessibilityScript computes the accessibility tree of the document.
+const accessibilityScript = `function() {
+	var selector = ` + selectorFunc + `;
+	var collapse = function(s) { return String(s || '').replace(/\s+/g, ' ').replace(/^ | $/g, ''); };
+
+	var implicit = {
+		ARTICLE: 'article', ASIDE: 'complementary', BUTTON: 'button', DIALOG: 'dialog',
+		FIELDSET: 'group', FORM: 'form', H1: 'heading', H2: 'heading', H3: 'heading',
+		H4: 'heading', H5: 'heading', H6: 'heading', HR: 'separator', LI: 'listitem',
+		MAIN: 'main', NAV: 'navigation', OL: 'list', OPTION: 'option', PROGRESS: 'progressbar',
+		TABLE: 'table', TD: 'cell', TEXTAREA: 'textbox', TH: 'columnheader', TR: 'row', UL: 'list'
+	};
+	var inputRoles = {
+		button: 'button', checkbox: 'checkbox', email: 'textbox', image: 'button', number: 'spinbutton',
+		password: 'textbox', radio: 'radio', range: 'slider', reset: 'button', search: 'searchbox',
+		submit: 'button', tel: 'textbox', text: 'textbox', url: 'textbox'
+	};
+	// Roles that take their name from their content.
+	var fromContent = {button: 1, cell: 1, columnheader: 1, heading: 1, link: 1, option: 1, tab: 1, menuitem: 1};
+
+	// Returns the nearest ancestor of el whose tag name matches re.
+	var ancestor = function(el, re) {
+		for (var n = el.parentElement; n; n = n.parentElement) {
+			if (re.test(n.tagName)) return n;
+		}
+		return null;
+	};
+
+	var roleOf = function(el) {
+		var role = el.getAttribute('role');
+		if (role) return role.split(' ')[0];
+		var tag = el.tagName;
+		if (tag === 'A' || tag === 'AREA') return el.hasAttribute('href') ? 'link' : '';
+		if (tag === 'IMG') return el.getAttribute('alt') === '' ? '' : 'img';
+		if (tag === 'INPUT') return inputRoles[String(el.type).toLowerCase()] || (el.type === 'hidden' ? '' : 'textbox');
+		if (tag === 'SELECT') return el.multiple || el.size > 1 ? 'listbox' : 'combobox';
+		if (tag === 'HEADER' && !ancestor(el, /^(ARTICLE|ASIDE|MAIN|NAV|SECTION)$/)) return 'banner';
+		if (tag === 'FOOTER' && !ancestor(el, /^(ARTICLE|ASIDE|MAIN|NAV|SECTION)$/)) return 'contentinfo';
+		if (tag === 'SECTION') return el.hasAttribute('aria-label') || el.hasAttribute('aria-labelledby') ? 'region' : '';
+		return implicit[tag] || '';
+	};
+
+	var labelOf = function(el) {
+		if (el.id) {
+			var label = document.querySelector('label[for="' + el.id.replace(/"/g, '\\"') + '"]');
+			if (label) return label.textContent;
+		}
+		var parent = ancestor(el, /^LABEL$/);
+		return parent ? parent.textContent : '';
+	};
+
+	var nameOf = function(el, role) {
+		var ids = el.getAttribute('aria-labelledby');
+		if (ids) {
+			return collapse(ids.split(/\s+/).map(function(id) {
+				var ref = document.getElementById(id);
+				return ref ? ref.textContent : '';
+			}).join(' '));
+		}
+		if (el.getAttribute('aria-label')) return collapse(el.getAttribute('aria-label'));
+		var tag = el.tagName;
+		if (tag === 'INPUT' || tag === 'SELECT' || tag === 'TEXTAREA') {
+			if (el.type === 'submit' || el.type === 'reset' || el.type === 'button') return collapse(el.value || el.type);
+			if (el.type === 'image') return collapse(el.alt);
+			return collapse(labelOf(el) || el.getAttribute('placeholder') || el.title);
+		}
+		if (tag === 'IMG' || tag === 'AREA') return collapse(el.alt || el.title);
+		if (fromContent[role]) return collapse(el.textContent || el.title);
+		return collapse(el.title);
+	};
+
+	var valueOf = function(el) {
+		var tag = el.tagName;
+		if (tag === 'INPUT' && el.type !== 'password' && el.type !== 'checkbox' && el.type !== 'radio' && inputRoles[el.type] !== 'button') return el.value;
+		if (tag === 'TEXTAREA') return el.value;
+		if (tag === 'SELECT') return el.selectedIndex >= 0 ? collapse(el.options[el.selectedIndex].text) : '';
+		if (tag === 'PROGRESS') return String(el.value);
+		return el.getAttribute('aria-valuenow') || '';
+	};
+
+	var statesOf = function(el) {
+		var states = [];
+		if (el.checked || el.getAttribute('aria-checked') === 'true') states.push('checked');
+		if (el.disabled || el.getAttribute('aria-disabled') === 'true') states.push('disabled');
+		if (el.getAttribute('aria-expanded') === 'true') states.push('expanded');
+		if (el.required || el.getAttribute('aria-required') === 'true') states.push('required');
+		if (el.selected || el.getAttribute('aria-selected') === 'true') states.push('selected');
+		return states;
+	};
+
+	var hidden = function(el) {
+		if (el.hidden || el.getAttribute('aria-hidden') === 'true') return true;
+		var style = window.getComputedStyle(el);
+		return style.display === 'none' || style.visibility === 'hidden';
+	};
+
+	// Appends the nodes of el's children to parent. Text is skipped if it
+	// already names the parent.
+	var walk = function(el, parent, named) {
+		for (var c = el.firstChild; c; c = c.nextSibling) {
+			if (c.nodeType === 3) {
+				var text = named ? '' : collapse(c.nodeValue);
+				if (text) parent.children.push({role: 'text', name: text, children: []});
+				continue;
+			} else if (c.nodeType !== 1 || /^(SCRIPT|STYLE|NOSCRIPT|TEMPLATE|HEAD)$/.test(c.tagName) || hidden(c)) {
+				continue;
+			}
+
+			var role = roleOf(c);
+			if (!role || role === 'presentation' || role === 'none') {
+				walk(c, parent, named);
+				continue;
+			}
+			var node = {
+				role: role,
+				name: nameOf(c, role),
+				value: valueOf(c),
+				level: role === 'heading' ? (Number(c.getAttribute('aria-level')) || Number(c.tagName.slice(1)) || 2) : 0,
+				states: statesOf(c),
+				selector: selector(c),
+				children: []
+			};
+			walk(c, node, !!fromContent[role]);
+			parent.children.push(node);
+		}
+	};
+
+	var root = {role: 'document', name: collapse(document.title), children: []};
+	if (document.body) walk(document.body, root, false);
+	return root;
+}`
@@ -0,0 +1,103 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultAnnotationColor is the color used by Annotation if none is set.
+const DefaultAnnotationColor = "red"
+
+// Annotation describes elements to highlight in RenderAnnotated.
+type Annotation struct {
+	// CSS selector of the elements to outline. Every matching element is
+	// outlined.
+	Selector string
+
+	// Text shown above each outline. Optional.
+	Label string
+
+	// CSS color of the outline and the label's background.
+	// Defaults to DefaultAnnotationColor.
+	Color string
+}
+
+// RenderAnnotated renders the page like RenderWithOptions with the elements
+// of each annotation outlined and labeled, for bug reports and monitoring
+// alerts. Outlines are computed from the elements' bounding boxes and drawn
+// by the page itself on an overlay that is removed after rendering, so they
+// follow the render's scale and clipping.
+//
+// Returns ErrElementNotFound if the selector of an annotation matches no
+// element.
+func (p *WebPage) RenderAnnotated(annotations []Annotation, opts RenderOptions) ([]byte, error) {
+	a := make([]map[string]string, len(annotations))
+	for i, ann := range annotations {
+		color := ann.Color
+		if color == "" {
+			color = DefaultAnnotationColor
+		}
+		a[i] = map[string]string{"selector": ann.Selector, "label": ann.Label, "color": color}
+	}
+	buf, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []int
+	err = p.evaluateInto(fmt.Sprintf(annotateScript, buf), &counts)
+	defer p.Evaluate(removeAnnotationsScript)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range counts {
+		if n == 0 {
+			return nil, ErrElementNotFound
+		}
+	}
+	return p.RenderWithOptions(opts)
+}
+
+// annotateScript adds an overlay that outlines the elements of each
+// annotation and returns the number of elements matched by each.
+const annotateScript = `function() {
+	var annotations = %s;
+	var old = document.getElementById('__phantomAnnotations');
+	if (old) old.parentNode.removeChild(old);
+
+	var overlay = document.createElement('div');
+	overlay.id = '__phantomAnnotations';
+	overlay.setAttribute('style', 'position:absolute;top:0;left:0;width:0;height:0;overflow:visible;z-index:2147483647;pointer-events:none;');
+
+	var div = function(style) {
+		var el = document.createElement('div');
+		el.setAttribute('style', 'position:absolute;margin:0;box-sizing:border-box;' + style);
+		overlay.appendChild(el);
+		return el;
+	};
+
+	var counts = [];
+	for (var i = 0; i < annotations.length; i++) {
+		var a = annotations[i], els = document.querySelectorAll(a.selector);
+		counts.push(els.length);
+		for (var j = 0; j < els.length; j++) {
+			var r = els[j].getBoundingClientRect();
+			var top = r.top + window.pageYOffset, left = r.left + window.pageXOffset;
+			div('top:' + (top - 3) + 'px;left:' + (left - 3) + 'px;width:' + (r.width + 6) + 'px;height:' + (r.height + 6) + 'px;border:3px solid ' + a.color + ';');
+			if (!a.label) continue;
+
+			// Place the label above the outline, or inside it at the top of the page.
+			var label = div('left:' + (left - 3) + 'px;padding:0 4px;background:' + a.color + ';color:#fff;font:bold 12px/18px sans-serif;white-space:nowrap;');
+			label.style.top = (top >= 21 ? top - 21 : top) + 'px';
+			label.textContent = a.label;
+		}
+	}
+	document.documentElement.appendChild(overlay);
+	return counts;
+}`
+
+// removeAnnotationsScript removes the overlay added by annotateScript.
+const removeAnnotationsScript = `function() {
+	var el = document.getElementById('__phantomAnnotations');
+	if (el) el.parentNode.removeChild(el);
+}`
@@ -0,0 +1,162 @@
+package phantomjs
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrArticleNotFound is returned by Article when the page has no content
+	// that looks like an article.
+	ErrArticleNotFound = errors.New("article not found")
+)
+
+// Article represents the main content of a page as extracted by Article.
+type Article struct {
+	Title  string
+	Byline string
+
+	// Publication time. Zero if the page does not declare one.
+	Published time.Time
+
+	// Cleaned HTML of the content and its plain text, with paragraphs
+	// separated by blank lines.
+	HTML string
+	Text string
+}
+
+// Article extracts the main content of the page using a readability-style
+// heuristic: blocks of text are scored by length and punctuation, penalized
+// for link density and boilerplate class names, and the best scoring
+// container is returned with navigation, scripts and forms removed.
+//
+// The page's current DOM is used, so content added by scripts is included.
+func (p *WebPage) Article() (*Article, error) {
+	var v *struct {
+		Title     string `json:"title"`
+		Byline    string `json:"byline"`
+		Published string `json:"published"`
+		HTML      string `json:"html"`
+		Text      string `json:"text"`
+	}
+	if err := p.evaluateInto(articleScript, &v); err != nil {
+		return nil, err
+	} else if v == nil {
+		return nil, ErrArticleNotFound
+	}
+
+	a := &Article{Title: v.Title, Byline: v.Byline, HTML: v.HTML, Text: v.Text}
+	if v.Published != "" {
+		a.Published, _ = time.Parse(time.RFC3339, v.Published)
+	}
+	return a, nil
+}
+
+// articleScript finds the article on the page. It returns null if no block
+// of text is long enough to be an article.
+const articleScript = `function() {
+	var unlikely = /ad-|banner|comment|footer|header|menu|nav|popup|related|share|sidebar|social|sponsor/i;
+	var positive = /article|body|content|entry|main|post|story|text/i;
+
+	function text(el) {
+		return (el.textContent || '').replace(/\s+/g, ' ').trim();
+	}
+
+	function meta(names) {
+		for (var i = 0; i < names.length; i++) {
+			var el = document.querySelector('meta[property="' + names[i] + '"], meta[name="' + names[i] + '"], meta[itemprop="' + names[i] + '"]');
+			if (el && el.getAttribute('content')) return el.getAttribute('content').trim();
+		}
+		return '';
+	}
+
+	function linkDensity(el) {
+		var length = text(el).length;
+		if (length === 0) return 0;
+		var links = el.querySelectorAll('a'), linkLength = 0;
+		for (var i = 0; i < links.length; i++) linkLength += text(links[i]).length;
+		return linkLength / length;
+	}
+
+	function weight(el) {
+		var s = (el.className || '') + ' ' + (el.id || ''), w = 0;
+		if (positive.test(s)) w += 25;
+		if (unlikely.test(s)) w -= 25;
+		if (el.tagName === 'ARTICLE' || el.tagName === 'MAIN') w += 25;
+		return w;
+	}
+
+	// Metadata.
+	var h1 = document.querySelector('h1');
+	var title = meta(['og:title', 'twitter:title']) || (h1 ? text(h1) : '') || document.title;
+	var byline = meta(['author', 'article:author', 'byl']);
+	if (!byline) {
+		var el = document.querySelector('[rel="author"], [itemprop="author"], .byline, .author');
+		if (el) byline = text(el);
+	}
+	var published = meta(['article:published_time', 'datePublished', 'date', 'pubdate', 'dc.date']);
+	if (!published) {
+		var t = document.querySelector('time[datetime]');
+		if (t) published = t.getAttribute('datetime');
+	}
+	var d = published ? new Date(published) : null;
+	published = d && !isNaN(d.getTime()) ? d.toISOString() : '';
+
+	// Work on a copy so the page is not modified.
+	var root = document.body.cloneNode(true);
+	var junk = root.querySelectorAll('script, style, noscript, iframe, form, nav, aside, footer, header, button, input, select, textarea, svg');
+	for (var i = 0; i < junk.length; i++) {
+		if (junk[i].parentNode) junk[i].parentNode.removeChild(junk[i]);
+	}
+
+	// Score the containers of each block of text.
+	var candidates = [];
+	function add(el, score) {
+		if (!el || el.nodeType !== 1) return;
+		if (el.__score === undefined) {
+			el.__score = weight(el);
+			candidates.push(el);
+		}
+		el.__score += score;
+	}
+	var blocks = root.querySelectorAll('p, pre, td');
+	for (var i = 0; i < blocks.length; i++) {
+		var s = text(blocks[i]);
+		if (s.length < 25) continue;
+		var score = 1 + s.split(',').length + Math.min(Math.floor(s.length / 100), 3);
+		add(blocks[i].parentNode, score);
+		if (blocks[i].parentNode) add(blocks[i].parentNode.parentNode, score / 2);
+	}
+
+	var best = null;
+	for (var i = 0; i < candidates.length; i++) {
+		var c = candidates[i];
+		c.__score *= 1 - linkDensity(c);
+		if (!best || c.__score > best.__score) best = c;
+	}
+	if (!best) return null;
+
+	// Remove link lists and short boilerplate blocks from the content.
+	var els = best.querySelectorAll('div, section, ul, ol, table');
+	for (var i = els.length - 1; i >= 0; i--) {
+		var el = els[i];
+		if (!el.parentNode) continue;
+		if (linkDensity(el) > 0.5 || (unlikely.test((el.className || '') + ' ' + (el.id || '')) && text(el).length < 200)) {
+			el.parentNode.removeChild(el);
+		}
+	}
+
+	var paragraphs = [], nodes = best.querySelectorAll('h1, h2, h3, h4, h5, h6, p, pre, li, blockquote');
+	for (var i = 0; i < nodes.length; i++) {
+		var s = text(nodes[i]);
+		if (s) paragraphs.push(s);
+	}
+
+	return {
+		title: title,
+		byline: byline,
+		published: published,
+		html: best.innerHTML.trim(),
+		text: paragraphs.length > 0 ? paragraphs.join('\n\n') : text(best)
+	};
+}`
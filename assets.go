@@ -0,0 +1,58 @@
+package phantomjs
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+)
+
+// AssetServer serves files over HTTP on the loopback interface, so HTML
+// generated in Go and loaded with SetContentAndURL or RenderTemplate can
+// reference local stylesheets, fonts and images by relative URL. Unlike
+// file:// URLs, assets are loaded with their MIME types and are subject to
+// the usual same-origin rules.
+type AssetServer struct {
+	url    string
+	server *http.Server
+}
+
+// ServeDir serves the files of dir on an ephemeral port until the server is
+// closed.
+func ServeDir(dir string) (*AssetServer, error) {
+	if fi, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, &fs.PathError{Op: "serve", Path: dir, Err: errors.New("not a directory")}
+	}
+	return ServeFS(os.DirFS(dir))
+}
+
+// ServeFS serves the files of fsys, such as an embed.FS, on an ephemeral port
+// until the server is closed.
+func ServeFS(fsys fs.FS) (*AssetServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &AssetServer{
+		url:    "http://" + ln.Addr().String() + "/",
+		server: &http.Server{Handler: http.FileServer(http.FS(fsys))},
+	}
+	go s.server.Serve(ln)
+	return s, nil
+}
+
+// URL returns the base URL of the served files, ending in a slash. Relative
+// paths resolve against it, so "css/style.css" refers to the file at that
+// path in the directory.
+func (s *AssetServer) URL() string {
+	return s.url
+}
+
+// Close stops the server.
+func (s *AssetServer) Close() error {
+	return s.server.Close()
+}
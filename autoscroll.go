@@ -0,0 +1,113 @@
+package phantomjs
+
+import (
+	"time"
+)
+
+// Default auto scroll settings.
+const (
+	DefaultMaxScrolls        = 50
+	DefaultScrollIdleTimeout = 10 * time.Second
+)
+
+// AutoScrollOptions represents the options of WebPage.AutoScroll.
+type AutoScrollOptions struct {
+	// Maximum number of scrolls. Defaults to DefaultMaxScrolls.
+	MaxScrolls int
+
+	// Document height, in pixels, at which to stop. Zero for no limit.
+	MaxHeight int
+
+	// Quiet period and number of requests allowed to remain pending for
+	// the network to count as idle after each scroll, as in OpenOptions.
+	// IdleTime defaults to DefaultNetworkIdleTime.
+	IdleTime    time.Duration
+	MaxInflight int
+
+	// Maximum time to wait for the network to become idle after each
+	// scroll. The height is checked anyway once it expires, so pages that
+	// poll the network still make progress.
+	// Defaults to DefaultScrollIdleTimeout.
+	IdleTimeout time.Duration
+}
+
+// AutoScrollResult represents the outcome of WebPage.AutoScroll.
+type AutoScrollResult struct {
+	// Number of scrolls whose requests were waited for and the final
+	// document height in pixels.
+	Scrolls int
+	Height  int
+
+	// True if the height stopped growing, rather than a limit being reached.
+	Complete bool
+}
+
+// AutoScroll loads the content of an infinite scrolling page. It repeatedly
+// scrolls to the bottom of the document and waits for the requests it
+// triggers to finish, until the document height stops growing or a limit in
+// opts is reached. The page is left scrolled to the bottom.
+func (p *WebPage) AutoScroll(opts AutoScrollOptions) (*AutoScrollResult, error) {
+	if opts.MaxScrolls <= 0 {
+		opts.MaxScrolls = DefaultMaxScrolls
+	}
+	if opts.IdleTime <= 0 {
+		opts.IdleTime = DefaultNetworkIdleTime
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultScrollIdleTimeout
+	}
+
+	var height int
+	if err := p.evaluateInto(autoScrollScript, &height); err != nil {
+		return nil, err
+	}
+
+	result := &AutoScrollResult{Scrolls: 1, Height: height}
+	for {
+		if _, err := p.waitNetworkIdle(opts.IdleTime, opts.MaxInflight, opts.IdleTimeout); err != nil {
+			return result, err
+		} else if err := p.evaluateInto(autoScrollScript, &height); err != nil {
+			return result, err
+		}
+
+		if height <= result.Height {
+			result.Complete = true
+			break
+		}
+		result.Height = height
+		if result.Scrolls >= opts.MaxScrolls || (opts.MaxHeight > 0 && height >= opts.MaxHeight) {
+			break
+		}
+		result.Scrolls++
+	}
+	return result, nil
+}
+
+// waitNetworkIdle waits until no more than maxInflight of the page's
+// requests have been pending for idleTime. Returns false if the network is
+// still busy once timeout expires.
+func (p *WebPage) waitNetworkIdle(idleTime time.Duration, maxInflight int, timeout time.Duration) (bool, error) {
+	var resp struct {
+		Idle bool `json:"idle"`
+	}
+	req := map[string]interface{}{
+		"ref":         p.ref.id,
+		"idleTime":    int64(idleTime / time.Millisecond),
+		"maxInflight": maxInflight,
+		"timeout":     int64(timeout / time.Millisecond),
+	}
+	// The shim bounds the wait by timeout, so the page's default timeout
+	// does not apply.
+	if err := p.call(p.context(), "POST", "/webpage/WaitNetworkIdle", req, &resp); err != nil {
+		return false, err
+	}
+	return resp.Idle, nil
+}
+
+// autoScrollScript scrolls to the bottom of the document and returns its
+// height.
+const autoScrollScript = `function() {
+	var height = Math.max(document.body ? document.body.scrollHeight : 0, document.documentElement.scrollHeight);
+	window.scrollTo(0, height);
+	return height;
+}`
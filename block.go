@@ -0,0 +1,356 @@
+package phantomjs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultFilterListInterval is how often a FilterListUpdater reloads its lists
+// if no interval is set.
+const DefaultFilterListInterval = 24 * time.Hour
+
+// FilterList represents a compiled EasyList-style (Adblock Plus) filter list.
+//
+// Only request blocking rules are used. Element hiding rules, comments and
+// rules with options that cannot be applied to a request, such as $popup or
+// $csp, are skipped.
+type FilterList struct {
+	// Name that pages refer to the list by.
+	Name string
+
+	rules []filterRule
+}
+
+// filterRule is a compiled filter rule, encoded as JSON for the shim.
+type filterRule struct {
+	// JavaScript regular expression matched against the request URL.
+	Pattern   string `json:"pattern"`
+	MatchCase bool   `json:"matchCase,omitempty"`
+
+	// Host the pattern is anchored to, if any. Used to index rules.
+	Host string `json:"host,omitempty"`
+
+	// If true, matching requests are allowed even if another rule blocks
+	// them.
+	Exception bool `json:"exception,omitempty"`
+
+	// 1 to match third-party requests only, -1 for first-party only.
+	ThirdParty int `json:"thirdParty,omitempty"`
+
+	// Page domains the rule is restricted to or excluded from.
+	Domains    []string `json:"domains,omitempty"`
+	NotDomains []string `json:"notDomains,omitempty"`
+
+	// Request types the rule is restricted to or excluded from.
+	Types    []string `json:"types,omitempty"`
+	NotTypes []string `json:"notTypes,omitempty"`
+}
+
+// filterTypes maps filter type options to the request types that the shim
+// can infer. Other type options are not supported.
+var filterTypes = map[string]string{
+	"script":         "script",
+	"image":          "image",
+	"stylesheet":     "stylesheet",
+	"css":            "stylesheet",
+	"xmlhttprequest": "xmlhttprequest",
+	"xhr":            "xmlhttprequest",
+	"font":           "font",
+	"media":          "media",
+	"other":          "other",
+}
+
+// ParseFilterList reads a filter list from r.
+func ParseFilterList(name string, r io.Reader) (*FilterList, error) {
+	l := &FilterList{Name: name}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if rule, ok := parseFilterRule(strings.TrimSpace(scanner.Text())); ok {
+			l.rules = append(l.rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Len returns the number of rules in the list.
+func (l *FilterList) Len() int {
+	return len(l.rules)
+}
+
+// parseFilterRule compiles a single line of a filter list. Returns false if
+// the line is not a request blocking rule.
+func parseFilterRule(line string) (filterRule, bool) {
+	var rule filterRule
+	switch {
+	case line == "", strings.HasPrefix(line, "!"), strings.HasPrefix(line, "["):
+		return rule, false
+	case strings.Contains(line, "##"), strings.Contains(line, "#@#"), strings.Contains(line, "#?#"), strings.Contains(line, "#$#"):
+		return rule, false
+	}
+
+	if strings.HasPrefix(line, "@@") {
+		rule.Exception = true
+		line = line[2:]
+	}
+
+	// Split off the options unless the pattern is a regular expression.
+	pattern := line
+	if i := strings.LastIndex(line, "$"); i >= 0 && !isFilterRegexp(line) {
+		pattern = line[:i]
+
+		var unsupported bool
+		for _, opt := range strings.Split(line[i+1:], ",") {
+			negate := strings.HasPrefix(opt, "~")
+			name := strings.ToLower(strings.TrimPrefix(opt, "~"))
+			switch {
+			case name == "third-party", name == "3p":
+				rule.ThirdParty = 1
+				if negate {
+					rule.ThirdParty = -1
+				}
+			case name == "first-party", name == "1p":
+				rule.ThirdParty = -1
+				if negate {
+					rule.ThirdParty = 1
+				}
+			case name == "match-case":
+				rule.MatchCase = true
+			case name == "important", name == "all":
+			case strings.HasPrefix(name, "domain="):
+				for _, d := range strings.Split(name[len("domain="):], "|") {
+					if strings.HasPrefix(d, "~") {
+						rule.NotDomains = append(rule.NotDomains, d[1:])
+					} else if d != "" {
+						rule.Domains = append(rule.Domains, d)
+					}
+				}
+			case filterTypes[name] != "":
+				if negate {
+					rule.NotTypes = append(rule.NotTypes, filterTypes[name])
+				} else {
+					rule.Types = append(rule.Types, filterTypes[name])
+				}
+			case name == "document", name == "subdocument", name == "object", name == "object-subrequest", name == "websocket", name == "webrtc", name == "ping", name == "popup":
+				// Excluding a type that is never inferred has no effect. A rule
+				// limited to such types never matches.
+				unsupported = unsupported || !negate
+			default:
+				return rule, false
+			}
+		}
+		if unsupported && len(rule.Types) == 0 {
+			return rule, false
+		}
+	}
+
+	rule.Pattern, rule.Host = filterPatternRegexp(pattern)
+	return rule, true
+}
+
+// isFilterRegexp returns true if the pattern is a regular expression literal.
+func isFilterRegexp(pattern string) bool {
+	return len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// filterPatternRegexp converts a filter pattern to a regular expression. Also
+// returns the host that the pattern is anchored to, if it starts with a
+// complete host name.
+func filterPatternRegexp(pattern string) (re, host string) {
+	if isFilterRegexp(pattern) {
+		return pattern[1 : len(pattern)-1], ""
+	}
+
+	var buf strings.Builder
+	if strings.HasPrefix(pattern, "||") {
+		pattern = pattern[2:]
+		buf.WriteString(`^[a-z][a-z0-9+.-]*:\/\/([^\/?#]*\.)?`)
+		if i := strings.IndexAny(pattern, "^/:?|*"); i > 0 && pattern[i] != '*' {
+			host = strings.ToLower(pattern[:i])
+		}
+	} else if strings.HasPrefix(pattern, "|") {
+		pattern = pattern[1:]
+		buf.WriteByte('^')
+	}
+
+	anchorEnd := strings.HasSuffix(pattern, "|")
+	if anchorEnd {
+		pattern = pattern[:len(pattern)-1]
+	}
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			buf.WriteString(".*")
+		case '^':
+			buf.WriteString(`(?:[^\w.%-]|$)`)
+		case '.', '+', '?', '$', '{', '}', '(', ')', '[', ']', '\\', '/', '|':
+			buf.WriteByte('\\')
+			buf.WriteRune(c)
+		default:
+			buf.WriteRune(c)
+		}
+	}
+	if anchorEnd {
+		buf.WriteByte('$')
+	}
+	return buf.String(), host
+}
+
+// SetFilterList installs a filter list on the process, replacing any list
+// with the same name. Pages using the list pick up the new rules immediately.
+func (p *Process) SetFilterList(l *FilterList) error {
+	rules := l.rules
+	if rules == nil {
+		rules = []filterRule{}
+	}
+	return p.doJSON(context.Background(), "POST", "/process/SetFilterList", map[string]interface{}{"name": l.Name, "rules": rules}, nil)
+}
+
+// RemoveFilterList removes a filter list from the process.
+func (p *Process) RemoveFilterList(name string) error {
+	return p.doJSON(context.Background(), "POST", "/process/RemoveFilterList", map[string]interface{}{"name": name}, nil)
+}
+
+// SetFilterLists blocks the page's requests that match the named filter lists
+// of the page's process, replacing the lists previously set. Lists that are
+// not installed on the process are ignored until they are. Call with no names
+// to stop blocking.
+//
+// The main document of an open is never blocked. Request types are inferred
+// from the URL and Accept header, and third-party requests are detected by
+// comparing the last two labels of host names (three for hosts such as
+// "example.co.uk").
+func (p *WebPage) SetFilterLists(names ...string) error {
+	if names == nil {
+		names = []string{}
+	}
+	return p.doJSON("POST", "/webpage/SetFilterLists", map[string]interface{}{"ref": p.ref.id, "names": names}, nil)
+}
+
+// BlockStats represents the requests blocked on a page by its filter lists
+// since the page was created.
+type BlockStats struct {
+	// Total number of blocked requests.
+	Blocked int
+
+	// Number of blocked requests by host.
+	Hosts map[string]int
+
+	// URLs of the most recently blocked requests, oldest first.
+	Recent []string
+}
+
+// BlockStats returns the requests blocked by the page's filter lists.
+func (p *WebPage) BlockStats() (*BlockStats, error) {
+	var resp struct {
+		Count  int            `json:"count"`
+		Hosts  map[string]int `json:"hosts"`
+		Recent []string       `json:"recent"`
+	}
+	if err := p.doJSON("POST", "/webpage/BlockStats", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+		return nil, err
+	}
+	return &BlockStats{Blocked: resp.Count, Hosts: resp.Hosts, Recent: resp.Recent}, nil
+}
+
+// FilterListUpdater downloads filter lists and keeps them installed on a set
+// of processes.
+type FilterListUpdater struct {
+	// Filter list URLs by list name.
+	URLs map[string]string
+
+	// Processes that the lists are installed on.
+	Processes []*Process
+
+	// How often the lists are reloaded. Defaults to
+	// DefaultFilterListInterval.
+	Interval time.Duration
+
+	// HTTP client used to download lists. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// Logger receives the failures of background reloads. If nil, nothing
+	// is logged.
+	Logger *slog.Logger
+}
+
+// Update downloads every list and installs it on every process. A list that
+// fails to download is left as it was. Returns the first error encountered.
+func (u *FilterListUpdater) Update(ctx context.Context) (err error) {
+	for name, url := range u.URLs {
+		l, e := u.fetch(ctx, name, url)
+		if e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		for _, p := range u.Processes {
+			if e := p.SetFilterList(l); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// Start updates the lists and then reloads them in the background every
+// Interval until ctx is done. Returns the error of the initial update, in
+// which case no background reloads are started.
+func (u *FilterListUpdater) Start(ctx context.Context) error {
+	if err := u.Update(ctx); err != nil {
+		return err
+	}
+
+	interval := u.Interval
+	if interval <= 0 {
+		interval = DefaultFilterListInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := u.Update(ctx); err != nil && ctx.Err() == nil && u.Logger != nil {
+					u.Logger.Warn("filter list update failed", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// fetch downloads and parses a filter list.
+func (u *FilterListUpdater) fetch(ctx context.Context, name, url string) (*FilterList, error) {
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("filter list %s: http status %d", name, resp.StatusCode)
+	}
+	return ParseFilterList(name, resp.Body)
+}
@@ -0,0 +1,128 @@
+package phantomjs
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrNotSupported is returned by a backend when it cannot implement a
+	// particular part of the Page API.
+	ErrNotSupported = errors.New("not supported")
+)
+
+// Browser represents a browser backend that can create pages.
+//
+// Process implements Browser by driving a PhantomJS process. Alternative
+// backends (such as the headless Chrome backend in the chrome subpackage)
+// implement the same interface so call sites can switch between them.
+type Browser interface {
+	// Open starts the browser.
+	Open() error
+
+	// Close stops the browser and releases its resources.
+	Close() error
+
+	// CreatePage returns a new page.
+	CreatePage() (Page, error)
+}
+
+// Page represents a web page within a browser.
+//
+// The method set mirrors WebPage. Backends that cannot implement a method
+// return ErrNotSupported.
+type Page interface {
+	Open(url string) error
+	Close() error
+
+	CanGoBack() (bool, error)
+	CanGoForward() (bool, error)
+	GoBack() error
+	GoForward() error
+	Go(index int) error
+	Reload() error
+	Stop() error
+
+	ClipRect() (Rect, error)
+	SetClipRect(rect Rect) error
+	Content() (string, error)
+	SetContent(content string) error
+	SetContentAndURL(content, url string) error
+	PlainText() (string, error)
+	Title() (string, error)
+	URL() (string, error)
+	WindowName() (string, error)
+
+	Cookies() ([]*http.Cookie, error)
+	SetCookies(cookies []*http.Cookie) error
+	AddCookie(cookie *http.Cookie) (bool, error)
+	DeleteCookie(name string) (bool, error)
+	ClearCookies() error
+	CustomHeaders() (http.Header, error)
+	SetCustomHeaders(header http.Header) error
+
+	FocusedFrameName() (string, error)
+	FrameContent() (string, error)
+	SetFrameContent(content string) error
+	FrameName() (string, error)
+	FramePlainText() (string, error)
+	FrameTitle() (string, error)
+	FrameURL() (string, error)
+	FrameCount() (int, error)
+	FrameNames() ([]string, error)
+	SwitchToFocusedFrame() error
+	SwitchToFrameName(name string) error
+	SwitchToFramePosition(pos int) error
+	SwitchToMainFrame() error
+	SwitchToParentFrame() error
+
+	LibraryPath() (string, error)
+	SetLibraryPath(path string) error
+	NavigationLocked() (bool, error)
+	SetNavigationLocked(value bool) error
+	OfflineStoragePath() (string, error)
+	OfflineStorageQuota() (int, error)
+	OwnsPages() (bool, error)
+	SetOwnsPages(v bool) error
+	PageWindowNames() ([]string, error)
+	PaperSize() (PaperSize, error)
+	SetPaperSize(size PaperSize) error
+	ScrollPosition() (Position, error)
+	SetScrollPosition(pos Position) error
+	Settings() (WebPageSettings, error)
+	SetSettings(settings WebPageSettings) error
+	ViewportSize() (width, height int, err error)
+	SetViewportSize(width, height int) error
+	ZoomFactor() (float64, error)
+	SetZoomFactor(factor float64) error
+
+	Evaluate(script string) (interface{}, error)
+	EvaluateAsync(script string, delay time.Duration) error
+	EvaluateJavaScript(script string) (interface{}, error)
+	IncludeJS(url string) error
+	InjectJS(filename string) error
+
+	Render(filename, format string, quality int) error
+	RenderBase64(format string) (string, error)
+
+	SendMouseEvent(eventType string, mouseX, mouseY int, button string) error
+	SendKeyboardEvent(eventType string, key string, modifier int) error
+	UploadFile(selector, filename string) error
+}
+
+// Ensure the PhantomJS types implement the backend interfaces.
+var (
+	_ Browser = (*Process)(nil)
+	_ Page    = (*WebPage)(nil)
+)
+
+// CreatePage returns a new web page as a Page.
+// This allows Process to be used as a Browser.
+func (p *Process) CreatePage() (Page, error) {
+	page, err := p.CreateWebPage()
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
@@ -0,0 +1,127 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotCheckable is returned when a selector matches an element that is
+	// not a checkbox or radio button.
+	ErrNotCheckable = errors.New("element is not a checkbox or radio button")
+
+	// ErrUncheckRadio is returned when unchecking a radio button, which
+	// clicking cannot do. Check another button of the group instead.
+	ErrUncheckRadio = errors.New("cannot uncheck radio button")
+
+	// ErrCheckFailed is returned when a click does not change the state of a
+	// checkbox or radio button, such as when it is disabled or a script
+	// cancels the click.
+	ErrCheckFailed = errors.New("checked state did not change")
+)
+
+// IsChecked returns true if the first checkbox or radio button matching
+// selector is checked.
+//
+// Returns ErrElementNotFound if no element matches selector.
+func (p *WebPage) IsChecked(selector string) (bool, error) {
+	v, err := p.checkable(selector)
+	if err != nil {
+		return false, err
+	}
+	return v.Checked, nil
+}
+
+// SetChecked checks or unchecks the first checkbox or radio button matching
+// selector by clicking it, if it is not already in that state. The click is
+// a real mouse event when the element is visible, so frameworks that bind to
+// click, input or change events observe the change; inputs hidden behind
+// styled labels are clicked through the DOM.
+//
+// Returns ErrElementNotFound if no element matches selector.
+func (p *WebPage) SetChecked(selector string, checked bool) error {
+	v, err := p.checkable(selector)
+	if err != nil {
+		return err
+	} else if v.Checked == checked {
+		return nil
+	} else if v.Type == "radio" && !checked {
+		return ErrUncheckRadio
+	}
+
+	if v.Visible {
+		err = p.SendMouseEvent("click", round(v.X), round(v.Y), "left")
+	} else {
+		_, err = p.Evaluate(fmt.Sprintf(clickScript, v.sel))
+	}
+	if err != nil {
+		return err
+	}
+
+	if v, err = p.checkable(selector); err != nil {
+		return err
+	} else if v.Checked != checked {
+		return ErrCheckFailed
+	}
+	return nil
+}
+
+// checkable returns the state of the checkbox or radio button matching
+// selector.
+func (p *WebPage) checkable(selector string) (*checkableJSON, error) {
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var v *checkableJSON
+	if err := p.evaluateInto(fmt.Sprintf(checkableScript, sel), &v); err != nil {
+		return nil, err
+	} else if v == nil {
+		return nil, ErrElementNotFound
+	} else if v.Type != "checkbox" && v.Type != "radio" {
+		return nil, ErrNotCheckable
+	}
+	v.sel = sel
+	return v, nil
+}
+
+// checkableJSON is a struct for decoding the state of a checkable input.
+type checkableJSON struct {
+	Type    string  `json:"type"`
+	Checked bool    `json:"checked"`
+	Visible bool    `json:"visible"`
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+
+	sel []byte
+}
+
+// checkableScript returns the type and checked state of an input and, after
+// scrolling it into view, the viewport coordinates of its center. The input
+// is visible if a click at its center would land on it or its label.
+const checkableScript = `function() {
+	var el = document.querySelector(%s);
+	if (!el) return null;
+	var type = el.tagName === 'INPUT' ? String(el.type).toLowerCase() : '';
+	if (type !== 'checkbox' && type !== 'radio') return {type: type};
+
+	el.scrollIntoView();
+	var r = el.getBoundingClientRect(), x = r.left + r.width / 2, y = r.top + r.height / 2;
+	var hit = r.width > 0 && r.height > 0 ? document.elementFromPoint(x, y) : null;
+	var visible = false;
+	for (var n = hit; n; n = n.parentNode) {
+		if (n === el || (n.tagName === 'LABEL' && n.control === el)) {
+			visible = true;
+			break;
+		}
+	}
+	return {type: type, checked: el.checked, visible: visible, x: x, y: y};
+}`
+
+// clickScript clicks an element through the DOM, which dispatches the click,
+// input and change events of a real click.
+const clickScript = `function() {
+	document.querySelector(%s).click();
+}`
@@ -0,0 +1,166 @@
+package chrome
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// errConnClosed is returned for calls made after the connection has closed.
+var errConnClosed = errors.New("devtools connection closed")
+
+// conn represents a DevTools protocol connection to a single target.
+type conn struct {
+	ws *wsConn
+
+	mu        sync.Mutex
+	nextID    int64
+	pending   map[int64]chan *message
+	listeners map[string][]chan json.RawMessage
+	err       error
+	done      chan struct{} // closed when the connection fails
+}
+
+// message represents a DevTools protocol message.
+// Responses set ID while events set Method.
+type message struct {
+	ID     int64           `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *messageError   `json:"error,omitempty"`
+}
+
+type messageError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// newConn returns a connection that reads from ws in a separate goroutine.
+func newConn(ws *wsConn) *conn {
+	c := &conn{
+		ws:        ws,
+		pending:   make(map[int64]chan *message),
+		listeners: make(map[string][]chan json.RawMessage),
+		done:      make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Close closes the underlying WebSocket.
+func (c *conn) Close() error {
+	return c.ws.Close()
+}
+
+// readLoop dispatches incoming messages until the connection fails.
+func (c *conn) readLoop() {
+	for {
+		buf, err := c.ws.ReadMessage()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		var msg message
+		if err := json.Unmarshal(buf, &msg); err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		if msg.ID != 0 {
+			if ch := c.pending[msg.ID]; ch != nil {
+				delete(c.pending, msg.ID)
+				ch <- &msg
+			}
+		} else if msg.Method != "" {
+			for _, ch := range c.listeners[msg.Method] {
+				select {
+				case ch <- msg.Params:
+				default:
+				}
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// fail marks the connection as failed and releases all waiting callers.
+func (c *conn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	close(c.done)
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// call sends a command and decodes its result into result, if not nil.
+func (c *conn) call(method string, params, result interface{}) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return errConnClosed
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	// Encode and send command.
+	req := map[string]interface{}{"id": id, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	buf, err := json.Marshal(req)
+	if err == nil {
+		err = c.ws.WriteMessage(buf)
+	}
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return err
+	}
+
+	// Wait for response.
+	msg, ok := <-ch
+	if !ok {
+		return errConnClosed
+	} else if msg.Error != nil {
+		return errors.New(msg.Error.Message)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listen returns a channel that receives params for events named method.
+// Call the returned function to stop listening. The channel is not closed when
+// the connection fails; select on c.done as well.
+func (c *conn) listen(method string) (<-chan json.RawMessage, func()) {
+	ch := make(chan json.RawMessage, 16)
+
+	c.mu.Lock()
+	c.listeners[method] = append(c.listeners[method], ch)
+	c.mu.Unlock()
+
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		a := c.listeners[method]
+		for i := range a {
+			if a[i] == ch {
+				c.listeners[method] = append(a[:i:i], a[i+1:]...)
+				break
+			}
+		}
+	}
+}
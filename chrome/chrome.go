@@ -0,0 +1,216 @@
+// Package chrome implements the phantomjs.Browser and phantomjs.Page
+// interfaces by driving headless Chrome over the DevTools protocol.
+//
+// It allows programs written against the phantomjs package to migrate off the
+// PhantomJS binary without rewriting call sites. Parts of the API that have no
+// Chrome equivalent return phantomjs.ErrNotSupported.
+package chrome
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Default settings.
+const (
+	DefaultPort    = 9222
+	DefaultBinPath = "google-chrome"
+
+	// Time that Page.Open waits for the load event.
+	LoadTimeout = 30 * time.Second
+)
+
+// Ensure types implement the backend interfaces.
+var (
+	_ phantomjs.Browser = (*Process)(nil)
+	_ phantomjs.Page    = (*Page)(nil)
+)
+
+// Process represents a headless Chrome process.
+type Process struct {
+	path string
+	cmd  *exec.Cmd
+
+	// Path to the Chrome binary.
+	BinPath string
+
+	// Port used for the DevTools protocol.
+	Port int
+
+	// Additional command line flags passed to Chrome.
+	Flags []string
+
+	// Output from the process.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewProcess returns a new instance of Process.
+func NewProcess(port int) *Process {
+	return &Process{
+		BinPath: DefaultBinPath,
+		Port:    port,
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	}
+}
+
+// Path returns the temporary user data directory used by the process.
+func (p *Process) Path() string {
+	return p.path
+}
+
+// Open starts the Chrome process.
+func (p *Process) Open() error {
+	if err := func() error {
+		// Generate temporary profile directory.
+		path, err := ioutil.TempDir("", "chrome-")
+		if err != nil {
+			return err
+		}
+		p.path = path
+
+		// Start external process.
+		args := []string{
+			"--headless",
+			"--disable-gpu",
+			"--no-first-run",
+			"--no-default-browser-check",
+			fmt.Sprintf("--remote-debugging-port=%d", p.Port),
+			fmt.Sprintf("--user-data-dir=%s", path),
+		}
+		args = append(args, p.Flags...)
+		args = append(args, "about:blank")
+
+		cmd := exec.Command(p.BinPath, args...)
+		cmd.Stdout = p.Stdout
+		cmd.Stderr = p.Stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		p.cmd = cmd
+
+		// Wait until process is available.
+		if err := p.wait(); err != nil {
+			return err
+		}
+		return nil
+	}(); err != nil {
+		p.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Close stops the process.
+func (p *Process) Close() (err error) {
+	// Kill process.
+	if p.cmd != nil {
+		if e := p.cmd.Process.Kill(); e != nil && err == nil {
+			err = e
+		}
+		p.cmd.Wait()
+	}
+
+	// Remove profile directory.
+	if p.path != "" {
+		if e := os.RemoveAll(p.path); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// URL returns the process' DevTools HTTP URL.
+func (p *Process) URL() string {
+	return fmt.Sprintf("http://localhost:%d", p.Port)
+}
+
+// wait continually checks the process until it gets a response or times out.
+func (p *Process) wait() error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(30 * time.Second)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return errors.New("timeout")
+		case <-ticker.C:
+			if err := p.doJSON("GET", "/json/version", nil); err == nil {
+				return nil
+			}
+		}
+	}
+}
+
+// doJSON sends a request to the DevTools HTTP endpoint and decodes the response.
+func (p *Process) doJSON(method, path string, resp interface{}) error {
+	req, err := http.NewRequest(method, p.URL()+path, nil)
+	if err != nil {
+		return err
+	}
+
+	httpResponse, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	body, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	} else if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %d: %s", httpResponse.StatusCode, body)
+	}
+
+	if resp != nil {
+		if err := json.Unmarshal(body, resp); err != nil {
+			return fmt.Errorf("unmarshal error: err=%s, body=%s", err, body)
+		}
+	}
+	return nil
+}
+
+// CreatePage returns a new page.
+func (p *Process) CreatePage() (phantomjs.Page, error) {
+	return p.CreateWebPage()
+}
+
+// CreateWebPage opens a new tab and returns it as a Page.
+func (p *Process) CreateWebPage() (*Page, error) {
+	var target struct {
+		ID                   string `json:"id"`
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := p.doJSON("PUT", "/json/new?about:blank", &target); err != nil {
+		return nil, err
+	}
+
+	ws, err := dialWebSocket(target.WebSocketDebuggerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	page := newPage(p, target.ID, newConn(ws))
+	for _, domain := range []string{"Page", "Runtime", "Network", "DOM"} {
+		if err := page.conn.call(domain+".enable", nil, nil); err != nil {
+			page.conn.Close()
+			return nil, err
+		}
+	}
+	return page, nil
+}
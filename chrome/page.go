@@ -0,0 +1,953 @@
+package chrome
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Page represents a Chrome tab driven over the DevTools protocol.
+type Page struct {
+	process  *Process
+	targetID string
+	conn     *conn
+
+	mu          sync.Mutex
+	clipRect    phantomjs.Rect
+	headers     http.Header
+	libraryPath string
+	paperSize   phantomjs.PaperSize
+	settings    phantomjs.WebPageSettings
+	width       int
+	height      int
+	zoomFactor  float64
+}
+
+// newPage returns a new instance of Page.
+func newPage(p *Process, targetID string, c *conn) *Page {
+	return &Page{
+		process:     p,
+		targetID:    targetID,
+		conn:        c,
+		headers:     make(http.Header),
+		libraryPath: p.Path(),
+		zoomFactor:  1,
+		settings: phantomjs.WebPageSettings{
+			JavascriptEnabled:  true,
+			LoadImages:         true,
+			WebSecurityEnabled: true,
+		},
+	}
+}
+
+// Open opens a URL and waits for the page to load.
+func (p *Page) Open(url string) error {
+	load, unlisten := p.conn.listen("Page.loadEventFired")
+	defer unlisten()
+
+	var resp struct {
+		LoaderID  string `json:"loaderId"`
+		ErrorText string `json:"errorText"`
+	}
+	if err := p.conn.call("Page.navigate", map[string]interface{}{"url": url}, &resp); err != nil {
+		return err
+	} else if resp.ErrorText != "" {
+		return errors.New("failed")
+	}
+
+	// Same-document navigations do not fire a load event.
+	if resp.LoaderID == "" {
+		return nil
+	}
+	timer := time.NewTimer(LoadTimeout)
+	defer timer.Stop()
+	select {
+	case <-load:
+		return nil
+	case <-p.conn.done:
+		return errConnClosed
+	case <-timer.C:
+		return errors.New("timeout waiting for page load")
+	}
+}
+
+// Close closes the tab and its connection.
+func (p *Page) Close() error {
+	p.conn.Close()
+	return p.process.doJSON("GET", "/json/close/"+p.targetID, nil)
+}
+
+// navigationHistory returns the history entries and the current index.
+func (p *Page) navigationHistory() (index int, entries []historyEntry, err error) {
+	var resp struct {
+		CurrentIndex int            `json:"currentIndex"`
+		Entries      []historyEntry `json:"entries"`
+	}
+	if err := p.conn.call("Page.getNavigationHistory", nil, &resp); err != nil {
+		return 0, nil, err
+	}
+	return resp.CurrentIndex, resp.Entries, nil
+}
+
+type historyEntry struct {
+	ID    int    `json:"id"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// CanGoBack returns true if the page can be navigated back.
+func (p *Page) CanGoBack() (bool, error) {
+	index, _, err := p.navigationHistory()
+	if err != nil {
+		return false, err
+	}
+	return index > 0, nil
+}
+
+// CanGoForward returns true if the page can be navigated forward.
+func (p *Page) CanGoForward() (bool, error) {
+	index, entries, err := p.navigationHistory()
+	if err != nil {
+		return false, err
+	}
+	return index < len(entries)-1, nil
+}
+
+// GoBack navigates back to the previous page.
+func (p *Page) GoBack() error { return p.Go(-1) }
+
+// GoForward navigates to the next page.
+func (p *Page) GoForward() error { return p.Go(1) }
+
+// Go navigates to the page in history by relative offset.
+// Offsets outside of the history are ignored.
+func (p *Page) Go(index int) error {
+	current, entries, err := p.navigationHistory()
+	if err != nil {
+		return err
+	}
+
+	i := current + index
+	if i < 0 || i >= len(entries) || i == current {
+		return nil
+	}
+	return p.conn.call("Page.navigateToHistoryEntry", map[string]interface{}{"entryId": entries[i].ID}, nil)
+}
+
+// Reload reloads the current web page.
+func (p *Page) Reload() error {
+	return p.conn.call("Page.reload", nil, nil)
+}
+
+// Stop stops the web page.
+func (p *Page) Stop() error {
+	return p.conn.call("Page.stopLoading", nil, nil)
+}
+
+// ClipRect returns the clipping rectangle used when rendering.
+func (p *Page) ClipRect() (phantomjs.Rect, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.clipRect, nil
+}
+
+// SetClipRect sets the clipping rectangle used when rendering.
+func (p *Page) SetClipRect(rect phantomjs.Rect) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clipRect = rect
+	return nil
+}
+
+// Content returns content of the webpage enclosed in an HTML/XML element.
+func (p *Page) Content() (s string, err error) {
+	err = p.evaluate(`document.documentElement ? document.documentElement.outerHTML : ""`, &s)
+	return s, err
+}
+
+// SetContent sets the content of the webpage.
+func (p *Page) SetContent(content string) error {
+	frameID, err := p.mainFrameID()
+	if err != nil {
+		return err
+	}
+	return p.conn.call("Page.setDocumentContent", map[string]interface{}{"frameId": frameID, "html": content}, nil)
+}
+
+// SetContentAndURL sets the content of the page as if it were served from url.
+func (p *Page) SetContentAndURL(content, url string) error {
+	paused, unlisten := p.conn.listen("Fetch.requestPaused")
+	defer unlisten()
+
+	// Intercept the request for url and serve content instead.
+	if err := p.conn.call("Fetch.enable", map[string]interface{}{
+		"patterns": []map[string]interface{}{{"urlPattern": url}},
+	}, nil); err != nil {
+		return err
+	}
+	defer p.conn.call("Fetch.disable", nil, nil)
+
+	errc, stop := make(chan error, 1), make(chan struct{})
+	defer close(stop)
+	go func() {
+		var params json.RawMessage
+		select {
+		case params = <-paused:
+		case <-p.conn.done:
+			errc <- errConnClosed
+			return
+		case <-stop:
+			return
+		}
+
+		var event struct {
+			RequestID string `json:"requestId"`
+		}
+		if err := json.Unmarshal(params, &event); err != nil {
+			errc <- err
+			return
+		}
+		errc <- p.conn.call("Fetch.fulfillRequest", map[string]interface{}{
+			"requestId":       event.RequestID,
+			"responseCode":    200,
+			"responseHeaders": []map[string]string{{"name": "Content-Type", "value": "text/html"}},
+			"body":            base64.StdEncoding.EncodeToString([]byte(content)),
+		}, nil)
+	}()
+
+	if err := p.Open(url); err != nil {
+		return err
+	}
+	return <-errc
+}
+
+// mainFrameID returns the identifier of the top-level frame.
+func (p *Page) mainFrameID() (string, error) {
+	var resp struct {
+		FrameTree struct {
+			Frame struct {
+				ID string `json:"id"`
+			} `json:"frame"`
+		} `json:"frameTree"`
+	}
+	if err := p.conn.call("Page.getFrameTree", nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.FrameTree.Frame.ID, nil
+}
+
+// PlainText returns the plain text representation of the page.
+func (p *Page) PlainText() (s string, err error) {
+	err = p.evaluate(`document.body ? document.body.innerText : ""`, &s)
+	return s, err
+}
+
+// Title returns the title of the web page.
+func (p *Page) Title() (s string, err error) {
+	err = p.evaluate(`document.title`, &s)
+	return s, err
+}
+
+// URL returns the current URL of the web page.
+func (p *Page) URL() (s string, err error) {
+	err = p.evaluate(`location.href`, &s)
+	return s, err
+}
+
+// WindowName returns the window name of the web page.
+func (p *Page) WindowName() (s string, err error) {
+	err = p.evaluate(`window.name`, &s)
+	return s, err
+}
+
+// Cookies returns a list of cookies visible to the current URL.
+func (p *Page) Cookies() ([]*http.Cookie, error) {
+	var resp struct {
+		Cookies []cookieJSON `json:"cookies"`
+	}
+	if err := p.conn.call("Network.getCookies", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	a := make([]*http.Cookie, len(resp.Cookies))
+	for i := range resp.Cookies {
+		a[i] = decodeCookieJSON(resp.Cookies[i])
+	}
+	return a, nil
+}
+
+// SetCookies replaces the cookies visible to the current URL.
+func (p *Page) SetCookies(cookies []*http.Cookie) error {
+	existing, err := p.Cookies()
+	if err != nil {
+		return err
+	}
+	for _, c := range existing {
+		if err := p.conn.call("Network.deleteCookies", map[string]interface{}{"name": c.Name, "domain": c.Domain, "path": c.Path}, nil); err != nil {
+			return err
+		}
+	}
+
+	a := make([]cookieJSON, len(cookies))
+	for i := range cookies {
+		a[i] = encodeCookieJSON(cookies[i])
+	}
+	return p.conn.call("Network.setCookies", map[string]interface{}{"cookies": a}, nil)
+}
+
+// AddCookie adds a cookie to the page.
+// Returns true if the cookie was successfully added.
+func (p *Page) AddCookie(cookie *http.Cookie) (bool, error) {
+	var resp struct {
+		Success bool `json:"success"`
+	}
+	if err := p.conn.call("Network.setCookie", encodeCookieJSON(cookie), &resp); err != nil {
+		return false, err
+	}
+	return resp.Success, nil
+}
+
+// DeleteCookie removes a cookie with a matching name.
+// Returns true if a cookie was found and deleted.
+func (p *Page) DeleteCookie(name string) (bool, error) {
+	existing, err := p.Cookies()
+	if err != nil {
+		return false, err
+	}
+
+	var found bool
+	for _, c := range existing {
+		if c.Name != name {
+			continue
+		}
+		if err := p.conn.call("Network.deleteCookies", map[string]interface{}{"name": c.Name, "domain": c.Domain, "path": c.Path}, nil); err != nil {
+			return false, err
+		}
+		found = true
+	}
+	return found, nil
+}
+
+// ClearCookies deletes all cookies.
+func (p *Page) ClearCookies() error {
+	return p.conn.call("Network.clearBrowserCookies", nil, nil)
+}
+
+// CustomHeaders returns a list of additional headers sent with the web page.
+func (p *Page) CustomHeaders() (http.Header, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hdr := make(http.Header)
+	for key := range p.headers {
+		hdr.Set(key, p.headers.Get(key))
+	}
+	return hdr, nil
+}
+
+// SetCustomHeaders sets a list of additional headers sent with the web page.
+// Only the first value for a header key will be used.
+func (p *Page) SetCustomHeaders(header http.Header) error {
+	m := make(map[string]string)
+	hdr := make(http.Header)
+	for key := range header {
+		m[key] = header.Get(key)
+		hdr.Set(key, header.Get(key))
+	}
+	if err := p.conn.call("Network.setExtraHTTPHeaders", map[string]interface{}{"headers": m}, nil); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.headers = hdr
+	return nil
+}
+
+// FocusedFrameName returns an empty string as frame switching is not supported.
+func (p *Page) FocusedFrameName() (string, error) { return "", nil }
+
+// FrameContent returns the content of the main frame.
+func (p *Page) FrameContent() (string, error) { return p.Content() }
+
+// SetFrameContent sets the content of the main frame.
+func (p *Page) SetFrameContent(content string) error { return p.SetContent(content) }
+
+// FrameName returns the name of the main frame.
+func (p *Page) FrameName() (string, error) { return p.WindowName() }
+
+// FramePlainText returns the plain text of the main frame.
+func (p *Page) FramePlainText() (string, error) { return p.PlainText() }
+
+// FrameTitle returns the title of the main frame.
+func (p *Page) FrameTitle() (string, error) { return p.Title() }
+
+// FrameURL returns the URL of the main frame.
+func (p *Page) FrameURL() (string, error) { return p.URL() }
+
+// FrameCount returns the number of child frames of the main frame.
+func (p *Page) FrameCount() (n int, err error) {
+	err = p.evaluate(`window.frames.length`, &n)
+	return n, err
+}
+
+// FrameNames returns the names of the child frames of the main frame.
+func (p *Page) FrameNames() (a []string, err error) {
+	err = p.evaluate(`Array.prototype.map.call(window.frames, function(f) { return f.name; })`, &a)
+	return a, err
+}
+
+// SwitchToFocusedFrame is not supported by this backend.
+func (p *Page) SwitchToFocusedFrame() error { return phantomjs.ErrNotSupported }
+
+// SwitchToFrameName is not supported by this backend.
+func (p *Page) SwitchToFrameName(name string) error { return phantomjs.ErrNotSupported }
+
+// SwitchToFramePosition is not supported by this backend.
+func (p *Page) SwitchToFramePosition(pos int) error { return phantomjs.ErrNotSupported }
+
+// SwitchToMainFrame is a no-op as the main frame is always current.
+func (p *Page) SwitchToMainFrame() error { return nil }
+
+// SwitchToParentFrame is a no-op as the main frame is always current.
+func (p *Page) SwitchToParentFrame() error { return nil }
+
+// LibraryPath returns the path used by InjectJS() to resolve scripts.
+func (p *Page) LibraryPath() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.libraryPath, nil
+}
+
+// SetLibraryPath sets the library path used by InjectJS().
+func (p *Page) SetLibraryPath(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.libraryPath = path
+	return nil
+}
+
+// NavigationLocked is not supported by this backend.
+func (p *Page) NavigationLocked() (bool, error) { return false, phantomjs.ErrNotSupported }
+
+// SetNavigationLocked is not supported by this backend.
+func (p *Page) SetNavigationLocked(value bool) error { return phantomjs.ErrNotSupported }
+
+// OfflineStoragePath is not supported by this backend.
+func (p *Page) OfflineStoragePath() (string, error) { return "", phantomjs.ErrNotSupported }
+
+// OfflineStorageQuota is not supported by this backend.
+func (p *Page) OfflineStorageQuota() (int, error) { return 0, phantomjs.ErrNotSupported }
+
+// OwnsPages is not supported by this backend.
+func (p *Page) OwnsPages() (bool, error) { return false, phantomjs.ErrNotSupported }
+
+// SetOwnsPages is not supported by this backend.
+func (p *Page) SetOwnsPages(v bool) error { return phantomjs.ErrNotSupported }
+
+// PageWindowNames is not supported by this backend.
+func (p *Page) PageWindowNames() ([]string, error) { return nil, phantomjs.ErrNotSupported }
+
+// PaperSize returns the size of the web page when rendered as a PDF.
+func (p *Page) PaperSize() (phantomjs.PaperSize, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paperSize, nil
+}
+
+// SetPaperSize sets the size of the web page when rendered as a PDF.
+func (p *Page) SetPaperSize(size phantomjs.PaperSize) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paperSize = size
+	return nil
+}
+
+// ScrollPosition returns the current scroll position of the page.
+func (p *Page) ScrollPosition() (phantomjs.Position, error) {
+	var resp struct {
+		Top  int `json:"top"`
+		Left int `json:"left"`
+	}
+	if err := p.evaluate(`({top: window.scrollY, left: window.scrollX})`, &resp); err != nil {
+		return phantomjs.Position{}, err
+	}
+	return phantomjs.Position{Top: resp.Top, Left: resp.Left}, nil
+}
+
+// SetScrollPosition sets the current scroll position of the page.
+func (p *Page) SetScrollPosition(pos phantomjs.Position) error {
+	return p.evaluate(`window.scrollTo(`+strconv.Itoa(pos.Left)+`, `+strconv.Itoa(pos.Top)+`)`, nil)
+}
+
+// Settings returns the settings used on the web page.
+func (p *Page) Settings() (phantomjs.WebPageSettings, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.settings, nil
+}
+
+// SetSettings sets various settings on the web page.
+//
+// Only JavascriptEnabled, UserAgent and LoadImages are applied by this backend.
+func (p *Page) SetSettings(settings phantomjs.WebPageSettings) error {
+	if err := p.conn.call("Emulation.setScriptExecutionDisabled", map[string]interface{}{"value": !settings.JavascriptEnabled}, nil); err != nil {
+		return err
+	}
+	if settings.UserAgent != "" {
+		if err := p.conn.call("Network.setUserAgentOverride", map[string]interface{}{"userAgent": settings.UserAgent}, nil); err != nil {
+			return err
+		}
+	}
+
+	var blocked []string
+	if !settings.LoadImages {
+		blocked = []string{"*.png", "*.jpg", "*.jpeg", "*.gif", "*.webp", "*.svg", "*.bmp", "*.ico"}
+	}
+	if err := p.conn.call("Network.setBlockedURLs", map[string]interface{}{"urls": blocked}, nil); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.settings = settings
+	return nil
+}
+
+// ViewportSize returns the size of the viewport on the browser.
+func (p *Page) ViewportSize() (width, height int, err error) {
+	p.mu.Lock()
+	width, height = p.width, p.height
+	p.mu.Unlock()
+
+	if width == 0 && height == 0 {
+		var resp struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		}
+		if err := p.evaluate(`({width: window.innerWidth, height: window.innerHeight})`, &resp); err != nil {
+			return 0, 0, err
+		}
+		return resp.Width, resp.Height, nil
+	}
+	return width, height, nil
+}
+
+// SetViewportSize sets the size of the viewport.
+func (p *Page) SetViewportSize(width, height int) error {
+	if err := p.conn.call("Emulation.setDeviceMetricsOverride", map[string]interface{}{
+		"width":             width,
+		"height":            height,
+		"deviceScaleFactor": 0,
+		"mobile":            false,
+	}, nil); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.width, p.height = width, height
+	return nil
+}
+
+// ZoomFactor returns zoom factor when rendering the page.
+func (p *Page) ZoomFactor() (float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.zoomFactor, nil
+}
+
+// SetZoomFactor sets the zoom factor when rendering the page.
+func (p *Page) SetZoomFactor(factor float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.zoomFactor = factor
+	return nil
+}
+
+// Evaluate executes a JavaScript function in the context of the web page.
+// Returns the value returned by the function.
+func (p *Page) Evaluate(script string) (v interface{}, err error) {
+	err = p.evaluate(`(`+script+`)()`, &v)
+	return v, err
+}
+
+// EvaluateAsync executes a JavaScript function after delay and returns immediately.
+func (p *Page) EvaluateAsync(script string, delay time.Duration) error {
+	return p.evaluate(`setTimeout(`+script+`, `+strconv.Itoa(int(delay/time.Millisecond))+`), undefined`, nil)
+}
+
+// EvaluateJavaScript executes a JavaScript function.
+// Returns the value returned by the function.
+func (p *Page) EvaluateJavaScript(script string) (interface{}, error) {
+	return p.Evaluate(script)
+}
+
+// IncludeJS includes an external script from url.
+// Returns after the script has been loaded.
+func (p *Page) IncludeJS(url string) error {
+	buf, err := json.Marshal(url)
+	if err != nil {
+		return err
+	}
+	return p.evaluate(`new Promise(function(resolve, reject) {
+		var el = document.createElement("script");
+		el.src = `+string(buf)+`;
+		el.onload = function() { resolve(); };
+		el.onerror = function() { reject(new Error("cannot load script")); };
+		document.head.appendChild(el);
+	})`, nil)
+}
+
+// InjectJS evaluates a script from the local filesystem.
+// Relative paths are resolved against the library path.
+func (p *Page) InjectJS(filename string) error {
+	if !filepath.IsAbs(filename) {
+		path, _ := p.LibraryPath()
+		filename = filepath.Join(path, filename)
+	}
+
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return phantomjs.ErrInjectionFailed
+	}
+	if err := p.evaluate(string(buf), nil); err != nil {
+		return phantomjs.ErrInjectionFailed
+	}
+	return nil
+}
+
+// evaluate evaluates a JavaScript expression and decodes the result into v.
+func (p *Page) evaluate(expr string, v interface{}) error {
+	var resp struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}
+	if err := p.conn.call("Runtime.evaluate", map[string]interface{}{
+		"expression":    expr,
+		"returnByValue": true,
+		"awaitPromise":  true,
+	}, &resp); err != nil {
+		return err
+	} else if resp.ExceptionDetails != nil {
+		if msg := resp.ExceptionDetails.Exception.Description; msg != "" {
+			return errors.New(msg)
+		}
+		return errors.New(resp.ExceptionDetails.Text)
+	}
+
+	if v != nil && len(resp.Result.Value) > 0 {
+		return json.Unmarshal(resp.Result.Value, v)
+	}
+	return nil
+}
+
+// RenderBase64 renders the web page to a base64 encoded string.
+// Supported formats are "PNG" and "JPEG".
+func (p *Page) RenderBase64(format string) (string, error) {
+	return p.screenshot(format, 100)
+}
+
+// Render renders the web page to a file with the given format and quality settings.
+// This supports the "PDF", "PNG" and "JPEG" formats.
+func (p *Page) Render(filename, format string, quality int) error {
+	var data string
+	var err error
+	if strings.EqualFold(format, "pdf") {
+		data, err = p.printToPDF()
+	} else {
+		data, err = p.screenshot(format, quality)
+	}
+	if err != nil {
+		return err
+	}
+
+	buf, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, buf, 0666)
+}
+
+// screenshot captures the page and returns the base64 encoded image.
+func (p *Page) screenshot(format string, quality int) (string, error) {
+	format = strings.ToLower(format)
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	if format != "png" && format != "jpeg" {
+		return "", phantomjs.ErrNotSupported
+	}
+
+	params := map[string]interface{}{"format": format}
+	if format == "jpeg" {
+		params["quality"] = quality
+	}
+
+	p.mu.Lock()
+	rect, zoom := p.clipRect, p.zoomFactor
+	p.mu.Unlock()
+	if rect != (phantomjs.Rect{}) || zoom != 1 {
+		if rect.Width == 0 || rect.Height == 0 {
+			var size struct {
+				Width  int `json:"width"`
+				Height int `json:"height"`
+			}
+			if err := p.evaluate(`({width: document.documentElement.scrollWidth, height: document.documentElement.scrollHeight})`, &size); err != nil {
+				return "", err
+			}
+			rect.Width, rect.Height = size.Width, size.Height
+		}
+		params["clip"] = map[string]interface{}{
+			"x":      rect.Left,
+			"y":      rect.Top,
+			"width":  rect.Width,
+			"height": rect.Height,
+			"scale":  zoom,
+		}
+		params["captureBeyondViewport"] = true
+	}
+
+	var resp struct {
+		Data string `json:"data"`
+	}
+	if err := p.conn.call("Page.captureScreenshot", params, &resp); err != nil {
+		return "", err
+	}
+	return resp.Data, nil
+}
+
+// printToPDF prints the page using the current paper size.
+func (p *Page) printToPDF() (string, error) {
+	p.mu.Lock()
+	size := p.paperSize
+	p.mu.Unlock()
+
+	params := map[string]interface{}{"printBackground": true}
+	if w, h, ok := paperDimensions(size); ok {
+		params["paperWidth"], params["paperHeight"] = w, h
+	}
+	if size.Orientation == "landscape" {
+		params["landscape"] = true
+	}
+	if m := size.Margin; m != nil {
+		for key, value := range map[string]string{"marginTop": m.Top, "marginBottom": m.Bottom, "marginLeft": m.Left, "marginRight": m.Right} {
+			if v, ok := parseLength(value); ok {
+				params[key] = v
+			}
+		}
+	}
+
+	var resp struct {
+		Data string `json:"data"`
+	}
+	if err := p.conn.call("Page.printToPDF", params, &resp); err != nil {
+		return "", err
+	}
+	return resp.Data, nil
+}
+
+// SendMouseEvent sends a mouse event as if it came from the user.
+func (p *Page) SendMouseEvent(eventType string, mouseX, mouseY int, button string) error {
+	if button == "" {
+		button = "left"
+	}
+	dispatch := func(typ string, clickCount int) error {
+		return p.conn.call("Input.dispatchMouseEvent", map[string]interface{}{
+			"type":       typ,
+			"x":          mouseX,
+			"y":          mouseY,
+			"button":     button,
+			"clickCount": clickCount,
+		}, nil)
+	}
+
+	switch eventType {
+	case "mousedown":
+		return dispatch("mousePressed", 1)
+	case "mouseup":
+		return dispatch("mouseReleased", 1)
+	case "mousemove":
+		return dispatch("mouseMoved", 0)
+	case "click", "doubleclick":
+		count := 1
+		if eventType == "doubleclick" {
+			count = 2
+		}
+		if err := dispatch("mousePressed", count); err != nil {
+			return err
+		}
+		return dispatch("mouseReleased", count)
+	default:
+		return phantomjs.ErrNotSupported
+	}
+}
+
+// SendKeyboardEvent sends a keyboard event as if it came from the user.
+func (p *Page) SendKeyboardEvent(eventType string, key string, modifier int) error {
+	// Translate PhantomJS modifier flags to DevTools flags.
+	var modifiers int
+	if modifier&phantomjs.AltKey != 0 {
+		modifiers |= 1
+	}
+	if modifier&phantomjs.CtrlKey != 0 {
+		modifiers |= 2
+	}
+	if modifier&phantomjs.MetaKey != 0 {
+		modifiers |= 4
+	}
+	if modifier&phantomjs.ShiftKey != 0 {
+		modifiers |= 8
+	}
+
+	dispatch := func(typ string, text string) error {
+		params := map[string]interface{}{"type": typ, "key": key, "modifiers": modifiers}
+		if text != "" {
+			params["text"] = text
+		}
+		return p.conn.call("Input.dispatchKeyEvent", params, nil)
+	}
+
+	switch eventType {
+	case "keydown":
+		return dispatch("rawKeyDown", "")
+	case "keyup":
+		return dispatch("keyUp", "")
+	case "keypress":
+		for _, ch := range key {
+			if err := dispatch("keyDown", string(ch)); err != nil {
+				return err
+			} else if err := dispatch("keyUp", ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return phantomjs.ErrNotSupported
+	}
+}
+
+// UploadFile sets the file on a file input element specified by selector.
+func (p *Page) UploadFile(selector, filename string) error {
+	var doc struct {
+		Root struct {
+			NodeID int `json:"nodeId"`
+		} `json:"root"`
+	}
+	if err := p.conn.call("DOM.getDocument", nil, &doc); err != nil {
+		return err
+	}
+
+	var node struct {
+		NodeID int `json:"nodeId"`
+	}
+	if err := p.conn.call("DOM.querySelector", map[string]interface{}{"nodeId": doc.Root.NodeID, "selector": selector}, &node); err != nil {
+		return err
+	} else if node.NodeID == 0 {
+		return errors.New("element not found: " + selector)
+	}
+
+	return p.conn.call("DOM.setFileInputFiles", map[string]interface{}{"nodeId": node.NodeID, "files": []string{filename}}, nil)
+}
+
+// cookieJSON is a struct for encoding cookies in the DevTools format.
+type cookieJSON struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain,omitempty"`
+	Path     string  `json:"path,omitempty"`
+	Expires  float64 `json:"expires,omitempty"`
+	HTTPOnly bool    `json:"httpOnly"`
+	Secure   bool    `json:"secure"`
+}
+
+func encodeCookieJSON(v *http.Cookie) cookieJSON {
+	out := cookieJSON{
+		Name:     v.Name,
+		Value:    v.Value,
+		Domain:   v.Domain,
+		Path:     v.Path,
+		HTTPOnly: v.HttpOnly,
+		Secure:   v.Secure,
+	}
+	if !v.Expires.IsZero() {
+		out.Expires = float64(v.Expires.Unix())
+	}
+	return out
+}
+
+func decodeCookieJSON(v cookieJSON) *http.Cookie {
+	out := &http.Cookie{
+		Name:     v.Name,
+		Value:    v.Value,
+		Domain:   v.Domain,
+		Path:     v.Path,
+		HttpOnly: v.HTTPOnly,
+		Secure:   v.Secure,
+	}
+	if v.Expires > 0 {
+		out.Expires = time.Unix(int64(v.Expires), 0).UTC()
+		out.RawExpires = out.Expires.Format(http.TimeFormat)
+	}
+	return out
+}
+
+// paperFormats maps PhantomJS paper formats to dimensions in inches.
+var paperFormats = map[string][2]float64{
+	"a3":      {11.69, 16.54},
+	"a4":      {8.27, 11.69},
+	"a5":      {5.83, 8.27},
+	"legal":   {8.5, 14},
+	"letter":  {8.5, 11},
+	"tabloid": {11, 17},
+}
+
+// paperDimensions returns the paper width & height in inches.
+func paperDimensions(size phantomjs.PaperSize) (w, h float64, ok bool) {
+	if dim, ok := paperFormats[strings.ToLower(size.Format)]; ok {
+		return dim[0], dim[1], true
+	}
+
+	w, wok := parseLength(size.Width)
+	h, hok := parseLength(size.Height)
+	return w, h, wok && hok
+}
+
+// parseLength converts a PhantomJS length ("10mm", "2cm", "1in", "96px") to inches.
+// Lengths without a unit are treated as pixels.
+func parseLength(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+
+	unit, scale := "px", 1.0/96
+	for u, v := range map[string]float64{"mm": 1 / 25.4, "cm": 1 / 2.54, "in": 1, "px": 1.0 / 96} {
+		if strings.HasSuffix(s, u) {
+			unit, scale = u, v
+			break
+		}
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, unit), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f * scale, true
+}
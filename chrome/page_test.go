@@ -0,0 +1,85 @@
+package chrome
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Ensure PhantomJS lengths are converted to inches.
+func TestParseLength(t *testing.T) {
+	for _, tt := range []struct {
+		s  string
+		in float64
+		ok bool
+	}{
+		{"1in", 1, true},
+		{"2.54cm", 1, true},
+		{"25.4mm", 1, true},
+		{"96px", 1, true},
+		{"192", 2, true},
+		{"", 0, false},
+		{"abc", 0, false},
+	} {
+		if v, ok := parseLength(tt.s); ok != tt.ok {
+			t.Fatalf("%q: unexpected ok: %v", tt.s, ok)
+		} else if diff := v - tt.in; diff > 0.0001 || diff < -0.0001 {
+			t.Fatalf("%q: unexpected value: %v", tt.s, v)
+		}
+	}
+}
+
+// Ensure paper formats take precedence over explicit dimensions.
+func TestPaperDimensions(t *testing.T) {
+	if w, h, ok := paperDimensions(phantomjs.PaperSize{Format: "Letter", Width: "1in", Height: "1in"}); !ok || w != 8.5 || h != 11 {
+		t.Fatalf("unexpected dimensions: %v x %v (%v)", w, h, ok)
+	}
+	if w, h, ok := paperDimensions(phantomjs.PaperSize{Width: "2in", Height: "3in"}); !ok || w != 2 || h != 3 {
+		t.Fatalf("unexpected dimensions: %v x %v (%v)", w, h, ok)
+	}
+	if _, _, ok := paperDimensions(phantomjs.PaperSize{}); ok {
+		t.Fatal("expected no dimensions")
+	}
+}
+
+// Ensure frames longer than the maximum message size are rejected before
+// their payload is allocated.
+func TestWebSocket_ReadMessage_TooLarge(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		hdr := []byte{0x81, 127, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(hdr[2:], 1<<62)
+		server.Write(hdr)
+	}()
+
+	ws := &wsConn{conn: client, r: bufio.NewReader(client)}
+	if _, err := ws.ReadMessage(); err != errMessageTooLarge {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure listeners are released when the connection fails.
+func TestConn_Done(t *testing.T) {
+	client, server := net.Pipe()
+	c := newConn(&wsConn{conn: client, r: bufio.NewReader(client)})
+	events, unlisten := c.listen("Page.loadEventFired")
+	defer unlisten()
+
+	server.Close()
+	select {
+	case <-events:
+		t.Fatal("unexpected event")
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+	if err := c.call("Page.navigate", nil, nil); err != errConnClosed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
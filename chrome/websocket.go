@@ -0,0 +1,236 @@
+package chrome
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// websocketGUID is the magic value used to compute Sec-WebSocket-Accept.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// maxMessageSize is the largest message accepted from the connection.
+// Screenshots and PDFs are returned as base64 inside a single message.
+const maxMessageSize = 256 << 20
+
+// errMessageTooLarge is returned when a frame or message exceeds maxMessageSize.
+var errMessageTooLarge = errors.New("websocket message too large")
+
+// wsConn is a minimal client-side WebSocket connection.
+// It only implements the subset of RFC 6455 needed by the DevTools protocol.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu sync.Mutex // serializes writes
+}
+
+// dialWebSocket opens a WebSocket connection to rawurl.
+func dialWebSocket(rawurl string) (*wsConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "ws" {
+		return nil, fmt.Errorf("unsupported websocket scheme: %s", u.Scheme)
+	}
+
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate handshake key.
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(buf)
+
+	// Send upgrade request.
+	req, err := http.NewRequest("GET", "http://"+u.Host+u.RequestURI(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// Verify the server accepted the upgrade.
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: status=%d", resp.StatusCode)
+	}
+	h := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(h[:]) {
+		conn.Close()
+		return nil, errors.New("websocket handshake failed: invalid accept key")
+	}
+
+	return &wsConn{conn: conn, r: r}, nil
+}
+
+// Close closes the underlying connection.
+func (c *wsConn) Close() error {
+	c.writeFrame(opClose, nil)
+	return c.conn.Close()
+}
+
+// WriteMessage writes buf as a single text frame.
+func (c *wsConn) WriteMessage(buf []byte) error {
+	return c.writeFrame(opText, buf)
+}
+
+// writeFrame writes a single, final, masked frame.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hdr := make([]byte, 2, 14)
+	hdr[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n < 126:
+		hdr[1] = 0x80 | byte(n)
+	case n <= 0xFFFF:
+		hdr[1] = 0x80 | 126
+		hdr = hdr[:4]
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr[1] = 0x80 | 127
+		hdr = hdr[:10]
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+
+	// Client frames must always be masked.
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	hdr = append(hdr, mask[:]...)
+
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+
+	if _, err := c.conn.Write(append(hdr, masked...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadMessage reads the next complete text or binary message.
+// Control frames are handled transparently. Returns io.EOF on close.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opClose:
+			return nil, io.EOF
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opText, opBinary, opContinuation:
+			if len(msg)+len(payload) > maxMessageSize {
+				return nil, errMessageTooLarge
+			}
+			msg = append(msg, payload...)
+		default:
+			return nil, fmt.Errorf("unexpected websocket opcode: %d", opcode)
+		}
+
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// readFrame reads a single frame from the connection.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = hdr[0]&0x80 != 0, hdr[0]&0x0F
+
+	// Read payload length.
+	n := uint64(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+
+	// Servers should not mask frames but handle it anyway.
+	var mask []byte
+	if hdr[1]&0x80 != 0 {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(c.r, mask); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	if n > maxMessageSize {
+		return false, 0, nil, errMessageTooLarge
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if mask != nil {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
@@ -0,0 +1,548 @@
+// Package cluster distributes render jobs across remote PhantomJS workers.
+//
+// A worker is any machine running an open phantomjs.Process whose shim port
+// is reachable by the coordinator, such as one started with "phantomgo
+// worker". The coordinator discovers workers, checks their health, moves
+// queued work away from failed workers and lets idle workers steal queued
+// jobs from busy ones.
+//
+//	c := cluster.NewCoordinator(cluster.StaticDiscovery{"10.0.0.1:20202", "10.0.0.2:20202"})
+//	if err := c.Open(); err != nil {
+//		log.Fatal(err)
+//	}
+//	defer c.Close()
+//
+//	c.Submit(&jobs.Job{URL: "https://example.com"})
+//	result := <-c.Results()
+package cluster
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/jobs"
+)
+
+// Default settings.
+const (
+	DefaultPagesPerWorker = 4
+	DefaultHealthInterval = 10 * time.Second
+	DefaultPingTimeout    = 5 * time.Second
+	DefaultMaxFailovers   = 2
+)
+
+// WorkerStatus describes a worker at a point in time.
+type WorkerStatus struct {
+	Addr    string
+	Healthy bool
+	Queued  int
+	Running int
+}
+
+// Coordinator dispatches jobs to workers.
+type Coordinator struct {
+	discovery Discovery
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	workers map[string]*worker
+	backlog taskQueue // jobs waiting for a healthy worker
+	seq     int
+	opened  bool
+	closed  bool
+	wg      sync.WaitGroup
+	results chan *jobs.Result
+
+	ctx    context.Context
+	cancel func()
+
+	// Number of jobs each worker renders at the same time.
+	PagesPerWorker int
+
+	// How often workers are rediscovered and health checked.
+	HealthInterval time.Duration
+
+	// Default per-attempt timeout for jobs that do not set one.
+	Timeout time.Duration
+
+	// Number of times a job is moved to another worker after its worker
+	// fails. Failovers do not count against the job's retries.
+	MaxFailovers int
+
+	// Buffer size of the results channel.
+	ResultsSize int
+
+	// If set, rendered output is written to the sink instead of being
+	// returned in the result's body.
+	Sink jobs.Sink
+
+	// Transport used for RPC calls and health checks.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Shared secret required by the workers' shims, as set with the
+	// worker's -token flag.
+	Token string
+}
+
+// NewCoordinator returns a new coordinator that finds workers with d.
+func NewCoordinator(d Discovery) *Coordinator {
+	c := &Coordinator{
+		discovery:      d,
+		workers:        make(map[string]*worker),
+		PagesPerWorker: DefaultPagesPerWorker,
+		HealthInterval: DefaultHealthInterval,
+		Timeout:        jobs.DefaultTimeout,
+		MaxFailovers:   DefaultMaxFailovers,
+		ResultsSize:    jobs.DefaultResultsSize,
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Open discovers and checks the initial set of workers and starts monitoring them.
+func (c *Coordinator) Open() error {
+	c.mu.Lock()
+	if c.opened {
+		c.mu.Unlock()
+		return errors.New("coordinator already open")
+	}
+	c.opened = true
+	c.results = make(chan *jobs.Result, c.ResultsSize)
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.mu.Unlock()
+
+	if err := c.refresh(c.ctx); err != nil {
+		c.Close()
+		return err
+	}
+
+	c.wg.Add(1)
+	go func() { defer c.wg.Done(); c.monitor() }()
+	return nil
+}
+
+// Close stops dispatching, cancels running jobs and waits for them to exit.
+// Jobs that have not started are reported with jobs.ErrQueueClosed and the
+// results channel is then closed.
+func (c *Coordinator) Close() error {
+	c.mu.Lock()
+	if c.closed || !c.opened {
+		c.closed = true
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.cancel()
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	c.wg.Wait()
+
+	// Report jobs that never ran.
+	c.mu.Lock()
+	pending := c.backlog
+	c.backlog = nil
+	for _, w := range c.workers {
+		pending = append(pending, w.queue...)
+		w.queue = nil
+	}
+	c.mu.Unlock()
+	for _, t := range pending {
+		t.result.Err = jobs.ErrQueueClosed
+		c.results <- t.result
+	}
+	close(c.results)
+	return nil
+}
+
+// Results returns the channel that results are delivered on. It must be
+// drained by the caller.
+func (c *Coordinator) Results() <-chan *jobs.Result {
+	return c.results
+}
+
+// Workers returns the status of each known worker, sorted by address.
+func (c *Coordinator) Workers() []WorkerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a := make([]WorkerStatus, 0, len(c.workers))
+	for _, w := range c.workers {
+		a = append(a, WorkerStatus{Addr: w.addr, Healthy: w.healthy, Queued: len(w.queue), Running: w.running})
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i].Addr < a[j].Addr })
+	return a
+}
+
+// Submit queues a job on the least loaded healthy worker. If no worker is
+// healthy, the job waits until one becomes available.
+func (c *Coordinator) Submit(job *jobs.Job) error {
+	if job.URL == "" {
+		return jobs.ErrInvalidJob
+	}
+	switch job.Output.Format {
+	case "":
+		job.Output.Format = jobs.FormatHTML
+	case jobs.FormatHTML, jobs.FormatPNG, jobs.FormatJPEG, jobs.FormatPDF:
+	default:
+		return jobs.ErrInvalidJob
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || !c.opened {
+		return jobs.ErrQueueClosed
+	}
+
+	c.seq++
+	if job.ID == "" {
+		job.ID = strconv.Itoa(c.seq)
+	}
+	c.enqueue(&task{job: job, seq: c.seq, result: &jobs.Result{Job: job}})
+	return nil
+}
+
+// enqueue adds t to the least loaded healthy worker or to the backlog.
+// Must be called with the lock held.
+func (c *Coordinator) enqueue(t *task) {
+	var target *worker
+	for _, w := range c.workers {
+		if !w.healthy || w == t.exclude {
+			continue
+		} else if target == nil || w.load() < target.load() {
+			target = w
+		}
+	}
+
+	if target != nil {
+		target.queue.push(t)
+	} else {
+		c.backlog.push(t)
+	}
+	c.cond.Broadcast()
+}
+
+// next returns the next task for w: from its own queue, then the backlog,
+// then stolen from the back of the longest queue of another worker.
+// Must be called with the lock held.
+func (c *Coordinator) next(w *worker) *task {
+	if len(w.queue) > 0 {
+		return w.queue.popFront()
+	} else if len(c.backlog) > 0 {
+		return c.backlog.popFront()
+	}
+
+	var victim *worker
+	for _, other := range c.workers {
+		if other != w && len(other.queue) > 0 && (victim == nil || len(other.queue) > len(victim.queue)) {
+			victim = other
+		}
+	}
+	if victim == nil {
+		return nil
+	}
+	return victim.queue.popBack()
+}
+
+// run executes tasks for w on a single slot until w is removed or the
+// coordinator closes.
+func (c *Coordinator) run(w *worker) {
+	for {
+		c.mu.Lock()
+		var t *task
+		for t == nil {
+			if c.closed || w.removed {
+				c.mu.Unlock()
+				return
+			} else if w.healthy {
+				t = c.next(w)
+			}
+			if t == nil {
+				c.cond.Wait()
+			}
+		}
+		w.running++
+		c.mu.Unlock()
+
+		err := c.execute(w, t)
+
+		c.mu.Lock()
+		w.running--
+		result := c.handle(w, t, err)
+		c.mu.Unlock()
+
+		if result != nil {
+			c.results <- result
+		}
+	}
+}
+
+// execute renders t's job on w once.
+func (c *Coordinator) execute(w *worker, t *task) error {
+	if t.result.Started.IsZero() {
+		t.result.Started = time.Now()
+	}
+	t.result.Attempts++
+
+	timeout := t.job.Timeout
+	if timeout <= 0 {
+		timeout = c.Timeout
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, timeout)
+	defer cancel()
+
+	page, err := w.pool.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer w.pool.Put(page)
+
+	body, err := jobs.Render(ctx, page, t.job)
+	if err != nil {
+		return err
+	}
+	t.result.ContentType, t.result.Size, t.result.Err = t.job.Output.ContentType(), len(body), nil
+	if c.Sink != nil {
+		t.result.Location, err = jobs.Store(ctx, c.Sink, t.job, body)
+		return err
+	}
+	t.result.Body = body
+	return nil
+}
+
+// handle requeues a failed task or returns its final result.
+// Must be called with the lock held.
+func (c *Coordinator) handle(w *worker, t *task, err error) *jobs.Result {
+	if err == nil || c.ctx.Err() != nil {
+		return t.finish(err)
+	}
+
+	// Fail over to another worker if this worker is down. The attempt
+	// does not count against the job's retries.
+	if c.unreachable(w) {
+		c.setHealthy(w, false)
+		if t.failovers < c.MaxFailovers {
+			t.failovers++
+			t.result.Attempts--
+			t.exclude = w
+			c.enqueue(t)
+			return nil
+		}
+		return t.finish(err)
+	}
+
+	if t.result.Attempts <= t.job.MaxRetries {
+		c.enqueue(t)
+		return nil
+	}
+	return t.finish(err)
+}
+
+// unreachable pings w without holding the lock and returns true if it fails.
+// Must be called with the lock held.
+func (c *Coordinator) unreachable(w *worker) bool {
+	c.mu.Unlock()
+	defer c.mu.Lock()
+	return c.ping(c.ctx, w.addr) != nil
+}
+
+// setHealthy updates w's health. Queued tasks on an unhealthy worker are
+// moved to other workers. Must be called with the lock held.
+func (c *Coordinator) setHealthy(w *worker, healthy bool) {
+	if w.healthy == healthy {
+		return
+	}
+	w.healthy = healthy
+	if !healthy {
+		queue := w.queue
+		w.queue = nil
+		for _, t := range queue {
+			c.enqueue(t)
+		}
+	}
+	c.cond.Broadcast()
+}
+
+// monitor refreshes workers until the coordinator is closed.
+func (c *Coordinator) monitor() {
+	ticker := time.NewTicker(c.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.refresh(c.ctx)
+		}
+	}
+}
+
+// refresh syncs the worker set with discovery and health checks each worker.
+// If discovery fails, the current workers are kept.
+func (c *Coordinator) refresh(ctx context.Context) error {
+	addrs, err := c.discovery.Discover(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	current := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		current[addr] = struct{}{}
+		if c.workers[addr] == nil {
+			w, err := c.newWorker(addr)
+			if err != nil {
+				c.mu.Unlock()
+				return err
+			}
+			c.workers[addr] = w
+		}
+	}
+	for addr, w := range c.workers {
+		if _, ok := current[addr]; !ok {
+			c.setHealthy(w, false)
+			w.removed = true
+			delete(c.workers, addr)
+		}
+	}
+	workers := make([]*worker, 0, len(c.workers))
+	for _, w := range c.workers {
+		workers = append(workers, w)
+	}
+	c.mu.Unlock()
+
+	// Check workers concurrently so slow hosts don't delay the others.
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			err := c.ping(ctx, w.addr)
+			c.mu.Lock()
+			c.setHealthy(w, err == nil)
+			c.mu.Unlock()
+		}(w)
+	}
+	wg.Wait()
+	return nil
+}
+
+// newWorker returns a worker for addr and starts its slots.
+// Must be called with the lock held.
+func (c *Coordinator) newWorker(addr string) (*worker, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	portN, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+
+	process := phantomjs.NewProcess(phantomjs.WithPort(portN))
+	process.Host = host
+	process.Transport = c.Transport
+	process.Token = c.Token
+
+	n := c.PagesPerWorker
+	if n <= 0 {
+		n = DefaultPagesPerWorker
+	}
+	w := &worker{addr: addr, pool: phantomjs.NewPool(n, process)}
+	for i := 0; i < n; i++ {
+		c.wg.Add(1)
+		go func() { defer c.wg.Done(); c.run(w) }()
+	}
+	return w, nil
+}
+
+// ping checks that the shim at addr responds.
+func (c *Coordinator) ping(ctx context.Context, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultPingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+addr+"/ping", nil)
+	if err != nil {
+		return err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := (&http.Client{Transport: c.Transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected status: " + resp.Status)
+	}
+	return nil
+}
+
+// worker represents a remote shim.
+type worker struct {
+	addr    string
+	pool    *phantomjs.Pool
+	queue   taskQueue
+	healthy bool
+	removed bool
+	running int
+}
+
+// load returns the number of queued and running tasks.
+func (w *worker) load() int {
+	return len(w.queue) + w.running
+}
+
+// task tracks a job through attempts and failovers.
+type task struct {
+	job       *jobs.Job
+	seq       int
+	result    *jobs.Result
+	failovers int
+	exclude   *worker // worker that last failed the task
+}
+
+// finish completes the task's result with err.
+func (t *task) finish(err error) *jobs.Result {
+	t.result.Err = err
+	if err != nil {
+		t.result.ContentType, t.result.Body, t.result.Size, t.result.Location = "", nil, 0, ""
+	}
+	t.result.Finished = time.Now()
+	return t.result
+}
+
+// taskQueue is a list of tasks ordered by priority and then submission.
+type taskQueue []*task
+
+// push inserts t after all tasks of equal or higher priority.
+func (q *taskQueue) push(t *task) {
+	i := sort.Search(len(*q), func(i int) bool {
+		other := (*q)[i]
+		return other.job.Priority < t.job.Priority || (other.job.Priority == t.job.Priority && other.seq > t.seq)
+	})
+	*q = append(*q, nil)
+	copy((*q)[i+1:], (*q)[i:])
+	(*q)[i] = t
+}
+
+func (q *taskQueue) popFront() *task {
+	t := (*q)[0]
+	*q = (*q)[1:]
+	return t
+}
+
+func (q *taskQueue) popBack() *task {
+	t := (*q)[len(*q)-1]
+	*q = (*q)[:len(*q)-1]
+	return t
+}
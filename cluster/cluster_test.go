@@ -0,0 +1,161 @@
+package cluster_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs/cluster"
+	"github.com/benbjohnson/phantomjs/jobs"
+)
+
+// Ensure jobs are spread across healthy workers.
+func TestCoordinator_Submit(t *testing.T) {
+	a, b := NewStubWorker(t), NewStubWorker(t)
+	c := cluster.NewCoordinator(cluster.StaticDiscovery{a.Addr(), b.Addr()})
+	c.PagesPerWorker = 1
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		MustSubmit(t, c, &jobs.Job{URL: "http://example.com/" + strconv.Itoa(i)})
+	}
+	for i := 0; i < 10; i++ {
+		if result := <-c.Results(); result.Err != nil {
+			t.Fatal(result.Err)
+		} else if string(result.Body) != "CONTENT" {
+			t.Fatalf("unexpected body: %q", result.Body)
+		}
+	}
+
+	if a.Opens() == 0 || b.Opens() == 0 {
+		t.Fatalf("expected both workers to render: a=%d b=%d", a.Opens(), b.Opens())
+	} else if a.Opens()+b.Opens() != 10 {
+		t.Fatalf("unexpected total renders: a=%d b=%d", a.Opens(), b.Opens())
+	}
+}
+
+// Ensure jobs move to another worker when their worker goes down.
+func TestCoordinator_Failover(t *testing.T) {
+	a, b := NewStubWorker(t), NewStubWorker(t)
+	c := cluster.NewCoordinator(cluster.StaticDiscovery{a.Addr(), b.Addr()})
+	c.PagesPerWorker = 1
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	a.SetDown(true)
+	for i := 0; i < 4; i++ {
+		MustSubmit(t, c, &jobs.Job{URL: "http://example.com/" + strconv.Itoa(i)})
+	}
+	for i := 0; i < 4; i++ {
+		if result := <-c.Results(); result.Err != nil {
+			t.Fatal(result.Err)
+		} else if result.Attempts != 1 {
+			t.Fatalf("unexpected attempts: %d", result.Attempts)
+		}
+	}
+
+	if b.Opens() != 4 {
+		t.Fatalf("unexpected renders on healthy worker: %d", b.Opens())
+	}
+	for _, status := range c.Workers() {
+		if status.Addr == a.Addr() && status.Healthy {
+			t.Fatal("expected failed worker to be unhealthy")
+		}
+	}
+}
+
+// Ensure jobs are rejected before open and after close.
+func TestCoordinator_Submit_Closed(t *testing.T) {
+	c := cluster.NewCoordinator(cluster.StaticDiscovery{})
+	if err := c.Submit(&jobs.Job{URL: "http://example.com"}); err != jobs.ErrQueueClosed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	} else if err := c.Submit(&jobs.Job{}); err != jobs.ErrInvalidJob {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c.Close()
+	if err := c.Submit(&jobs.Job{URL: "http://example.com"}); err != jobs.ErrQueueClosed {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, ok := <-c.Results(); ok {
+		t.Fatal("expected results to be closed")
+	}
+}
+
+// MustSubmit submits job to c and fails the test on error.
+func MustSubmit(tb testing.TB, c *cluster.Coordinator, job *jobs.Job) {
+	tb.Helper()
+	if err := c.Submit(job); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// StubWorker is a stub shim server that renders every page as "CONTENT".
+type StubWorker struct {
+	*httptest.Server
+	opens int32
+	down  int32
+}
+
+// NewStubWorker returns a running stub worker.
+func NewStubWorker(tb testing.TB) *StubWorker {
+	w := &StubWorker{}
+	var mu sync.Mutex
+	var nextID int
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&w.down) != 0 {
+			http.Error(rw, "down", http.StatusServiceUnavailable)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/webpage/Create":
+			mu.Lock()
+			nextID++
+			id := nextID
+			mu.Unlock()
+			json.NewEncoder(rw).Encode(map[string]interface{}{"ref": map[string]string{"id": strconv.Itoa(id)}})
+		case "/webpage/Open":
+			atomic.AddInt32(&w.opens, 1)
+			rw.Write([]byte(`{"status":"success"}`))
+		case "/webpage/Content":
+			rw.Write([]byte(`{"value":"CONTENT"}`))
+		default:
+			rw.Write([]byte(`{}`))
+		}
+	}))
+	tb.Cleanup(w.Close)
+	return w
+}
+
+// Addr returns the worker's "host:port" address.
+func (w *StubWorker) Addr() string {
+	return strings.TrimPrefix(w.URL, "http://")
+}
+
+// Opens returns the number of pages opened on the worker.
+func (w *StubWorker) Opens() int {
+	return int(atomic.LoadInt32(&w.opens))
+}
+
+// SetDown sets whether the worker fails all requests.
+func (w *StubWorker) SetDown(v bool) {
+	var i int32
+	if v {
+		i = 1
+	}
+	atomic.StoreInt32(&w.down, i)
+}
@@ -0,0 +1,73 @@
+package cluster
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Discovery returns the addresses ("host:port") of the available workers.
+type Discovery interface {
+	Discover(ctx context.Context) ([]string, error)
+}
+
+// StaticDiscovery is a fixed list of worker addresses.
+type StaticDiscovery []string
+
+// Discover returns the list of addresses.
+func (d StaticDiscovery) Discover(ctx context.Context) ([]string, error) {
+	return []string(d), nil
+}
+
+// DNSDiscovery finds workers by resolving a DNS name.
+//
+// If Service is set then SRV records for _service._proto.name are used and
+// each target's port is taken from its record. Otherwise the name's A and
+// AAAA records are used with Port.
+type DNSDiscovery struct {
+	Name string
+	Port int
+
+	// SRV service and protocol, such as "phantomjs" and "tcp".
+	Service string
+	Proto   string
+
+	// Resolver used for lookups. Defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// Discover resolves the worker addresses.
+func (d *DNSDiscovery) Discover(ctx context.Context) ([]string, error) {
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	if d.Service != "" {
+		proto := d.Proto
+		if proto == "" {
+			proto = "tcp"
+		}
+		_, records, err := resolver.LookupSRV(ctx, d.Service, proto, d.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs := make([]string, len(records))
+		for i, r := range records {
+			addrs[i] = net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port)))
+		}
+		return addrs, nil
+	}
+
+	hosts, err := resolver.LookupHost(ctx, d.Name)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(hosts))
+	for i, host := range hosts {
+		addrs[i] = net.JoinHostPort(host, strconv.Itoa(d.Port))
+	}
+	return addrs, nil
+}
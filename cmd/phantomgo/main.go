@@ -0,0 +1,286 @@
+// Command phantomgo renders web pages to screenshots, PDFs or HTML using
+// PhantomJS.
+//
+// Usage:
+//
+//	phantomgo screenshot [flags] URL
+//	phantomgo pdf [flags] URL
+//	phantomgo html [flags] URL
+//	phantomgo worker [flags]
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// ErrUsage is returned when the command is invoked with invalid arguments.
+var ErrUsage = errors.New("usage")
+
+func main() {
+	m := NewMain()
+	if err := m.Run(os.Args[1:]...); err == ErrUsage {
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(m.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Main represents the program execution.
+type Main struct {
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewMain returns a new instance of Main connected to the standard streams.
+func NewMain() *Main {
+	return &Main{Stdout: os.Stdout, Stderr: os.Stderr}
+}
+
+// Run executes the subcommand named by the first argument.
+func (m *Main) Run(args ...string) error {
+	var cmd string
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "screenshot", "pdf", "html":
+		return m.render(cmd, args)
+	case "worker":
+		return m.worker(args)
+	case "", "help", "-h", "--help":
+		fmt.Fprint(m.Stderr, usage)
+		return ErrUsage
+	default:
+		fmt.Fprintf(m.Stderr, "phantomgo: unknown command %q\n\n%s", cmd, usage)
+		return ErrUsage
+	}
+}
+
+// options represents the flags shared by all subcommands.
+type options struct {
+	output   string
+	width    int
+	height   int
+	wait     time.Duration
+	selector string
+	format   string
+	quality  int
+	paper    string
+	binPath  string
+	port     int
+}
+
+// render parses flags for cmd and renders the URL.
+func (m *Main) render(cmd string, args []string) error {
+	var opt options
+	fs := flag.NewFlagSet("phantomgo "+cmd, flag.ContinueOnError)
+	fs.SetOutput(m.Stderr)
+	fs.StringVar(&opt.output, "o", "", "output file (default stdout)")
+	fs.IntVar(&opt.width, "width", 1280, "viewport width")
+	fs.IntVar(&opt.height, "height", 800, "viewport height")
+	fs.DurationVar(&opt.wait, "wait", 0, "time to wait after the page loads")
+	fs.StringVar(&opt.selector, "selector", "", "CSS selector of the element to capture")
+	fs.StringVar(&opt.binPath, "bin", phantomjs.DefaultBinPath, "path to the phantomjs binary")
+	fs.IntVar(&opt.port, "port", phantomjs.DefaultPort, "port used to communicate with phantomjs")
+	if cmd == "screenshot" {
+		fs.StringVar(&opt.format, "format", "png", "image format: png, jpeg or gif")
+		fs.IntVar(&opt.quality, "quality", 100, "image quality (0-100)")
+	}
+	if cmd == "pdf" {
+		fs.StringVar(&opt.paper, "paper", "A4", "paper format: A3, A4, A5, Legal, Letter or Tabloid")
+	}
+	if err := fs.Parse(args); err != nil {
+		return ErrUsage
+	} else if fs.NArg() != 1 {
+		fmt.Fprintf(m.Stderr, "phantomgo %s: exactly one URL required\n", cmd)
+		return ErrUsage
+	}
+	url := fs.Arg(0)
+
+	// Start process.
+	p := phantomjs.NewProcess(phantomjs.WithPort(opt.port))
+	p.BinPath = opt.binPath
+	p.Stdout, p.Stderr = ioutil.Discard, m.Stderr
+	if err := p.Open(); err != nil {
+		return err
+	}
+	defer p.Close()
+
+	page, err := p.CreateWebPage()
+	if err != nil {
+		return err
+	}
+	defer page.Close()
+
+	// Load page.
+	if err := page.SetViewportSize(opt.width, opt.height); err != nil {
+		return err
+	} else if err := page.Open(url); err != nil {
+		return fmt.Errorf("open %s: %s", url, err)
+	}
+	time.Sleep(opt.wait)
+
+	// Restrict output to the selected element.
+	if opt.selector != "" && cmd != "html" {
+		rect, err := elementRect(page, opt.selector)
+		if err != nil {
+			return err
+		} else if err := page.SetClipRect(rect); err != nil {
+			return err
+		}
+	}
+
+	var buf []byte
+	switch cmd {
+	case "html":
+		if buf, err = renderHTML(page, opt.selector); err != nil {
+			return err
+		}
+	case "screenshot":
+		if buf, err = renderFile(page, strings.ToUpper(opt.format), opt.quality); err != nil {
+			return err
+		}
+	case "pdf":
+		if err := page.SetPaperSize(phantomjs.PaperSize{Format: opt.paper}); err != nil {
+			return err
+		} else if buf, err = renderFile(page, "PDF", 100); err != nil {
+			return err
+		}
+	}
+
+	if opt.output == "" {
+		_, err := m.Stdout.Write(buf)
+		return err
+	}
+	return ioutil.WriteFile(opt.output, buf, 0666)
+}
+
+// worker runs a process whose shim serves remote cluster coordinators until
+// the command is interrupted.
+func (m *Main) worker(args []string) error {
+	fs := flag.NewFlagSet("phantomgo worker", flag.ContinueOnError)
+	fs.SetOutput(m.Stderr)
+	binPath := fs.String("bin", phantomjs.DefaultBinPath, "path to the phantomjs binary")
+	port := fs.Int("port", phantomjs.DefaultPort, "port the shim listens on")
+	token := fs.String("token", "", "shared secret required from coordinators (default random)")
+	if err := fs.Parse(args); err != nil {
+		return ErrUsage
+	} else if fs.NArg() != 0 {
+		fmt.Fprintln(m.Stderr, "phantomgo worker: no arguments expected")
+		return ErrUsage
+	}
+
+	p := phantomjs.NewProcess(phantomjs.WithPort(*port), phantomjs.WithToken(*token))
+	p.BinPath = *binPath
+	p.Stdout, p.Stderr = ioutil.Discard, m.Stderr
+	if err := p.Open(); err != nil {
+		return err
+	}
+	defer p.Close()
+	fmt.Fprintf(m.Stderr, "worker listening on port %d\n", *port)
+	if *token == "" {
+		fmt.Fprintf(m.Stderr, "worker token: %s\n", p.Token)
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	<-c
+	return nil
+}
+
+// elementRect returns the bounding rectangle of the first element matching selector.
+func elementRect(page *phantomjs.WebPage, selector string) (phantomjs.Rect, error) {
+	v, err := page.Evaluate(fmt.Sprintf(`function() {
+		var el = document.querySelector(%q);
+		if (!el) return null;
+		var r = el.getBoundingClientRect();
+		return {top: r.top + window.scrollY, left: r.left + window.scrollX, width: r.width, height: r.height};
+	}`, selector))
+	if err != nil {
+		return phantomjs.Rect{}, err
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return phantomjs.Rect{}, fmt.Errorf("element not found: %s", selector)
+	}
+	num := func(key string) int { f, _ := m[key].(float64); return int(f) }
+	return phantomjs.Rect{Top: num("top"), Left: num("left"), Width: num("width"), Height: num("height")}, nil
+}
+
+// renderHTML returns the page content or the outer HTML of the selected element.
+func renderHTML(page *phantomjs.WebPage, selector string) ([]byte, error) {
+	if selector == "" {
+		content, err := page.Content()
+		return []byte(content), err
+	}
+
+	v, err := page.Evaluate(fmt.Sprintf(`function() {
+		var el = document.querySelector(%q);
+		return el ? el.outerHTML : null;
+	}`, selector))
+	if err != nil {
+		return nil, err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("element not found: %s", selector)
+	}
+	return []byte(s), nil
+}
+
+// renderFile renders the page in the given format and returns the file contents.
+func renderFile(page *phantomjs.WebPage, format string, quality int) ([]byte, error) {
+	// Image formats can be returned directly.
+	if format == "PNG" || format == "JPEG" || format == "GIF" {
+		if quality == 100 {
+			data, err := page.RenderBase64(format)
+			if err != nil {
+				return nil, err
+			}
+			return base64.StdEncoding.DecodeString(data)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "phantomgo-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "output."+strings.ToLower(format))
+	if err := page.Render(path, format, quality); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
+const usage = `phantomgo renders web pages using PhantomJS.
+
+Usage:
+
+	phantomgo <command> [flags] [URL]
+
+The commands are:
+
+	screenshot  render the page to an image
+	pdf         render the page to a PDF
+	html        print the page's HTML after JavaScript has run
+	worker      serve render requests from a cluster coordinator
+
+Use "phantomgo <command> -h" for more information about a command.
+`
@@ -0,0 +1,70 @@
+package phantomjs
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ImportFromJar adds the cookies that jar holds for u to the page so that a
+// session established by an http.Client can be reused by the page. If u is
+// nil then the page's current URL is used.
+//
+// Cookie jars only expose names and values, so imported cookies are scoped to
+// the host of u with a path of "/".
+func (p *WebPage) ImportFromJar(jar http.CookieJar, u *url.URL) error {
+	u, err := p.cookieURL(u)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range jar.Cookies(u) {
+		cookie := &http.Cookie{
+			Name:   c.Name,
+			Value:  c.Value,
+			Domain: u.Hostname(),
+			Path:   "/",
+			Secure: u.Scheme == "https",
+		}
+		if ok, err := p.AddCookie(cookie); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("cookie rejected: %s", c.Name)
+		}
+	}
+	return nil
+}
+
+// ExportToJar stores the cookies visible to the page's current URL in jar
+// under u so they can be used by an http.Client. If u is nil then the page's
+// current URL is used.
+func (p *WebPage) ExportToJar(jar http.CookieJar, u *url.URL) error {
+	u, err := p.cookieURL(u)
+	if err != nil {
+		return err
+	}
+
+	cookies, err := p.Cookies()
+	if err != nil {
+		return err
+	}
+	jar.SetCookies(u, cookies)
+	return nil
+}
+
+// cookieURL returns u or, if u is nil, the page's current URL.
+func (p *WebPage) cookieURL(u *url.URL) (*url.URL, error) {
+	if u != nil {
+		return u, nil
+	}
+
+	s, err := p.URL()
+	if err != nil {
+		return nil, err
+	} else if u, err = url.Parse(s); err != nil {
+		return nil, err
+	} else if !u.IsAbs() {
+		return nil, fmt.Errorf("page url is not absolute: %q", s)
+	}
+	return u, nil
+}
@@ -0,0 +1,75 @@
+package phantomjs_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+)
+
+// Ensure cookies from a jar are added to the page for the jar URL's host.
+func TestWebPage_ImportFromJar(t *testing.T) {
+	var added []map[string]interface{}
+	p, srv := NewStubProcess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/AddCookie":
+			var req struct {
+				Cookie map[string]interface{} `json:"cookie"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			added = append(added, req.Cookie)
+			w.Write([]byte(`{"returnValue":true}`))
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse("https://example.com/login")
+	jar, _ := cookiejar.New(nil)
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+
+	page, err := p.CreateWebPage()
+	if err != nil {
+		t.Fatal(err)
+	} else if err := page.ImportFromJar(jar, u); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(added) != 1 {
+		t.Fatalf("unexpected cookie count: %d", len(added))
+	} else if c := added[0]; c["name"] != "session" || c["value"] != "abc" || c["domain"] != "example.com" || c["path"] != "/" || c["secure"] != true {
+		t.Fatalf("unexpected cookie: %#v", c)
+	}
+}
+
+// Ensure page cookies are stored in a jar under the page's URL.
+func TestWebPage_ExportToJar(t *testing.T) {
+	p, srv := NewStubProcess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/URL":
+			w.Write([]byte(`{"value":"http://example.com/account"}`))
+		case "/webpage/Cookies":
+			w.Write([]byte(`{"value":[{"name":"session","value":"xyz","domain":".example.com","path":"/"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	page, err := p.CreateWebPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jar, _ := cookiejar.New(nil)
+	if err := page.ExportToJar(jar, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("http://www.example.com/")
+	if cookies := jar.Cookies(u); len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "xyz" {
+		t.Fatalf("unexpected cookies: %v", cookies)
+	}
+}
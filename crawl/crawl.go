@@ -0,0 +1,365 @@
+// Package crawl implements a crawler that renders pages with PhantomJS and
+// follows the links found in the rendered DOM.
+//
+//	c := crawl.NewCrawler(pool)
+//	c.SameDomain = true
+//	c.MaxDepth = 2
+//	c.HandlePage = func(ctx context.Context, page *crawl.Page) error {
+//		title, err := page.WebPage.Title()
+//		fmt.Println(page.URL, title)
+//		return err
+//	}
+//	if err := c.Run(ctx, "https://example.com/"); err != nil {
+//		log.Fatal(err)
+//	}
+package crawl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"golang.org/x/time/rate"
+)
+
+// ErrSkipLinks can be returned from HandlePage to prevent the links on the
+// current page from being followed. It does not stop the crawl.
+var ErrSkipLinks = errors.New("skip links")
+
+// Page represents a page visited during a crawl.
+type Page struct {
+	// URL that was opened.
+	URL string
+
+	// Number of links followed from a seed URL to reach the page.
+	Depth int
+
+	// URL of the page that linked to this page. Empty for seed URLs.
+	Referrer string
+
+	// The rendered page. It is only valid until the handler returns.
+	WebPage *phantomjs.WebPage
+}
+
+// Crawler renders pages from a pool and follows their links.
+type Crawler struct {
+	pool *phantomjs.Pool
+
+	// Maximum link depth from a seed URL. Seeds have a depth of zero.
+	// If zero, depth is not limited.
+	MaxDepth int
+
+	// If true, only URLs on the same hosts as the seed URLs are visited.
+	SameDomain bool
+
+	// If set, URLs must match at least one pattern to be visited.
+	Allow []*regexp.Regexp
+
+	// URLs matching any of these patterns are not visited.
+	Deny []*regexp.Regexp
+
+	// Maximum number of pages rendered at the same time.
+	// Defaults to the pool's maximum page count.
+	Concurrency int
+
+	// Time to wait after a page loads before it is handled.
+	Wait time.Duration
+
+	// If set, URLs disallowed by robots.txt are skipped and crawl delays are
+	// applied to Limiter.
+	Robots *Robots
+
+	// If set, limits the rate of page loads for each host.
+	Limiter *HostLimiter
+
+	// Called for each page after it has been rendered. Returning ErrSkipLinks
+	// skips the page's links. Any other error stops the crawl.
+	HandlePage func(ctx context.Context, page *Page) error
+
+	// Called when a page fails to load or is disallowed by robots.txt.
+	// Returning an error stops the crawl. If nil, failed pages are skipped.
+	HandleError func(ctx context.Context, page *Page, err error) error
+}
+
+// NewCrawler returns a new crawler that renders pages from pool.
+func NewCrawler(pool *phantomjs.Pool) *Crawler {
+	return &Crawler{pool: pool}
+}
+
+// Run crawls from the seed URLs until no URLs remain, ctx is done, or a
+// handler returns an error. Each URL is visited at most once.
+func (c *Crawler) Run(ctx context.Context, seeds ...string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r := &run{
+		crawler: c,
+		seen:    make(map[string]struct{}),
+		hosts:   make(map[string]struct{}),
+	}
+	r.cond = sync.NewCond(&r.mu)
+
+	// Seed the frontier.
+	for _, seed := range seeds {
+		u, err := url.Parse(seed)
+		if err != nil {
+			return err
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.New("crawl: seed url must be http or https: " + seed)
+		}
+		r.hosts[strings.ToLower(u.Host)] = struct{}{}
+		r.enqueue(&Page{URL: seed})
+	}
+
+	// Wake workers when the context is done.
+	go func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		r.cond.Broadcast()
+		r.mu.Unlock()
+	}()
+
+	n := c.Concurrency
+	if n <= 0 {
+		n = c.pool.MaxPages()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx, cancel)
+		}()
+	}
+	wg.Wait()
+
+	if r.err != nil {
+		return r.err
+	}
+	return ctx.Err()
+}
+
+// run holds the state of a single crawl.
+type run struct {
+	crawler *Crawler
+	hosts   map[string]struct{}
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	seen    map[string]struct{}
+	queue   []*Page
+	pending int // queued plus in-progress pages
+	err     error
+}
+
+// enqueue adds page to the frontier if its URL has not been seen.
+// Must be called with the lock held or before workers start.
+func (r *run) enqueue(page *Page) {
+	key := normalize(page.URL)
+	if _, ok := r.seen[key]; ok {
+		return
+	}
+	r.seen[key] = struct{}{}
+	r.queue = append(r.queue, page)
+	r.pending++
+	r.cond.Signal()
+}
+
+// work processes pages from the frontier until it is exhausted or the crawl stops.
+func (r *run) work(ctx context.Context, cancel func()) {
+	for {
+		r.mu.Lock()
+		for len(r.queue) == 0 && r.pending > 0 && ctx.Err() == nil {
+			r.cond.Wait()
+		}
+		if len(r.queue) == 0 || ctx.Err() != nil {
+			r.mu.Unlock()
+			return
+		}
+		page := r.queue[0]
+		r.queue = r.queue[1:]
+		r.mu.Unlock()
+
+		links, err := r.visit(ctx, page)
+
+		r.mu.Lock()
+		if err != nil && r.err == nil && ctx.Err() == nil {
+			r.err = err
+			cancel()
+		}
+		for _, link := range links {
+			r.enqueue(&Page{URL: link, Depth: page.Depth + 1, Referrer: page.URL})
+		}
+		r.pending--
+		if r.pending == 0 {
+			r.cond.Broadcast()
+		}
+		r.mu.Unlock()
+	}
+}
+
+// visit renders page, calls the page handler and returns the in-scope links to follow.
+func (r *run) visit(ctx context.Context, page *Page) ([]string, error) {
+	c := r.crawler
+
+	// Honor robots.txt and rate limits before taking a page from the pool.
+	if err := r.wait(ctx, page); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, r.handleError(ctx, page, err)
+	}
+
+	wp, err := c.pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.pool.Put(wp)
+	page.WebPage = wp
+	defer func() { page.WebPage = nil }()
+
+	if err := wp.Open(page.URL); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, r.handleError(ctx, page, err)
+	}
+
+	// Wait for additional scripts to run.
+	if c.Wait > 0 {
+		timer := time.NewTimer(c.Wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// Extract links before the handler can navigate away.
+	var links []string
+	if c.MaxDepth == 0 || page.Depth < c.MaxDepth {
+		if links, err = r.links(wp); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.HandlePage != nil {
+		if err := c.HandlePage(ctx, page); err == ErrSkipLinks {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return links, nil
+}
+
+// wait returns ErrDisallowed if robots.txt disallows page and otherwise
+// blocks until the page's host is within its rate limit.
+func (r *run) wait(ctx context.Context, page *Page) error {
+	c := r.crawler
+	host := hostOf(page.URL)
+
+	if c.Robots != nil {
+		if ok, err := c.Robots.Allowed(ctx, page.URL); err != nil {
+			return err
+		} else if !ok {
+			return ErrDisallowed
+		}
+
+		// Slow the host down if robots.txt asks for a longer crawl delay.
+		if c.Limiter != nil {
+			if d, err := c.Robots.CrawlDelay(ctx, page.URL); err != nil {
+				return err
+			} else if lim := c.Limiter.limiter(host); d > 0 && rate.Every(d) < lim.Limit() {
+				lim.SetLimit(rate.Every(d))
+			}
+		}
+	}
+
+	if c.Limiter != nil {
+		return c.Limiter.Wait(ctx, host)
+	}
+	return nil
+}
+
+// handleError passes a page error to the crawler's error handler, if set.
+func (r *run) handleError(ctx context.Context, page *Page, err error) error {
+	if r.crawler.HandleError == nil {
+		return nil
+	}
+	return r.crawler.HandleError(ctx, page, err)
+}
+
+// links returns the absolute, in-scope link URLs on the page.
+func (r *run) links(wp *phantomjs.WebPage) ([]string, error) {
+	v, err := wp.Evaluate(`function() {
+		var a = document.querySelectorAll("a[href]"), hrefs = [];
+		for (var i = 0; i < a.length; i++) { hrefs.push(a[i].href); }
+		return hrefs;
+	}`)
+	if err != nil {
+		return nil, err
+	}
+
+	values, _ := v.([]interface{})
+	links := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok && r.inScope(s) {
+			links = append(links, s)
+		}
+	}
+	return links, nil
+}
+
+// inScope returns true if rawurl should be visited according to the crawler's rules.
+func (r *run) inScope(rawurl string) bool {
+	c := r.crawler
+
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if c.SameDomain {
+		if _, ok := r.hosts[strings.ToLower(u.Host)]; !ok {
+			return false
+		}
+	}
+
+	for _, re := range c.Deny {
+		if re.MatchString(rawurl) {
+			return false
+		}
+	}
+	if len(c.Allow) == 0 {
+		return true
+	}
+	for _, re := range c.Allow {
+		if re.MatchString(rawurl) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize returns a key used to deduplicate URLs. Fragments are removed
+// and the scheme and host are lowercased.
+func normalize(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	u.Fragment, u.RawFragment = "", ""
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
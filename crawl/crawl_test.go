@@ -0,0 +1,172 @@
+package crawl_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/crawl"
+	"golang.org/x/time/rate"
+)
+
+// Ensure the crawler follows in-scope links once each, up to the max depth.
+func TestCrawler_Run(t *testing.T) {
+	pool := NewStubPool(t, 2, map[string][]string{
+		"http://example.com/":  {"http://example.com/a", "http://example.com/b#top", "http://other.com/"},
+		"http://example.com/a": {"http://example.com/", "http://example.com/b", "http://example.com/admin"},
+		"http://example.com/b": {"http://example.com/c"},
+		"http://example.com/c": {"http://example.com/d"},
+	})
+
+	var mu sync.Mutex
+	var visited []string
+	c := crawl.NewCrawler(pool)
+	c.SameDomain = true
+	c.MaxDepth = 2
+	c.Deny = []*regexp.Regexp{regexp.MustCompile(`/admin`)}
+	c.HandlePage = func(ctx context.Context, page *crawl.Page) error {
+		mu.Lock()
+		defer mu.Unlock()
+		visited = append(visited, page.URL)
+		return nil
+	}
+
+	if err := c.Run(context.Background(), "http://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(visited)
+	if exp := []string{"http://example.com/", "http://example.com/a", "http://example.com/b#top", "http://example.com/c"}; !equal(visited, exp) {
+		t.Fatalf("unexpected pages: %v", visited)
+	}
+}
+
+// Ensure a handler error stops the crawl and ErrSkipLinks does not.
+func TestCrawler_Run_HandlerError(t *testing.T) {
+	pool := NewStubPool(t, 1, map[string][]string{
+		"http://example.com/":  {"http://example.com/a"},
+		"http://example.com/a": {"http://example.com/b"},
+	})
+
+	errMarker := errors.New("marker")
+	c := crawl.NewCrawler(pool)
+	c.HandlePage = func(ctx context.Context, page *crawl.Page) error {
+		if page.URL == "http://example.com/a" {
+			return errMarker
+		}
+		return nil
+	}
+	if err := c.Run(context.Background(), "http://example.com/"); err != errMarker {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var n int
+	c.HandlePage = func(ctx context.Context, page *crawl.Page) error {
+		n++
+		return crawl.ErrSkipLinks
+	}
+	if err := c.Run(context.Background(), "http://example.com/"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("unexpected page count: %d", n)
+	}
+}
+
+// Ensure URLs disallowed by robots.txt are reported and not visited.
+func TestCrawler_Run_Robots(t *testing.T) {
+	pool := NewStubPool(t, 1, map[string][]string{
+		"http://example.com/": {"http://example.com/private", "http://example.com/public"},
+	})
+
+	robots := crawl.NewRobots("testbot")
+	robots.Client = &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		w.WriteString("User-agent: *\nDisallow: /private\n")
+		return w.Result(), nil
+	})}
+
+	var visited, disallowed []string
+	c := crawl.NewCrawler(pool)
+	c.Robots = robots
+	c.Limiter = crawl.NewHostLimiter(rate.Inf, 1)
+	c.HandlePage = func(ctx context.Context, page *crawl.Page) error {
+		visited = append(visited, page.URL)
+		return nil
+	}
+	c.HandleError = func(ctx context.Context, page *crawl.Page, err error) error {
+		if err == crawl.ErrDisallowed {
+			disallowed = append(disallowed, page.URL)
+		}
+		return nil
+	}
+
+	if err := c.Run(context.Background(), "http://example.com/"); err != nil {
+		t.Fatal(err)
+	} else if !equal(visited, []string{"http://example.com/", "http://example.com/public"}) {
+		t.Fatalf("unexpected visited: %v", visited)
+	} else if !equal(disallowed, []string{"http://example.com/private"}) {
+		t.Fatalf("unexpected disallowed: %v", disallowed)
+	}
+}
+
+// NewStubPool returns a pool of size n backed by a stub process that serves
+// the given links for each URL.
+func NewStubPool(t *testing.T, n int, links map[string][]string) *phantomjs.Pool {
+	var mu sync.Mutex
+	var nextID int
+	urls := make(map[string]string)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Ref string `json:"ref"`
+			URL string `json:"url"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/webpage/Create":
+			nextID++
+			json.NewEncoder(w).Encode(map[string]interface{}{"ref": map[string]string{"id": strconv.Itoa(nextID)}})
+		case "/webpage/Open":
+			urls[req.Ref] = req.URL
+			w.Write([]byte(`{"status":"success"}`))
+		case "/webpage/Evaluate":
+			json.NewEncoder(w).Encode(map[string]interface{}{"returnValue": links[strings.Split(urls[req.Ref], "#")[0]]})
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	return phantomjs.NewPool(n, phantomjs.NewProcess(phantomjs.WithPort(portN)))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return fn(req) }
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// HostLimiter limits the rate of requests to each host using a token bucket
+// per host.
+//
+// It is used by a Crawler to space out page loads and can also wrap an
+// http.RoundTripper with Transport.
+type HostLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+// NewHostLimiter returns a limiter that allows limit requests per second to
+// each host with bursts of up to burst requests.
+func NewHostLimiter(limit rate.Limit, burst int) *HostLimiter {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &HostLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// SetHostLimit overrides the rate limit for a single host, such as when
+// robots.txt requests a crawl delay.
+func (l *HostLimiter) SetHostLimit(host string, limit rate.Limit) {
+	l.limiter(host).SetLimit(limit)
+}
+
+// Wait blocks until a request to host is allowed or ctx is done.
+func (l *HostLimiter) Wait(ctx context.Context, host string) error {
+	return l.limiter(host).Wait(ctx)
+}
+
+// limiter returns the limiter for host, creating it if necessary.
+func (l *HostLimiter) limiter(host string) *rate.Limiter {
+	host = strings.ToLower(host)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lim := l.limiters[host]
+	if lim == nil {
+		lim = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[host] = lim
+	}
+	return lim
+}
+
+// Transport returns a RoundTripper that waits for the request's host to be
+// allowed before passing the request to next.
+func (l *HostLimiter) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if err := l.Wait(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// hostOf returns the host of rawurl or an empty string if it cannot be parsed.
+func hostOf(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,29 @@
+package crawl_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs/crawl"
+	"golang.org/x/time/rate"
+)
+
+// Ensure each host has its own token bucket.
+func TestHostLimiter_Wait(t *testing.T) {
+	l := crawl.NewHostLimiter(rate.Every(time.Hour), 1)
+
+	// First request to each host uses the burst.
+	if err := l.Wait(context.Background(), "a.com"); err != nil {
+		t.Fatal(err)
+	} else if err := l.Wait(context.Background(), "b.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Second request to the same host must wait.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "A.com"); err == nil {
+		t.Fatal("expected error")
+	}
+}
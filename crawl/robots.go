@@ -0,0 +1,161 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// ErrDisallowed is returned when robots.txt does not allow a URL to be visited.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// Robots fetches and caches robots.txt rules for each host.
+//
+// It is used by a Crawler to skip disallowed URLs and can also wrap an
+// http.RoundTripper with Transport.
+type Robots struct {
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+
+	// User agent that rules are matched against and that is sent when
+	// fetching robots.txt.
+	UserAgent string
+
+	// Client used to fetch robots.txt. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type robotsEntry struct {
+	mu   sync.Mutex
+	data *robotstxt.RobotsData
+}
+
+// NewRobots returns a new robots.txt cache that matches rules for userAgent.
+func NewRobots(userAgent string) *Robots {
+	return &Robots{
+		hosts:     make(map[string]*robotsEntry),
+		UserAgent: userAgent,
+	}
+}
+
+// Allowed returns true if robots.txt for the URL's host allows it to be visited.
+// An error is returned if robots.txt cannot be fetched.
+func (r *Robots) Allowed(ctx context.Context, rawurl string) (bool, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := r.data(ctx, u)
+	if err != nil {
+		return false, err
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return data.TestAgent(path, r.UserAgent), nil
+}
+
+// CrawlDelay returns the crawl delay requested by robots.txt for the URL's
+// host, or zero if no delay is set.
+func (r *Robots) CrawlDelay(ctx context.Context, rawurl string) (time.Duration, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := r.data(ctx, u)
+	if err != nil {
+		return 0, err
+	} else if g := data.FindGroup(r.UserAgent); g != nil {
+		return g.CrawlDelay, nil
+	}
+	return 0, nil
+}
+
+// data returns the cached rules for u's host, fetching them if necessary.
+// Failed fetches are not cached so they are retried on the next call.
+func (r *Robots) data(ctx context.Context, u *url.URL) (*robotstxt.RobotsData, error) {
+	key := u.Scheme + "://" + u.Host
+
+	r.mu.Lock()
+	if r.hosts == nil {
+		r.hosts = make(map[string]*robotsEntry)
+	}
+	entry := r.hosts[key]
+	if entry == nil {
+		entry = &robotsEntry{}
+		r.hosts[key] = entry
+	}
+	r.mu.Unlock()
+
+	// Only one caller fetches a host's rules at a time.
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.data != nil {
+		return entry.data, nil
+	}
+
+	data, err := r.fetch(ctx, key+"/robots.txt")
+	if err != nil {
+		return nil, err
+	}
+	entry.data = data
+	return data, nil
+}
+
+// fetch retrieves and parses a robots.txt file.
+func (r *Robots) fetch(ctx context.Context, rawurl string) (*robotstxt.RobotsData, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.UserAgent != "" {
+		req.Header.Set("User-Agent", r.UserAgent)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch robots.txt: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// 4xx responses allow everything and 5xx responses disallow everything.
+	return robotstxt.FromResponse(resp)
+}
+
+// Transport returns a RoundTripper that rejects requests disallowed by
+// robots.txt with ErrDisallowed before passing them to next.
+func (r *Robots) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if ok, err := r.Allowed(req.Context(), req.URL.String()); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, ErrDisallowed
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// roundTripperFunc adapts a function to the http.RoundTripper interface.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return fn(req) }
@@ -0,0 +1,55 @@
+package crawl_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs/crawl"
+)
+
+// Ensure robots.txt rules are fetched once per host and applied to URLs.
+func TestRobots_Allowed(t *testing.T) {
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&fetches, 1)
+		w.Write([]byte("User-agent: *\nDisallow: /private\nCrawl-delay: 2\n"))
+	}))
+	defer srv.Close()
+
+	robots := crawl.NewRobots("testbot")
+	for path, exp := range map[string]bool{"/": true, "/public?q=1": true, "/private": false, "/private/x": false} {
+		if ok, err := robots.Allowed(context.Background(), srv.URL+path); err != nil {
+			t.Fatal(err)
+		} else if ok != exp {
+			t.Fatalf("%s: unexpected result: %v", path, ok)
+		}
+	}
+
+	if d, err := robots.CrawlDelay(context.Background(), srv.URL+"/"); err != nil {
+		t.Fatal(err)
+	} else if d != 2*time.Second {
+		t.Fatalf("unexpected crawl delay: %s", d)
+	} else if fetches != 1 {
+		t.Fatalf("unexpected fetch count: %d", fetches)
+	}
+}
+
+// Ensure a missing robots.txt allows everything.
+func TestRobots_Allowed_NotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if ok, err := crawl.NewRobots("testbot").Allowed(context.Background(), srv.URL+"/private"); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected url to be allowed")
+	}
+}
@@ -0,0 +1,184 @@
+package phantomjs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// debugPollInterval is how often shim events are received while dumping.
+const debugPollInterval = 250 * time.Millisecond
+
+// SetDebugWriter starts writing every RPC request and response, and the page
+// events seen by the shim such as resource requests and console messages, to
+// w as indented JSON records. Pass nil to stop. The process must be open.
+//
+// Events are buffered by the shim and written a short time after they occur,
+// so they may appear after the RPC calls that follow them. The writer is kept
+// when the process is reopened.
+func (p *Process) SetDebugWriter(w io.Writer) error {
+	p.debugMu.Lock()
+	defer p.debugMu.Unlock()
+
+	p.debugWriter = w
+	if err := p.restartDebug(); err != nil {
+		p.debugWriter = nil
+		return err
+	}
+	return nil
+}
+
+// SetEventHandler calls fn with the page events seen by the shim for every
+// page of the process, such as resource requests and responses, load events
+// and console messages. Pass nil to stop. The process must be open.
+//
+// Events are received a short time after they occur, like those written by
+// SetDebugWriter, and fn is called from a single goroutine. It must not block.
+// The handler is kept when the process is reopened.
+func (p *Process) SetEventHandler(fn func(PageEvent)) error {
+	p.debugMu.Lock()
+	defer p.debugMu.Unlock()
+
+	p.eventHandler = fn
+	if err := p.restartDebug(); err != nil {
+		p.eventHandler = nil
+		return err
+	}
+	return nil
+}
+
+// restartDebug stops receiving shim events and starts again if a debug writer
+// or an event handler is set. Must be called with debugMu held.
+func (p *Process) restartDebug() error {
+	if d := p.debug.Swap(nil); d != nil {
+		d.stop()
+	}
+	if p.debugWriter == nil && p.eventHandler == nil {
+		return p.doJSON(context.Background(), "POST", "/process/SetDebug", map[string]interface{}{"enabled": false}, nil)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &debugDump{w: p.debugWriter, fn: p.eventHandler, cancel: cancel, done: make(chan struct{})}
+	p.debug.Store(d)
+	if err := p.doJSON(ctx, "POST", "/process/SetDebug", map[string]interface{}{"enabled": true}, nil); err != nil {
+		p.debug.Store(nil)
+		cancel()
+		return err
+	}
+	go p.pollDebugEvents(ctx, d)
+	return nil
+}
+
+// pollDebugEvents writes the shim's buffered events until ctx is canceled.
+func (p *Process) pollDebugEvents(ctx context.Context, d *debugDump) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(debugPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var resp struct {
+			Events []struct {
+				Time  int64           `json:"time"`
+				Ref   string          `json:"ref"`
+				Event string          `json:"event"`
+				Data  json.RawMessage `json:"data"`
+			} `json:"events"`
+			Dropped int `json:"dropped"`
+		}
+		if err := p.doJSON(ctx, "POST", "/process/DebugEvents", nil, &resp); err != nil {
+			continue
+		}
+		for _, e := range resp.Events {
+			d.write(debugEntry{Time: msTime(e.Time), Type: "event", Ref: e.Ref, Event: e.Event, Body: debugBody(e.Data)})
+			if d.fn != nil {
+				var data map[string]interface{}
+				json.Unmarshal(e.Data, &data)
+				d.fn(PageEvent{Time: msTime(e.Time), Ref: e.Ref, Event: e.Event, Data: data})
+			}
+		}
+		if resp.Dropped > 0 {
+			d.write(debugEntry{Time: time.Now(), Type: "event", Event: "Dropped", Body: map[string]int{"count": resp.Dropped}})
+		}
+	}
+}
+
+// debugDump writes RPC traffic and shim events to a writer, if set, and
+// passes shim events to a handler, if set.
+type debugDump struct {
+	w      io.Writer
+	fn     func(PageEvent)
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// debugEntry is a record written by a debug dump.
+type debugEntry struct {
+	Time     time.Time   `json:"time"`
+	Type     string      `json:"type"`
+	Method   string      `json:"method,omitempty"`
+	Path     string      `json:"path,omitempty"`
+	Ref      string      `json:"ref,omitempty"`
+	Event    string      `json:"event,omitempty"`
+	Body     interface{} `json:"body,omitempty"`
+	Duration string      `json:"duration,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// stop stops polling for events and waits for the poller to exit.
+func (d *debugDump) stop() {
+	d.cancel()
+	<-d.done
+}
+
+// middleware returns an RPC handler that writes each call's request and
+// response. Event polls are not written.
+func (d *debugDump) middleware(next RPCHandler) RPCHandler {
+	return func(ctx context.Context, call *RPCCall) error {
+		if d.w == nil || call.Path == "/process/DebugEvents" {
+			return next(ctx, call)
+		}
+
+		d.write(debugEntry{Time: time.Now(), Type: "request", Method: call.Method, Path: call.Path, Ref: call.Ref, Body: debugBody(call.Request)})
+		t := time.Now()
+		err := next(ctx, call)
+		e := debugEntry{Time: time.Now(), Type: "response", Path: call.Path, Ref: call.Ref, Body: debugBody(call.Response), Duration: time.Since(t).String()}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		d.write(e)
+		return err
+	}
+}
+
+// write writes an entry as indented JSON.
+func (d *debugDump) write(e debugEntry) {
+	if d.w == nil {
+		return
+	}
+	buf, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.w.Write(append(buf, '\n'))
+}
+
+// debugBody returns a body for a debug entry, keeping JSON bodies as JSON.
+func debugBody(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	} else if json.Valid(b) {
+		return json.RawMessage(b)
+	}
+	return string(b)
+}
@@ -0,0 +1,144 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+)
+
+var (
+	// ErrBlocked is returned by the open methods when a detector recognizes
+	// a captcha, rate limit or firewall page. The returned error is a
+	// *BlockedError.
+	ErrBlocked = errors.New("blocked")
+)
+
+// Kinds of block pages recognized by detectors.
+const (
+	BlockCaptcha   = "captcha"
+	BlockRateLimit = "ratelimit"
+	BlockWAF       = "waf"
+)
+
+// BlockedError is returned by the open methods when a detector recognizes
+// the opened page as a block page instead of the requested content.
+type BlockedError struct {
+	// Kind of block page, such as BlockCaptcha.
+	Kind string
+
+	// Result of the open. The page still shows the block page, so it can be
+	// inspected or handed to a solving service.
+	Result *OpenResult
+}
+
+// Error returns the kind of block and the blocked URL.
+func (e *BlockedError) Error() string {
+	return "blocked by " + e.Kind + ": " + e.Result.URL
+}
+
+// Is returns true if target is ErrBlocked.
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// Detector recognizes block pages, such as captchas and rate limit pages,
+// that are served instead of the requested content. The open methods call
+// each of the process' Detectors after a page loads and return a
+// *BlockedError for the first that reports a kind.
+type Detector interface {
+	// Detect returns the kind of block page that page shows, such as
+	// BlockCaptcha, or an empty string if it is not one.
+	Detect(page *WebPage, r *OpenResult) (string, error)
+}
+
+// DefaultDetectors recognizes rate limit responses and the captchas and
+// challenge pages of common providers.
+var DefaultDetectors = []Detector{
+	StatusDetector(BlockRateLimit, 429),
+	SelectorDetector(BlockCaptcha, `.g-recaptcha, .h-captcha, iframe[src*="recaptcha/"], iframe[src*="hcaptcha.com"], #captcha, form[action*="captcha"]`),
+	SelectorDetector(BlockWAF, `#challenge-form, #cf-challenge-running, #challenge-running, form#challenge-form`),
+	TextDetector(BlockWAF, regexp.MustCompile(`(?i)<title>(Attention Required! \| Cloudflare|Just a moment\.\.\.|Access Denied)</title>`)),
+}
+
+// StatusDetector returns a detector that reports kind when the main document
+// has one of the HTTP status codes.
+func StatusDetector(kind string, codes ...int) Detector {
+	return &statusDetector{kind: kind, codes: codes}
+}
+
+type statusDetector struct {
+	kind  string
+	codes []int
+}
+
+func (d *statusDetector) Detect(page *WebPage, r *OpenResult) (string, error) {
+	for _, code := range d.codes {
+		if r.StatusCode == code {
+			return d.kind, nil
+		}
+	}
+	return "", nil
+}
+
+// SelectorDetector returns a detector that reports kind when an element
+// matches selector.
+func SelectorDetector(kind, selector string) Detector {
+	return &selectorDetector{kind: kind, selector: selector}
+}
+
+type selectorDetector struct {
+	kind     string
+	selector string
+}
+
+func (d *selectorDetector) Detect(page *WebPage, r *OpenResult) (string, error) {
+	sel, err := json.Marshal(d.selector)
+	if err != nil {
+		return "", err
+	}
+	var found bool
+	if err := page.evaluateInto(fmt.Sprintf(`function() { return document.querySelector(%s) !== null; }`, sel), &found); err != nil {
+		return "", err
+	} else if !found {
+		return "", nil
+	}
+	return d.kind, nil
+}
+
+// TextDetector returns a detector that reports kind when the page's HTML
+// content matches re.
+func TextDetector(kind string, re *regexp.Regexp) Detector {
+	return &textDetector{kind: kind, re: re}
+}
+
+type textDetector struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+func (d *textDetector) Detect(page *WebPage, r *OpenResult) (string, error) {
+	content, err := page.Content()
+	if err != nil {
+		return "", err
+	} else if !d.re.MatchString(content) {
+		return "", nil
+	}
+	return d.kind, nil
+}
+
+// detect runs the process' detectors against a loaded page. Detectors that
+// fail are logged and skipped.
+func (p *WebPage) detect(r *OpenResult) error {
+	for _, d := range p.ref.process.Detectors {
+		kind, err := d.Detect(p, r)
+		if err != nil {
+			p.ref.process.log(slog.LevelWarn, "phantomjs detector failed", "url", r.URL, "error", err)
+			continue
+		} else if kind != "" {
+			return &BlockedError{Kind: kind, Result: r}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Package dom parses snapshots of a page's rendered DOM so they can be queried
+// in Go.
+//
+// The page content is fetched once and parsed locally, so read-only
+// extraction does not require a round trip to the browser per selector:
+//
+//	doc, err := dom.Query(page)
+//	if err != nil {
+//		return err
+//	}
+//	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+//		fmt.Println(s.AttrOr("href", ""))
+//	})
+//
+// Changes made by scripts after the snapshot is taken are not reflected.
+package dom
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/benbjohnson/phantomjs"
+	"golang.org/x/net/html"
+)
+
+// Document returns a parsed snapshot of the page's current DOM.
+func Document(page phantomjs.Page) (*html.Node, error) {
+	content, err := page.Content()
+	if err != nil {
+		return nil, err
+	}
+	return Parse(content)
+}
+
+// Parse parses serialized page content into a node tree.
+func Parse(content string) (*html.Node, error) {
+	return html.Parse(strings.NewReader(content))
+}
+
+// Query returns a goquery document for a snapshot of the page's current DOM.
+// The document's URL is set to the page's URL so relative links can be
+// resolved against it.
+func Query(page phantomjs.Page) (*goquery.Document, error) {
+	node, err := Document(page)
+	if err != nil {
+		return nil, err
+	}
+	doc := goquery.NewDocumentFromNode(node)
+
+	// Attach the page URL, if it is available and valid.
+	if s, err := page.URL(); err != nil {
+		return nil, err
+	} else if u, err := url.Parse(s); err == nil && u.IsAbs() {
+		doc.Url = u
+	}
+	return doc, nil
+}
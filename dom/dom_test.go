@@ -0,0 +1,61 @@
+package dom_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs/dom"
+	"github.com/benbjohnson/phantomjs/fake"
+	"golang.org/x/net/html"
+)
+
+// Ensure the rendered content can be parsed into a node tree.
+func TestDocument(t *testing.T) {
+	page := fake.NewPage()
+	if err := page.SetContent(`<html><body><h1 id="title">Hello</h1></body></html>`); err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := dom.Document(page)
+	if err != nil {
+		t.Fatal(err)
+	} else if node.Type != html.DocumentNode {
+		t.Fatalf("unexpected node type: %v", node.Type)
+	}
+}
+
+// Ensure content errors are returned.
+func TestDocument_ErrContent(t *testing.T) {
+	errMarker := errors.New("marker")
+	page := fake.NewPage()
+	page.Fail("Content", errMarker)
+	if _, err := dom.Document(page); err != errMarker {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a goquery document can be queried and resolves against the page URL.
+func TestQuery(t *testing.T) {
+	b := fake.NewBrowser()
+	b.Sites["http://example.com/a/"] = `<html><body><ul><li><a href="b">B</a></li><li><a href="/c">C</a></li></ul></body></html>`
+	page := b.CreateFakePage()
+	if err := page.Open("http://example.com/a/"); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := dom.Query(page)
+	if err != nil {
+		t.Fatal(err)
+	} else if n := doc.Find("li a").Length(); n != 2 {
+		t.Fatalf("unexpected link count: %d", n)
+	} else if text := doc.Find("li").Last().Text(); text != "C" {
+		t.Fatalf("unexpected text: %q", text)
+	}
+
+	href, _ := doc.Find("a").First().Attr("href")
+	if u, err := doc.Url.Parse(href); err != nil {
+		t.Fatal(err)
+	} else if u.String() != "http://example.com/a/b" {
+		t.Fatalf("unexpected url: %s", u)
+	}
+}
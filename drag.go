@@ -0,0 +1,96 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Easing maps the progress of a gesture, from 0 to 1, to the fraction of
+// the distance covered.
+type Easing func(t float64) float64
+
+// Easing functions for DragAndDrop.
+var (
+	// EaseLinear moves at a constant speed.
+	EaseLinear Easing = func(t float64) float64 { return t }
+
+	// EaseInOut accelerates from the start and decelerates into the end, as
+	// a hand would.
+	EaseInOut Easing = func(t float64) float64 {
+		if t < 0.5 {
+			return 2 * t * t
+		}
+		return 1 - math.Pow(-2*t+2, 2)/2
+	}
+)
+
+// DragAndDrop presses the left mouse button on the center of the first
+// element matching fromSelector, moves the mouse to the center of the first
+// element matching toSelector in steps moves and releases the button. easing
+// spaces out the moves and defaults to EaseLinear.
+//
+// The source element is scrolled into view first. The events are real mouse
+// events, so sortable lists and sliders that listen for mousedown, mousemove
+// and mouseup respond as they would to a user. HTML5 drag and drop is not
+// supported by PhantomJS.
+//
+// Returns ErrElementNotFound if either selector matches no element.
+func (p *WebPage) DragAndDrop(fromSelector, toSelector string, steps int, easing Easing) error {
+	if steps < 1 {
+		steps = 1
+	}
+	if easing == nil {
+		easing = EaseLinear
+	}
+
+	from, err := json.Marshal(fromSelector)
+	if err != nil {
+		return err
+	}
+	to, err := json.Marshal(toSelector)
+	if err != nil {
+		return err
+	}
+
+	var v *struct {
+		From, To struct{ X, Y float64 }
+	}
+	if err := p.evaluateInto(fmt.Sprintf(dragScript, from, to), &v); err != nil {
+		return err
+	} else if v == nil {
+		return ErrElementNotFound
+	}
+
+	x0, y0, x1, y1 := v.From.X, v.From.Y, v.To.X, v.To.Y
+	if err := p.SendMouseEvent("mousemove", round(x0), round(y0), "left"); err != nil {
+		return err
+	} else if err := p.SendMouseEvent("mousedown", round(x0), round(y0), "left"); err != nil {
+		return err
+	}
+	for i := 1; i <= steps; i++ {
+		f := easing(float64(i) / float64(steps))
+		if err := p.SendMouseEvent("mousemove", round(x0+(x1-x0)*f), round(y0+(y1-y0)*f), "left"); err != nil {
+			return err
+		}
+	}
+	return p.SendMouseEvent("mouseup", round(x1), round(y1), "left")
+}
+
+// round returns the nearest integer to f.
+func round(f float64) int {
+	return int(math.Round(f))
+}
+
+// dragScript returns the viewport coordinates of the centers of the source
+// and target elements, or null if either is missing.
+const dragScript = `function() {
+	var from = document.querySelector(%s), to = document.querySelector(%s);
+	if (!from || !to) return null;
+	from.scrollIntoView();
+	var center = function(el) {
+		var r = el.getBoundingClientRect();
+		return {x: r.left + r.width / 2, y: r.top + r.height / 2};
+	};
+	return {from: center(from), to: center(to)};
+}`
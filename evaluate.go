@@ -0,0 +1,142 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxErrorValueLength is the length that values are truncated to in an
+// EvaluateError.
+const maxErrorValueLength = 40
+
+// EvaluateError is returned by EvaluateTo when a returned value cannot be
+// decoded into its destination.
+type EvaluateError struct {
+	// Key of the value in the returned object, with the path to the value
+	// for nested fields such as "user.age". Blank if the script did not
+	// return an object.
+	Key string
+
+	// JavaScript type of the value, such as KindString. KindUndefined if
+	// the key is missing.
+	Kind string
+
+	// JSON of the value, truncated. Blank if unknown.
+	Value string
+
+	// Type of the destination. Nil if the script did not return an object.
+	Type reflect.Type
+}
+
+// Error returns a description of the mismatch.
+func (e *EvaluateError) Error() string {
+	value := e.Kind
+	if e.Value != "" {
+		value += " " + e.Value
+	}
+	if e.Key == "" {
+		return "evaluate: returned " + value + ", not an object"
+	}
+	return fmt.Sprintf("evaluate: key %q: cannot decode %s into %s", e.Key, value, e.Type)
+}
+
+// EvaluateTo executes a JavaScript function that returns an object and
+// decodes the object's values into typed destinations by key, instead of
+// asserting the types of Evaluate's result:
+//
+//	var title string
+//	var count int
+//	err := page.EvaluateTo(`function() {
+//		return {title: document.title, count: document.links.length};
+//	}`, map[string]interface{}{"title": &title, "count": &count})
+//
+// Each destination must be a non-nil pointer and is decoded like
+// json.Unmarshal. A null value leaves its destination unchanged. Returns an
+// *EvaluateError if the script does not return an object, a key is missing or
+// a value does not match its destination's type.
+func (p *WebPage) EvaluateTo(script string, outs map[string]interface{}) error {
+	for key, dest := range outs {
+		if v := reflect.ValueOf(dest); v.Kind() != reflect.Ptr || v.IsNil() {
+			return fmt.Errorf("evaluate: key %q: destination must be a non-nil pointer, got %T", key, dest)
+		}
+	}
+
+	var raw json.RawMessage
+	if err := p.evaluateInto(script, &raw); err != nil {
+		return err
+	}
+	var values map[string]json.RawMessage
+	if kind := jsonKind(raw); kind != KindObject {
+		return &EvaluateError{Kind: kind, Value: truncateJSON(raw)}
+	} else if err := json.Unmarshal(raw, &values); err != nil {
+		return err
+	}
+
+	// Decode in key order so that the reported error is deterministic.
+	keys := make([]string, 0, len(outs))
+	for key := range outs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		dest := outs[key]
+		value, ok := values[key]
+		if !ok {
+			return &EvaluateError{Key: key, Kind: KindUndefined, Type: reflect.TypeOf(dest).Elem()}
+		}
+
+		err := json.Unmarshal(value, dest)
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			e := &EvaluateError{Key: key, Kind: jsonKind(value), Value: truncateJSON(value), Type: typeErr.Type}
+			if typeErr.Field != "" {
+				e.Key += "." + typeErr.Field
+				e.Kind, e.Value, _ = strings.Cut(typeErr.Value, " ")
+				if e.Kind == "bool" {
+					e.Kind = KindBool
+				}
+			}
+			return e
+		} else if err != nil {
+			return fmt.Errorf("evaluate: key %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// jsonKind returns the JavaScript type of a JSON value. Missing values are
+// undefined.
+func jsonKind(data []byte) string {
+	s := strings.TrimSpace(string(data))
+	if s == "" {
+		return KindUndefined
+	}
+	switch s[0] {
+	case '{':
+		return KindObject
+	case '[':
+		return KindArray
+	case '"':
+		return KindString
+	case 't', 'f':
+		return KindBool
+	case 'n':
+		return KindNull
+	default:
+		return KindNumber
+	}
+}
+
+// truncateJSON returns data truncated to maxErrorValueLength characters.
+func truncateJSON(data []byte) string {
+	s := []rune(strings.TrimSpace(string(data)))
+	if len(s) > maxErrorValueLength {
+		return string(s[:maxErrorValueLength-3]) + "..."
+	}
+	return string(s)
+}
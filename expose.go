@@ -0,0 +1,118 @@
+package phantomjs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+var (
+	// ErrInvalidFunctionName is returned by ExposeFunction when the name is
+	// not a JavaScript identifier.
+	ErrInvalidFunctionName = errors.New("invalid function name")
+)
+
+// callPollTimeout is how long the shim holds a request open while waiting
+// for calls to exposed functions.
+const callPollTimeout = 1 * time.Second
+
+// functionNameRegexp matches JavaScript identifiers.
+var functionNameRegexp = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// ExposedFunc is a Go function called by page scripts. It receives the
+// JSON-decoded arguments of the call and returns a value that is marshaled to
+// JSON for the page. A returned error is raised in the page as an Error with
+// the error's message.
+type ExposedFunc func(args ...interface{}) (interface{}, error)
+
+// ExposeFunction defines a function named name on the page's window that
+// calls fn. The function is defined in the current document and in every
+// document the page loads afterwards. Exposing a name again replaces fn.
+//
+// Calls are asynchronous. The page function returns a Promise of fn's result
+// or, where Promise is unavailable, takes a Node-style callback as its last
+// argument:
+//
+//	window.fetchToken('user', function(err, token) { ... });
+//
+// Each call runs in its own goroutine, so fn may use the page. Calls that are
+// still running when the page navigates are discarded.
+func (p *WebPage) ExposeFunction(name string, fn ExposedFunc) error {
+	if !functionNameRegexp.MatchString(name) {
+		return ErrInvalidFunctionName
+	}
+	if err := p.doJSON("POST", "/webpage/ExposeFunction", map[string]interface{}{"ref": p.ref.id, "name": name}, nil); err != nil {
+		return err
+	}
+
+	proc := p.ref.process
+	proc.exposeMu.Lock()
+	defer proc.exposeMu.Unlock()
+	if proc.exposed == nil {
+		proc.exposed = make(map[string]map[string]ExposedFunc)
+	}
+	fns := proc.exposed[p.ref.id]
+	if fns == nil {
+		fns = make(map[string]ExposedFunc)
+		proc.exposed[p.ref.id] = fns
+		go proc.pollCalls(p.ref.id)
+	}
+	fns[name] = fn
+	return nil
+}
+
+// pollCalls runs the calls to a page's exposed functions until the page is
+// closed or the process stops responding.
+func (p *Process) pollCalls(id string) {
+	defer func() {
+		p.exposeMu.Lock()
+		delete(p.exposed, id)
+		p.exposeMu.Unlock()
+	}()
+
+	req := map[string]interface{}{"ref": id, "timeout": int(callPollTimeout / time.Millisecond)}
+	for {
+		var resp struct {
+			Closed bool       `json:"closed"`
+			Calls  []callJSON `json:"calls"`
+		}
+		if err := p.doJSON(context.Background(), "POST", "/webpage/NextCalls", req, &resp); err != nil || resp.Closed {
+			return
+		}
+		for _, c := range resp.Calls {
+			go p.runCall(id, c)
+		}
+	}
+}
+
+// runCall calls an exposed function and sends its result to the page.
+func (p *Process) runCall(id string, c callJSON) {
+	p.exposeMu.Lock()
+	fn := p.exposed[id][c.Name]
+	p.exposeMu.Unlock()
+
+	req := map[string]interface{}{"ref": id, "id": c.ID}
+	if fn == nil {
+		req["error"] = "function not exposed: " + c.Name
+	} else if result, err := fn(c.Args...); err != nil {
+		req["error"] = err.Error()
+	} else if buf, err := json.Marshal(result); err != nil {
+		req["error"] = err.Error()
+	} else {
+		req["result"] = json.RawMessage(buf)
+	}
+
+	if err := p.doJSON(context.Background(), "POST", "/webpage/ResolveCall", req, nil); err != nil {
+		p.log(slog.LevelWarn, "phantomjs exposed function result not sent", "name", c.Name, "error", err)
+	}
+}
+
+// callJSON is a struct for decoding calls to exposed functions from the shim.
+type callJSON struct {
+	ID   string        `json:"id"`
+	Name string        `json:"name"`
+	Args []interface{} `json:"args"`
+}
@@ -0,0 +1,327 @@
+// Package extract fills Go structs from a page's DOM using selectors declared
+// in struct tags.
+//
+// Each tagged field names a CSS selector and what to read from the matching
+// element. All selections are performed by a single script, so a struct is
+// filled in one round trip to the browser:
+//
+//	type Product struct {
+//		Name    string   `phantom:"css=h1"`
+//		Price   float64  `phantom:"css=.price,required"`
+//		Image   string   `phantom:"css=img.main,attr=src"`
+//		InStock bool     `phantom:"css=.in-stock"`
+//		Tags    []string `phantom:"css=.tags a"`
+//		Reviews []struct {
+//			Author string `phantom:"css=.author"`
+//			Body   string `phantom:"css=.body,attr=html"`
+//		} `phantom:"css=.review"`
+//	}
+//
+//	var p Product
+//	if err := extract.Extract(page, &p); err != nil {
+//		return err
+//	}
+//
+// The tag is a comma-separated list of options:
+//
+//	css=SELECTOR  elements to read, relative to the enclosing struct's element
+//	attr=NAME     what to read: "text" (the default), "html", "outerhtml" or
+//	              an attribute name
+//	required      return ErrNotFound if no element matches
+//
+// Selectors may contain commas, such as "css=h1, h2". Fields without a tag,
+// or with the tag "-", are left unchanged.
+//
+// Fields are filled by type. Strings receive the value as is, with text
+// whitespace collapsed and the href, src and action attributes resolved to
+// absolute URLs. Numbers are parsed from the first number in the value, so
+// "$1,299.00" reads as 1299. Bools are true if an element matches and, for
+// attributes, has the attribute. Types implementing encoding.TextUnmarshaler
+// are given the value. Slices receive every matching element and structs,
+// or slices of structs, are filled from their own tags within each matching
+// element. A struct field without a selector is read from the enclosing
+// element. Pointers are left nil if no element matches.
+package extract
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+var (
+	// ErrNotFound is returned when no element matches the selector of a
+	// required field.
+	ErrNotFound = errors.New("element not found")
+
+	// ErrInvalidTarget is returned when the value passed to Extract is not
+	// a non-nil pointer to a struct.
+	ErrInvalidTarget = errors.New("extract target must be a non-nil pointer to a struct")
+)
+
+// Extract fills the tagged fields of the struct pointed to by v from the
+// page's current DOM.
+func Extract(page phantomjs.Page, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrInvalidTarget
+	}
+
+	fields, err := compile(rv.Elem().Type(), "")
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	result, err := page.Evaluate(fmt.Sprintf(extractScript, buf))
+	if err != nil {
+		return err
+	}
+	return fill(rv.Elem(), fields, result)
+}
+
+// field represents a tagged struct field. The exported fields are sent to
+// the extract script.
+type field struct {
+	CSS    string   `json:"css"`
+	Attr   string   `json:"attr"`
+	Many   bool     `json:"many"`
+	Fields []*field `json:"fields"`
+
+	index    int
+	path     string
+	required bool
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// compile returns the tagged fields of a struct type. Field paths are
+// prefixed with prefix.
+func compile(t reflect.Type, prefix string) ([]*field, error) {
+	var fields []*field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("phantom")
+		if !ok || tag == "-" || sf.PkgPath != "" {
+			continue
+		}
+
+		f, err := parseTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %w", prefix, sf.Name, err)
+		}
+		f.index, f.path = i, prefix+sf.Name
+
+		typ := sf.Type
+		if typ.Kind() == reflect.Slice && !isScalar(typ) {
+			if f.CSS == "" {
+				return nil, fmt.Errorf("%s: slice field requires a selector", f.path)
+			}
+			f.Many, typ = true, typ.Elem()
+		}
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		switch {
+		case isScalar(typ):
+		case typ.Kind() == reflect.Struct:
+			if f.Fields, err = compile(typ, f.path+"."); err != nil {
+				return nil, err
+			} else if f.Fields == nil {
+				f.Fields = []*field{}
+			}
+		default:
+			return nil, fmt.Errorf("%s: unsupported type %s", f.path, sf.Type)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// isScalar returns true if a value of type t is filled from a single value.
+func isScalar(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// parseTag parses a phantom struct tag. Segments that do not start a new
+// option are part of the preceding selector.
+func parseTag(tag string) (*field, error) {
+	f := &field{Attr: "text"}
+	var last *string
+	for _, seg := range strings.Split(tag, ",") {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(seg), "=")
+		switch {
+		case key == "" && !hasValue && last == nil:
+			continue
+		case key == "css" && hasValue:
+			f.CSS, last = value, &f.CSS
+		case key == "attr" && hasValue && value != "":
+			f.Attr, last = strings.ToLower(value), nil
+		case key == "required" && !hasValue:
+			f.required, last = true, nil
+		case last != nil:
+			*last += "," + seg
+		default:
+			return nil, fmt.Errorf("invalid tag option %q", seg)
+		}
+	}
+	f.CSS = strings.TrimSpace(f.CSS)
+	return f, nil
+}
+
+// fill sets the fields of the struct v from the values returned by the
+// extract script for its element.
+func fill(v reflect.Value, fields []*field, result interface{}) error {
+	values, ok := result.([]interface{})
+	if !ok || len(values) != len(fields) {
+		return fmt.Errorf("unexpected extract result: %v", result)
+	}
+
+	for i, f := range fields {
+		fv := v.Field(f.index)
+		if !f.Many {
+			if err := fillValue(fv, f, values[i]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		items, _ := values[i].([]interface{})
+		if len(items) == 0 && f.required {
+			return fmt.Errorf("%s: %w", f.path, ErrNotFound)
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		for j, item := range items {
+			if err := fillValue(slice.Index(j), f, item); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+	}
+	return nil
+}
+
+// fillValue sets a single value of a field. A nil value means no element
+// matched.
+func fillValue(v reflect.Value, f *field, value interface{}) error {
+	if value == nil {
+		if f.required {
+			return fmt.Errorf("%s: %w", f.path, ErrNotFound)
+		} else if v.Kind() == reflect.Bool {
+			v.SetBool(false)
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := fillValue(ptr.Elem(), f, value); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	if f.Fields != nil {
+		return fill(v, f.Fields, value)
+	}
+
+	s, _ := value.(string)
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("%s: %w", f.path, err)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(number(s), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer %q", f.path, s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(number(s), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer %q", f.path, s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(number(s), v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %q", f.path, s)
+		}
+		v.SetFloat(n)
+	}
+	return nil
+}
+
+// numberRegexp matches a number with optional thousands separators.
+var numberRegexp = regexp.MustCompile(`[-+]?\d[\d,]*(\.\d+)?`)
+
+// number returns the first number in s without thousands separators.
+func number(s string) string {
+	return strings.ReplaceAll(numberRegexp.FindString(s), ",", "")
+}
+
+// extractScript reads the fields of a plan from the document. It returns an
+// array of values for each struct: null if no element matched, a string for
+// single values, an array of values for structs and an array of those for
+// slices.
+const extractScript = `function() {
+	var plan = %s;
+	var resolve = {href: 1, src: 1, action: 1};
+
+	var read = function(el, attr) {
+		switch (attr) {
+		case 'text': return (el.textContent || '').replace(/\s+/g, ' ').replace(/^ | $/g, '');
+		case 'html': return el.innerHTML;
+		case 'outerhtml': return el.outerHTML;
+		}
+		if (!el.hasAttribute(attr)) return null;
+		if (resolve[attr] && typeof el[attr] === 'string') return el[attr];
+		return el.getAttribute(attr);
+	};
+
+	var extract = function(scope, fields) {
+		return fields.map(function(f) {
+			var els = f.css ? scope.querySelectorAll(f.css) : [scope];
+			var get = function(el) { return f.fields ? extract(el, f.fields) : read(el, f.attr); };
+			if (!f.many) return els.length ? get(els[0]) : null;
+
+			var values = [];
+			for (var i = 0; i < els.length; i++) {
+				var value = get(els[i]);
+				if (value !== null) values.push(value);
+			}
+			return values;
+		});
+	};
+	return extract(document.documentElement, plan || []);
+}`
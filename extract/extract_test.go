@@ -0,0 +1,152 @@
+package extract_test
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs/extract"
+	"github.com/benbjohnson/phantomjs/fake"
+)
+
+type Review struct {
+	Author string `phantom:"css=.author"`
+	Rating int    `phantom:"css=.rating,attr=data-rating"`
+}
+
+type Product struct {
+	Name      string    `phantom:"css=h1, h2"`
+	Price     float64   `phantom:"css=.price,required"`
+	Stock     uint      `phantom:"css=.stock"`
+	Image     string    `phantom:"css=img.main,attr=src"`
+	InStock   bool      `phantom:"css=.in-stock"`
+	OnSale    bool      `phantom:"css=.sale"`
+	Tags      []string  `phantom:"css=.tags a"`
+	Reviews   []Review  `phantom:"css=.review"`
+	Top       *Review   `phantom:"css=.review.top"`
+	Missing   *Review   `phantom:"css=.review.missing"`
+	Published time.Time `phantom:"css=time,attr=datetime"`
+	Seller    struct {
+		Name string `phantom:"css=.seller"`
+	} `phantom:""`
+	Ignored string
+	Skipped string `phantom:"-"`
+}
+
+// Ensure fields are selected in one script and filled by type.
+func TestExtract(t *testing.T) {
+	page := fake.NewPage()
+	page.EvaluateFunc = func(script string) (interface{}, error) {
+		if plan := regexp.MustCompile(`var plan = (.*);`).FindStringSubmatch(script); plan == nil {
+			t.Fatalf("plan not found: %s", script)
+		} else if !strings.HasPrefix(plan[1], `[{"css":"h1, h2","attr":"text","many":false,"fields":null},`) ||
+			!strings.Contains(plan[1], `{"css":".review","attr":"text","many":true,"fields":[{"css":".author","attr":"text","many":false,"fields":null},{"css":".rating","attr":"data-rating","many":false,"fields":null}]}`) ||
+			!strings.HasSuffix(plan[1], `{"css":"","attr":"text","many":false,"fields":[{"css":".seller","attr":"text","many":false,"fields":null}]}]`) {
+			t.Fatalf("unexpected plan: %s", plan[1])
+		}
+		return []interface{}{
+			"Widget",
+			"$1,299.50",
+			"12 left",
+			"http://example.com/w.png",
+			"",
+			nil,
+			[]interface{}{"blue", "small"},
+			[]interface{}{
+				[]interface{}{"Ann", "5"},
+				[]interface{}{"Bob", nil},
+			},
+			[]interface{}{"Ann", "5"},
+			nil,
+			"2020-01-02T03:04:05Z",
+			[]interface{}{"Acme"},
+		}, nil
+	}
+
+	var p Product
+	p.Ignored, p.Skipped = "x", "y"
+	if err := extract.Extract(page, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := Product{
+		Name:      "Widget",
+		Price:     1299.5,
+		Stock:     12,
+		Image:     "http://example.com/w.png",
+		InStock:   true,
+		Tags:      []string{"blue", "small"},
+		Reviews:   []Review{{Author: "Ann", Rating: 5}, {Author: "Bob"}},
+		Top:       &Review{Author: "Ann", Rating: 5},
+		Published: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Ignored:   "x",
+		Skipped:   "y",
+	}
+	exp.Seller.Name = "Acme"
+	if !reflect.DeepEqual(p, exp) {
+		t.Fatalf("unexpected product: %#v", p)
+	}
+	if n := page.Called("Evaluate"); n != 1 {
+		t.Fatalf("unexpected evaluate calls: %d", n)
+	}
+}
+
+// Ensure a missing required field returns ErrNotFound with its path.
+func TestExtract_ErrNotFound(t *testing.T) {
+	var v struct {
+		Items []struct {
+			Name string `phantom:"css=.name,required"`
+		} `phantom:"css=li"`
+	}
+
+	page := fake.NewPage()
+	page.EvaluateFunc = func(script string) (interface{}, error) {
+		return []interface{}{[]interface{}{[]interface{}{"a"}, []interface{}{nil}}}, nil
+	}
+	if err := extract.Extract(page, &v); !errors.Is(err, extract.ErrNotFound) || err.Error() != "Items.Name: element not found" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure invalid targets, tags and values are reported.
+func TestExtract_Errors(t *testing.T) {
+	page := fake.NewPage()
+	page.EvaluateFunc = func(script string) (interface{}, error) {
+		return []interface{}{"none"}, nil
+	}
+
+	var s string
+	if err := extract.Extract(page, &s); err != extract.ErrInvalidTarget {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var badTag struct {
+		A string `phantom:"selector=a"`
+	}
+	if err := extract.Extract(page, &badTag); err == nil || err.Error() != `A: invalid tag option "selector=a"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var badType struct {
+		A map[string]string `phantom:"css=a"`
+	}
+	if err := extract.Extract(page, &badType); err == nil || err.Error() != "A: unsupported type map[string]string" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var badNumber struct {
+		A int `phantom:"css=a"`
+	}
+	if err := extract.Extract(page, &badNumber); err == nil || err.Error() != `A: invalid integer "none"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	errMarker := errors.New("marker")
+	page.Fail("Evaluate", errMarker)
+	if err := extract.Extract(page, &badNumber); err != errMarker {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,964 @@
+// Package fake provides an in-memory implementation of phantomjs.Browser and
+// phantomjs.Page for unit testing code that depends on the phantomjs package
+// without spawning a browser binary.
+//
+// Pages keep their state in memory: setters update it and getters read it
+// back. Open serves content from Browser.Sites, and Evaluate returns canned
+// results from Page.Results or Page.EvaluateFunc. Every call is recorded and
+// can be inspected with Page.Calls.
+package fake
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Ensure types implement the backend interfaces.
+var (
+	_ phantomjs.Browser = (*Browser)(nil)
+	_ phantomjs.Page    = (*Page)(nil)
+)
+
+// ErrClosed is returned when calling a method on a closed page.
+var ErrClosed = errors.New("fake: page closed")
+
+// Browser is an in-memory phantomjs.Browser.
+type Browser struct {
+	mu     sync.Mutex
+	opened bool
+	pages  []*Page
+
+	// Sites maps URLs to the HTML content served when a page opens them.
+	// Opening a URL that is not listed fails.
+	Sites map[string]string
+
+	// InitPage, if set, is called on every page returned by CreatePage.
+	// It can be used to install canned results or failures.
+	InitPage func(page *Page)
+}
+
+// NewBrowser returns a new instance of Browser.
+func NewBrowser() *Browser {
+	return &Browser{Sites: make(map[string]string)}
+}
+
+// Open marks the browser as open.
+func (b *Browser) Open() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.opened = true
+	return nil
+}
+
+// Close marks the browser as closed and closes all of its pages.
+func (b *Browser) Close() error {
+	b.mu.Lock()
+	pages := b.pages
+	b.opened = false
+	b.mu.Unlock()
+
+	for _, page := range pages {
+		page.Close()
+	}
+	return nil
+}
+
+// Opened returns true if the browser has been opened and not closed.
+func (b *Browser) Opened() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.opened
+}
+
+// CreatePage returns a new in-memory page.
+func (b *Browser) CreatePage() (phantomjs.Page, error) {
+	return b.CreateFakePage(), nil
+}
+
+// CreateFakePage returns a new page as its concrete type.
+func (b *Browser) CreateFakePage() *Page {
+	page := NewPage()
+	page.browser = b
+	if b.InitPage != nil {
+		b.InitPage(page)
+	}
+
+	b.mu.Lock()
+	b.pages = append(b.pages, page)
+	b.mu.Unlock()
+	return page
+}
+
+// Pages returns all pages created by the browser, including closed pages.
+func (b *Browser) Pages() []*Page {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*Page(nil), b.pages...)
+}
+
+// site returns the content for a URL and whether it exists.
+func (b *Browser) site(url string) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	content, ok := b.Sites[url]
+	return content, ok
+}
+
+// Call represents a single method call made on a Page.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Page is an in-memory phantomjs.Page.
+type Page struct {
+	mu      sync.Mutex
+	browser *Browser
+	calls   []Call
+	errs    map[string]error
+	closed  bool
+
+	history []string
+	index   int
+
+	content         string
+	url             string
+	clipRect        phantomjs.Rect
+	cookies         []*http.Cookie
+	headers         http.Header
+	libraryPath     string
+	navLocked       bool
+	ownsPages       bool
+	paperSize       phantomjs.PaperSize
+	scrollPosition  phantomjs.Position
+	settings        phantomjs.WebPageSettings
+	viewportWidth   int
+	viewportHeight  int
+	zoomFactor      float64
+	frameContent    string
+	uploadedFiles   map[string]string
+	injectedScripts []string
+
+	// Results maps scripts passed to Evaluate or EvaluateJavaScript to the
+	// values that should be returned.
+	Results map[string]interface{}
+
+	// EvaluateFunc, if set, is called for scripts not found in Results.
+	EvaluateFunc func(script string) (interface{}, error)
+
+	// OpenFunc, if set, replaces the default Open behavior of serving
+	// content from Browser.Sites. Returned content is set on the page.
+	OpenFunc func(url string) (content string, err error)
+}
+
+// NewPage returns a standalone in-memory page.
+func NewPage() *Page {
+	return &Page{
+		errs:           make(map[string]error),
+		headers:        make(http.Header),
+		viewportWidth:  400,
+		viewportHeight: 300,
+		zoomFactor:     1,
+		index:          -1,
+		uploadedFiles:  make(map[string]string),
+		Results:        make(map[string]interface{}),
+		settings: phantomjs.WebPageSettings{
+			JavascriptEnabled:  true,
+			LoadImages:         true,
+			WebSecurityEnabled: true,
+		},
+	}
+}
+
+// Fail causes subsequent calls to method to return err.
+// Pass a nil error to clear the failure.
+func (p *Page) Fail(method string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		delete(p.errs, method)
+		return
+	}
+	p.errs[method] = err
+}
+
+// Calls returns a copy of all calls made on the page, in order.
+func (p *Page) Calls() []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Call(nil), p.calls...)
+}
+
+// Called returns the number of times method has been called.
+func (p *Page) Called(method string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var n int
+	for _, c := range p.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Closed returns true if the page has been closed.
+func (p *Page) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// UploadedFile returns the filename uploaded to selector by UploadFile.
+func (p *Page) UploadedFile(selector string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.uploadedFiles[selector]
+}
+
+// InjectedScripts returns the filenames passed to InjectJS and URLs passed to IncludeJS.
+func (p *Page) InjectedScripts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.injectedScripts...)
+}
+
+// record logs a call and returns any injected failure.
+// The caller must hold the lock.
+func (p *Page) record(method string, args ...interface{}) error {
+	p.calls = append(p.calls, Call{Method: method, Args: args})
+	if err := p.errs[method]; err != nil {
+		return err
+	} else if p.closed && method != "Close" {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Open loads the content for url from OpenFunc or Browser.Sites.
+func (p *Page) Open(url string) error {
+	p.mu.Lock()
+	if err := p.record("Open", url); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	fn := p.OpenFunc
+	p.mu.Unlock()
+
+	var content string
+	if fn != nil {
+		var err error
+		if content, err = fn(url); err != nil {
+			return err
+		}
+	} else {
+		var ok bool
+		if content, ok = p.browser.site(url); !ok {
+			return errors.New("failed")
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.navigate(url, content)
+	return nil
+}
+
+// navigate pushes a new history entry. The caller must hold the lock.
+func (p *Page) navigate(url, content string) {
+	p.history = append(p.history[:p.index+1], url)
+	p.index = len(p.history) - 1
+	p.url, p.content = url, content
+}
+
+// Close marks the page as closed.
+func (p *Page) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("Close"); err != nil {
+		return err
+	}
+	p.closed = true
+	return nil
+}
+
+// CanGoBack returns true if there is a previous history entry.
+func (p *Page) CanGoBack() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("CanGoBack"); err != nil {
+		return false, err
+	}
+	return p.index > 0, nil
+}
+
+// CanGoForward returns true if there is a next history entry.
+func (p *Page) CanGoForward() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("CanGoForward"); err != nil {
+		return false, err
+	}
+	return p.index < len(p.history)-1, nil
+}
+
+// GoBack navigates back to the previous page.
+func (p *Page) GoBack() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("GoBack"); err != nil {
+		return err
+	}
+	p.goTo(p.index - 1)
+	return nil
+}
+
+// GoForward navigates to the next page.
+func (p *Page) GoForward() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("GoForward"); err != nil {
+		return err
+	}
+	p.goTo(p.index + 1)
+	return nil
+}
+
+// Go navigates to the page in history by relative offset.
+func (p *Page) Go(index int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("Go", index); err != nil {
+		return err
+	}
+	p.goTo(p.index + index)
+	return nil
+}
+
+// goTo moves to a history index, if it exists. The caller must hold the lock.
+func (p *Page) goTo(i int) {
+	if i < 0 || i >= len(p.history) {
+		return
+	}
+	p.index, p.url = i, p.history[i]
+	p.content, _ = p.browser.site(p.url)
+}
+
+// Reload records the call.
+func (p *Page) Reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("Reload")
+}
+
+// Stop records the call.
+func (p *Page) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("Stop")
+}
+
+// ClipRect returns the clipping rectangle.
+func (p *Page) ClipRect() (phantomjs.Rect, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.clipRect, p.record("ClipRect")
+}
+
+// SetClipRect sets the clipping rectangle.
+func (p *Page) SetClipRect(rect phantomjs.Rect) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetClipRect", rect); err != nil {
+		return err
+	}
+	p.clipRect = rect
+	return nil
+}
+
+// Content returns the page content.
+func (p *Page) Content() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.content, p.record("Content")
+}
+
+// SetContent sets the page content.
+func (p *Page) SetContent(content string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetContent", content); err != nil {
+		return err
+	}
+	p.content = content
+	return nil
+}
+
+// SetContentAndURL sets the page content and URL.
+func (p *Page) SetContentAndURL(content, url string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetContentAndURL", content, url); err != nil {
+		return err
+	}
+	p.navigate(url, content)
+	return nil
+}
+
+// PlainText returns the text of the content with tags removed.
+func (p *Page) PlainText() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return plainText(p.content), p.record("PlainText")
+}
+
+// Title returns the contents of the <title> element.
+func (p *Page) Title() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return title(p.content), p.record("Title")
+}
+
+// URL returns the current URL.
+func (p *Page) URL() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.url, p.record("URL")
+}
+
+// WindowName returns an empty window name.
+func (p *Page) WindowName() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return "", p.record("WindowName")
+}
+
+// Cookies returns the cookies set on the page.
+func (p *Page) Cookies() ([]*http.Cookie, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*http.Cookie(nil), p.cookies...), p.record("Cookies")
+}
+
+// SetCookies replaces the cookies on the page.
+func (p *Page) SetCookies(cookies []*http.Cookie) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetCookies", cookies); err != nil {
+		return err
+	}
+	p.cookies = append([]*http.Cookie(nil), cookies...)
+	return nil
+}
+
+// AddCookie adds a cookie, replacing any cookie with the same name.
+func (p *Page) AddCookie(cookie *http.Cookie) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("AddCookie", cookie); err != nil {
+		return false, err
+	}
+	p.deleteCookie(cookie.Name)
+	p.cookies = append(p.cookies, cookie)
+	return true, nil
+}
+
+// DeleteCookie removes a cookie by name.
+func (p *Page) DeleteCookie(name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("DeleteCookie", name); err != nil {
+		return false, err
+	}
+	return p.deleteCookie(name), nil
+}
+
+// deleteCookie removes a cookie by name. The caller must hold the lock.
+func (p *Page) deleteCookie(name string) bool {
+	for i, c := range p.cookies {
+		if c.Name == name {
+			p.cookies = append(p.cookies[:i:i], p.cookies[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearCookies removes all cookies.
+func (p *Page) ClearCookies() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("ClearCookies"); err != nil {
+		return err
+	}
+	p.cookies = nil
+	return nil
+}
+
+// CustomHeaders returns the custom headers.
+func (p *Page) CustomHeaders() (http.Header, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	hdr := make(http.Header)
+	for key := range p.headers {
+		hdr.Set(key, p.headers.Get(key))
+	}
+	return hdr, p.record("CustomHeaders")
+}
+
+// SetCustomHeaders sets the custom headers.
+func (p *Page) SetCustomHeaders(header http.Header) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetCustomHeaders", header); err != nil {
+		return err
+	}
+	p.headers = make(http.Header)
+	for key := range header {
+		p.headers.Set(key, header.Get(key))
+	}
+	return nil
+}
+
+// FocusedFrameName returns an empty frame name.
+func (p *Page) FocusedFrameName() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return "", p.record("FocusedFrameName")
+}
+
+// FrameContent returns the frame content.
+func (p *Page) FrameContent() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.frameContent, p.record("FrameContent")
+}
+
+// SetFrameContent sets the frame content.
+func (p *Page) SetFrameContent(content string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetFrameContent", content); err != nil {
+		return err
+	}
+	p.frameContent = content
+	return nil
+}
+
+// FrameName returns an empty frame name.
+func (p *Page) FrameName() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return "", p.record("FrameName")
+}
+
+// FramePlainText returns the plain text of the frame content.
+func (p *Page) FramePlainText() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return plainText(p.frameContent), p.record("FramePlainText")
+}
+
+// FrameTitle returns the title of the frame content.
+func (p *Page) FrameTitle() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return title(p.frameContent), p.record("FrameTitle")
+}
+
+// FrameURL returns the page URL.
+func (p *Page) FrameURL() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.url, p.record("FrameURL")
+}
+
+// FrameCount returns zero.
+func (p *Page) FrameCount() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return 0, p.record("FrameCount")
+}
+
+// FrameNames returns no frame names.
+func (p *Page) FrameNames() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return nil, p.record("FrameNames")
+}
+
+// SwitchToFocusedFrame records the call.
+func (p *Page) SwitchToFocusedFrame() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("SwitchToFocusedFrame")
+}
+
+// SwitchToFrameName records the call.
+func (p *Page) SwitchToFrameName(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("SwitchToFrameName", name)
+}
+
+// SwitchToFramePosition records the call.
+func (p *Page) SwitchToFramePosition(pos int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("SwitchToFramePosition", pos)
+}
+
+// SwitchToMainFrame records the call.
+func (p *Page) SwitchToMainFrame() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("SwitchToMainFrame")
+}
+
+// SwitchToParentFrame records the call.
+func (p *Page) SwitchToParentFrame() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("SwitchToParentFrame")
+}
+
+// LibraryPath returns the library path.
+func (p *Page) LibraryPath() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.libraryPath, p.record("LibraryPath")
+}
+
+// SetLibraryPath sets the library path.
+func (p *Page) SetLibraryPath(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetLibraryPath", path); err != nil {
+		return err
+	}
+	p.libraryPath = path
+	return nil
+}
+
+// NavigationLocked returns whether navigation is locked.
+func (p *Page) NavigationLocked() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.navLocked, p.record("NavigationLocked")
+}
+
+// SetNavigationLocked sets whether navigation is locked.
+func (p *Page) SetNavigationLocked(value bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetNavigationLocked", value); err != nil {
+		return err
+	}
+	p.navLocked = value
+	return nil
+}
+
+// OfflineStoragePath returns an empty path.
+func (p *Page) OfflineStoragePath() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return "", p.record("OfflineStoragePath")
+}
+
+// OfflineStorageQuota returns zero.
+func (p *Page) OfflineStorageQuota() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return 0, p.record("OfflineStorageQuota")
+}
+
+// OwnsPages returns whether the page owns child pages.
+func (p *Page) OwnsPages() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ownsPages, p.record("OwnsPages")
+}
+
+// SetOwnsPages sets whether the page owns child pages.
+func (p *Page) SetOwnsPages(v bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetOwnsPages", v); err != nil {
+		return err
+	}
+	p.ownsPages = v
+	return nil
+}
+
+// PageWindowNames returns no window names.
+func (p *Page) PageWindowNames() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return nil, p.record("PageWindowNames")
+}
+
+// PaperSize returns the paper size.
+func (p *Page) PaperSize() (phantomjs.PaperSize, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paperSize, p.record("PaperSize")
+}
+
+// SetPaperSize sets the paper size.
+func (p *Page) SetPaperSize(size phantomjs.PaperSize) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetPaperSize", size); err != nil {
+		return err
+	}
+	p.paperSize = size
+	return nil
+}
+
+// ScrollPosition returns the scroll position.
+func (p *Page) ScrollPosition() (phantomjs.Position, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.scrollPosition, p.record("ScrollPosition")
+}
+
+// SetScrollPosition sets the scroll position.
+func (p *Page) SetScrollPosition(pos phantomjs.Position) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetScrollPosition", pos); err != nil {
+		return err
+	}
+	p.scrollPosition = pos
+	return nil
+}
+
+// Settings returns the page settings.
+func (p *Page) Settings() (phantomjs.WebPageSettings, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.settings, p.record("Settings")
+}
+
+// SetSettings sets the page settings.
+func (p *Page) SetSettings(settings phantomjs.WebPageSettings) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetSettings", settings); err != nil {
+		return err
+	}
+	p.settings = settings
+	return nil
+}
+
+// ViewportSize returns the viewport size. Defaults to 400x300.
+func (p *Page) ViewportSize() (width, height int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.viewportWidth, p.viewportHeight, p.record("ViewportSize")
+}
+
+// SetViewportSize sets the viewport size.
+func (p *Page) SetViewportSize(width, height int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetViewportSize", width, height); err != nil {
+		return err
+	}
+	p.viewportWidth, p.viewportHeight = width, height
+	return nil
+}
+
+// ZoomFactor returns the zoom factor.
+func (p *Page) ZoomFactor() (float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.zoomFactor, p.record("ZoomFactor")
+}
+
+// SetZoomFactor sets the zoom factor.
+func (p *Page) SetZoomFactor(factor float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("SetZoomFactor", factor); err != nil {
+		return err
+	}
+	p.zoomFactor = factor
+	return nil
+}
+
+// Evaluate returns the canned result for script.
+func (p *Page) Evaluate(script string) (interface{}, error) {
+	return p.evaluate("Evaluate", script)
+}
+
+// EvaluateJavaScript returns the canned result for script.
+func (p *Page) EvaluateJavaScript(script string) (interface{}, error) {
+	return p.evaluate("EvaluateJavaScript", script)
+}
+
+// EvaluateAsync records the call. The script is not executed.
+func (p *Page) EvaluateAsync(script string, delay time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("EvaluateAsync", script, delay)
+}
+
+// evaluate looks up a canned result in Results, then EvaluateFunc.
+func (p *Page) evaluate(method, script string) (interface{}, error) {
+	p.mu.Lock()
+	if err := p.record(method, script); err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+	v, ok := p.Results[script]
+	fn := p.EvaluateFunc
+	p.mu.Unlock()
+
+	if ok {
+		return v, nil
+	} else if fn != nil {
+		return fn(script)
+	}
+	return nil, nil
+}
+
+// IncludeJS records the script URL.
+func (p *Page) IncludeJS(url string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("IncludeJS", url); err != nil {
+		return err
+	}
+	p.injectedScripts = append(p.injectedScripts, url)
+	return nil
+}
+
+// InjectJS records the script filename.
+func (p *Page) InjectJS(filename string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("InjectJS", filename); err != nil {
+		return err
+	}
+	p.injectedScripts = append(p.injectedScripts, filename)
+	return nil
+}
+
+// Render writes a blank image the size of the viewport to filename.
+// PDF renders write a minimal placeholder document.
+func (p *Page) Render(filename, format string, quality int) error {
+	p.mu.Lock()
+	if err := p.record("Render", filename, format, quality); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	w, h := p.viewportWidth, p.viewportHeight
+	p.mu.Unlock()
+
+	if strings.EqualFold(format, "pdf") {
+		return ioutil.WriteFile(filename, []byte("%PDF-1.4\n%%EOF\n"), 0666)
+	}
+
+	buf, err := blankPNG(w, h)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, buf, 0666)
+}
+
+// RenderBase64 returns a blank PNG the size of the viewport.
+func (p *Page) RenderBase64(format string) (string, error) {
+	p.mu.Lock()
+	if err := p.record("RenderBase64", format); err != nil {
+		p.mu.Unlock()
+		return "", err
+	}
+	w, h := p.viewportWidth, p.viewportHeight
+	p.mu.Unlock()
+
+	buf, err := blankPNG(w, h)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// SendMouseEvent records the call.
+func (p *Page) SendMouseEvent(eventType string, mouseX, mouseY int, button string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("SendMouseEvent", eventType, mouseX, mouseY, button)
+}
+
+// SendKeyboardEvent records the call.
+func (p *Page) SendKeyboardEvent(eventType string, key string, modifier int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.record("SendKeyboardEvent", eventType, key, modifier)
+}
+
+// UploadFile records the filename for selector.
+func (p *Page) UploadFile(selector, filename string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.record("UploadFile", selector, filename); err != nil {
+		return err
+	}
+	p.uploadedFiles[selector] = filename
+	return nil
+}
+
+var (
+	titleRegexp = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	tagRegexp   = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>|<[^>]*>`)
+	spaceRegexp = regexp.MustCompile(`\s+`)
+)
+
+// title returns the text within the <title> element of content.
+func title(content string) string {
+	m := titleRegexp.FindStringSubmatch(content)
+	if m == nil {
+		return ""
+	}
+	return html.UnescapeString(strings.TrimSpace(m[1]))
+}
+
+// plainText returns content with tags removed and whitespace collapsed.
+func plainText(content string) string {
+	s := tagRegexp.ReplaceAllString(content, " ")
+	return html.UnescapeString(strings.TrimSpace(spaceRegexp.ReplaceAllString(s, " ")))
+}
+
+// blankPNG returns an encoded white PNG image.
+func blankPNG(width, height int) ([]byte, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("fake: invalid viewport size: %dx%d", width, height)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
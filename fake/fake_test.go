@@ -0,0 +1,70 @@
+package fake_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/fake"
+)
+
+// Ensure a fake page serves content from the browser's sites.
+func TestPage_Open(t *testing.T) {
+	b := fake.NewBrowser()
+	b.Sites["http://example.com"] = `<html><head><title>Example</title></head><body><p>Hello &amp; welcome</p></body></html>`
+
+	var browser phantomjs.Browser = b
+	page, err := browser.CreatePage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := page.Open("http://example.com"); err != nil {
+		t.Fatal(err)
+	} else if title, _ := page.Title(); title != "Example" {
+		t.Fatalf("unexpected title: %q", title)
+	} else if text, _ := page.PlainText(); text != "Hello & welcome" {
+		t.Fatalf("unexpected text: %q", text)
+	} else if u, _ := page.URL(); u != "http://example.com" {
+		t.Fatalf("unexpected url: %q", u)
+	}
+
+	// Unknown URLs fail.
+	if err := page.Open("http://unknown.com"); err == nil || err.Error() != "failed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure canned evaluate results and injected failures are returned.
+func TestPage_Evaluate(t *testing.T) {
+	page := fake.NewPage()
+	page.Results["function() { return 1 }"] = float64(1)
+	page.EvaluateFunc = func(script string) (interface{}, error) { return "fallback", nil }
+
+	if v, err := page.Evaluate("function() { return 1 }"); err != nil {
+		t.Fatal(err)
+	} else if v != float64(1) {
+		t.Fatalf("unexpected value: %#v", v)
+	}
+	if v, _ := page.Evaluate("function() { return 2 }"); v != "fallback" {
+		t.Fatalf("unexpected value: %#v", v)
+	}
+
+	errMarker := errors.New("marker")
+	page.Fail("Evaluate", errMarker)
+	if _, err := page.Evaluate("function() {}"); err != errMarker {
+		t.Fatalf("unexpected error: %v", err)
+	} else if n := page.Called("Evaluate"); n != 3 {
+		t.Fatalf("unexpected call count: %d", n)
+	}
+}
+
+// Ensure closed pages reject further calls.
+func TestPage_Close(t *testing.T) {
+	page := fake.NewPage()
+	if err := page.Close(); err != nil {
+		t.Fatal(err)
+	} else if _, err := page.Content(); err != fake.ErrClosed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package phantomjs
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Favicon represents an icon of a page retrieved by Favicons.
+type Favicon struct {
+	// Absolute URL of the icon.
+	URL string
+
+	// Link relation, such as "icon" or "apple-touch-icon", and the declared
+	// sizes, such as "32x32". Rel is empty for the /favicon.ico fallback.
+	Rel   string
+	Sizes string
+
+	// Media type from the response's Content-Type header, or sniffed from
+	// the data if the header is missing or generic.
+	MIMEType string
+
+	Data []byte
+}
+
+// Favicons returns the icons declared with <link rel="icon">, "shortcut icon"
+// and "apple-touch-icon" in document order. If the page declares none then
+// /favicon.ico of the page's origin is tried instead.
+//
+// Icons are fetched with Fetch, so they are requested with the page's
+// cookies and session and are subject to its same-origin policy; icons on
+// other origins are only returned when the page's WebSecurityEnabled setting
+// is false. Icons that fail to load or respond with an error status are
+// skipped, but timeouts and cancellation of the page's context are returned.
+func (p *WebPage) Favicons() ([]Favicon, error) {
+	var links []struct {
+		Href  string `json:"href"`
+		Rel   string `json:"rel"`
+		Sizes string `json:"sizes"`
+	}
+	if err := p.evaluateInto(faviconsScript, &links); err != nil {
+		return nil, err
+	}
+
+	var icons []Favicon
+	for _, link := range links {
+		resp, err := p.Fetch(link.Href, nil)
+		switch {
+		case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrTimeout), errors.Is(err, ErrScriptTimeout):
+			return nil, err
+		case err != nil, resp.StatusCode < 200 || resp.StatusCode >= 300, len(resp.Body) == 0:
+			continue
+		}
+		icons = append(icons, Favicon{
+			URL:      link.Href,
+			Rel:      link.Rel,
+			Sizes:    link.Sizes,
+			MIMEType: faviconType(resp),
+			Data:     resp.Body,
+		})
+	}
+	return icons, nil
+}
+
+// faviconType returns the media type of an icon response.
+func faviconType(resp *FetchResponse) string {
+	if typ, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && strings.HasPrefix(typ, "image/") {
+		return typ
+	}
+	typ, _, _ := mime.ParseMediaType(http.DetectContentType(resp.Body))
+	return typ
+}
+
+// faviconsScript returns the page's icon links, or the /favicon.ico fallback
+// if there are none. Duplicate URLs are skipped.
+const faviconsScript = `function() {
+	var icons = [], seen = {};
+	var links = document.querySelectorAll('link[rel][href]');
+	for (var i = 0; i < links.length; i++) {
+		var rels = links[i].getAttribute('rel').toLowerCase().split(/\s+/);
+		var rel = '';
+		for (var j = 0; j < rels.length; j++) {
+			if (rels[j] === 'icon' || rels[j] === 'apple-touch-icon' || rels[j] === 'apple-touch-icon-precomposed') rel = rels[j];
+		}
+		if (!rel || seen[links[i].href]) continue;
+		seen[links[i].href] = true;
+		icons.push({href: links[i].href, rel: rel, sizes: links[i].getAttribute('sizes') || ''});
+	}
+	if (!icons.length && /^https?:$/.test(location.protocol)) {
+		icons.push({href: location.protocol + '//' + location.host + '/favicon.ico', rel: '', sizes: ''});
+	}
+	return icons;
+}`
@@ -0,0 +1,63 @@
+package phantomjs
+
+// Feed formats reported in Feed.Format.
+const (
+	FeedRSS  = "rss"
+	FeedAtom = "atom"
+	FeedJSON = "json"
+)
+
+// Feed represents a feed link declared by a page.
+type Feed struct {
+	// Absolute URL of the feed.
+	URL string
+
+	// Format of the feed, such as FeedRSS, and its declared media type.
+	Format string
+	Type   string
+
+	// Title attribute of the link, such as "Comments Feed". May be empty.
+	Title string
+}
+
+// FeedDiscovery represents the feeds and canonical URL of a page.
+type FeedDiscovery struct {
+	// Absolute URL from <link rel="canonical">. Empty if not declared.
+	CanonicalURL string
+
+	// Feeds declared with <link rel="alternate"> in document order.
+	// Duplicate URLs are skipped.
+	Feeds []Feed
+}
+
+// DiscoverFeeds returns the RSS, Atom and JSON Feed links and the canonical
+// URL of the page. They are read from the current DOM, so links added by
+// scripts after the page loads are included.
+func (p *WebPage) DiscoverFeeds() (*FeedDiscovery, error) {
+	var d FeedDiscovery
+	if err := p.evaluateInto(feedsScript, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// feedsScript returns the page's canonical URL and feed links.
+const feedsScript = `function() {
+	var formats = {
+		'application/rss+xml': 'rss', 'application/rdf+xml': 'rss', 'application/atom+xml': 'atom',
+		'application/feed+json': 'json'
+	};
+
+	var canonical = '', feeds = [], seen = {};
+	var links = document.querySelectorAll('link[rel][href]');
+	for (var i = 0; i < links.length; i++) {
+		var link = links[i], rel = ' ' + link.getAttribute('rel').toLowerCase().replace(/\s+/g, ' ') + ' ';
+		if (rel.indexOf(' canonical ') !== -1 && !canonical) canonical = link.href;
+
+		var type = (link.getAttribute('type') || '').toLowerCase().split(';')[0].replace(/^\s+|\s+$/g, '');
+		if ((rel.indexOf(' alternate ') === -1 && rel.indexOf(' feed ') === -1) || !formats[type] || seen[link.href]) continue;
+		seen[link.href] = true;
+		feeds.push({url: link.href, format: formats[type], type: type, title: link.getAttribute('title') || ''});
+	}
+	return {canonicalURL: canonical, feeds: feeds};
+}`
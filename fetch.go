@@ -0,0 +1,128 @@
+package phantomjs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FetchOptions represents the options of a request made by WebPage.Fetch.
+type FetchOptions struct {
+	// HTTP method. Defaults to GET.
+	Method string
+
+	// Request headers. Headers the browser controls, such as Cookie and
+	// Origin, cannot be set.
+	Header http.Header
+
+	// Request body, sent as is.
+	Body string
+}
+
+// FetchResponse represents the response to a request made by WebPage.Fetch.
+type FetchResponse struct {
+	StatusCode int
+	Status     string // e.g. "200 OK"
+	Header     http.Header
+	Body       []byte
+}
+
+// JSON decodes the response body into v.
+func (r *FetchResponse) JSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Fetch makes an HTTP request from within the page's current document, so it
+// carries the page's cookies, origin and credentials as a request made by the
+// page's own scripts would. This is useful for JSON endpoints that require
+// the page's session. opts may be nil.
+//
+// The request is subject to the page's same-origin policy. It blocks the
+// page's scripts until the response is received and is bounded by the
+// process' ScriptTimeout. A response with an error status is returned without
+// an error; a request that fails, such as one blocked by the same-origin
+// policy, returns an error.
+func (p *WebPage) Fetch(url string, opts *FetchOptions) (*FetchResponse, error) {
+	req := map[string]interface{}{"url": url, "method": "GET", "headers": map[string]string{}, "body": nil}
+	if opts != nil {
+		if opts.Method != "" {
+			req["method"] = opts.Method
+		}
+		headers := make(map[string]string, len(opts.Header))
+		for k, v := range opts.Header {
+			headers[k] = strings.Join(v, ", ")
+		}
+		req["headers"] = headers
+		if opts.Body != "" {
+			req["body"] = opts.Body
+		}
+	}
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var v struct {
+		Status     int    `json:"status"`
+		StatusText string `json:"statusText"`
+		Headers    string `json:"headers"`
+		Body       string `json:"body"`
+		Error      string `json:"error"`
+	}
+	if err := p.evaluateInto(fmt.Sprintf(fetchScript, buf), &v); err != nil {
+		return nil, err
+	} else if v.Error != "" {
+		return nil, errors.New("fetch failed: " + v.Error)
+	} else if v.Status == 0 {
+		return nil, errors.New("fetch failed: request blocked or aborted")
+	}
+
+	body, err := base64.StdEncoding.DecodeString(v.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &FetchResponse{StatusCode: v.Status, Status: fmt.Sprintf("%d %s", v.Status, v.StatusText), Header: parseFetchHeaders(v.Headers), Body: body}, nil
+}
+
+// parseFetchHeaders parses the headers returned by getAllResponseHeaders().
+func parseFetchHeaders(s string) http.Header {
+	h := make(http.Header)
+	for _, line := range strings.Split(s, "\n") {
+		if i := strings.Index(line, ":"); i > 0 {
+			h.Add(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+		}
+	}
+	return h
+}
+
+// fetchScript makes a synchronous XMLHttpRequest and returns the response
+// with its body as base64. The response is read as bytes so that binary and
+// non-UTF-8 bodies are returned unchanged.
+const fetchScript = `function() {
+	var req = %s;
+	var xhr = new XMLHttpRequest();
+	try {
+		xhr.open(req.method, req.url, false);
+		for (var name in req.headers) xhr.setRequestHeader(name, req.headers[name]);
+		xhr.overrideMimeType('text/plain; charset=x-user-defined');
+		xhr.send(req.body);
+	} catch (e) {
+		return {error: String(e.message || e)};
+	}
+
+	var text = xhr.responseText || '', bytes = [];
+	for (var i = 0; i < text.length; i += 8192) {
+		var chunk = [];
+		for (var j = i; j < Math.min(i + 8192, text.length); j++) chunk.push(text.charCodeAt(j) & 0xff);
+		bytes.push(String.fromCharCode.apply(null, chunk));
+	}
+	return {
+		status: xhr.status,
+		statusText: xhr.statusText,
+		headers: xhr.getAllResponseHeaders() || '',
+		body: btoa(bytes.join('')),
+	};
+}`
@@ -0,0 +1,147 @@
+package phantomjs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// DefaultFontFamilies are the families probed by ListFonts if none are given.
+// They cover the fonts commonly requested by stylesheets and the fonts that
+// Linux servers usually provide in their place.
+var DefaultFontFamilies = []string{
+	"Arial", "Helvetica", "Helvetica Neue", "Verdana", "Tahoma", "Trebuchet MS",
+	"Segoe UI", "Times New Roman", "Times", "Georgia", "Garamond",
+	"Courier New", "Courier", "Consolas", "Menlo", "Monaco",
+	"DejaVu Sans", "DejaVu Serif", "DejaVu Sans Mono",
+	"Liberation Sans", "Liberation Serif", "Liberation Mono",
+	"Noto Sans", "Noto Serif", "Noto Sans Mono", "Noto Color Emoji",
+	"Noto Sans CJK SC", "Noto Sans CJK JP", "WenQuanYi Micro Hei",
+	"Roboto", "Open Sans", "Lato", "Ubuntu", "Cantarell", "FreeSans", "FreeSerif",
+}
+
+// FontFallback represents a font family requested by a page that is not
+// available, so its text is rendered with a fallback font.
+type FontFallback struct {
+	// Requested family, as written in the page's CSS.
+	Family string
+
+	// Number of elements with text whose primary family is Family.
+	Elements int
+}
+
+// ListFonts returns the families that are available to the process' pages,
+// in the given order. If no families are given, DefaultFontFamilies are
+// probed. Availability is probed on a scratch page by measuring text drawn
+// on a canvas, so it reflects the fonts PhantomJS actually uses, including
+// fontconfig's configuration. Families that fontconfig substitutes with a
+// generic font are reported as unavailable.
+func (p *Process) ListFonts(families ...string) ([]string, error) {
+	if len(families) == 0 {
+		families = DefaultFontFamilies
+	}
+	buf, err := json.Marshal(families)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Ref refJSON `json:"ref"`
+	}
+	if err := p.doJSON(context.Background(), "POST", "/webpage/Create", nil, &resp); err != nil {
+		return nil, err
+	}
+	page := &WebPage{ref: newRef(p, resp.Ref.ID)}
+	defer page.Close()
+
+	var available []string
+	if err := page.evaluateInto(fmt.Sprintf(listFontsScript, buf), &available); err != nil {
+		return nil, err
+	}
+	return available, nil
+}
+
+// MissingFonts returns the font families requested by the elements of the
+// page that are not available, ordered by the number of elements using them.
+// Generic families, such as sans-serif, are never reported. A family is
+// requested by an element if it is the first family of the element's
+// font-family, so an available family later in the list still counts as a
+// fallback.
+func (p *WebPage) MissingFonts() ([]FontFallback, error) {
+	var fallbacks []FontFallback
+	if err := p.evaluateInto(missingFontsScript, &fallbacks); err != nil {
+		return nil, err
+	}
+	return fallbacks, nil
+}
+
+// checkFonts reports the page's missing fonts to the process'
+// OnFontFallback hook, if set. Failures are logged.
+func (p *WebPage) checkFonts() {
+	fn := p.ref.process.OnFontFallback
+	if fn == nil {
+		return
+	}
+	fallbacks, err := p.MissingFonts()
+	if err != nil {
+		p.ref.process.log(slog.LevelWarn, "phantomjs font check failed", "error", err)
+		return
+	}
+	for _, f := range fallbacks {
+		fn(p, f)
+	}
+}
+
+// fontAvailableFunc is a JavaScript function expression that returns true if
+// a font family is available. The family is drawn in front of each generic
+// family and is available if the text's width changes.
+const fontAvailableFunc = `function fontAvailable(family) {
+	var ctx = document.createElement('canvas').getContext('2d');
+	var text = 'mmmmmmmmmmlli1WwQ@#', name = '"' + family.replace(/["\\]/g, '\\$&') + '"';
+	var generics = ['monospace', 'sans-serif', 'serif'];
+	for (var i = 0; i < generics.length; i++) {
+		ctx.font = '72px ' + generics[i];
+		var width = ctx.measureText(text).width;
+		ctx.font = '72px ' + name + ', ' + generics[i];
+		if (ctx.measureText(text).width !== width) return true;
+	}
+	return false;
+}`
+
+// listFontsScript returns the available families of a list.
+const listFontsScript = `function() {
+	var fontAvailable = ` + fontAvailableFunc + `;
+	var families = %s, available = [];
+	for (var i = 0; i < families.length; i++) {
+		if (fontAvailable(families[i])) available.push(families[i]);
+	}
+	return available;
+}`
+
+// missingFontsScript returns the unavailable primary families of the
+// elements with text, with the number of elements using each.
+const missingFontsScript = `function() {
+	var fontAvailable = ` + fontAvailableFunc + `;
+	var generics = {'serif': 1, 'sans-serif': 1, 'monospace': 1, 'cursive': 1, 'fantasy': 1, 'system-ui': 1};
+	var counts = {}, checked = {}, missing = [];
+	var els = document.body ? [document.body].concat(Array.prototype.slice.call(document.body.getElementsByTagName('*'))) : [];
+	for (var i = 0; i < els.length; i++) {
+		var el = els[i], hasText = false;
+		for (var n = el.firstChild; n; n = n.nextSibling) {
+			if (n.nodeType === 3 && /\S/.test(n.nodeValue)) { hasText = true; break; }
+		}
+		if (!hasText) continue;
+
+		var family = window.getComputedStyle(el).fontFamily.split(',')[0].replace(/^\s*["']?|["']?\s*$/g, '');
+		if (!family || generics[family.toLowerCase()]) continue;
+		if (!(family in checked)) {
+			checked[family] = fontAvailable(family);
+			if (!checked[family]) missing.push(family);
+		}
+		if (!checked[family]) counts[family] = (counts[family] || 0) + 1;
+	}
+
+	missing.sort(function(a, b) { return counts[b] - counts[a] || (a < b ? -1 : 1); });
+	return missing.map(function(family) { return {family: family, elements: counts[family]}; });
+}`
@@ -0,0 +1,86 @@
+package phantomjs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultHealthTimeout is the time allowed for a check by HealthHandler if
+// the request context has no earlier deadline.
+const DefaultHealthTimeout = 5 * time.Second
+
+// healthCheckScript is evaluated on a scratch page by Healthy.
+const healthCheckScript = `function() { return 1 + 1; }`
+
+// Live returns an error if the process' shim does not respond to a ping.
+func (p *Process) Live(ctx context.Context) error {
+	return p.ping(ctx)
+}
+
+// Healthy returns an error if the process cannot do work. Unlike Live, it
+// creates a scratch page and evaluates a script on it, so it also detects a
+// process whose shim responds but whose JavaScript engine is stuck.
+func (p *Process) Healthy(ctx context.Context) error {
+	if err := p.ping(ctx); err != nil {
+		return err
+	}
+
+	var resp struct {
+		Ref refJSON `json:"ref"`
+	}
+	if err := p.doJSON(ctx, "POST", "/webpage/Create", nil, &resp); err != nil {
+		return err
+	}
+	page := (&WebPage{ref: newRef(p, resp.Ref.ID)}).WithContext(ctx)
+	defer page.WithContext(context.Background()).Close()
+
+	v, err := page.Evaluate(healthCheckScript)
+	if err != nil {
+		return err
+	} else if v != float64(2) {
+		return fmt.Errorf("unexpected health check result: %v", v)
+	}
+	return nil
+}
+
+// Live returns an error if any process of the pool does not respond.
+func (p *Pool) Live(ctx context.Context) error {
+	for _, process := range p.processes {
+		if err := process.Live(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Healthy returns an error if any process of the pool cannot do work.
+func (p *Pool) Healthy(ctx context.Context) error {
+	for _, process := range p.processes {
+		if err := process.Healthy(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HealthHandler returns an HTTP handler for liveness and readiness probes.
+// It responds with 200 OK if check succeeds and 503 Service Unavailable with
+// the error otherwise. Use Pool.Live for liveness probes and Pool.Healthy for
+// readiness probes.
+func HealthHandler(check func(context.Context) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), DefaultHealthTimeout)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := check(ctx); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,33 @@
+package phantomjs
+
+// HistoryEntry represents a document in a page's navigation history.
+type HistoryEntry struct {
+	URL   string
+	Title string
+}
+
+// History returns the page's back/forward history and the index of the
+// current entry, or -1 if the page has not navigated.
+//
+// PhantomJS does not expose its history list so it is recorded from URL
+// changes since the page was created. Navigations made with GoBack, GoForward
+// and Go move through the list. A script-driven change to the URL of an
+// adjacent entry is treated as a back or forward navigation.
+func (p *WebPage) History() (entries []HistoryEntry, index int, err error) {
+	var resp struct {
+		Entries []struct {
+			URL   string `json:"url"`
+			Title string `json:"title"`
+		} `json:"entries"`
+		Index int `json:"index"`
+	}
+	if err := p.doJSON("POST", "/webpage/History", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+		return nil, 0, err
+	}
+
+	entries = make([]HistoryEntry, len(resp.Entries))
+	for i, e := range resp.Entries {
+		entries[i] = HistoryEntry{URL: e.URL, Title: e.Title}
+	}
+	return entries, resp.Index, nil
+}
@@ -0,0 +1,122 @@
+// Package images compares screenshots for visual regression tests.
+//
+// Screenshots are compared pixel by pixel. The result reports how much of the
+// image changed and includes an annotated image that highlights the changes:
+//
+//	data, err := page.RenderBase64("PNG")
+//	if err != nil {
+//		return err
+//	}
+//	actual, err := base64.StdEncoding.DecodeString(data)
+//	if err != nil {
+//		return err
+//	}
+//	ok, diff, err := images.Within(golden, actual, 0.5)
+//	if err != nil {
+//		return err
+//	} else if !ok {
+//		png.Encode(w, diff.Image)
+//	}
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+var (
+	// ErrSizeMismatch is returned when the compared images have different
+	// dimensions.
+	ErrSizeMismatch = errors.New("image size mismatch")
+)
+
+// Highlight is the color of differing pixels in Diff.Image.
+var Highlight = color.RGBA{R: 255, A: 255}
+
+// Diff represents the difference between two images.
+type Diff struct {
+	// Number and percentage, from 0 to 100, of pixels that differ.
+	Pixels  int
+	Percent float64
+
+	// Smallest rectangle containing every differing pixel. Empty if the
+	// images are identical.
+	Bounds image.Rectangle
+
+	// Faded copy of the first image with differing pixels painted in
+	// Highlight.
+	Image *image.RGBA
+}
+
+// CompareScreenshots decodes two encoded images, such as PNG screenshots, and
+// compares them. Returns ErrSizeMismatch if their dimensions differ.
+func CompareScreenshots(a, b []byte) (*Diff, error) {
+	imgA, _, err := image.Decode(bytes.NewReader(a))
+	if err != nil {
+		return nil, err
+	}
+	imgB, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return Compare(imgA, imgB)
+}
+
+// Compare compares two decoded images. Returns ErrSizeMismatch if their
+// dimensions differ.
+func Compare(a, b image.Image) (*Diff, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return nil, ErrSizeMismatch
+	}
+
+	rect := image.Rect(0, 0, ab.Dx(), ab.Dy())
+	diff := &Diff{Image: image.NewRGBA(rect)}
+	draw.Draw(diff.Image, rect, a, ab.Min, draw.Src)
+
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			r0, g0, b0, a0 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r1, g1, b1, a1 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			if r0 == r1 && g0 == g1 && b0 == b1 && a0 == a1 {
+				diff.Image.SetRGBA(x, y, fade(diff.Image.RGBAAt(x, y)))
+				continue
+			}
+
+			diff.Pixels++
+			diff.Image.SetRGBA(x, y, Highlight)
+			diff.Bounds = diff.Bounds.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	if !rect.Empty() {
+		diff.Percent = float64(diff.Pixels) * 100 / float64(rect.Dx()*rect.Dy())
+	}
+	return diff, nil
+}
+
+// Within compares two encoded images and reports whether no more than
+// threshold percent of their pixels differ. Images with different dimensions
+// return ErrSizeMismatch.
+func Within(a, b []byte, threshold float64) (bool, *Diff, error) {
+	diff, err := CompareScreenshots(a, b)
+	if err != nil {
+		return false, nil, err
+	}
+	return diff.Percent <= threshold, diff, nil
+}
+
+// fade blends c toward white so highlighted pixels stand out.
+func fade(c color.RGBA) color.RGBA {
+	return color.RGBA{
+		R: c.R/4 + 191,
+		G: c.G/4 + 191,
+		B: c.B/4 + 191,
+		A: 255,
+	}
+}
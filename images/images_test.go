@@ -0,0 +1,68 @@
+package images_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs/images"
+)
+
+// Ensure screenshots are compared and differences are highlighted.
+func TestCompareScreenshots(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	b := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	b.Set(1, 2, color.White)
+	b.Set(3, 3, color.White)
+
+	diff, err := images.CompareScreenshots(MustEncodePNG(a), MustEncodePNG(b))
+	if err != nil {
+		t.Fatal(err)
+	} else if diff.Pixels != 2 || diff.Percent != 12.5 {
+		t.Fatalf("unexpected diff: %d pixels, %v%%", diff.Pixels, diff.Percent)
+	} else if diff.Bounds != image.Rect(1, 2, 4, 4) {
+		t.Fatalf("unexpected bounds: %v", diff.Bounds)
+	} else if diff.Image.RGBAAt(1, 2) != images.Highlight {
+		t.Fatalf("expected highlighted pixel: %v", diff.Image.RGBAAt(1, 2))
+	} else if diff.Image.RGBAAt(0, 0) == images.Highlight {
+		t.Fatal("unexpected highlighted pixel")
+	}
+}
+
+// Ensure images of different sizes cannot be compared.
+func TestCompareScreenshots_ErrSizeMismatch(t *testing.T) {
+	a := MustEncodePNG(image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	b := MustEncodePNG(image.NewRGBA(image.Rect(0, 0, 3, 2)))
+	if _, err := images.CompareScreenshots(a, b); err != images.ErrSizeMismatch {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the threshold is checked against the diff percentage.
+func TestWithin(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	b := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	b.Set(0, 0, color.White)
+
+	if ok, diff, err := images.Within(MustEncodePNG(a), MustEncodePNG(b), 1); err != nil {
+		t.Fatal(err)
+	} else if !ok || diff.Percent != 1 {
+		t.Fatalf("unexpected result: %v, %v%%", ok, diff.Percent)
+	}
+	if ok, _, err := images.Within(MustEncodePNG(a), MustEncodePNG(b), 0.5); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("expected difference above threshold")
+	}
+}
+
+// MustEncodePNG encodes img as a PNG. Panic on error.
+func MustEncodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
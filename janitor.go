@@ -0,0 +1,125 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Janitor records the processes started by Open in a state directory so that
+// processes and shim files left behind by a crashed program can be reaped by
+// a later run. Set Process.Janitor to enable recording.
+type Janitor struct {
+	// Directory that state files are written to. It is created if it does
+	// not exist.
+	Dir string
+}
+
+// NewJanitor returns a janitor that records processes in dir.
+func NewJanitor(dir string) *Janitor {
+	return &Janitor{Dir: dir}
+}
+
+// JanitorEntry represents a process recorded by a Janitor.
+type JanitorEntry struct {
+	// Process ID of PhantomJS and of the program that started it.
+	PID   int `json:"pid"`
+	Owner int `json:"owner"`
+
+	// Temporary directory holding the shim script.
+	Path string `json:"path"`
+
+	BinPath string    `json:"binPath"`
+	Port    int       `json:"port"`
+	Started time.Time `json:"started"`
+}
+
+// Reap kills the recorded processes whose owning program is no longer
+// running, removes their shim directories and state files, and returns the
+// entries that were reaped. Processes are only killed if they still appear
+// to be the recorded PhantomJS process, since the PID may have been reused.
+// Call Reap at startup, before opening processes.
+func (j *Janitor) Reap() ([]*JanitorEntry, error) {
+	fis, err := ioutil.ReadDir(j.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var a []*JanitorEntry
+	for _, fi := range fis {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(j.Dir, fi.Name())
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return a, err
+		}
+
+		var e JanitorEntry
+		if err := json.Unmarshal(buf, &e); err != nil {
+			// Remove files that were only partially written.
+			os.Remove(path)
+			continue
+		}
+
+		// Leave processes whose owner is still running.
+		if e.Owner == os.Getpid() || processRunning(e.Owner) {
+			continue
+		}
+
+		if processRunning(e.PID) && isShimProcess(&e) {
+			if err := killOrphan(e.PID); err != nil {
+				return a, err
+			}
+		}
+		if e.Path != "" && strings.HasPrefix(filepath.Base(e.Path), "phantomjs-") {
+			if err := os.RemoveAll(e.Path); err != nil {
+				return a, err
+			}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return a, err
+		}
+		a = append(a, &e)
+	}
+	return a, nil
+}
+
+// record writes the state file of a started process.
+func (j *Janitor) record(p *Process) error {
+	if err := os.MkdirAll(j.Dir, 0700); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(&JanitorEntry{
+		PID:     p.cmd.Process.Pid,
+		Owner:   os.Getpid(),
+		Path:    p.path,
+		BinPath: p.BinPath,
+		Port:    p.Port,
+		Started: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(j.statePath(p.cmd.Process.Pid), buf, 0600)
+}
+
+// forget removes the state file of a stopped process.
+func (j *Janitor) forget(pid int) error {
+	if err := os.Remove(j.statePath(pid)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// statePath returns the path of a process' state file.
+func (j *Janitor) statePath(pid int) string {
+	return filepath.Join(j.Dir, strconv.Itoa(pid)+".json")
+}
@@ -0,0 +1,562 @@
+// Package jobs implements a queue of render jobs that are scheduled across a
+// page pool by priority, with per-job timeouts and retries.
+//
+//	q := jobs.NewQueue(pool)
+//	if err := q.Open(); err != nil {
+//		log.Fatal(err)
+//	}
+//	defer q.Close()
+//
+//	q.Submit(&jobs.Job{URL: "https://example.com", Output: jobs.Output{Format: jobs.FormatPNG}})
+//	for result := range q.Results() {
+//		...
+//	}
+package jobs
+
+import (
+	"container/heap"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/rendercache"
+)
+
+// Default settings.
+const (
+	DefaultTimeout     = 30 * time.Second
+	DefaultRetryDelay  = 1 * time.Second
+	DefaultResultsSize = 64
+)
+
+// Output formats.
+const (
+	FormatHTML = "html"
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatPDF  = "pdf"
+)
+
+var (
+	// ErrQueueClosed is returned when submitting to a closed queue. It is also
+	// set on the results of jobs still pending when the queue is closed.
+	ErrQueueClosed = errors.New("queue closed")
+
+	// ErrInvalidJob is returned when submitting a job without a URL or with an
+	// unknown output format.
+	ErrInvalidJob = errors.New("invalid job")
+)
+
+// Job represents a request to render a URL.
+type Job struct {
+	// Unique identifier. Assigned by Submit if blank.
+	ID string
+
+	// URL to render.
+	URL string
+
+	// Format and settings of the rendered output.
+	Output Output
+
+	// Time to wait after the page loads before rendering.
+	Wait time.Duration
+
+	// Maximum time for a single attempt. Defaults to the queue's timeout.
+	Timeout time.Duration
+
+	// Jobs with higher priorities are started first. Jobs with equal
+	// priorities are started in the order they were submitted.
+	Priority int
+
+	// Number of times a failed job is retried.
+	MaxRetries int
+
+	// If set, the job's result is posted to this URL as a WebhookPayload
+	// when the job completes or fails.
+	CallbackURL string
+
+	// Name of the stored output when the queue has a sink. Defaults to the
+	// job ID followed by the output format's file extension.
+	Key string
+
+	seq int // submission order
+}
+
+// Output describes the rendered output of a job.
+type Output struct {
+	// One of FormatHTML, FormatPNG, FormatJPEG or FormatPDF.
+	// Defaults to FormatHTML.
+	Format string
+
+	// Image quality (0-100). Defaults to 100.
+	Quality int
+
+	// Viewport size. Uses the page default if zero.
+	ViewportWidth  int
+	ViewportHeight int
+
+	// Paper size used for PDF output.
+	PaperSize *phantomjs.PaperSize
+}
+
+// ContentType returns the MIME type of the output format.
+func (o Output) ContentType() string {
+	switch o.Format {
+	case FormatPNG:
+		return "image/png"
+	case FormatJPEG:
+		return "image/jpeg"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "text/html; charset=utf-8"
+	}
+}
+
+// Result represents the outcome of a job.
+type Result struct {
+	Job *Job
+
+	// Rendered output. Body is empty if Err is set or if the output was
+	// written to a sink.
+	ContentType string
+	Body        []byte
+	Size        int
+
+	// Number of attempts made, including the last.
+	Attempts int
+
+	// Time the first attempt started and the last attempt finished.
+	Started  time.Time
+	Finished time.Time
+
+	// Error from the last attempt, if the job failed.
+	Err error
+
+	// Location of the stored output, if the output was stored.
+	Location string
+
+	// Status of the output in the queue's cache, such as
+	// rendercache.StatusHit. Blank if the queue has no cache.
+	CacheStatus string
+}
+
+// Queue schedules render jobs across a pool.
+type Queue struct {
+	pool    *phantomjs.Pool
+	results chan *Result
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending jobHeap
+	seq     int
+	opened  bool
+	closed  bool
+	wg      sync.WaitGroup
+
+	webhooks sync.WaitGroup
+
+	ctx    context.Context
+	cancel func()
+
+	// Maximum number of jobs rendered at the same time.
+	// Defaults to the pool's maximum page count.
+	Concurrency int
+
+	// Default per-attempt timeout for jobs that do not set one.
+	Timeout time.Duration
+
+	// Time to wait between attempts of a failed job.
+	RetryDelay time.Duration
+
+	// Buffer size of the results channel.
+	ResultsSize int
+
+	// Client used to deliver webhooks. Defaults to http.DefaultClient.
+	WebhookClient *http.Client
+
+	// If set, webhooks are signed with this secret. See Sign.
+	WebhookSecret string
+
+	// Number of times a failed webhook delivery is retried.
+	WebhookRetries int
+
+	// If set, rendered output is written to the sink instead of being
+	// returned in the result's body.
+	Sink Sink
+
+	// If set, jobs with the same URL, output and wait share rendered output
+	// while it is cached. Shared renders are also bounded by the cache's
+	// Timeout.
+	Cache *rendercache.Cache
+
+	// Logger receives errors that cannot be returned to the caller,
+	// such as failed webhook deliveries.
+	Logger *slog.Logger
+}
+
+// NewQueue returns a new queue that renders jobs using pool.
+func NewQueue(pool *phantomjs.Pool) *Queue {
+	q := &Queue{
+		pool:           pool,
+		Timeout:        DefaultTimeout,
+		RetryDelay:     DefaultRetryDelay,
+		ResultsSize:    DefaultResultsSize,
+		WebhookRetries: DefaultWebhookRetries,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Open starts the queue's workers.
+func (q *Queue) Open() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.opened {
+		return errors.New("queue already open")
+	}
+	q.opened = true
+	q.results = make(chan *Result, q.ResultsSize)
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+
+	n := q.Concurrency
+	if n <= 0 {
+		n = q.pool.MaxPages()
+	}
+	for i := 0; i < n; i++ {
+		q.wg.Add(1)
+		go func() { defer q.wg.Done(); q.work() }()
+	}
+	return nil
+}
+
+// Close stops accepting jobs, cancels running jobs and waits for the workers
+// to exit. Jobs that have not started are reported with ErrQueueClosed.
+// The results channel is closed once all results have been sent and Close
+// returns once pending webhooks have been delivered.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if q.closed || !q.opened {
+		q.closed = true
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.cancel()
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	q.wg.Wait()
+
+	// Report jobs that never ran.
+	q.mu.Lock()
+	pending := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+	for _, job := range pending {
+		q.finish(&Result{Job: job, Err: ErrQueueClosed})
+	}
+	close(q.results)
+
+	q.webhooks.Wait()
+	return nil
+}
+
+// Results returns the channel that results are delivered on. Workers block
+// when the channel is full so it must be drained by the caller.
+func (q *Queue) Results() <-chan *Result {
+	return q.results
+}
+
+// Len returns the number of jobs waiting to start.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Submit adds a job to the queue.
+func (q *Queue) Submit(job *Job) error {
+	if job.URL == "" {
+		return ErrInvalidJob
+	} else if job.CallbackURL != "" {
+		if u, err := url.Parse(job.CallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ErrInvalidJob
+		}
+	}
+	switch job.Output.Format {
+	case "":
+		job.Output.Format = FormatHTML
+	case FormatHTML, FormatPNG, FormatJPEG, FormatPDF:
+	default:
+		return ErrInvalidJob
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || !q.opened {
+		return ErrQueueClosed
+	}
+
+	q.seq++
+	job.seq = q.seq
+	if job.ID == "" {
+		job.ID = strconv.Itoa(q.seq)
+	}
+	heap.Push(&q.pending, job)
+	q.cond.Signal()
+	return nil
+}
+
+// work runs jobs until the queue is closed.
+func (q *Queue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		job := heap.Pop(&q.pending).(*Job)
+		q.mu.Unlock()
+
+		q.finish(q.run(job))
+	}
+}
+
+// finish sends a result to its job's webhook and the results channel.
+func (q *Queue) finish(result *Result) {
+	q.notify(result)
+	q.results <- result
+}
+
+// log writes a warning to the queue's logger, if set.
+func (q *Queue) log(msg string, args ...interface{}) {
+	if q.Logger == nil {
+		return
+	}
+	q.Logger.Warn(msg, args...)
+}
+
+// run executes job, retrying failed attempts up to its retry limit.
+func (q *Queue) run(job *Job) *Result {
+	result := &Result{Job: job, Started: time.Now()}
+	for {
+		result.Attempts++
+		result.Err = q.attempt(job, result)
+		if result.Err == nil || result.Attempts > job.MaxRetries || q.ctx.Err() != nil {
+			break
+		}
+
+		// Wait before retrying unless the queue is closing.
+		timer := time.NewTimer(q.RetryDelay)
+		select {
+		case <-timer.C:
+		case <-q.ctx.Done():
+			timer.Stop()
+		}
+	}
+	result.Finished = time.Now()
+	return result
+}
+
+// attempt renders job once within its timeout and stores the output on result.
+func (q *Queue) attempt(job *Job, result *Result) error {
+	result.ContentType, result.Body, result.Size, result.Location, result.CacheStatus = "", nil, 0, "", ""
+
+	ctx, cancel := context.WithTimeout(q.ctx, q.timeout(job))
+	defer cancel()
+
+	var entry *rendercache.Entry
+	var err error
+	if q.Cache != nil {
+		entry, result.CacheStatus, err = q.Cache.Get(ctx, CacheKey(job), func(ctx context.Context) (*rendercache.Entry, error) {
+			return q.render(ctx, job)
+		})
+	} else {
+		entry, err = q.render(ctx, job)
+	}
+	if err != nil {
+		return err
+	}
+
+	body := entry.Body
+	if q.Sink != nil {
+		if result.Location, err = Store(ctx, q.Sink, job, body); err != nil {
+			return err
+		}
+	} else {
+		result.Body = body
+	}
+	result.ContentType, result.Size = job.Output.ContentType(), len(body)
+	return nil
+}
+
+// timeout returns the maximum time of an attempt of job.
+func (q *Queue) timeout(job *Job) time.Duration {
+	if job.Timeout > 0 {
+		return job.Timeout
+	}
+	return q.Timeout
+}
+
+// render checks out a page and renders job on it within the job's timeout,
+// which also bounds renders that the cache runs in the background.
+func (q *Queue) render(ctx context.Context, job *Job) (*rendercache.Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout(job))
+	defer cancel()
+
+	page, err := q.pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer q.pool.Put(page)
+
+	res, err := load(ctx, page, job)
+	var body []byte
+	if err == nil {
+		body, err = Capture(page, job.Output)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return &rendercache.Entry{
+		ContentType:  job.Output.ContentType(),
+		Body:         body,
+		StatusCode:   res.StatusCode,
+		CacheControl: rendercache.CacheControl(res.Header),
+	}, nil
+}
+
+// CacheKey returns the key of a job's output in a render cache. Jobs with
+// the same URL, output and wait have the same key.
+func CacheKey(job *Job) string {
+	return rendercache.Key(job.URL, map[string]interface{}{
+		"output": job.Output,
+		"wait":   job.Wait.String(),
+	})
+}
+
+// Render opens the job's URL on page and returns the rendered output.
+func Render(ctx context.Context, page *phantomjs.WebPage, job *Job) ([]byte, error) {
+	if err := Load(ctx, page, job); err != nil {
+		return nil, err
+	}
+	return Capture(page, job.Output)
+}
+
+// Load applies the job's page settings, opens its URL and waits for the
+// job's wait duration.
+func Load(ctx context.Context, page *phantomjs.WebPage, job *Job) error {
+	_, err := load(ctx, page, job)
+	return err
+}
+
+// load implements Load and returns the result of opening the job's URL.
+func load(ctx context.Context, page *phantomjs.WebPage, job *Job) (*phantomjs.OpenResult, error) {
+	out := job.Output
+	if out.ViewportWidth > 0 && out.ViewportHeight > 0 {
+		if err := page.SetViewportSize(out.ViewportWidth, out.ViewportHeight); err != nil {
+			return nil, err
+		}
+	}
+	if out.Format == FormatPDF && out.PaperSize != nil {
+		if err := page.SetPaperSize(*out.PaperSize); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := page.OpenURL(job.URL)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %s", job.URL, err)
+	}
+
+	// Wait for additional scripts to run.
+	if job.Wait > 0 {
+		timer := time.NewTimer(job.Wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return res, nil
+}
+
+// Capture returns the current state of a loaded page in the output's format.
+func Capture(page *phantomjs.WebPage, out Output) ([]byte, error) {
+	quality := out.Quality
+	if quality <= 0 {
+		quality = 100
+	}
+
+	switch out.Format {
+	case FormatPNG, FormatJPEG:
+		if quality == 100 {
+			data, err := page.RenderBase64(strings.ToUpper(out.Format))
+			if err != nil {
+				return nil, err
+			}
+			return base64.StdEncoding.DecodeString(data)
+		}
+		return renderFile(page, out.Format, quality)
+	case FormatPDF:
+		return renderFile(page, out.Format, quality)
+	default:
+		content, err := page.Content()
+		return []byte(content), err
+	}
+}
+
+// renderFile renders the page to a temporary file and returns its contents.
+// PhantomJS can only write PDFs and reduced quality images to disk.
+func renderFile(page *phantomjs.WebPage, format string, quality int) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "phantomjs-jobs-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "output."+format)
+	if err := page.Render(path, strings.ToUpper(format), quality); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
+// jobHeap is a priority queue of jobs ordered by priority and then submission.
+type jobHeap []*Job
+
+func (h jobHeap) Len() int { return len(h) }
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h jobHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x interface{}) { *h = append(*h, x.(*Job)) }
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	job := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return job
+}
@@ -0,0 +1,189 @@
+package jobs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/jobs"
+	"github.com/benbjohnson/phantomjs/rendercache"
+)
+
+// Ensure jobs are started in priority order and results are delivered.
+func TestQueue_Priority(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	var opened []string
+	pool := NewStubPool(t, 1, func(url string) error {
+		mu.Lock()
+		opened = append(opened, url)
+		mu.Unlock()
+		if url == "http://example.com/first" {
+			close(started)
+			<-release
+		}
+		return nil
+	})
+
+	q := jobs.NewQueue(pool)
+	if err := q.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	// The first job occupies the only worker while the others are queued.
+	MustSubmit(t, q, &jobs.Job{URL: "http://example.com/first"})
+	<-started
+	MustSubmit(t, q, &jobs.Job{URL: "http://example.com/low", Priority: 1})
+	MustSubmit(t, q, &jobs.Job{URL: "http://example.com/high", Priority: 10})
+	MustSubmit(t, q, &jobs.Job{URL: "http://example.com/low2", Priority: 1})
+	close(release)
+
+	for i := 0; i < 4; i++ {
+		if result := <-q.Results(); result.Err != nil {
+			t.Fatal(result.Err)
+		} else if string(result.Body) != "CONTENT" || result.ContentType != "text/html; charset=utf-8" {
+			t.Fatalf("unexpected result: %s %q", result.ContentType, result.Body)
+		}
+	}
+
+	exp := []string{"http://example.com/first", "http://example.com/high", "http://example.com/low", "http://example.com/low2"}
+	for i := range exp {
+		if opened[i] != exp[i] {
+			t.Fatalf("unexpected order: %v", opened)
+		}
+	}
+}
+
+// Ensure failed jobs are retried up to their limit.
+func TestQueue_Retry(t *testing.T) {
+	var attempts int
+	pool := NewStubPool(t, 1, func(url string) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("fail")
+		}
+		return nil
+	})
+
+	q := jobs.NewQueue(pool)
+	q.RetryDelay = time.Millisecond
+	if err := q.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	MustSubmit(t, q, &jobs.Job{URL: "http://example.com", MaxRetries: 1})
+	if result := <-q.Results(); result.Err == nil || result.Attempts != 2 {
+		t.Fatalf("unexpected result: attempts=%d err=%v", result.Attempts, result.Err)
+	}
+
+	MustSubmit(t, q, &jobs.Job{URL: "http://example.com", MaxRetries: 1})
+	if result := <-q.Results(); result.Err != nil || result.Attempts != 1 {
+		t.Fatalf("unexpected result: attempts=%d err=%v", result.Attempts, result.Err)
+	}
+}
+
+// Ensure jobs with the same URL and output share cached output.
+func TestQueue_Cache(t *testing.T) {
+	var opens int
+	pool := NewStubPool(t, 1, func(url string) error {
+		opens++
+		return nil
+	})
+
+	q := jobs.NewQueue(pool)
+	q.Cache = rendercache.New(rendercache.NewMemoryStore())
+	if err := q.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	for i, status := range []string{rendercache.StatusMiss, rendercache.StatusHit} {
+		MustSubmit(t, q, &jobs.Job{URL: "http://example.com"})
+		if result := <-q.Results(); result.Err != nil {
+			t.Fatal(result.Err)
+		} else if result.CacheStatus != status || string(result.Body) != "CONTENT" {
+			t.Fatalf("%d: unexpected result: %s %q", i, result.CacheStatus, result.Body)
+		}
+	}
+
+	MustSubmit(t, q, &jobs.Job{URL: "http://example.com", Output: jobs.Output{Format: jobs.FormatPNG}})
+	if result := <-q.Results(); result.CacheStatus != rendercache.StatusMiss {
+		t.Fatalf("unexpected cache status: %s", result.CacheStatus)
+	} else if opens != 2 {
+		t.Fatalf("unexpected open count: %d", opens)
+	}
+}
+
+// Ensure invalid jobs and jobs submitted after close are rejected.
+func TestQueue_Submit_Err(t *testing.T) {
+	q := jobs.NewQueue(phantomjs.NewPool(1))
+	if err := q.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Submit(&jobs.Job{}); err != jobs.ErrInvalidJob {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := q.Submit(&jobs.Job{URL: "http://example.com", Output: jobs.Output{Format: "gif"}}); err != jobs.ErrInvalidJob {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q.Close()
+	if err := q.Submit(&jobs.Job{URL: "http://example.com"}); err != jobs.ErrQueueClosed {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, ok := <-q.Results(); ok {
+		t.Fatal("expected results to be closed")
+	}
+}
+
+// MustSubmit submits job to q and fails the test on error.
+func MustSubmit(tb testing.TB, q *jobs.Queue, job *jobs.Job) {
+	tb.Helper()
+	if err := q.Submit(job); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// NewStubPool returns a pool of size n backed by a stub process. The open
+// function is called for each page load and its error fails the load.
+func NewStubPool(tb testing.TB, n int, open func(url string) error) *phantomjs.Pool {
+	var mu sync.Mutex
+	var nextID int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			mu.Lock()
+			nextID++
+			id := nextID
+			mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"ref": map[string]string{"id": strconv.Itoa(id)}})
+		case "/webpage/Open":
+			var req struct {
+				URL string `json:"url"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if err := open(req.URL); err != nil {
+				w.Write([]byte(`{"status":"fail"}`))
+				return
+			}
+			w.Write([]byte(`{"status":"success"}`))
+		case "/webpage/Content":
+			w.Write([]byte(`{"value":"CONTENT"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	tb.Cleanup(srv.Close)
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	return phantomjs.NewPool(n, phantomjs.NewProcess(phantomjs.WithPort(portN)))
+}
@@ -0,0 +1,294 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// ErrInvalidKey is returned when storing output under an empty key.
+var ErrInvalidKey = errors.New("invalid key")
+
+// Sink stores rendered output so that it does not need to be returned to
+// the caller.
+type Sink interface {
+	// Write stores data under key and returns its location.
+	Write(ctx context.Context, key, contentType string, data []byte) (location string, err error)
+}
+
+// RenderTo opens the job's URL on page, renders it and writes the output to
+// sink. Returns the location of the stored output.
+func RenderTo(ctx context.Context, page *phantomjs.WebPage, job *Job, sink Sink) (string, error) {
+	body, err := Render(ctx, page, job)
+	if err != nil {
+		return "", err
+	}
+	return Store(ctx, sink, job, body)
+}
+
+// Store writes a job's rendered output to sink under the job's key.
+func Store(ctx context.Context, sink Sink, job *Job, body []byte) (string, error) {
+	key := job.Key
+	if key == "" {
+		key = job.ID + "." + job.Output.Extension()
+	}
+	return sink.Write(ctx, key, job.Output.ContentType(), body)
+}
+
+// Extension returns the file extension of the output format.
+func (o Output) Extension() string {
+	switch o.Format {
+	case FormatPNG:
+		return "png"
+	case FormatJPEG:
+		return "jpg"
+	case FormatPDF:
+		return "pdf"
+	default:
+		return "html"
+	}
+}
+
+// cleanKey returns key as a relative slash-separated path that cannot
+// escape the sink's root.
+func cleanKey(key string) (string, error) {
+	key = path.Clean("/" + key)[1:]
+	if key == "" {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
+
+// DirSink writes output to files in a local directory. Keys may contain
+// slashes to store output in subdirectories.
+type DirSink struct {
+	Path string
+}
+
+// Write writes data to the key's file and returns the file's path.
+func (s *DirSink) Write(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(s.Path, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so readers never see partial output.
+	f, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	} else if err := f.Close(); err != nil {
+		return "", err
+	} else if err := os.Rename(f.Name(), filename); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// S3Sink uploads output to an Amazon S3 bucket, or to an S3-compatible
+// service if Endpoint is set. Requests are signed with AWS Signature V4.
+type S3Sink struct {
+	Bucket string
+	Region string
+
+	// Prepended to every key, such as "renders/".
+	Prefix string
+
+	// Static credentials used to sign requests.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Base URL of an S3-compatible service, such as "http://localhost:9000".
+	// Buckets are addressed by path when set. Defaults to AWS.
+	Endpoint string
+
+	// Client used for uploads. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Write uploads data and returns its "s3://bucket/key" location.
+func (s *S3Sink) Write(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	key, err := cleanKey(s.Prefix + key)
+	if err != nil {
+		return "", err
+	}
+
+	var u string
+	if s.Endpoint != "" {
+		u = strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/" + escapePath(key)
+	} else {
+		u = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, escapePath(key))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", u, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, data, time.Now())
+
+	if err := upload(s.Client, req); err != nil {
+		return "", err
+	}
+	return "s3://" + s.Bucket + "/" + key, nil
+}
+
+// sign adds AWS Signature V4 headers to req.
+func (s *S3Sink) sign(req *http.Request, data []byte, t time.Time) {
+	t = t.UTC()
+	amzDate, date := t.Format("20060102T150405Z"), t.Format("20060102")
+	payloadHash := sha256.Sum256(data)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	// Build the canonical headers from every header set above.
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(req.Header.Get(name)))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Del("Host")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data using key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// GCSSink uploads output to a Google Cloud Storage bucket.
+//
+// The sink does not authenticate requests itself. Client should add
+// credentials, such as a client returned by golang.org/x/oauth2/google's
+// DefaultClient.
+type GCSSink struct {
+	Bucket string
+
+	// Prepended to every key, such as "renders/".
+	Prefix string
+
+	// Base URL of the storage API. Defaults to "https://storage.googleapis.com".
+	Endpoint string
+
+	// Client used for uploads. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Write uploads data and returns its "gs://bucket/key" location.
+func (s *GCSSink) Write(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	key, err := cleanKey(s.Prefix + key)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	u := strings.TrimSuffix(endpoint, "/") + "/upload/storage/v1/b/" + url.PathEscape(s.Bucket) +
+		"/o?uploadType=media&name=" + url.QueryEscape(key)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	if err := upload(s.Client, req); err != nil {
+		return "", err
+	}
+	return "gs://" + s.Bucket + "/" + key, nil
+}
+
+// upload sends req and returns an error for non-2xx responses.
+func upload(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upload %s: unexpected status: %d %s", req.URL.Redacted(), resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// escapePath percent-encodes key the way S3 signs it: everything except
+// unreserved characters and slashes is escaped.
+func escapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package jobs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs/jobs"
+)
+
+// Ensure the queue writes output to its sink instead of the result body.
+func TestQueue_Sink(t *testing.T) {
+	q := jobs.NewQueue(NewStubPool(t, 1, func(url string) error { return nil }))
+	q.Sink = &jobs.DirSink{Path: t.TempDir()}
+	if err := q.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	MustSubmit(t, q, &jobs.Job{ID: "a", URL: "http://example.com"})
+	result := <-q.Results()
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	} else if result.Body != nil || result.Size != len("CONTENT") {
+		t.Fatalf("unexpected body: %q (%d)", result.Body, result.Size)
+	} else if filepath.Base(result.Location) != "a.html" {
+		t.Fatalf("unexpected location: %s", result.Location)
+	}
+
+	if buf, err := ioutil.ReadFile(result.Location); err != nil {
+		t.Fatal(err)
+	} else if string(buf) != "CONTENT" {
+		t.Fatalf("unexpected file contents: %q", buf)
+	}
+}
+
+// Ensure keys cannot escape the sink's directory.
+func TestDirSink_Write(t *testing.T) {
+	dir := t.TempDir()
+	s := &jobs.DirSink{Path: dir}
+	if location, err := s.Write(context.Background(), "../../x/y.png", "image/png", []byte("PNG")); err != nil {
+		t.Fatal(err)
+	} else if location != filepath.Join(dir, "x", "y.png") {
+		t.Fatalf("unexpected location: %s", location)
+	}
+
+	if _, err := s.Write(context.Background(), "/", "image/png", nil); err != jobs.ErrInvalidKey {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure S3 uploads are signed and sent to the bucket's path.
+func TestS3Sink_Write(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != "PUT" || r.URL.EscapedPath() != "/bucket/renders/a%20b.pdf" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.EscapedPath())
+		} else if string(body) != "PDF" || r.Header.Get("Content-Type") != "application/pdf" {
+			t.Errorf("unexpected body: %s %q", r.Header.Get("Content-Type"), body)
+		} else if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/us-east-1/s3/aws4_request") {
+			t.Errorf("unexpected authorization: %s", auth)
+		} else if r.Header.Get("X-Amz-Content-Sha256") == "" || r.Header.Get("X-Amz-Date") == "" {
+			t.Error("expected signing headers")
+		}
+	}))
+	defer srv.Close()
+
+	s := &jobs.S3Sink{Bucket: "bucket", Region: "us-east-1", Prefix: "renders/", AccessKeyID: "AKID", SecretAccessKey: "SECRET", Endpoint: srv.URL}
+	if location, err := s.Write(context.Background(), "a b.pdf", "application/pdf", []byte("PDF")); err != nil {
+		t.Fatal(err)
+	} else if location != "s3://bucket/renders/a b.pdf" {
+		t.Fatalf("unexpected location: %s", location)
+	}
+}
+
+// Ensure failed GCS uploads return an error.
+func TestGCSSink_Write_ErrStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/upload/storage/v1/b/bucket/o" || r.URL.Query().Get("name") != "a.png" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		http.Error(w, "denied", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s := &jobs.GCSSink{Bucket: "bucket", Endpoint: srv.URL}
+	if _, err := s.Write(context.Background(), "a.png", "image/png", []byte("PNG")); err == nil || !strings.Contains(err.Error(), "403 denied") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
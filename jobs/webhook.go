@@ -0,0 +1,148 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Job statuses reported to webhooks.
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// SignatureHeader is the header that carries the hex-encoded HMAC-SHA256 of
+// the webhook body when the queue has a webhook secret.
+const SignatureHeader = "X-Phantomjs-Signature"
+
+// Default webhook settings.
+const (
+	DefaultWebhookRetries = 3
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+// WebhookPayload is the JSON body posted to a job's callback URL.
+type WebhookPayload struct {
+	ID       string    `json:"id"`
+	URL      string    `json:"url"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Attempts int       `json:"attempts"`
+	Started  time.Time `json:"started,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+
+	// Output is set for successful jobs.
+	Output *WebhookOutput `json:"output,omitempty"`
+}
+
+// WebhookOutput describes the output of a successful job.
+type WebhookOutput struct {
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+
+	// Location of the stored output, if the output was stored.
+	Location string `json:"location,omitempty"`
+}
+
+// NewWebhookPayload returns the webhook payload for a result.
+func NewWebhookPayload(result *Result) *WebhookPayload {
+	payload := &WebhookPayload{
+		ID:       result.Job.ID,
+		URL:      result.Job.URL,
+		Status:   StatusSucceeded,
+		Attempts: result.Attempts,
+		Started:  result.Started,
+		Finished: result.Finished,
+	}
+	if result.Err != nil {
+		payload.Status, payload.Error = StatusFailed, result.Err.Error()
+		return payload
+	}
+	payload.Output = &WebhookOutput{
+		ContentType: result.ContentType,
+		Size:        result.Size,
+		Location:    result.Location,
+	}
+	return payload
+}
+
+// notify delivers the result to the job's callback URL in the background.
+func (q *Queue) notify(result *Result) {
+	if result.Job.CallbackURL == "" {
+		return
+	}
+
+	q.webhooks.Add(1)
+	go func() {
+		defer q.webhooks.Done()
+		if err := q.deliver(result); err != nil {
+			q.log("job webhook failed", "id", result.Job.ID, "callback", result.Job.CallbackURL, "error", err)
+		}
+	}()
+}
+
+// deliver posts the result's payload, retrying failed deliveries with
+// exponential backoff.
+func (q *Queue) deliver(result *Result) error {
+	body, err := json.Marshal(NewWebhookPayload(result))
+	if err != nil {
+		return err
+	}
+
+	delay := q.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if err = q.post(result.Job.CallbackURL, body); err == nil || attempt >= q.WebhookRetries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post sends a single webhook request.
+func (q *Queue) post(callbackURL string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if q.WebhookSecret != "" {
+		req.Header.Set(SignatureHeader, Sign(q.WebhookSecret, body))
+	}
+
+	client := q.WebhookClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of body using secret. Receivers
+// can compare it against the SignatureHeader to verify a webhook.
+func Sign(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
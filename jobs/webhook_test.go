@@ -0,0 +1,81 @@
+package jobs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs/jobs"
+)
+
+// Ensure completed and failed jobs are posted to their callback URLs.
+func TestQueue_Webhook(t *testing.T) {
+	pool := NewStubPool(t, 1, func(url string) error {
+		if url == "http://example.com/fail" {
+			return errors.New("fail")
+		}
+		return nil
+	})
+
+	var mu sync.Mutex
+	var calls int
+	payloads := make(map[string]*jobs.WebhookPayload)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if sig := r.Header.Get(jobs.SignatureHeader); sig != jobs.Sign("secret", body) {
+			t.Errorf("unexpected signature: %s", sig)
+		}
+
+		// Reject the first delivery to exercise retries.
+		mu.Lock()
+		defer mu.Unlock()
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload jobs.WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Error(err)
+		}
+		payloads[payload.ID] = &payload
+	}))
+	defer hook.Close()
+
+	q := jobs.NewQueue(pool)
+	q.RetryDelay = time.Millisecond
+	q.WebhookSecret = "secret"
+	if err := q.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	MustSubmit(t, q, &jobs.Job{ID: "ok", URL: "http://example.com/", CallbackURL: hook.URL})
+	<-q.Results()
+	MustSubmit(t, q, &jobs.Job{ID: "bad", URL: "http://example.com/fail", CallbackURL: hook.URL})
+	<-q.Results()
+	q.Close()
+
+	if p := payloads["ok"]; p == nil || p.Status != jobs.StatusSucceeded || p.Output == nil || p.Output.Size != len("CONTENT") {
+		t.Fatalf("unexpected success payload: %#v", p)
+	} else if p := payloads["bad"]; p == nil || p.Status != jobs.StatusFailed || p.Error == "" || p.Output != nil {
+		t.Fatalf("unexpected failure payload: %#v", p)
+	}
+}
+
+// Ensure invalid callback URLs are rejected.
+func TestQueue_Submit_ErrCallbackURL(t *testing.T) {
+	q := jobs.NewQueue(NewStubPool(t, 1, func(string) error { return nil }))
+	if err := q.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if err := q.Submit(&jobs.Job{URL: "http://example.com", CallbackURL: "ftp://example.com"}); err != jobs.ErrInvalidJob {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+// Package keys defines the key codes and modifier flags of PhantomJS's
+// page.event.key and page.event.modifier, so keyboard events can be sent
+// without copying numbers from the PhantomJS documentation:
+//
+//	page.SendKeyCode("keypress", keys.Enter, 0)
+//	page.SendKeyboardEvent("keypress", "a", keys.ModCtrl|keys.ModShift)
+//
+// The values are Qt key codes. The constants are untyped so they can be
+// passed directly as the int arguments of the event methods.
+package keys
+
+// Modifier flags. Combine them with the bitwise OR operator.
+const (
+	ModShift  = 0x02000000
+	ModCtrl   = 0x04000000
+	ModAlt    = 0x08000000
+	ModMeta   = 0x10000000
+	ModKeypad = 0x20000000
+)
+
+// Editing and navigation keys.
+const (
+	Escape    = 0x01000000
+	Tab       = 0x01000001
+	Backtab   = 0x01000002
+	Backspace = 0x01000003
+	Return    = 0x01000004
+	Enter     = 0x01000005
+	Insert    = 0x01000006
+	Delete    = 0x01000007
+	Pause     = 0x01000008
+	Print     = 0x01000009
+	SysReq    = 0x0100000a
+	Clear     = 0x0100000b
+	Home      = 0x01000010
+	End       = 0x01000011
+	Left      = 0x01000012
+	Up        = 0x01000013
+	Right     = 0x01000014
+	Down      = 0x01000015
+	PageUp    = 0x01000016
+	PageDown  = 0x01000017
+	Menu      = 0x01000055
+)
+
+// Modifier and lock keys, for sending their own keydown and keyup events.
+const (
+	Shift      = 0x01000020
+	Control    = 0x01000021
+	Meta       = 0x01000022
+	Alt        = 0x01000023
+	CapsLock   = 0x01000024
+	NumLock    = 0x01000025
+	ScrollLock = 0x01000026
+)
+
+// Function keys.
+const (
+	F1 = 0x01000030 + iota
+	F2
+	F3
+	F4
+	F5
+	F6
+	F7
+	F8
+	F9
+	F10
+	F11
+	F12
+)
+
+// Space key.
+const Space = 0x20
+
+// Digit keys.
+const (
+	Key0 = 0x30 + iota
+	Key1
+	Key2
+	Key3
+	Key4
+	Key5
+	Key6
+	Key7
+	Key8
+	Key9
+)
+
+// Letter keys. Letters use the codes of their upper case characters.
+const (
+	A = 0x41 + iota
+	B
+	C
+	D
+	E
+	F
+	G
+	H
+	I
+	J
+	K
+	L
+	M
+	N
+	O
+	P
+	Q
+	R
+	S
+	T
+	U
+	V
+	W
+	X
+	Y
+	Z
+)
@@ -0,0 +1,29 @@
+package keys_test
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/keys"
+)
+
+// Ensure the modifier flags match the ones accepted by SendKeyboardEvent.
+func TestModifiers(t *testing.T) {
+	if keys.ModShift != phantomjs.ShiftKey || keys.ModCtrl != phantomjs.CtrlKey || keys.ModAlt != phantomjs.AltKey || keys.ModMeta != phantomjs.MetaKey || keys.ModKeypad != phantomjs.Keypad {
+		t.Fatal("modifier flags do not match")
+	}
+}
+
+// Ensure sequential key codes follow the Qt key enumeration.
+func TestKeys(t *testing.T) {
+	for _, tt := range []struct{ key, code int }{
+		{keys.F12, 0x0100003b},
+		{keys.Key9, '9'},
+		{keys.Z, 'Z'},
+		{keys.Enter, 16777221},
+	} {
+		if tt.key != tt.code {
+			t.Fatalf("unexpected code: %#x != %#x", tt.key, tt.code)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package phantomjs
+
+import (
+	"strings"
+)
+
+// Link represents an anchor on a page.
+type Link struct {
+	// Absolute URL of the link.
+	Href string
+
+	// Text content of the anchor with whitespace collapsed.
+	Text string
+
+	// Value of the rel attribute.
+	Rel string
+
+	// True if rel includes "nofollow", "ugc" or "sponsored".
+	NoFollow bool
+}
+
+// Assets represents the resources referenced by a page. URLs are absolute
+// and each URL is listed once, in document order.
+type Assets struct {
+	Scripts     []string
+	Stylesheets []string
+	Images      []string
+}
+
+// Links returns the links on the page.
+func (p *WebPage) Links() ([]Link, error) {
+	links, _, err := p.LinksAndAssets()
+	return links, err
+}
+
+// Assets returns the scripts, stylesheets and images referenced by the page.
+func (p *WebPage) Assets() (*Assets, error) {
+	_, assets, err := p.LinksAndAssets()
+	return assets, err
+}
+
+// LinksAndAssets returns the page's links and assets in a single round trip.
+func (p *WebPage) LinksAndAssets() ([]Link, *Assets, error) {
+	var v struct {
+		Links []struct {
+			Href string `json:"href"`
+			Text string `json:"text"`
+			Rel  string `json:"rel"`
+		} `json:"links"`
+		Scripts     []string `json:"scripts"`
+		Stylesheets []string `json:"stylesheets"`
+		Images      []string `json:"images"`
+	}
+	if err := p.evaluateInto(linksScript, &v); err != nil {
+		return nil, nil, err
+	}
+
+	links := make([]Link, len(v.Links))
+	for i, l := range v.Links {
+		links[i] = Link{Href: l.Href, Text: l.Text, Rel: l.Rel, NoFollow: isNoFollow(l.Rel)}
+	}
+	return links, &Assets{Scripts: v.Scripts, Stylesheets: v.Stylesheets, Images: v.Images}, nil
+}
+
+// isNoFollow returns true if rel asks crawlers not to follow a link.
+func isNoFollow(rel string) bool {
+	for _, v := range strings.Fields(strings.ToLower(rel)) {
+		if v == "nofollow" || v == "ugc" || v == "sponsored" {
+			return true
+		}
+	}
+	return false
+}
+
+// linksScript collects anchors and asset URLs. The DOM resolves href and src
+// properties to absolute URLs.
+const linksScript = `function() {
+	function unique(els, prop) {
+		var seen = {}, a = [];
+		for (var i = 0; i < els.length; i++) {
+			var u = els[i][prop];
+			if (u && !seen[u]) {
+				seen[u] = true;
+				a.push(u);
+			}
+		}
+		return a;
+	}
+
+	var links = [], anchors = document.querySelectorAll('a[href], area[href]');
+	for (var i = 0; i < anchors.length; i++) {
+		links.push({
+			href: anchors[i].href,
+			text: (anchors[i].textContent || '').replace(/\s+/g, ' ').trim(),
+			rel: anchors[i].getAttribute('rel') || ''
+		});
+	}
+
+	var stylesheets = [], sheets = document.querySelectorAll('link[href]');
+	for (var i = 0; i < sheets.length; i++) {
+		if ((' ' + (sheets[i].getAttribute('rel') || '').toLowerCase() + ' ').indexOf(' stylesheet ') !== -1) {
+			stylesheets.push(sheets[i]);
+		}
+	}
+
+	return {
+		links: links,
+		scripts: unique(document.querySelectorAll('script[src]'), 'src'),
+		stylesheets: unique(stylesheets, 'href'),
+		images: unique(document.querySelectorAll('img[src], input[type="image"][src]'), 'src')
+	};
+}`
@@ -0,0 +1,143 @@
+package phantomjs
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Resource types reported by LoadSummary.
+const (
+	ResourceTypeDocument   = "document"
+	ResourceTypeScript     = "script"
+	ResourceTypeStylesheet = "stylesheet"
+	ResourceTypeImage      = "image"
+	ResourceTypeXHR        = "xhr"
+	ResourceTypeOther      = "other"
+)
+
+// SlowestResourceN is the number of resources listed in LoadSummary.Slowest.
+const SlowestResourceN = 10
+
+// LoadSummary represents the requests made by a page since it was last
+// opened. It is intended for checking page weight and performance budgets.
+type LoadSummary struct {
+	// Total number of requests and bytes received.
+	Requests int
+	Bytes    int64
+
+	// Requests and bytes received by resource type, such as
+	// ResourceTypeScript.
+	RequestsByType map[string]int
+	BytesByType    map[string]int64
+
+	// All requests in the order they were made.
+	Resources []LoadedResource
+
+	// Completed requests that took the longest, slowest first.
+	Slowest []LoadedResource
+
+	// Requests that failed or received an HTTP error status.
+	Failed []LoadedResource
+}
+
+// LoadedResource represents a single request made by a page.
+type LoadedResource struct {
+	URL    string
+	Method string
+	Type   string
+	Status int
+
+	// Size of the response body in bytes. Taken from the Content-Length
+	// header when present, so compressed responses report their transfer size.
+	Size int64
+
+	// Time from the request to the end of the response. Zero if the request
+	// has not completed.
+	Duration time.Duration
+
+	// Network error reported by PhantomJS, if any.
+	Error string
+}
+
+// LoadSummary returns a summary of the requests made by the page since the
+// last call to Open. Requests made before the first Open, such as those for
+// content set with SetContent, are included until the page is opened.
+func (p *WebPage) LoadSummary() (*LoadSummary, error) {
+	var resp struct {
+		Value []struct {
+			URL            string       `json:"url"`
+			Method         string       `json:"method"`
+			RequestHeaders []headerJSON `json:"requestHeaders"`
+			Status         int          `json:"status"`
+			ContentType    string       `json:"contentType"`
+			Size           int64        `json:"size"`
+			Start          time.Time    `json:"start"`
+			End            time.Time    `json:"end"`
+			Error          string       `json:"error"`
+		} `json:"value"`
+	}
+	if err := p.doJSON("POST", "/webpage/LoadedResources", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+		return nil, err
+	}
+
+	s := &LoadSummary{
+		RequestsByType: make(map[string]int),
+		BytesByType:    make(map[string]int64),
+	}
+	for i, v := range resp.Value {
+		r := LoadedResource{
+			URL:    v.URL,
+			Method: v.Method,
+			Type:   resourceType(v.ContentType, decodeHeaderJSON(v.RequestHeaders).Get("X-Requested-With") != "", i == 0),
+			Status: v.Status,
+			Size:   v.Size,
+			Error:  v.Error,
+		}
+		if !v.Start.IsZero() && v.End.After(v.Start) {
+			r.Duration = v.End.Sub(v.Start)
+		}
+
+		s.Requests++
+		s.Bytes += r.Size
+		s.RequestsByType[r.Type]++
+		s.BytesByType[r.Type] += r.Size
+		s.Resources = append(s.Resources, r)
+		if r.Error != "" || r.Status >= 400 {
+			s.Failed = append(s.Failed, r)
+		}
+		if !v.End.IsZero() {
+			s.Slowest = append(s.Slowest, r)
+		}
+	}
+
+	sort.SliceStable(s.Slowest, func(i, j int) bool { return s.Slowest[i].Duration > s.Slowest[j].Duration })
+	if len(s.Slowest) > SlowestResourceN {
+		s.Slowest = s.Slowest[:SlowestResourceN]
+	}
+	return s, nil
+}
+
+// resourceType classifies a resource by its content type. The first request
+// of a load is always the document.
+func resourceType(contentType string, xhr, first bool) string {
+	contentType = strings.ToLower(contentType)
+	switch {
+	case first:
+		return ResourceTypeDocument
+	case xhr:
+		return ResourceTypeXHR
+	case strings.Contains(contentType, "javascript"), strings.Contains(contentType, "ecmascript"):
+		return ResourceTypeScript
+	case strings.HasPrefix(contentType, "text/css"):
+		return ResourceTypeStylesheet
+	case strings.HasPrefix(contentType, "image/"):
+		return ResourceTypeImage
+	case strings.HasPrefix(contentType, "text/html"):
+		return ResourceTypeDocument
+	case strings.Contains(contentType, "json"), strings.Contains(contentType, "xml"):
+		return ResourceTypeXHR
+	default:
+		return ResourceTypeOther
+	}
+}
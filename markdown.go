@@ -0,0 +1,165 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Markdown converts the rendered content of the first element matching
+// selector, or of the whole body if selector is empty, to Markdown.
+//
+// Headings, paragraphs, emphasis, code, links, images, lists, block quotes
+// and tables are converted; links and images use absolute URLs. Scripts,
+// styles, form controls and hidden elements are dropped, so the output suits
+// indexing and language model pipelines rather than faithful round trips.
+//
+// Returns ErrElementNotFound if no element matches selector.
+func (p *WebPage) Markdown(selector string) (string, error) {
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return "", err
+	}
+
+	var v *string
+	if err := p.evaluateInto(fmt.Sprintf(markdownScript, sel), &v); err != nil {
+		return "", err
+	} else if v == nil {
+		return "", ErrElementNotFound
+	}
+	return *v, nil
+}
+
+// markdownScript converts an element to Markdown. Backticks are written as
+// \x60 since they cannot appear in the Go string.
+const markdownScript = `function() {
+	var sel = %s;
+	var root = sel ? document.querySelector(sel) : document.body;
+	if (!root) return null;
+
+	var BT = '\x60';
+	var BLOCK = /^(ADDRESS|ARTICLE|ASIDE|BLOCKQUOTE|BODY|DD|DETAILS|DIV|DL|DT|FIELDSET|FIGCAPTION|FIGURE|FOOTER|FORM|H[1-6]|HEADER|HR|LI|MAIN|NAV|OL|P|PRE|SECTION|SUMMARY|TABLE|UL)$/;
+	var SKIP = /^(SCRIPT|STYLE|NOSCRIPT|TEMPLATE|HEAD|INPUT|SELECT|TEXTAREA|BUTTON|IFRAME|SVG|CANVAS)$/i;
+
+	var repeat = function(s, n) { return new Array(n + 1).join(s); };
+	var escape = function(s) { return s.replace(/([\\\x60*_\[\]])/g, '\\$1'); };
+	var skip = function(el) {
+		if (SKIP.test(el.tagName)) return true;
+		var style = window.getComputedStyle(el);
+		return style.display === 'none' || style.visibility === 'hidden';
+	};
+
+	// Returns the inline Markdown of a node.
+	var inlineNode = function(c) {
+		if (c.nodeType === 3) return escape(c.nodeValue.replace(/\s+/g, ' '));
+		if (c.nodeType !== 1 || skip(c)) return '';
+		var s;
+		switch (c.tagName) {
+		case 'BR':
+			return '\\\n';
+		case 'STRONG': case 'B':
+			s = inline(c).trim();
+			return s ? '**' + s + '**' : '';
+		case 'EM': case 'I':
+			s = inline(c).trim();
+			return s ? '*' + s + '*' : '';
+		case 'CODE': case 'KBD': case 'SAMP':
+			s = c.textContent;
+			var fence = s.indexOf(BT) === -1 ? BT : BT + BT;
+			return s ? fence + s + fence : '';
+		case 'A':
+			s = inline(c).trim();
+			var href = c.getAttribute('href');
+			return s && href && !/^(javascript:|#)/i.test(href) ? '[' + s + '](' + c.href + ')' : s;
+		case 'IMG':
+			return c.getAttribute('src') ? '![' + escape(c.alt || '') + '](' + c.src + ')' : '';
+		default:
+			return inline(c);
+		}
+	};
+	var inline = function(el) {
+		var out = '';
+		for (var c = el.firstChild; c; c = c.nextSibling) out += inlineNode(c);
+		return out;
+	};
+
+	// Returns the blocks of an element's children. Runs of inline content
+	// between child blocks form paragraphs.
+	var blocks = function(el) {
+		var out = [], buf = '';
+		var flush = function() {
+			var s = buf.replace(/[ \t]+/g, ' ').replace(/ ?\n ?/g, '\n').trim();
+			if (s) out.push(s);
+			buf = '';
+		};
+		for (var c = el.firstChild; c; c = c.nextSibling) {
+			if (c.nodeType === 1 && BLOCK.test(c.tagName) && !skip(c)) {
+				flush();
+				out = out.concat(block(c));
+			} else {
+				buf += inlineNode(c);
+			}
+		}
+		flush();
+		return out;
+	};
+
+	// Returns the blocks of an element.
+	var block = function(el) {
+		var t = el.tagName, s;
+		if (/^H[1-6]$/.test(t)) {
+			s = inline(el).replace(/\s+/g, ' ').trim();
+			return s ? [repeat('#', Number(t.charAt(1))) + ' ' + s] : [];
+		}
+		switch (t) {
+		case 'HR':
+			return ['---'];
+		case 'PRE':
+			return [repeat(BT, 3) + '\n' + el.textContent.replace(/\n$/, '') + '\n' + repeat(BT, 3)];
+		case 'BLOCKQUOTE':
+			s = blocks(el).join('\n\n');
+			return s ? [s.split('\n').map(function(line) { return line ? '> ' + line : '>'; }).join('\n')] : [];
+		case 'UL': case 'OL':
+			s = list(el);
+			return s ? [s] : [];
+		case 'TABLE':
+			s = table(el);
+			return s ? [s] : [];
+		default:
+			return blocks(el);
+		}
+	};
+
+	var list = function(el) {
+		var lines = [], n = el.tagName === 'OL' && el.start ? el.start : 1;
+		for (var c = el.firstChild; c; c = c.nextSibling) {
+			if (c.nodeType !== 1 || c.tagName !== 'LI' || skip(c)) continue;
+			var marker = el.tagName === 'OL' ? (n++) + '. ' : '- ';
+			var indent = repeat(' ', marker.length);
+			lines.push(marker + blocks(c).join('\n').split('\n').join('\n' + indent));
+		}
+		return lines.join('\n');
+	};
+
+	var table = function(el) {
+		var rows = [], width = 0;
+		for (var i = 0; i < el.rows.length; i++) {
+			var cells = [];
+			for (var j = 0; j < el.rows[i].cells.length; j++) {
+				cells.push(inline(el.rows[i].cells[j]).replace(/\s+/g, ' ').trim().replace(/\|/g, '\\|'));
+			}
+			width = Math.max(width, cells.length);
+			rows.push(cells);
+		}
+		if (!rows.length) return '';
+
+		var line = function(cells) {
+			while (cells.length < width) cells.push('');
+			return '| ' + cells.join(' | ') + ' |';
+		};
+		var out = [line(rows[0]), line(rows[0].map(function() { return '---'; }))];
+		for (var k = 1; k < rows.length; k++) out.push(line(rows[k]));
+		return out.join('\n');
+	};
+
+	return block(root).join('\n\n');
+}`
@@ -0,0 +1,83 @@
+package phantomjs
+
+import (
+	"time"
+)
+
+// ConsoleMessage represents a message logged to a page's console.
+type ConsoleMessage struct {
+	Message string
+
+	// Line and source of the call, if reported by PhantomJS.
+	Line   int
+	Source string
+
+	// Time the message was logged.
+	Time time.Time
+}
+
+// JSError represents an uncaught JavaScript error on a page.
+type JSError struct {
+	Message string
+	Stack   []StackFrame
+
+	// Time the error was raised.
+	Time time.Time
+}
+
+// StackFrame represents a frame of a JavaScript stack trace.
+type StackFrame struct {
+	File     string
+	Line     int
+	Function string
+}
+
+// ConsoleMessages returns the messages logged to the page's console since it
+// was created, oldest first. Only the most recent 1000 messages are kept.
+func (p *WebPage) ConsoleMessages() ([]ConsoleMessage, error) {
+	var resp struct {
+		Value []struct {
+			Message string    `json:"message"`
+			Line    int       `json:"line"`
+			Source  string    `json:"source"`
+			Time    time.Time `json:"time"`
+		} `json:"value"`
+	}
+	if err := p.doJSON("POST", "/webpage/ConsoleMessages", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+		return nil, err
+	}
+
+	a := make([]ConsoleMessage, len(resp.Value))
+	for i, v := range resp.Value {
+		a[i] = ConsoleMessage{Message: v.Message, Line: v.Line, Source: v.Source, Time: v.Time}
+	}
+	return a, nil
+}
+
+// JSErrors returns the uncaught JavaScript errors raised by the page since it
+// was created, oldest first. Only the most recent 1000 errors are kept.
+func (p *WebPage) JSErrors() ([]JSError, error) {
+	var resp struct {
+		Value []struct {
+			Message string `json:"message"`
+			Trace   []struct {
+				File     string `json:"file"`
+				Line     int    `json:"line"`
+				Function string `json:"function"`
+			} `json:"trace"`
+			Time time.Time `json:"time"`
+		} `json:"value"`
+	}
+	if err := p.doJSON("POST", "/webpage/JSErrors", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+		return nil, err
+	}
+
+	a := make([]JSError, len(resp.Value))
+	for i, v := range resp.Value {
+		a[i] = JSError{Message: v.Message, Time: v.Time}
+		for _, t := range v.Trace {
+			a[i].Stack = append(a[i].Stack, StackFrame{File: t.File, Line: t.Line, Function: t.Function})
+		}
+	}
+	return a, nil
+}
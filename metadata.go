@@ -0,0 +1,176 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Metadata represents the metadata declared by a page.
+type Metadata struct {
+	Title       string
+	Description string
+
+	// Absolute URL from <link rel="canonical">.
+	CanonicalURL string
+
+	// Absolute URLs of the icons declared with <link rel="icon">,
+	// "shortcut icon" and "apple-touch-icon", in document order.
+	Favicons []string
+
+	OpenGraph OpenGraph
+	Twitter   TwitterCard
+
+	// Parsed JSON-LD blocks. Blocks containing arrays are flattened and
+	// blocks that are not valid JSON are skipped.
+	JSONLD []map[string]interface{}
+}
+
+// OpenGraph represents the Open Graph properties of a page.
+type OpenGraph struct {
+	Title       string
+	Type        string
+	URL         string
+	Description string
+	SiteName    string
+	Images      []string
+
+	// All og:* properties by name without the "og:" prefix. Only the first
+	// value of repeated properties is kept.
+	Properties map[string]string
+}
+
+// TwitterCard represents the twitter:* properties of a page.
+type TwitterCard struct {
+	Card        string
+	Site        string
+	Creator     string
+	Title       string
+	Description string
+	Image       string
+}
+
+// Metadata returns the page's metadata. It is read from the current DOM so
+// tags added by scripts are included.
+func (p *WebPage) Metadata() (*Metadata, error) {
+	var v struct {
+		Title        string     `json:"title"`
+		Description  string     `json:"description"`
+		CanonicalURL string     `json:"canonical"`
+		Favicons     []string   `json:"favicons"`
+		Properties   [][]string `json:"properties"`
+		JSONLD       []string   `json:"jsonld"`
+	}
+	if err := p.evaluateInto(metadataScript, &v); err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{
+		Title:        v.Title,
+		Description:  v.Description,
+		CanonicalURL: v.CanonicalURL,
+		Favicons:     v.Favicons,
+		OpenGraph:    OpenGraph{Properties: make(map[string]string)},
+	}
+
+	twitter := make(map[string]string)
+	for _, prop := range v.Properties {
+		if len(prop) != 2 {
+			continue
+		}
+		name, value := strings.ToLower(prop[0]), prop[1]
+		switch {
+		case name == "og:image" || name == "og:image:url":
+			m.OpenGraph.Images = append(m.OpenGraph.Images, value)
+			fallthrough
+		case strings.HasPrefix(name, "og:"):
+			if _, ok := m.OpenGraph.Properties[name[3:]]; !ok {
+				m.OpenGraph.Properties[name[3:]] = value
+			}
+		case strings.HasPrefix(name, "twitter:"):
+			if _, ok := twitter[name[8:]]; !ok {
+				twitter[name[8:]] = value
+			}
+		}
+	}
+
+	og := m.OpenGraph.Properties
+	m.OpenGraph.Title, m.OpenGraph.Type, m.OpenGraph.URL = og["title"], og["type"], og["url"]
+	m.OpenGraph.Description, m.OpenGraph.SiteName = og["description"], og["site_name"]
+	m.Twitter = TwitterCard{
+		Card:        twitter["card"],
+		Site:        twitter["site"],
+		Creator:     twitter["creator"],
+		Title:       twitter["title"],
+		Description: twitter["description"],
+		Image:       twitter["image"],
+	}
+	if m.Twitter.Image == "" {
+		m.Twitter.Image = twitter["image:src"]
+	}
+
+	for _, block := range v.JSONLD {
+		m.JSONLD = append(m.JSONLD, parseJSONLD(block)...)
+	}
+	return m, nil
+}
+
+// parseJSONLD returns the objects in a JSON-LD block.
+func parseJSONLD(block string) []map[string]interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(block), &v); err != nil {
+		return nil
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []interface{}:
+		var a []map[string]interface{}
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				a = append(a, m)
+			}
+		}
+		return a
+	default:
+		return nil
+	}
+}
+
+// metadataScript collects the page's meta properties, links and JSON-LD
+// sources. Parsing is done in Go.
+const metadataScript = `function() {
+	var properties = [], favicons = [], jsonld = [];
+
+	var metas = document.querySelectorAll('meta[property], meta[name]');
+	for (var i = 0; i < metas.length; i++) {
+		var name = metas[i].getAttribute('property') || metas[i].getAttribute('name');
+		var content = metas[i].getAttribute('content');
+		if (name && content !== null) properties.push([name, content.trim()]);
+	}
+
+	var links = document.querySelectorAll('link[rel][href]');
+	var canonical = '';
+	for (var i = 0; i < links.length; i++) {
+		var rel = ' ' + links[i].getAttribute('rel').toLowerCase() + ' ';
+		if (rel.indexOf(' canonical ') !== -1 && !canonical) canonical = links[i].href;
+		if (rel.indexOf(' icon ') !== -1 || rel.indexOf(' apple-touch-icon ') !== -1 || rel.indexOf(' apple-touch-icon-precomposed ') !== -1) {
+			favicons.push(links[i].href);
+		}
+	}
+
+	var scripts = document.querySelectorAll('script[type="application/ld+json"]');
+	for (var i = 0; i < scripts.length; i++) {
+		jsonld.push(scripts[i].textContent);
+	}
+
+	var description = document.querySelector('meta[name="description"]');
+	return {
+		title: document.title,
+		description: description ? (description.getAttribute('content') || '').trim() : '',
+		canonical: canonical,
+		favicons: favicons,
+		properties: properties,
+		jsonld: jsonld
+	};
+}`
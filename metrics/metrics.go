@@ -0,0 +1,284 @@
+// Package metrics exposes Prometheus metrics for phantomjs processes.
+//
+// Metrics are collected by wrapping the RPC transport of a process:
+//
+//	m := metrics.New(prometheus.DefaultRegisterer)
+//	m.Instrument(process)
+//
+// Resource events are counted once the process is open with InstrumentEvents.
+// Tasks and process rotations of a pool are measured with InstrumentPool, and
+// process replacements of a supervisor with InstrumentSupervisor.
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the collectors for process and page activity.
+type Metrics struct {
+	inFlight  prometheus.Gauge
+	duration  *prometheus.HistogramVec
+	errors    *prometheus.CounterVec
+	openPages prometheus.Gauge
+	renders   *prometheus.CounterVec
+	restarts  prometheus.Counter
+	resources *prometheus.CounterVec
+	tasks     *prometheus.CounterVec
+	taskWait  prometheus.Histogram
+	taskTime  prometheus.Histogram
+}
+
+// New returns a new set of metrics registered with reg.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "phantomjs",
+			Name:      "rpc_in_flight",
+			Help:      "Number of RPC calls currently in flight.",
+		}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "phantomjs",
+			Name:      "rpc_duration_seconds",
+			Help:      "Latency of RPC calls by endpoint.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, []string{"endpoint"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "phantomjs",
+			Name:      "rpc_errors_total",
+			Help:      "Number of failed RPC calls by endpoint.",
+		}, []string{"endpoint"}),
+		openPages: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "phantomjs",
+			Name:      "open_pages",
+			Help:      "Number of web pages currently open.",
+		}),
+		renders: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "phantomjs",
+			Name:      "renders_total",
+			Help:      "Number of completed renders by endpoint.",
+		}, []string{"endpoint"}),
+		restarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "phantomjs",
+			Name:      "process_restarts_total",
+			Help:      "Number of process restarts.",
+		}),
+		resources: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "phantomjs",
+			Name:      "resource_events_total",
+			Help:      "Number of resource events by type and HTTP status.",
+		}, []string{"type", "status"}),
+		tasks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "phantomjs",
+			Name:      "pool_tasks_total",
+			Help:      "Number of pool tasks by result.",
+		}, []string{"result"}),
+		taskWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "phantomjs",
+			Name:      "pool_task_wait_seconds",
+			Help:      "Time pool tasks waited for a page.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}),
+		taskTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "phantomjs",
+			Name:      "pool_task_duration_seconds",
+			Help:      "Duration of pool tasks.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}),
+	}
+
+	reg.MustRegister(m.inFlight, m.duration, m.errors, m.openPages, m.renders, m.restarts, m.resources, m.tasks, m.taskWait, m.taskTime)
+	return m
+}
+
+// Instrument wraps the transport of p so that its RPC calls are measured.
+// The pages that p has open are no longer counted once it is closed. An
+// OnClose hook that is already set is still called.
+func (m *Metrics) Instrument(p *phantomjs.Process) {
+	t := m.transport(p.Transport)
+	p.Transport = t
+
+	next := p.OnClose
+	p.OnClose = func(p *phantomjs.Process) {
+		t.reset()
+		if next != nil {
+			next(p)
+		}
+	}
+}
+
+// Transport returns a transport that measures calls sent through next.
+// If next is nil then http.DefaultTransport is used. Unlike Instrument, the
+// pages of a process that closes stay counted.
+func (m *Metrics) Transport(next http.RoundTripper) http.RoundTripper {
+	return m.transport(next)
+}
+
+// transport returns a new transport that measures calls sent through next.
+func (m *Metrics) transport(next http.RoundTripper) *transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{metrics: m, next: next}
+}
+
+// InstrumentEvents sets the event handler of p so that its resource events
+// are counted. The process must be open.
+func (m *Metrics) InstrumentEvents(p *phantomjs.Process) error {
+	return p.SetEventHandler(m.ObserveEvent)
+}
+
+// ObserveEvent counts a resource event, such as one passed to an event
+// handler. Events are counted by type, such as "received" or "error", and by
+// HTTP status for received resources. Other events are ignored.
+func (m *Metrics) ObserveEvent(e phantomjs.PageEvent) {
+	typ := strings.TrimPrefix(e.Event, "Resource")
+	if typ == e.Event || typ == "" {
+		return
+	}
+
+	var status string
+	if v, ok := e.Data["status"].(float64); ok && v > 0 {
+		status = strconv.Itoa(int(v))
+	}
+	m.resources.WithLabelValues(strings.ToLower(typ), status).Inc()
+}
+
+// ObserveRestart increments the process restart counter. Supervisors and
+// pools instrumented with InstrumentSupervisor and InstrumentPool call it
+// whenever a process is replaced.
+func (m *Metrics) ObserveRestart() {
+	m.restarts.Inc()
+}
+
+// InstrumentPool sets the OnTask hook of pool so that its tasks are measured,
+// and the OnRotate hook of its rotation policy, if any, so that its process
+// rotations are counted as restarts. Hooks that are already set are still
+// called.
+func (m *Metrics) InstrumentPool(pool *phantomjs.Pool) {
+	next := pool.OnTask
+	pool.OnTask = func(task phantomjs.TaskStats) {
+		m.ObserveTask(task)
+		if next != nil {
+			next(task)
+		}
+	}
+
+	if pool.Rotation != nil {
+		next := pool.Rotation.OnRotate
+		pool.Rotation.OnRotate = func(p *phantomjs.Process, err error) {
+			if err == nil {
+				m.ObserveRestart()
+			}
+			if next != nil {
+				next(p, err)
+			}
+		}
+	}
+}
+
+// InstrumentSupervisor sets the OnEvent hook of s so that its process
+// replacements are counted as restarts. A hook that is already set is still
+// called.
+func (m *Metrics) InstrumentSupervisor(s *phantomjs.Supervisor) {
+	next := s.OnEvent
+	s.OnEvent = func(e phantomjs.SupervisorEvent) {
+		if e.Type == phantomjs.EventProcessRecycling {
+			m.ObserveRestart()
+		}
+		if next != nil {
+			next(e)
+		}
+	}
+}
+
+// ObserveTask records a task run by a pool's WithPage. Tasks are counted by
+// result: "success", "error" or "panic".
+func (m *Metrics) ObserveTask(task phantomjs.TaskStats) {
+	result := "success"
+	if task.Panicked {
+		result = "panic"
+	} else if task.Err != nil {
+		result = "error"
+	}
+	m.tasks.WithLabelValues(result).Inc()
+	m.taskWait.Observe(task.Wait.Seconds())
+	m.taskTime.Observe(task.Duration.Seconds())
+}
+
+// transport is an http.RoundTripper that records metrics.
+type transport struct {
+	metrics *Metrics
+	next    http.RoundTripper
+
+	mu    sync.Mutex
+	pages int // pages open on the process
+}
+
+// addPages adjusts the number of open pages by n, never below zero.
+func (t *transport) addPages(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n = max(n, -t.pages)
+	t.pages += n
+	t.metrics.openPages.Add(float64(n))
+}
+
+// reset stops counting the open pages of a closed process.
+func (t *transport) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.metrics.openPages.Sub(float64(t.pages))
+	t.pages = 0
+}
+
+// RoundTrip sends req to the underlying transport and records its outcome.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := req.URL.Path
+	m := t.metrics
+
+	m.inFlight.Inc()
+	defer m.inFlight.Dec()
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	m.duration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+
+	if err != nil || resp.StatusCode != http.StatusOK {
+		m.errors.WithLabelValues(endpoint).Inc()
+		return resp, err
+	}
+
+	switch {
+	case endpoint == "/webpage/Create":
+		t.addPages(1)
+	case endpoint == "/webpage/CreateNamed":
+		// No page is created if the name is in use.
+		buf, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(buf))
+		if err != nil {
+			return resp, nil
+		}
+		var msg struct {
+			Exists bool `json:"exists"`
+		}
+		if json.Unmarshal(buf, &msg) == nil && !msg.Exists {
+			t.addPages(1)
+		}
+	case endpoint == "/webpage/Close":
+		t.addPages(-1)
+	case strings.HasPrefix(endpoint, "/webpage/Render"):
+		m.renders.WithLabelValues(endpoint).Inc()
+	}
+	return resp, nil
+}
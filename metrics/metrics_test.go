@@ -0,0 +1,187 @@
+package metrics_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Ensure RPC calls are counted and open pages are tracked.
+func TestMetrics_Instrument(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/CreateNamed":
+			if strings.Contains(readBody(r), `"taken"`) {
+				w.Write([]byte(`{"exists":true}`))
+				return
+			}
+			w.Write([]byte(`{"ref":{"id":"2"}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+	p := phantomjs.NewProcess(phantomjs.WithPort(portN))
+	m.Instrument(p)
+	openPages := func() float64 {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mf := range mfs {
+			if mf.GetName() == "phantomjs_open_pages" {
+				return mf.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+		t.Fatal("open pages not gathered")
+		return 0
+	}
+
+	page, err := p.CreateWebPage()
+	if err != nil {
+		t.Fatal(err)
+	} else if err := page.Render("out.png", "png", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := testutil.GatherAndCount(reg, "phantomjs_rpc_duration_seconds"); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("unexpected endpoint count: %d", n)
+	}
+	if n, err := testutil.GatherAndCount(reg, "phantomjs_renders_total"); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("unexpected render series: %d", n)
+	}
+
+	if err := page.Close(); err != nil {
+		t.Fatal(err)
+	} else if n := openPages(); n != 0 {
+		t.Fatalf("unexpected open pages: %v", n)
+	}
+
+	// Named pages are counted unless the name is in use, and pages are no
+	// longer counted once their process is closed.
+	if _, err := p.CreateNamedWebPage("main"); err != nil {
+		t.Fatal(err)
+	} else if _, err := p.CreateNamedWebPage("taken"); err != phantomjs.ErrPageExists {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, err := p.CreateWebPage(); err != nil {
+		t.Fatal(err)
+	} else if n := openPages(); n != 2 {
+		t.Fatalf("unexpected open pages: %v", n)
+	} else if err := p.Close(); err != nil {
+		t.Fatal(err)
+	} else if n := openPages(); n != 0 {
+		t.Fatalf("unexpected open pages after close: %v", n)
+	}
+}
+
+// Ensure resource events are counted by type and status.
+func TestMetrics_ObserveEvent(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+
+	m.ObserveEvent(phantomjs.PageEvent{Event: "ResourceRequested", Data: map[string]interface{}{"url": "http://a/"}})
+	m.ObserveEvent(phantomjs.PageEvent{Event: "ResourceReceived", Data: map[string]interface{}{"status": float64(200)}})
+	m.ObserveEvent(phantomjs.PageEvent{Event: "ResourceReceived", Data: map[string]interface{}{"status": float64(404)}})
+	m.ObserveEvent(phantomjs.PageEvent{Event: "ResourceError", Data: map[string]interface{}{"errorCode": float64(5)}})
+	m.ObserveEvent(phantomjs.PageEvent{Event: "ConsoleMessage"})
+
+	const exp = `
+# HELP phantomjs_resource_events_total Number of resource events by type and HTTP status.
+# TYPE phantomjs_resource_events_total counter
+phantomjs_resource_events_total{status="",type="error"} 1
+phantomjs_resource_events_total{status="",type="requested"} 1
+phantomjs_resource_events_total{status="200",type="received"} 1
+phantomjs_resource_events_total{status="404",type="received"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(exp), "phantomjs_resource_events_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure supervisor replacements and pool rotations are counted as restarts.
+func TestMetrics_Restarts(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+
+	var events int
+	s := phantomjs.NewSupervisor(1, nil)
+	s.OnEvent = func(phantomjs.SupervisorEvent) { events++ }
+	m.InstrumentSupervisor(s)
+	s.OnEvent(phantomjs.SupervisorEvent{Type: phantomjs.EventProcessStarted})
+	s.OnEvent(phantomjs.SupervisorEvent{Type: phantomjs.EventProcessRecycling, Reason: phantomjs.RecycleAge})
+	if events != 2 {
+		t.Fatalf("unexpected event count: %d", events)
+	}
+
+	pool := phantomjs.NewPool(1)
+	pool.Rotation = &phantomjs.RotationPolicy{MaxPages: 10}
+	m.InstrumentPool(pool)
+	pool.Rotation.OnRotate(nil, nil)
+	pool.Rotation.OnRotate(nil, errors.New("failed to open"))
+
+	const exp = `
+# HELP phantomjs_process_restarts_total Number of process restarts.
+# TYPE phantomjs_process_restarts_total counter
+phantomjs_process_restarts_total 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(exp), "phantomjs_process_restarts_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readBody returns the body of r as a string.
+func readBody(r *http.Request) string {
+	buf, _ := io.ReadAll(r.Body)
+	return string(buf)
+}
+
+// Ensure pool tasks are counted by result.
+func TestMetrics_InstrumentPool(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.New(reg)
+
+	var called bool
+	pool := phantomjs.NewPool(1)
+	pool.OnTask = func(phantomjs.TaskStats) { called = true }
+	m.InstrumentPool(pool)
+
+	pool.OnTask(phantomjs.TaskStats{Duration: time.Second})
+	pool.OnTask(phantomjs.TaskStats{Err: errors.New("failed")})
+	pool.OnTask(phantomjs.TaskStats{Panicked: true})
+	if !called {
+		t.Fatal("expected existing hook to be called")
+	}
+
+	const exp = `
+# HELP phantomjs_pool_tasks_total Number of pool tasks by result.
+# TYPE phantomjs_pool_tasks_total counter
+phantomjs_pool_tasks_total{result="error"} 1
+phantomjs_pool_tasks_total{result="panic"} 1
+phantomjs_pool_tasks_total{result="success"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(exp), "phantomjs_pool_tasks_total"); err != nil {
+		t.Fatal(err)
+	}
+}
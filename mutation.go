@@ -0,0 +1,206 @@
+package phantomjs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMutationBufferSize is the channel size used by WatchMutations if
+// MutationOptions.BufferSize is not set.
+const DefaultMutationBufferSize = 100
+
+// mutationPollTimeout is how long the shim holds a request open while waiting
+// for mutations.
+const mutationPollTimeout = 1 * time.Second
+
+// MutationOptions represents options for WatchMutations.
+type MutationOptions struct {
+	// Also report attribute and text changes. Added and removed nodes are
+	// always reported.
+	Attributes    bool
+	CharacterData bool
+
+	// Size of the mutation channel. Mutations are not dropped; the page's
+	// records are queued in PhantomJS until they are received.
+	BufferSize int
+}
+
+// Mutation represents a change to the DOM observed by a MutationWatcher.
+type Mutation struct {
+	// Type of mutation: "childList", "attributes" or "characterData".
+	Type string
+
+	// Node that changed.
+	Target NodeSummary
+
+	// Nodes added to or removed from the target.
+	Added   []NodeSummary
+	Removed []NodeSummary
+
+	// Name of the changed attribute and the new attribute value or text.
+	AttributeName string
+	Value         string
+}
+
+// NodeSummary describes a DOM node.
+type NodeSummary struct {
+	// Lowercase tag name of elements, or the node name of other nodes such
+	// as "#text".
+	Tag       string
+	ID        string
+	ClassName string
+
+	// Text content with whitespace collapsed, truncated to 200 characters.
+	Text string
+}
+
+// MutationWatcher streams the mutations of an element.
+type MutationWatcher struct {
+	page   *WebPage
+	id     string
+	c      chan Mutation
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+// WatchMutations observes the element matched by selector and its descendants
+// and sends their mutations to the watcher's channel. The observer is
+// reinstalled whenever the page loads a new document. Returns
+// ErrElementNotFound if selector matches no element.
+func (p *WebPage) WatchMutations(selector string, opts MutationOptions) (*MutationWatcher, error) {
+	req := map[string]interface{}{
+		"ref":      p.ref.id,
+		"selector": selector,
+		"options": map[string]interface{}{
+			"attributes":    opts.Attributes,
+			"characterData": opts.CharacterData,
+		},
+	}
+	var resp struct {
+		Found bool   `json:"found"`
+		ID    string `json:"id"`
+	}
+	if err := p.doJSON("POST", "/webpage/WatchMutations", req, &resp); err != nil {
+		return nil, err
+	} else if !resp.Found {
+		return nil, ErrElementNotFound
+	}
+
+	size := opts.BufferSize
+	if size <= 0 {
+		size = DefaultMutationBufferSize
+	}
+	ctx, cancel := context.WithCancel(p.context())
+	w := &MutationWatcher{
+		page:   p.WithContext(ctx),
+		id:     resp.ID,
+		c:      make(chan Mutation, size),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go w.poll(ctx)
+	return w, nil
+}
+
+// C returns the channel that mutations are sent on. It is closed when the
+// watcher is closed or fails.
+func (w *MutationWatcher) C() <-chan Mutation {
+	return w.c
+}
+
+// Err returns the error that stopped the watcher, if any.
+func (w *MutationWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close stops observing the page and closes the channel.
+func (w *MutationWatcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		w.cancel()
+		<-w.done
+		err = w.page.ref.process.doJSON(context.Background(), "POST", "/webpage/UnwatchMutations", map[string]interface{}{"ref": w.page.ref.id, "id": w.id}, nil)
+	})
+	return err
+}
+
+// poll receives mutations from the shim until the watcher is closed.
+func (w *MutationWatcher) poll(ctx context.Context) {
+	defer close(w.done)
+	defer close(w.c)
+
+	req := map[string]interface{}{
+		"ref":     w.page.ref.id,
+		"id":      w.id,
+		"timeout": int(mutationPollTimeout / time.Millisecond),
+	}
+	for {
+		var resp struct {
+			Closed  bool             `json:"closed"`
+			Records []mutationRecord `json:"records"`
+		}
+		if err := w.page.ref.process.doJSON(ctx, "POST", "/webpage/NextMutations", req, &resp); err != nil {
+			if ctx.Err() == nil {
+				w.mu.Lock()
+				w.err = err
+				w.mu.Unlock()
+			}
+			return
+		} else if resp.Closed {
+			return
+		}
+
+		for _, r := range resp.Records {
+			select {
+			case w.c <- r.mutation():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// mutationRecord is a struct for decoding mutations from the shim.
+type mutationRecord struct {
+	Type          string      `json:"type"`
+	Target        *nodeJSON   `json:"target"`
+	Added         []*nodeJSON `json:"added"`
+	Removed       []*nodeJSON `json:"removed"`
+	AttributeName string      `json:"attributeName"`
+	Value         string      `json:"value"`
+}
+
+// mutation returns the record as a Mutation.
+func (r *mutationRecord) mutation() Mutation {
+	m := Mutation{Type: r.Type, Target: r.Target.summary(), AttributeName: r.AttributeName, Value: r.Value}
+	for _, n := range r.Added {
+		m.Added = append(m.Added, n.summary())
+	}
+	for _, n := range r.Removed {
+		m.Removed = append(m.Removed, n.summary())
+	}
+	return m
+}
+
+// nodeJSON is a struct for decoding node summaries from the shim.
+type nodeJSON struct {
+	Tag       string `json:"tag"`
+	ID        string `json:"id"`
+	ClassName string `json:"className"`
+	Text      string `json:"text"`
+}
+
+// summary returns n as a NodeSummary.
+func (n *nodeJSON) summary() NodeSummary {
+	if n == nil {
+		return NodeSummary{}
+	}
+	return NodeSummary{Tag: n.Tag, ID: n.ID, ClassName: n.ClassName, Text: n.Text}
+}
@@ -0,0 +1,228 @@
+package phantomjs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrInvalidWaitUntil is returned when OpenOptions.WaitUntil is not a
+	// known event.
+	ErrInvalidWaitUntil = errors.New("invalid wait until event")
+)
+
+// OpenResult represents the outcome of opening a URL.
+//
+// PhantomJS reports pages that load with an HTTP error status, such as a 404,
+// as successful. Check StatusCode to distinguish them.
+type OpenResult struct {
+	// Final URL of the page after redirects.
+	URL string
+
+	// Load status reported by PhantomJS: "success" or "fail".
+	Status string
+
+	// HTTP status of the main document. Zero if no response was received,
+	// such as when the host cannot be resolved.
+	StatusCode int
+	StatusText string
+
+	// Headers of the main document's response, such as Cache-Control.
+	Header http.Header
+
+	// Redirects followed before the final URL, in order.
+	Redirects []Redirect
+
+	// Wall-clock time taken to open the page.
+	Duration time.Duration
+
+	// Network error of the main document, if any, with its PhantomJS
+	// (QNetworkReply) error code.
+	Error     string
+	ErrorCode int
+
+	// True if the main document exceeded the resource timeout.
+	TimedOut bool
+}
+
+// Redirect represents a redirect response followed while opening a page.
+type Redirect struct {
+	URL        string
+	StatusCode int
+}
+
+// OpenError is returned when a page fails to load.
+type OpenError struct {
+	Result *OpenResult
+}
+
+// Error returns the reason the page failed to load.
+func (e *OpenError) Error() string {
+	switch r := e.Result; {
+	case r.TimedOut:
+		return "failed: timeout"
+	case r.Error != "":
+		return "failed: " + r.Error
+	case r.StatusCode != 0:
+		return "failed: http status " + strconv.Itoa(r.StatusCode)
+	default:
+		return "failed"
+	}
+}
+
+// Events that OpenOptions.WaitUntil can wait for.
+const (
+	// WaitLoad waits for the page's load event. This is the default.
+	WaitLoad = "load"
+
+	// WaitDOMReady waits until the document has been parsed, without
+	// waiting for images and other subresources.
+	WaitDOMReady = "domready"
+
+	// WaitNetworkIdle waits for the load event and then until no more than
+	// MaxInflight requests have been pending for IdleTime.
+	WaitNetworkIdle = "networkidle"
+)
+
+// DefaultNetworkIdleTime is the IdleTime used by WaitNetworkIdle if none is set.
+const DefaultNetworkIdleTime = 500 * time.Millisecond
+
+// OpenOptions represents options for opening a URL.
+type OpenOptions struct {
+	// Event to wait for before returning. Defaults to WaitLoad.
+	WaitUntil string
+
+	// Quiet period and number of requests allowed to remain pending for
+	// WaitNetworkIdle. Pages that poll the network may never become idle so
+	// use the page's context to bound the wait.
+	IdleTime    time.Duration
+	MaxInflight int
+}
+
+// OpenURL opens a URL and returns the response of its main document. If the
+// page fails to load then the result is returned with an *OpenError, and if
+// one of the process' Detectors recognizes a block page then it is returned
+// with a *BlockedError.
+func (p *WebPage) OpenURL(url string) (*OpenResult, error) {
+	return p.OpenWithOptions(url, OpenOptions{})
+}
+
+// OpenWithOptions opens a URL like OpenURL and waits for the event selected
+// by opts.
+func (p *WebPage) OpenWithOptions(url string, opts OpenOptions) (*OpenResult, error) {
+	switch opts.WaitUntil {
+	case "", WaitLoad, WaitDOMReady:
+	case WaitNetworkIdle:
+		if opts.IdleTime == 0 {
+			opts.IdleTime = DefaultNetworkIdleTime
+		}
+	default:
+		return nil, ErrInvalidWaitUntil
+	}
+
+	req := map[string]interface{}{
+		"ref":         p.ref.id,
+		"url":         url,
+		"waitUntil":   opts.WaitUntil,
+		"idleTime":    int64(opts.IdleTime / time.Millisecond),
+		"maxInflight": opts.MaxInflight,
+	}
+	var resp struct {
+		Status     string       `json:"status"`
+		URL        string       `json:"url"`
+		StatusCode int          `json:"statusCode"`
+		StatusText string       `json:"statusText"`
+		Headers    []headerJSON `json:"headers"`
+		Redirects  []struct {
+			URL        string `json:"url"`
+			StatusCode int    `json:"statusCode"`
+		} `json:"redirects"`
+		Error     string `json:"networkError"`
+		ErrorCode int    `json:"networkErrorCode"`
+		TimedOut  bool   `json:"timedOut"`
+		Start     int64  `json:"start"`
+		End       int64  `json:"end"`
+	}
+	if err := p.doJSON("POST", "/webpage/Open", req, &resp); err != nil {
+		return nil, err
+	}
+
+	r := &OpenResult{
+		URL:        resp.URL,
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		StatusText: resp.StatusText,
+		Header:     decodeHeaderJSON(resp.Headers),
+		Duration:   msDuration(resp.Start, resp.End),
+		Error:      resp.Error,
+		ErrorCode:  resp.ErrorCode,
+		TimedOut:   resp.TimedOut,
+	}
+	for _, v := range resp.Redirects {
+		r.Redirects = append(r.Redirects, Redirect{URL: v.URL, StatusCode: v.StatusCode})
+	}
+
+	if r.Status != "success" {
+		return r, &OpenError{Result: r}
+	} else if err := p.detect(r); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// OpenHandle represents a URL being opened in the background by OpenAsync.
+type OpenHandle struct {
+	page   *WebPage
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	result *OpenResult
+	err    error
+}
+
+// OpenAsync starts opening a URL and returns immediately. Other calls may be
+// made on the page, such as subscribing to events, while the page loads.
+func (p *WebPage) OpenAsync(url string) *OpenHandle {
+	ctx, cancel := context.WithCancel(p.context())
+	h := &OpenHandle{page: p, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(h.done)
+		defer cancel()
+		// The open runs alongside the page's other calls, so it does not
+		// hold the page's operation queue while the page loads.
+		page := &WebPage{ref: p.ref, ctx: ctx, unqueued: true}
+		h.result, h.err = page.OpenURL(url)
+		if h.err != nil && ctx.Err() != nil {
+			h.result, h.err = nil, ctx.Err()
+		}
+	}()
+	return h
+}
+
+// Done returns a channel that is closed when the open completes.
+func (h *OpenHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Result waits for the open to complete and returns its result. Returns the
+// context's error if the open was canceled.
+func (h *OpenHandle) Result() (*OpenResult, error) {
+	<-h.done
+	return h.result, h.err
+}
+
+// Cancel stops the page from loading and abandons the open. It has no effect
+// if the open has already completed.
+func (h *OpenHandle) Cancel() {
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+	h.cancel()
+	h.page.Stop()
+	<-h.done
+}
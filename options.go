@@ -0,0 +1,70 @@
+package phantomjs
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Option configures a Process created by NewProcess.
+type Option func(*Process)
+
+// WithBinPath sets the path to the 'phantomjs' binary.
+func WithBinPath(path string) Option {
+	return func(p *Process) { p.BinPath = path }
+}
+
+// WithPort sets the HTTP port used to communicate with phantomjs.
+func WithPort(port int) Option {
+	return func(p *Process) { p.Port = port }
+}
+
+// WithPortRetries sets the number of following ports that Open tries when
+// the port is in use.
+func WithPortRetries(n int) Option {
+	return func(p *Process) { p.PortRetries = n }
+}
+
+// WithTimeout sets how long Open waits for the process to respond.
+func WithTimeout(d time.Duration) Option {
+	return func(p *Process) { p.StartTimeout = d }
+}
+
+// WithProbe sets the probe that checks whether the process is ready.
+func WithProbe(probe Probe) Option {
+	return func(p *Process) { p.Probe = probe }
+}
+
+// WithDetectors appends detectors that recognize block pages.
+func WithDetectors(detectors ...Detector) Option {
+	return func(p *Process) { p.Detectors = append(p.Detectors, detectors...) }
+}
+
+// WithFlags appends command line flags passed to phantomjs.
+func WithFlags(flags ...string) Option {
+	return func(p *Process) { p.Flags = append(p.Flags, flags...) }
+}
+
+// WithLogger sets the logger that receives RPC and lifecycle logs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Process) { p.Logger = logger }
+}
+
+// WithEnv appends environment variables, in "key=value" form, to the process.
+func WithEnv(env ...string) Option {
+	return func(p *Process) { p.Env = append(p.Env, env...) }
+}
+
+// WithMiddleware appends middleware wrapped around every RPC call.
+func WithMiddleware(mw ...RPCMiddleware) Option {
+	return func(p *Process) { p.Middleware = append(p.Middleware, mw...) }
+}
+
+// WithJanitor sets the janitor that records the process while it runs.
+func WithJanitor(j *Janitor) Option {
+	return func(p *Process) { p.Janitor = j }
+}
+
+// WithToken sets the shared secret sent to the shim.
+func WithToken(token string) Option {
+	return func(p *Process) { p.Token = token }
+}
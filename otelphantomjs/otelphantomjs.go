@@ -0,0 +1,210 @@
+// Package otelphantomjs traces phantomjs operations with OpenTelemetry.
+//
+// Every RPC call sent to the PhantomJS shim becomes a span. Calls made with a
+// page context, via WebPage.WithContext, become children of the span in that
+// context so renders can be traced alongside the rest of a request. Page
+// checkouts of a pool wrapped with InstrumentPool are traced as well:
+//
+//	otelphantomjs.Instrument(process)
+//	...
+//	page = page.WithContext(r.Context())
+//	page.Open(url)
+package otelphantomjs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/benbjohnson/phantomjs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name used for the tracer.
+const ScopeName = "github.com/benbjohnson/phantomjs/otelphantomjs"
+
+// Attribute keys set on spans.
+const (
+	EndpointKey     = attribute.Key("phantomjs.endpoint")
+	RefKey          = attribute.Key("phantomjs.ref")
+	URLKey          = attribute.Key("url.full")
+	StatusKey       = attribute.Key("phantomjs.status")
+	FormatKey       = attribute.Key("phantomjs.render.format")
+	RequestSizeKey  = attribute.Key("phantomjs.request.size")
+	ResponseSizeKey = attribute.Key("phantomjs.response.size")
+	WaitKey         = attribute.Key("phantomjs.pool.wait")
+	PortKey         = attribute.Key("phantomjs.process.port")
+	PIDKey          = attribute.Key("process.pid")
+)
+
+// DefaultEndpoints are the prefixes of the endpoints traced when no filter is
+// set, so that "/webpage/Render" also traces RenderBase64, RenderWithOptions
+// and RenderAll.
+var DefaultEndpoints = []string{
+	"/webpage/Open",
+	"/webpage/Evaluate",
+	"/webpage/Render",
+}
+
+// Option configures the transport.
+type Option func(*transport)
+
+// WithTracerProvider sets the provider used to create the tracer.
+// Defaults to the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *transport) { t.tracer = tp.Tracer(ScopeName) }
+}
+
+// WithFilter sets a function that reports whether an endpoint is traced.
+// Defaults to the endpoints that start with one of DefaultEndpoints.
+func WithFilter(fn func(endpoint string) bool) Option {
+	return func(t *transport) { t.filter = fn }
+}
+
+// Instrument wraps the transport of p so that its RPC calls are traced.
+func Instrument(p *phantomjs.Process, opts ...Option) {
+	p.Transport = NewTransport(p.Transport, opts...)
+}
+
+// NewTransport returns a transport that traces calls sent through next.
+// If next is nil then http.DefaultTransport is used.
+func NewTransport(next http.RoundTripper, opts ...Option) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &transport{
+		next:   next,
+		tracer: otel.GetTracerProvider().Tracer(ScopeName),
+		filter: defaultFilter,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// defaultFilter reports whether endpoint starts with one of DefaultEndpoints.
+func defaultFilter(endpoint string) bool {
+	for _, e := range DefaultEndpoints {
+		if strings.HasPrefix(endpoint, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// transport is an http.RoundTripper that creates spans.
+type transport struct {
+	next   http.RoundTripper
+	tracer trace.Tracer
+	filter func(endpoint string) bool
+}
+
+// RoundTrip sends req within a span named after its endpoint.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := req.URL.Path
+	if !t.filter(endpoint) {
+		return t.next.RoundTrip(req)
+	}
+
+	// Buffer request body so attributes can be extracted.
+	var body []byte
+	if req.Body != nil {
+		buf, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		body = buf
+	}
+
+	ctx, span := t.tracer.Start(req.Context(), spanName(endpoint), trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	attrs := requestAttributes(body)
+	span.SetAttributes(EndpointKey.String(endpoint), RequestSizeKey.Int(len(body)))
+	span.SetAttributes(attrs...)
+
+	// Record the URL opened by a checked out page on its checkout span.
+	if checkout, ok := req.Context().Value(checkoutKey{}).(trace.Span); ok && strings.HasPrefix(endpoint, "/webpage/Open") {
+		for _, kv := range attrs {
+			if kv.Key == URLKey {
+				checkout.SetAttributes(kv)
+			}
+		}
+	}
+
+	req = req.Clone(ctx)
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	// Buffer response body so status and size can be recorded.
+	buf, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	span.SetAttributes(ResponseSizeKey.Int(len(buf)))
+
+	var msg struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	json.Unmarshal(buf, &msg)
+	if msg.Status != "" {
+		span.SetAttributes(StatusKey.String(msg.Status))
+	}
+
+	switch {
+	case msg.Error != "":
+		span.SetStatus(codes.Error, msg.Error)
+	case resp.StatusCode != http.StatusOK:
+		span.SetStatus(codes.Error, fmt.Sprintf("unexpected status: %d", resp.StatusCode))
+	case msg.Status != "" && msg.Status != "success":
+		span.SetStatus(codes.Error, "open failed: "+msg.Status)
+	}
+	return resp, nil
+}
+
+// spanName returns the span name for an endpoint, e.g. "phantomjs.webpage.Open".
+func spanName(endpoint string) string {
+	return "phantomjs" + strings.Replace(endpoint, "/", ".", -1)
+}
+
+// requestAttributes extracts well-known fields from an RPC request body.
+func requestAttributes(body []byte) []attribute.KeyValue {
+	var msg struct {
+		Ref    string `json:"ref"`
+		URL    string `json:"url"`
+		Format string `json:"format"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil
+	}
+
+	var a []attribute.KeyValue
+	if msg.Ref != "" {
+		a = append(a, RefKey.String(msg.Ref))
+	}
+	if msg.URL != "" {
+		a = append(a, URLKey.String(msg.URL))
+	}
+	if msg.Format != "" {
+		a = append(a, FormatKey.String(msg.Format))
+	}
+	return a
+}
@@ -0,0 +1,167 @@
+package otelphantomjs_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/otelphantomjs"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Ensure page operations create child spans of the page context.
+func TestInstrument(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Open":
+			w.Write([]byte(`{"status":"success"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	p := phantomjs.NewProcess(phantomjs.WithPort(portN))
+	otelphantomjs.Instrument(p, otelphantomjs.WithTracerProvider(tp))
+
+	page, err := p.CreateWebPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	if err := page.WithContext(ctx).Open("http://example.com"); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected span count: %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name != "phantomjs.webpage.Open" {
+		t.Fatalf("unexpected span name: %s", span.Name)
+	} else if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("expected span to be a child of the page context")
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range span.Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["url.full"] != "http://example.com" || attrs["phantomjs.status"] != "success" || attrs["phantomjs.ref"] != "1" {
+		t.Fatalf("unexpected attributes: %v", attrs)
+	}
+}
+
+// Ensure every render endpoint is traced by default.
+func TestInstrument_Render(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		default:
+			w.Write([]byte(`{"data":""}`))
+		}
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	p := phantomjs.NewProcess(phantomjs.WithPort(portN))
+	otelphantomjs.Instrument(p, otelphantomjs.WithTracerProvider(tp))
+	page, err := p.CreateWebPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page.RenderWithOptions(phantomjs.RenderOptions{Format: "PNG"})
+	page.RenderAll([]phantomjs.RenderSpec{{Format: "PNG"}})
+	page.Title()
+
+	var names []string
+	for _, span := range exporter.GetSpans() {
+		names = append(names, span.Name)
+	}
+	if len(names) != 2 || names[0] != "phantomjs.webpage.RenderWithOptions" || names[1] != "phantomjs.webpage.RenderAll" {
+		t.Fatalf("unexpected spans: %v", names)
+	}
+}
+
+// Ensure pool checkouts are traced with their wait, process and URL.
+func TestInstrumentPool(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Open":
+			w.Write([]byte(`{"status":"success"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	p := phantomjs.NewProcess(phantomjs.WithPort(portN))
+	otelphantomjs.Instrument(p, otelphantomjs.WithTracerProvider(tp))
+	pool := otelphantomjs.InstrumentPool(phantomjs.NewPool(1, p), otelphantomjs.WithTracerProvider(tp))
+
+	if err := pool.WithPage(context.Background(), func(page *phantomjs.WebPage) error {
+		return page.Open("http://example.com")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	page, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if err := pool.Put(page); err != nil {
+		t.Fatal(err)
+	}
+
+	var checkouts []sdktrace.ReadOnlySpan
+	for _, span := range exporter.GetSpans().Snapshots() {
+		if span.Name() == otelphantomjs.CheckoutSpanName {
+			checkouts = append(checkouts, span)
+		}
+	}
+	if len(checkouts) != 2 {
+		t.Fatalf("unexpected checkout span count: %d", len(checkouts))
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range checkouts[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["url.full"] != "http://example.com" || attrs["phantomjs.process.port"] != port || attrs["phantomjs.pool.wait"] == "" {
+		t.Fatalf("unexpected attributes: %v", attrs)
+	}
+
+	// RPC calls of the checked out page are children of the checkout.
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "phantomjs.webpage.Open" && span.Parent.SpanID() != checkouts[0].SpanContext().SpanID() {
+			t.Fatal("expected open span to be a child of the checkout span")
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package otelphantomjs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CheckoutSpanName is the name of the spans of pool checkouts.
+const CheckoutSpanName = "phantomjs.pool.Checkout"
+
+// Pool wraps a pool so that its page checkouts are traced. A checkout is a
+// span from Get until Put, or around the task of WithPage, that records the
+// time spent waiting for a page and the process the page was created on. The
+// RPC calls of the checked out page are children of the span, and if the
+// pool's processes are instrumented, the span also records the URL that the
+// page opens:
+//
+//	pool := otelphantomjs.InstrumentPool(phantomjs.NewPool(4, process))
+//	otelphantomjs.Instrument(process)
+//	err := pool.WithPage(r.Context(), func(page *phantomjs.WebPage) error {
+//		return page.Open(url)
+//	})
+type Pool struct {
+	*phantomjs.Pool
+	tracer trace.Tracer
+	spans  sync.Map // checkout spans by page ref
+}
+
+// checkoutKey is the context key of the span of a checkout.
+type checkoutKey struct{}
+
+// InstrumentPool returns a wrapper of pool that traces page checkouts. Only
+// the WithTracerProvider option applies.
+func InstrumentPool(pool *phantomjs.Pool, opts ...Option) *Pool {
+	t := &transport{tracer: otel.GetTracerProvider().Tracer(ScopeName)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return &Pool{Pool: pool, tracer: t.tracer}
+}
+
+// Get checks out a page within a new checkout span, which ends when the page
+// is returned with Put.
+func (p *Pool) Get(ctx context.Context) (*phantomjs.WebPage, error) {
+	ctx, span := p.start(ctx)
+	start := time.Now()
+	page, err := p.Pool.Get(ctx)
+	span.SetAttributes(WaitKey.Float64(time.Since(start).Seconds()))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+	span.SetAttributes(processAttributes(page.Ref().Process())...)
+	p.spans.Store(page.Ref(), span)
+	return page, nil
+}
+
+// Put returns page to the pool and ends its checkout span.
+func (p *Pool) Put(page *phantomjs.WebPage) error {
+	err := p.Pool.Put(page)
+	if v, ok := p.spans.LoadAndDelete(page.Ref()); ok {
+		span := v.(trace.Span)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+	return err
+}
+
+// WithPage runs fn on a checked out page within a new checkout span.
+func (p *Pool) WithPage(ctx context.Context, fn func(page *phantomjs.WebPage) error) error {
+	ctx, span := p.start(ctx)
+	defer span.End()
+
+	start, waited := time.Now(), false
+	err := p.Pool.WithPage(ctx, func(page *phantomjs.WebPage) error {
+		span.SetAttributes(WaitKey.Float64(time.Since(start).Seconds()))
+		span.SetAttributes(processAttributes(page.Ref().Process())...)
+		waited = true
+		return fn(page)
+	})
+	if !waited {
+		span.SetAttributes(WaitKey.Float64(time.Since(start).Seconds()))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// start starts a checkout span and returns a context that carries it.
+func (p *Pool) start(ctx context.Context) (context.Context, trace.Span) {
+	ctx, span := p.tracer.Start(ctx, CheckoutSpanName)
+	return context.WithValue(ctx, checkoutKey{}, span), span
+}
+
+// processAttributes returns the attributes that identify a process.
+func processAttributes(process *phantomjs.Process) []attribute.KeyValue {
+	a := []attribute.KeyValue{PortKey.Int(process.Port)}
+	if pid := process.PID(); pid != 0 {
+		a = append(a, PIDKey.Int(pid))
+	}
+	return a
+}
@@ -0,0 +1,150 @@
+package phantomjs
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output streams of a process.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// NoisyOutput matches lines that PhantomJS and Qt print routinely and that
+// are dropped by Process.FilterOutput, such as font and image warnings.
+var NoisyOutput = []*regexp.Regexp{
+	regexp.MustCompile(`QFont::setPixelSize`),
+	regexp.MustCompile(`QFontDatabase`),
+	regexp.MustCompile(`^libpng warning`),
+	regexp.MustCompile(`^Fontconfig (warning|error)`),
+	regexp.MustCompile(`QNetworkReplyImplPrivate::error`),
+	regexp.MustCompile(`QSslSocket: cannot (resolve|call)`),
+	regexp.MustCompile(`Unsafe JavaScript attempt to access frame`),
+	regexp.MustCompile(`Attempting to change the setter of an unconfigurable property`),
+}
+
+// OutputLine represents a line written by the process to stdout or stderr.
+type OutputLine struct {
+	// Stream the line was written to: StreamStdout or StreamStderr.
+	Stream string
+
+	// Time from the line's timestamp prefix, or the time the line was read
+	// if it has none.
+	Time time.Time
+
+	// Level from the line's "[WARNING]" style prefix. Defaults to info for
+	// stdout and warn for stderr.
+	Level slog.Level
+
+	// Text of the line without its timestamp and level.
+	Text string
+
+	// Line as written by the process, without the trailing newline.
+	Raw string
+}
+
+// outputLineRegexp matches the optional timestamp and level that PhantomJS
+// prefixes its own messages with, such as
+// "2016-01-02T15:04:05 [WARNING] - message".
+var outputLineRegexp = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2})?\s*\[(DEBUG|INFO|WARNING|ERROR|CRITICAL|FATAL)\]\s*(?:- )?(.*)$`)
+
+// ParseOutputLine parses a line written by the process to stream.
+func ParseOutputLine(stream, s string) OutputLine {
+	s = strings.TrimSuffix(s, "\r")
+	line := OutputLine{Stream: stream, Time: time.Now(), Level: slog.LevelInfo, Text: s, Raw: s}
+	if stream == StreamStderr {
+		line.Level = slog.LevelWarn
+	}
+
+	m := outputLineRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return line
+	}
+	if m[1] != "" {
+		if t, err := time.ParseInLocation("2006-01-02T15:04:05", m[1], time.Local); err == nil {
+			line.Time = t
+		}
+	}
+	switch m[2] {
+	case "DEBUG":
+		line.Level = slog.LevelDebug
+	case "INFO":
+		line.Level = slog.LevelInfo
+	case "WARNING":
+		line.Level = slog.LevelWarn
+	default:
+		line.Level = slog.LevelError
+	}
+	line.Text = m[3]
+	return line
+}
+
+// Noisy returns true if the line matches NoisyOutput.
+func (l OutputLine) Noisy() bool {
+	for _, re := range NoisyOutput {
+		if re.MatchString(l.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogOutput returns a callback for Process.OnStdoutLine and
+// Process.OnStderrLine that writes lines to logger at their level.
+func LogOutput(logger *slog.Logger) func(OutputLine) {
+	return func(l OutputLine) {
+		logger.Log(context.Background(), l.Level, l.Text, "stream", l.Stream, "time", l.Time)
+	}
+}
+
+// lineWriter splits the output of a stream into lines and passes them to a
+// callback.
+type lineWriter struct {
+	mu     sync.Mutex
+	buf    []byte
+	stream string
+	filter bool
+	fn     func(OutputLine)
+}
+
+// Write calls the callback for each complete line in buf and keeps the
+// remainder until the next write.
+func (w *lineWriter) Write(buf []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, buf...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i == -1 {
+			break
+		}
+		w.emit(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(buf), nil
+}
+
+// Flush calls the callback for the remaining partial line, if any.
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(s string) {
+	line := ParseOutputLine(w.stream, s)
+	if w.filter && line.Noisy() {
+		return
+	}
+	w.fn(line)
+}
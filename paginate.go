@@ -0,0 +1,139 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+)
+
+// DefaultPaginateTimeout is the default time to wait for the next page after
+// clicking the next button.
+const DefaultPaginateTimeout = 30 * time.Second
+
+var (
+	// ErrPaginateTimeout is returned by Paginate when the next page does not
+	// load in time after clicking the next button.
+	ErrPaginateTimeout = errors.New("next page did not load")
+)
+
+// ButtonSpec describes the button that moves a listing to its next page.
+type ButtonSpec struct {
+	// Selector of the next button, such as "a[rel=next]".
+	Selector string
+
+	// Maximum time to wait for the next page after each click.
+	// Defaults to the page's default timeout or DefaultPaginateTimeout.
+	Timeout time.Duration
+}
+
+// Predicate reports whether pagination should stop after page n, counted
+// from 1.
+type Predicate func(page *WebPage, n int) (bool, error)
+
+// MaxPages returns a predicate that stops pagination after n pages.
+func MaxPages(n int) Predicate {
+	return func(page *WebPage, i int) (bool, error) {
+		return i >= n, nil
+	}
+}
+
+// Paginate returns an iterator over the pages of a listing. It yields the
+// current page as page 1, then clicks the next button and yields each
+// following page once it has loaded:
+//
+//	for n, err := range page.Paginate(phantomjs.ButtonSpec{Selector: "a.next"}, phantomjs.MaxPages(10)) {
+//		if err != nil {
+//			return err
+//		}
+//		// Extract the results of page n.
+//	}
+//
+// The next page has loaded once the click navigates to a new document that
+// finished loading, or changes the content of the current one for listings
+// updated by scripts. Iteration stops when until returns true, which may be
+// nil, or when the next button is missing, disabled or hidden. Errors, such
+// as ErrPaginateTimeout, are yielded with the number of the page that failed
+// and end the iteration.
+func (p *WebPage) Paginate(next ButtonSpec, until Predicate) iter.Seq2[int, error] {
+	return func(yield func(int, error) bool) {
+		for n := 1; ; n++ {
+			if !yield(n, nil) {
+				return
+			}
+
+			if until != nil {
+				if done, err := until(p, n); err != nil {
+					yield(n, err)
+					return
+				} else if done {
+					return
+				}
+			}
+
+			if ok, err := p.nextPage(next); err != nil {
+				yield(n+1, err)
+				return
+			} else if !ok {
+				return
+			}
+		}
+	}
+}
+
+// nextPage clicks the next button and waits for the next page. Returns false
+// if there is no enabled next button.
+func (p *WebPage) nextPage(next ButtonSpec) (bool, error) {
+	sel, err := json.Marshal(next.Selector)
+	if err != nil {
+		return false, err
+	}
+
+	var v *struct{ X, Y float64 }
+	if err := p.evaluateInto(fmt.Sprintf(paginateClickScript, sel), &v); err != nil {
+		return false, err
+	} else if v == nil {
+		return false, nil
+	} else if err := p.SendMouseEvent("click", round(v.X), round(v.Y), "left"); err != nil {
+		return false, err
+	}
+
+	timeout := next.Timeout
+	if timeout <= 0 {
+		timeout = p.timeoutOr(DefaultPaginateTimeout)
+	}
+	return true, p.waitFor(paginateWaitScript, timeout, ErrPaginateTimeout)
+}
+
+// hashFunc is a JavaScript function expression that returns a hash of a
+// string, for detecting content changes without keeping the content.
+const hashFunc = `function hash(s) {
+	var h = 5381;
+	for (var i = 0; i < s.length; i++) h = ((h << 5) + h + s.charCodeAt(i)) | 0;
+	return h;
+}`
+
+// paginateClickScript records the content of the page and returns the
+// center of the next button, or null if it is missing, disabled or hidden.
+const paginateClickScript = `function() {
+	var hash = ` + hashFunc + `;
+	var el = document.querySelector(%s);
+	if (!el || el.disabled || el.getAttribute('aria-disabled') === 'true' || /(^|\s)disabled(\s|$)/.test(el.className)) return null;
+
+	el.scrollIntoView();
+	var r = el.getBoundingClientRect();
+	if (r.width === 0 && r.height === 0) return null;
+
+	window.__phantomPaginate = {hash: hash(document.body.innerHTML)};
+	return {x: r.left + r.width / 2, y: r.top + r.height / 2};
+}`
+
+// paginateWaitScript returns true once a new document has loaded or the
+// content recorded by paginateClickScript has changed.
+const paginateWaitScript = `function() {
+	var hash = ` + hashFunc + `;
+	if (document.readyState !== 'complete' || !document.body) return false;
+	var m = window.__phantomPaginate;
+	return !m || hash(document.body.innerHTML) !== m.hash;
+}`
@@ -0,0 +1,474 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+// DefaultTOCTitle is the heading of the table of contents if none is set.
+const DefaultTOCTitle = "Contents"
+
+// Table of contents layout, in points.
+const (
+	tocMargin     = 72
+	tocLeading    = 20
+	tocFontSize   = 12
+	tocHeaderSize = 18
+)
+
+// MergeOptions represents the settings of Merge.
+type MergeOptions struct {
+	// If true, table of contents pages listing each document's title and
+	// first page number are inserted before the documents.
+	TOC bool
+
+	// Heading of the table of contents. Defaults to DefaultTOCTitle.
+	TOCTitle string
+}
+
+// Merge concatenates the pages of docs into a single PDF. Each document with
+// pages is bookmarked by its title in the outline, and listed with links in
+// the table of contents if opts.TOC is set. The table of contents uses the
+// page size of the first document with pages.
+//
+// Documents must use cross-reference tables, as written by PhantomJS. Their
+// outlines, named destinations and forms are not copied.
+func Merge(docs []*Document, opts MergeOptions) ([]byte, error) {
+	if opts.TOCTitle == "" {
+		opts.TOCTitle = DefaultTOCTitle
+	}
+
+	w := &writer{version: "1.4"}
+	catalog, root, outlines := w.alloc(), w.alloc(), w.alloc()
+
+	// Parse every document first, since the table of contents comes first
+	// and lists their page numbers.
+	copiers := make([]*copier, len(docs))
+	pages := make([][]int, len(docs))
+	for i, doc := range docs {
+		d, err := parseDocument(doc.Data)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		} else if pages[i], err = d.pages(); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		copiers[i] = &copier{doc: d, w: w, parent: ref{num: root}, nums: make(map[int]int)}
+		w.version = max(w.version, d.version)
+	}
+
+	count, first := 0, -1
+	for i := range docs {
+		if len(pages[i]) == 0 {
+			continue
+		} else if first == -1 {
+			first = i
+		}
+		count++
+	}
+
+	var toc *tocLayout
+	var kids array
+	if opts.TOC && count > 0 {
+		width, height := 612.0, 792.0
+		if box, err := copiers[first].mediaBox(pages[first][0]); err == nil {
+			width, height = box[2]-box[0], box[3]-box[1]
+		}
+		toc = newTOCLayout(width, height, count)
+		for range toc.pages {
+			kids = append(kids, ref{num: w.alloc()})
+		}
+	}
+
+	// Number the pages of each document, then copy the objects they use.
+	var entries []tocEntry
+	for i, c := range copiers {
+		if len(pages[i]) == 0 {
+			continue
+		}
+		entries = append(entries, tocEntry{title: docs[i].Title, dest: c.ref(pages[i][0]), page: len(kids) + 1})
+		for _, num := range pages[i] {
+			kids = append(kids, c.ref(num))
+		}
+	}
+	for i, c := range copiers {
+		if err := c.flush(); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+	}
+
+	if toc != nil {
+		toc.write(w, kids[:len(toc.pages)], ref{num: root}, opts.TOCTitle, entries)
+	}
+
+	w.write(root, dict{"Type": name("Pages"), "Kids": kids, "Count": intToken(len(kids))})
+	w.write(catalog, dict{
+		"Type":     name("Catalog"),
+		"Pages":    ref{num: root},
+		"Outlines": ref{num: outlines},
+		"PageMode": name("UseOutlines"),
+	})
+	writeOutlines(w, outlines, entries)
+	return w.bytes(), nil
+}
+
+// tocEntry is a document listed in the outline and table of contents.
+type tocEntry struct {
+	title string
+	dest  ref
+	page  int
+}
+
+// writeOutlines writes the outline with a bookmark per entry to num.
+func writeOutlines(w *writer, num int, entries []tocEntry) {
+	if len(entries) == 0 {
+		w.write(num, dict{"Type": name("Outlines"), "Count": intToken(0)})
+		return
+	}
+
+	items := make([]int, len(entries))
+	for i := range items {
+		items[i] = w.alloc()
+	}
+	for i, e := range entries {
+		item := dict{
+			"Title":  textString(e.title),
+			"Parent": ref{num: num},
+			"Dest":   array{e.dest, name("Fit")},
+		}
+		if i > 0 {
+			item["Prev"] = ref{num: items[i-1]}
+		}
+		if i < len(items)-1 {
+			item["Next"] = ref{num: items[i+1]}
+		}
+		w.write(items[i], item)
+	}
+	w.write(num, dict{
+		"Type":  name("Outlines"),
+		"First": ref{num: items[0]},
+		"Last":  ref{num: items[len(items)-1]},
+		"Count": intToken(len(items)),
+	})
+}
+
+// tocLayout positions the entries of a table of contents on pages.
+type tocLayout struct {
+	width, height float64
+	pages         [][2]int // range of entries on each page
+}
+
+// newTOCLayout returns the layout of n entries on pages of the given size.
+func newTOCLayout(width, height float64, n int) *tocLayout {
+	perPage := max(1, int((height-2*tocMargin-2*tocLeading)/tocLeading))
+	l := &tocLayout{width: width, height: height}
+	for i := 0; i < n; i += perPage {
+		l.pages = append(l.pages, [2]int{i, min(n, i+perPage)})
+	}
+	return l
+}
+
+// write writes the table of contents pages to the objects of kids.
+func (l *tocLayout) write(w *writer, kids array, parent ref, title string, entries []tocEntry) {
+	regular, bold := w.alloc(), w.alloc()
+	w.write(regular, font("Helvetica"))
+	w.write(bold, font("Helvetica-Bold"))
+	resources := dict{"Font": dict{"F1": ref{num: regular}, "F2": ref{num: bold}}}
+
+	// Titles are truncated to leave room for the page numbers, assuming an
+	// average character width of half the font size.
+	numberX := l.width - tocMargin - 3*tocFontSize
+	maxChars := max(4, int((numberX-tocMargin-tocFontSize)/(tocFontSize/2)))
+
+	for i, r := range l.pages {
+		var content bytes.Buffer
+		y := l.height - tocMargin - tocHeaderSize
+		if i == 0 {
+			fmt.Fprintf(&content, "BT /F2 %d Tf %s %s Td %s Tj ET\n", tocHeaderSize, formatFloat(tocMargin), formatFloat(y), latinString(title))
+		}
+		y -= 2 * tocLeading
+
+		var annots array
+		for _, e := range entries[r[0]:r[1]] {
+			text := []rune(e.title)
+			if len(text) > maxChars {
+				text = append(text[:maxChars-3], []rune("...")...)
+			}
+			fmt.Fprintf(&content, "BT /F1 %d Tf %s %s Td %s Tj ET\n", tocFontSize, formatFloat(tocMargin), formatFloat(y), latinString(string(text)))
+			fmt.Fprintf(&content, "BT /F1 %d Tf %s %s Td %s Tj ET\n", tocFontSize, formatFloat(numberX), formatFloat(y), latinString(strconv.Itoa(e.page)))
+
+			annots = append(annots, dict{
+				"Type":    name("Annot"),
+				"Subtype": name("Link"),
+				"Rect":    array{floatToken(tocMargin), floatToken(y - 4), floatToken(l.width - tocMargin), floatToken(y + tocFontSize)},
+				"Border":  array{intToken(0), intToken(0), intToken(0)},
+				"Dest":    array{e.dest, name("Fit")},
+			})
+			y -= tocLeading
+		}
+
+		contents := w.alloc()
+		w.write(contents, stream{dict: dict{}, data: content.Bytes()})
+		w.write(kids[i].(ref).num, dict{
+			"Type":      name("Page"),
+			"Parent":    parent,
+			"MediaBox":  array{intToken(0), intToken(0), floatToken(l.width), floatToken(l.height)},
+			"Resources": resources,
+			"Contents":  ref{num: contents},
+			"Annots":    annots,
+		})
+	}
+}
+
+// font returns a standard Type 1 font.
+func font(base string) dict {
+	return dict{
+		"Type":     name("Font"),
+		"Subtype":  name("Type1"),
+		"BaseFont": name(base),
+		"Encoding": name("WinAnsiEncoding"),
+	}
+}
+
+// copier copies the objects of a document to a writer, renumbering them.
+type copier struct {
+	doc    *document
+	w      *writer
+	parent ref
+	nums   map[int]int // source object number to output object number
+	queue  []int
+}
+
+// ref returns the output reference of the source object num, queuing it to
+// be copied on first use.
+func (c *copier) ref(num int) ref {
+	if n, ok := c.nums[num]; ok {
+		return ref{num: n}
+	}
+	n := c.w.alloc()
+	c.nums[num] = n
+	c.queue = append(c.queue, num)
+	return ref{num: n}
+}
+
+// flush copies the queued objects and the objects they reference. Pages are
+// moved to the parent, so the source page tree is not copied.
+func (c *copier) flush() error {
+	for len(c.queue) > 0 {
+		num := c.queue[0]
+		c.queue = c.queue[1:]
+
+		obj, err := c.doc.object(num)
+		if err != nil {
+			return err
+		}
+		if d, ok := obj.(dict); ok && d["Type"] == name("Page") {
+			page := make(dict, len(d))
+			for k, v := range d {
+				page[k] = v
+			}
+			delete(page, "Parent")
+			out := c.translate(page).(dict)
+			out["Parent"] = c.parent
+			c.w.write(c.nums[num], out)
+			continue
+		}
+		c.w.write(c.nums[num], c.translate(obj))
+	}
+	return nil
+}
+
+// translate returns obj with its references renumbered.
+func (c *copier) translate(obj object) object {
+	switch v := obj.(type) {
+	case ref:
+		return c.ref(v.num)
+	case array:
+		a := make(array, len(v))
+		for i := range v {
+			a[i] = c.translate(v[i])
+		}
+		return a
+	case dict:
+		d := make(dict, len(v))
+		for k, x := range v {
+			d[k] = c.translate(x)
+		}
+		return d
+	case stream:
+		d := make(dict, len(v.dict))
+		for k, x := range v.dict {
+			if k != "Length" {
+				d[k] = c.translate(x)
+			}
+		}
+		return stream{dict: d, data: v.data}
+	default:
+		return obj
+	}
+}
+
+// mediaBox returns the media box of the source page num.
+func (c *copier) mediaBox(num int) ([4]float64, error) {
+	var box [4]float64
+	obj, err := c.doc.object(num)
+	if err != nil {
+		return box, err
+	}
+	d, _ := obj.(dict)
+	v, err := c.doc.resolve(d["MediaBox"])
+	if err != nil {
+		return box, err
+	}
+	a, _ := v.(array)
+	if len(a) != 4 {
+		return box, ErrMalformed
+	}
+	for i := range box {
+		t, _ := a[i].(token)
+		if box[i], err = strconv.ParseFloat(string(t), 64); err != nil {
+			return box, ErrMalformed
+		}
+	}
+	if box[2] <= box[0] || box[3] <= box[1] {
+		return box, ErrMalformed
+	}
+	return box, nil
+}
+
+// writer writes numbered objects to a PDF file.
+type writer struct {
+	version string
+	objects []object // by number - 1
+}
+
+// alloc reserves the next object number.
+func (w *writer) alloc() int {
+	w.objects = append(w.objects, null)
+	return len(w.objects)
+}
+
+// write sets object num. Objects are written in number order by bytes.
+func (w *writer) write(num int, obj object) {
+	w.objects[num-1] = obj
+}
+
+// bytes returns the file.
+func (w *writer) bytes() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", w.version)
+	offsets := make([]int, len(w.objects))
+	for i, obj := range w.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		writeObject(&buf, obj)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// writeObject writes the syntax of obj to buf.
+func writeObject(buf *bytes.Buffer, obj object) {
+	switch v := obj.(type) {
+	case name:
+		buf.WriteByte('/')
+		buf.WriteString(string(v))
+	case token:
+		buf.Write(v)
+	case ref:
+		fmt.Fprintf(buf, "%d %d R", v.num, v.gen)
+	case array:
+		buf.WriteByte('[')
+		for i, x := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeObject(buf, x)
+		}
+		buf.WriteByte(']')
+	case dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+
+		buf.WriteString("<<")
+		for _, k := range keys {
+			buf.WriteString(" /")
+			buf.WriteString(k)
+			buf.WriteByte(' ')
+			writeObject(buf, v[name(k)])
+		}
+		buf.WriteString(" >>")
+	case stream:
+		d := make(dict, len(v.dict)+1)
+		for k, x := range v.dict {
+			d[k] = x
+		}
+		d["Length"] = intToken(len(v.data))
+		writeObject(buf, d)
+		buf.WriteString("\nstream\n")
+		buf.Write(v.data)
+		buf.WriteString("\nendstream")
+	default:
+		buf.Write(null)
+	}
+}
+
+func intToken(n int) token {
+	return token(strconv.Itoa(n))
+}
+
+func floatToken(f float64) token {
+	return token(formatFloat(f))
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// textString encodes s as a PDF text string, using UTF-16 if it is not ASCII.
+func textString(s string) token {
+	for _, r := range s {
+		if r >= 0x80 {
+			var buf bytes.Buffer
+			buf.WriteString("<FEFF")
+			for _, u := range utf16.Encode([]rune(s)) {
+				fmt.Fprintf(&buf, "%04X", u)
+			}
+			buf.WriteByte('>')
+			return buf.Bytes()
+		}
+	}
+	return latinString(s)
+}
+
+// latinString encodes s as a literal string for the standard fonts' Latin
+// encoding, replacing other characters with '?'.
+func latinString(s string) token {
+	var buf bytes.Buffer
+	buf.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r < 0x20 || (r >= 0x7f && r < 0xa0) || r > 0xff:
+			buf.WriteByte('?')
+		default:
+			buf.WriteByte(byte(r))
+		}
+	}
+	buf.WriteByte(')')
+	return buf.Bytes()
+}
@@ -0,0 +1,486 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	// ErrMalformed is returned by Merge when a document cannot be parsed.
+	ErrMalformed = errors.New("malformed pdf")
+
+	// ErrUnsupported is returned by Merge for encrypted documents and
+	// documents with cross-reference streams, which PhantomJS does not
+	// produce.
+	ErrUnsupported = errors.New("unsupported pdf")
+)
+
+// PDF objects. Numbers, strings, booleans and null are kept verbatim as
+// tokens since merging only needs to copy them.
+type (
+	object interface{}
+	name   string
+	token  []byte
+	array  []object
+	dict   map[name]object
+	ref    struct{ num, gen int }
+	stream struct {
+		dict dict
+		data []byte
+	}
+)
+
+// null is the token of the null object.
+var null = token("null")
+
+// document is a parsed PDF file whose objects are read on demand.
+type document struct {
+	data    []byte
+	version string
+	offsets map[int]int
+	trailer dict
+	objects map[int]object
+}
+
+// parseDocument parses the header and cross-reference table of data.
+func parseDocument(data []byte) (*document, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) || len(data) < 8 {
+		return nil, ErrMalformed
+	}
+	d := &document{
+		data:    data,
+		version: string(data[5:8]),
+		offsets: make(map[int]int),
+		objects: make(map[int]object),
+	}
+
+	i := bytes.LastIndex(data, []byte("startxref"))
+	if i == -1 {
+		return nil, ErrMalformed
+	}
+	p := &parser{data: data, pos: i + len("startxref")}
+	off, err := p.int()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the newest table first, following the chain of updates.
+	seen := make(map[int]bool)
+	for !seen[off] {
+		seen[off] = true
+		if off >= len(data) {
+			return nil, ErrMalformed
+		}
+		trailer, err := d.parseXref(off)
+		if err != nil {
+			return nil, err
+		} else if d.trailer == nil {
+			d.trailer = trailer
+		}
+
+		prev, ok := trailer["Prev"].(token)
+		if !ok {
+			break
+		} else if off, err = strconv.Atoi(string(prev)); err != nil {
+			return nil, ErrMalformed
+		}
+	}
+
+	if _, ok := d.trailer["Encrypt"]; ok {
+		return nil, ErrUnsupported
+	}
+	return d, nil
+}
+
+// parseXref reads the cross-reference table at off and returns its trailer.
+// Entries already read from a newer table are kept.
+func (d *document) parseXref(off int) (dict, error) {
+	p := &parser{data: d.data, pos: off}
+	if !p.keyword("xref") {
+		if p.skipSpace(); p.pos < len(p.data) && isDigit(p.data[p.pos]) {
+			return nil, ErrUnsupported
+		}
+		return nil, ErrMalformed
+	}
+
+	for !p.keyword("trailer") {
+		start, err := p.int()
+		if err != nil {
+			return nil, err
+		}
+		count, err := p.int()
+		if err != nil {
+			return nil, err
+		}
+		for num := start; num < start+count; num++ {
+			offset, err := p.int()
+			if err != nil {
+				return nil, err
+			} else if _, err := p.int(); err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			if p.pos >= len(p.data) {
+				return nil, ErrMalformed
+			}
+			inUse := p.data[p.pos] == 'n'
+			p.pos++
+
+			if _, ok := d.offsets[num]; !ok && inUse {
+				d.offsets[num] = offset
+			}
+		}
+	}
+
+	obj, err := p.object()
+	if err != nil {
+		return nil, err
+	}
+	trailer, ok := obj.(dict)
+	if !ok {
+		return nil, ErrMalformed
+	}
+	return trailer, nil
+}
+
+// object returns the object with the given number. Missing objects are null.
+func (d *document) object(num int) (object, error) {
+	if obj, ok := d.objects[num]; ok {
+		return obj, nil
+	}
+	off, ok := d.offsets[num]
+	if !ok {
+		return null, nil
+	} else if off >= len(d.data) {
+		return nil, fmt.Errorf("%w: object %d offset %d out of range", ErrMalformed, num, off)
+	}
+
+	p := &parser{data: d.data, pos: off}
+	if n, err := p.int(); err != nil {
+		return nil, err
+	} else if n != num {
+		return nil, fmt.Errorf("%w: object %d not found at offset %d", ErrMalformed, num, off)
+	} else if _, err := p.int(); err != nil {
+		return nil, err
+	} else if !p.keyword("obj") {
+		return nil, ErrMalformed
+	}
+
+	obj, err := p.object()
+	if err != nil {
+		return nil, err
+	}
+	if dt, ok := obj.(dict); ok && p.keyword("stream") {
+		if obj, err = d.parseStream(p, dt); err != nil {
+			return nil, err
+		}
+	}
+	d.objects[num] = obj
+	return obj, nil
+}
+
+// parseStream reads the data of a stream whose keyword was just read.
+func (d *document) parseStream(p *parser, dt dict) (object, error) {
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	// Use the declared length if it is followed by the end of the stream,
+	// otherwise search for it.
+	end := -1
+	if v, err := d.resolve(dt["Length"]); err != nil {
+		return nil, err
+	} else if t, ok := v.(token); ok {
+		if n, err := strconv.Atoi(string(t)); err == nil && n >= 0 && start+n <= len(p.data) {
+			q := &parser{data: p.data, pos: start + n}
+			if q.keyword("endstream") {
+				end = start + n
+			}
+		}
+	}
+	if end == -1 {
+		i := bytes.Index(p.data[start:], []byte("endstream"))
+		if i == -1 {
+			return nil, ErrMalformed
+		}
+		end = start + i
+		if end > start && p.data[end-1] == '\n' {
+			end--
+		}
+		if end > start && p.data[end-1] == '\r' {
+			end--
+		}
+	}
+	return stream{dict: dt, data: p.data[start:end]}, nil
+}
+
+// resolve returns the object referenced by obj, or obj if it is direct.
+func (d *document) resolve(obj object) (object, error) {
+	if r, ok := obj.(ref); ok {
+		return d.object(r.num)
+	}
+	return obj, nil
+}
+
+// pages returns the object numbers of the document's pages in order. The
+// attributes that pages inherit from the page tree are copied to them so
+// they can be moved to another tree.
+func (d *document) pages() ([]int, error) {
+	root, err := d.resolve(d.trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := root.(dict)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing catalog", ErrMalformed)
+	}
+	r, ok := catalog["Pages"].(ref)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing page tree", ErrMalformed)
+	}
+
+	var nums []int
+	seen := make(map[int]bool)
+	var walk func(r ref, inherited dict) error
+	walk = func(r ref, inherited dict) error {
+		if seen[r.num] {
+			return fmt.Errorf("%w: page tree cycle", ErrMalformed)
+		}
+		seen[r.num] = true
+
+		obj, err := d.object(r.num)
+		if err != nil {
+			return err
+		}
+		node, ok := obj.(dict)
+		if !ok {
+			return fmt.Errorf("%w: invalid page tree node %d", ErrMalformed, r.num)
+		}
+		for _, key := range inheritable {
+			if _, ok := node[key]; !ok && inherited[key] != nil {
+				node[key] = inherited[key]
+			}
+		}
+
+		if node["Type"] != name("Pages") {
+			nums = append(nums, r.num)
+			return nil
+		}
+		kids, err := d.resolve(node["Kids"])
+		if err != nil {
+			return err
+		}
+		a, _ := kids.(array)
+		for _, kid := range a {
+			if kr, ok := kid.(ref); !ok {
+				return fmt.Errorf("%w: invalid page tree kid", ErrMalformed)
+			} else if err := walk(kr, node); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(r, nil); err != nil {
+		return nil, err
+	}
+	return nums, nil
+}
+
+// inheritable lists the page attributes that are inherited from the page tree.
+var inheritable = []name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// parser reads PDF objects from data.
+type parser struct {
+	data []byte
+	pos  int
+}
+
+// skipSpace skips whitespace and comments.
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		case isSpace(c):
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// keyword reads kw if it is the next token.
+func (p *parser) keyword(kw string) bool {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return false
+	}
+	end := p.pos + len(kw)
+	if !bytes.HasPrefix(p.data[p.pos:], []byte(kw)) || (end < len(p.data) && isRegular(p.data[end])) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// int reads a non-negative integer.
+func (p *parser) int() (int, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return 0, ErrMalformed
+	}
+	start := p.pos
+	for p.pos < len(p.data) && isDigit(p.data[p.pos]) {
+		p.pos++
+	}
+	n, err := strconv.Atoi(string(p.data[start:p.pos]))
+	if err != nil {
+		return 0, ErrMalformed
+	}
+	return n, nil
+}
+
+// regular reads a run of regular characters, such as a number or keyword.
+func (p *parser) regular() []byte {
+	start := p.pos
+	for p.pos < len(p.data) && isRegular(p.data[p.pos]) {
+		p.pos++
+	}
+	return p.data[start:p.pos]
+}
+
+// object reads a direct object or a reference.
+func (p *parser) object() (object, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, ErrMalformed
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return name(p.regular()), nil
+
+	case bytes.HasPrefix(p.data[p.pos:], []byte("<<")):
+		p.pos += 2
+		d := make(dict)
+		for {
+			if p.skipSpace(); bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+				p.pos += 2
+				return d, nil
+			}
+			key, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(name)
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid dictionary key", ErrMalformed)
+			}
+			if d[k], err = p.object(); err != nil {
+				return nil, err
+			}
+		}
+
+	case c == '<':
+		i := bytes.IndexByte(p.data[p.pos:], '>')
+		if i == -1 {
+			return nil, ErrMalformed
+		}
+		start := p.pos
+		p.pos += i + 1
+		return token(p.data[start:p.pos]), nil
+
+	case c == '[':
+		p.pos++
+		a := array{}
+		for {
+			if p.skipSpace(); p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return a, nil
+			}
+			obj, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, obj)
+		}
+
+	case c == '(':
+		start, depth := p.pos, 0
+		for p.pos < len(p.data) {
+			switch p.data[p.pos] {
+			case '\\':
+				p.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			p.pos++
+			if depth == 0 {
+				return token(p.data[start:p.pos]), nil
+			}
+		}
+		return nil, ErrMalformed
+
+	case isRegular(c):
+		tok := p.regular()
+		if !isInt(tok) {
+			return token(tok), nil
+		}
+
+		// An integer may start a reference: "num gen R".
+		pos := p.pos
+		p.skipSpace()
+		if gen := p.regular(); isInt(gen) {
+			if p.keyword("R") {
+				num, _ := strconv.Atoi(string(tok))
+				g, _ := strconv.Atoi(string(gen))
+				return ref{num: num, gen: g}, nil
+			}
+		}
+		p.pos = pos
+		return token(tok), nil
+
+	default:
+		return nil, fmt.Errorf("%w: unexpected %q at offset %d", ErrMalformed, c, p.pos)
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) != -1
+}
+
+func isRegular(c byte) bool {
+	return !isSpace(c) && !isDelimiter(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isInt reports whether tok is an unsigned integer.
+func isInt(tok []byte) bool {
+	if len(tok) == 0 {
+		return false
+	}
+	for _, c := range tok {
+		if !isDigit(c) {
+			return false
+		}
+	}
+	return true
+}
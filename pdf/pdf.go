@@ -0,0 +1,185 @@
+// Package pdf converts batches of URLs and HTML documents to PDF through a
+// page pool and merges the results into a single document with a table of
+// contents, for report generation:
+//
+//	docs, err := pdf.Batch(ctx, pool, []pdf.Source{
+//		{URL: "https://example.com/summary", Title: "Summary"},
+//		{HTML: detailsHTML, Title: "Details"},
+//	}, pdf.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	report, err := pdf.Merge(docs, pdf.MergeOptions{TOC: true})
+package pdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+var (
+	// ErrInvalidSource is returned by Batch when a source has neither a URL
+	// nor HTML.
+	ErrInvalidSource = errors.New("source requires a url or html")
+)
+
+// Source represents a document to convert.
+type Source struct {
+	// URL to open. If HTML is set, the URL is not opened and is used as the
+	// base URL of the HTML's relative links instead.
+	URL string
+
+	// HTML content to render.
+	HTML string
+
+	// Title of the document in the merged outline and table of contents.
+	// Defaults to the page's title, then to the URL.
+	Title string
+}
+
+// Options represents the settings of Batch.
+type Options struct {
+	// Maximum number of documents rendered at the same time.
+	// Defaults to the pool's MaxPages.
+	Concurrency int
+
+	// Paper size of every document. Uses the PhantomJS default if nil.
+	PaperSize *phantomjs.PaperSize
+
+	// Time to wait after each document loads before rendering it.
+	Wait time.Duration
+
+	// Maximum time to load and render each document. Zero for no limit.
+	Timeout time.Duration
+}
+
+// Document represents a converted source.
+type Document struct {
+	Source Source
+	Title  string
+	Data   []byte
+}
+
+// Batch renders sources to PDF concurrently using pages from pool and returns
+// the documents in the order of sources. If any source fails, the remaining
+// renders are canceled and its error is returned with its index.
+func Batch(ctx context.Context, pool *phantomjs.Pool, sources []Source, opts Options) ([]*Document, error) {
+	for i, src := range sources {
+		if src.URL == "" && src.HTML == "" {
+			return nil, fmt.Errorf("source %d: %w", i, ErrInvalidSource)
+		}
+	}
+
+	n := opts.Concurrency
+	if n <= 0 {
+		n = pool.MaxPages()
+	}
+	n = min(n, len(sources))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	docs := make([]*Document, len(sources))
+	indexes := make(chan int)
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				doc, err := render(ctx, pool, sources[i], opts)
+				if err != nil {
+					once.Do(func() {
+						firstErr = fmt.Errorf("source %d: %w", i, err)
+						cancel()
+					})
+					continue
+				}
+				docs[i] = doc
+			}
+		}()
+	}
+
+loop:
+	for i := range sources {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// render loads src on a page from pool and renders it to PDF.
+func render(ctx context.Context, pool *phantomjs.Pool, src Source, opts Options) (*Document, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	page, err := pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Put(page)
+
+	if opts.PaperSize != nil {
+		if err := page.SetPaperSize(*opts.PaperSize); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case src.HTML != "" && src.URL != "":
+		err = page.SetContentAndURL(src.HTML, src.URL)
+	case src.HTML != "":
+		err = page.SetContent(src.HTML)
+	default:
+		err = page.Open(src.URL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Wait > 0 {
+		timer := time.NewTimer(opts.Wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	doc := &Document{Source: src, Title: src.Title}
+	if doc.Title == "" {
+		if doc.Title, err = page.Title(); err != nil {
+			return nil, err
+		} else if doc.Title == "" {
+			doc.Title = src.URL
+		}
+	}
+	if doc.Data, err = page.RenderWithOptions(phantomjs.RenderOptions{Format: "PDF"}); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,212 @@
+package pdf_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/pdf"
+)
+
+// Ensure sources are rendered through the pool in order.
+func TestBatch(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	pool := NewStubPool(t, 2, func(path string, req map[string]interface{}) interface{} {
+		mu.Lock()
+		calls[path]++
+		mu.Unlock()
+
+		switch path {
+		case "/webpage/Title":
+			return map[string]interface{}{"value": "Page Title"}
+		case "/webpage/RenderWithOptions":
+			if req["options"].(map[string]interface{})["format"] != "PDF" {
+				t.Errorf("unexpected options: %v", req["options"])
+			}
+			return map[string]interface{}{"found": true, "data": base64.StdEncoding.EncodeToString(MinimalPDF("x"))}
+		}
+		return map[string]interface{}{"status": "success"}
+	})
+
+	docs, err := pdf.Batch(context.Background(), pool, []pdf.Source{
+		{URL: "http://example.com/a", Title: "A"},
+		{HTML: "<h1>B</h1>"},
+		{HTML: "<h1>C</h1>", URL: "http://example.com/c"},
+	}, pdf.Options{PaperSize: &phantomjs.PaperSize{Format: "A4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(docs) != 3 {
+		t.Fatalf("unexpected documents: %d", len(docs))
+	}
+	for i, title := range []string{"A", "Page Title", "Page Title"} {
+		if docs[i].Title != title || !bytes.HasPrefix(docs[i].Data, []byte("%PDF-")) {
+			t.Fatalf("%d: unexpected document: %q %q", i, docs[i].Title, docs[i].Data)
+		}
+	}
+	if calls["/webpage/Open"] != 1 || calls["/webpage/SetContent"] != 1 || calls["/webpage/SetContentAndURL"] != 1 || calls["/webpage/SetPaperSize"] != 3 || calls["/webpage/Title"] != 2 {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+}
+
+// Ensure the first failed source is returned with its index.
+func TestBatch_Error(t *testing.T) {
+	pool := NewStubPool(t, 1, func(path string, req map[string]interface{}) interface{} {
+		if path == "/webpage/Open" && req["url"] == "http://example.com/bad" {
+			return map[string]interface{}{"status": "fail"}
+		}
+		return map[string]interface{}{"status": "success"}
+	})
+
+	_, err := pdf.Batch(context.Background(), pool, []pdf.Source{{URL: "http://example.com/bad"}}, pdf.Options{})
+	var e *phantomjs.OpenError
+	if !errors.As(err, &e) || !strings.HasPrefix(err.Error(), "source 0: ") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := pdf.Batch(context.Background(), pool, []pdf.Source{{URL: "http://example.com"}, {}}, pdf.Options{}); !errors.Is(err, pdf.ErrInvalidSource) || err.Error() != "source 1: source requires a url or html" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure documents are merged in order with an outline and table of contents.
+func TestMerge(t *testing.T) {
+	buf, err := pdf.Merge([]*pdf.Document{
+		{Title: "Alpha", Data: MinimalPDF("A1")},
+		{Title: "Bravo (2)", Data: MinimalPDF("B1", "B2")},
+		{Title: "Ünïcode", Data: MinimalPDF("U1")},
+	}, pdf.MergeOptions{TOC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(buf)
+	if !strings.HasPrefix(s, "%PDF-1.4\n") {
+		t.Fatalf("unexpected header: %q", s[:10])
+	} else if !strings.Contains(s, "/Count 5 /Kids [") {
+		t.Fatalf("unexpected page tree: %s", s)
+	}
+	for _, exp := range []string{
+		"BT /F2 18 Tf 72 702 Td (Contents) Tj ET",
+		"(Alpha) Tj", "(2) Tj",
+		"(Bravo \\(2\\)) Tj", "(3) Tj",
+		"(\xdcn\xefcode) Tj", "(5) Tj",
+		"/Title (Bravo \\(2\\))",
+		"/Title <FEFF00DC006E00EF0063006F00640065>",
+		"/Subtype /Link",
+		"/MediaBox [0 0 612 792]",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Fatalf("expected %q: %s", exp, s)
+		}
+	}
+	if a, b1, b2, u := strings.Index(s, "(A1)"), strings.Index(s, "(B1)"), strings.Index(s, "(B2)"), strings.Index(s, "(U1)"); !(a < b1 && b1 < b2 && b2 < u) {
+		t.Fatalf("unexpected page order: %d %d %d %d", a, b1, b2, u)
+	}
+
+	// Merged documents can be merged again.
+	buf, err = pdf.Merge([]*pdf.Document{{Title: "Report", Data: buf}, {Title: "Empty", Data: MinimalPDF()}, {Title: "Last", Data: MinimalPDF("L1")}}, pdf.MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	} else if s := string(buf); !strings.Contains(s, "/Count 6 /Kids [") || strings.Contains(s, "/Title (Empty)") || !strings.Contains(s, "/Title (Last)") {
+		t.Fatalf("unexpected merge: %s", s)
+	}
+}
+
+// Ensure invalid and unsupported documents are rejected.
+func TestMerge_Errors(t *testing.T) {
+	if _, err := pdf.Merge([]*pdf.Document{{Data: MinimalPDF("A")}, {Data: []byte("<html>")}}, pdf.MergeOptions{}); !errors.Is(err, pdf.ErrMalformed) || err.Error() != "document 1: malformed pdf" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	xrefStream := []byte("%PDF-1.5\n1 0 obj\n<< /Type /XRef /Size 2 >>\nstream\n\nendstream\nendobj\nstartxref\n9\n%%EOF\n")
+	if _, err := pdf.Merge([]*pdf.Document{{Data: xrefStream}}, pdf.MergeOptions{}); !errors.Is(err, pdf.ErrUnsupported) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure Merge returns an error instead of panicking on malformed input.
+func FuzzMerge(f *testing.F) {
+	f.Add(MinimalPDF("A"))
+	f.Add(MinimalPDF("A", "B"))
+	f.Add([]byte("%PDF-startxref020"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pdf.Merge([]*pdf.Document{{Title: "A", Data: data}}, pdf.MergeOptions{TOC: true})
+	})
+}
+
+// MinimalPDF returns a PDF with a page per text, laid out like PhantomJS's
+// output: the media box is inherited and stream lengths are indirect.
+func MinimalPDF(texts ...string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // page tree
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	var kids []string
+	for _, text := range texts {
+		content := fmt.Sprintf("BT /F1 12 Tf 10 10 Td (%s) Tj ET", text)
+		n := len(objects)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", n+2),
+			fmt.Sprintf("<< /Length %d 0 R >>\nstream\n%s\nendstream", n+3, content),
+			strconv.Itoa(len(content)),
+		)
+		kids = append(kids, fmt.Sprintf("%d 0 R", n+1))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /MediaBox [0 0 612 792] /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// NewStubPool returns a pool of n pages on a process whose RPC calls are
+// answered by fn with the request path and body.
+func NewStubPool(tb testing.TB, n int, fn func(path string, req map[string]interface{}) interface{}) *phantomjs.Pool {
+	var mu sync.Mutex
+	var nextID int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/webpage/Create" {
+			mu.Lock()
+			nextID++
+			id := nextID
+			mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"ref": map[string]string{"id": strconv.Itoa(id)}})
+			return
+		}
+
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(fn(r.URL.Path, req))
+	}))
+	tb.Cleanup(srv.Close)
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	return phantomjs.NewPool(n, phantomjs.NewProcess(phantomjs.WithPort(portN)))
+}
@@ -2,21 +2,54 @@ package phantomjs
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
 	// ErrInjectionFailed is returned by InjectJS when injection fails.
 	ErrInjectionFailed = errors.New("injection failed")
+
+	// ErrElementNotFound is returned when a selector matches no element.
+	ErrElementNotFound = errors.New("element not found")
+
+	// ErrFrameNotFound is returned by EvaluateInFrame when the frame does not
+	// exist.
+	ErrFrameNotFound = errors.New("frame not found")
+
+	// ErrPageExists is returned by CreateNamedWebPage when the name is
+	// already in use.
+	ErrPageExists = errors.New("page name already in use")
+
+	// ErrPageNotFound is returned when a page does not exist.
+	ErrPageNotFound = errors.New("page not found")
+
+	// ErrScriptTimeout is returned when a page script exceeds the process'
+	// ScriptTimeout or the page's default timeout.
+	ErrScriptTimeout = errors.New("script timeout")
+
+	// ErrTimeout is returned when a page call exceeds the page's default
+	// timeout.
+	ErrTimeout = errors.New("timeout")
+
+	// ErrUnauthorized is returned when the shim rejects the process' Token.
+	ErrUnauthorized = errors.New("unauthorized")
 )
 
 // Keyboard modifiers.
@@ -30,14 +63,37 @@ const (
 
 // Default settings.
 const (
-	DefaultPort    = 20202
-	DefaultBinPath = "phantomjs"
+	DefaultPort         = 20202
+	DefaultBinPath      = "phantomjs"
+	DefaultStartTimeout = 30 * time.Second
 )
 
+// processWaitDelay is how long Close waits for the output of a killed process
+// to be closed, such as by a child process that inherited it.
+const processWaitDelay = 5 * time.Second
+
 // Process represents a PhantomJS process.
 type Process struct {
-	path string
-	cmd  *exec.Cmd
+	path   string
+	cmd    *exec.Cmd
+	tree   *processTree
+	exited chan struct{}
+	pid    atomic.Int64
+
+	debugMu      sync.Mutex
+	debug        atomic.Pointer[debugDump]
+	debugWriter  io.Writer
+	eventHandler func(PageEvent)
+
+	exposeMu sync.Mutex
+	exposed  map[string]map[string]ExposedFunc
+
+	refMu sync.Mutex
+	refs  map[string]*Ref
+
+	lines []*lineWriter
+
+	routes map[string]string
 
 	// Path to the 'phantomjs' binary.
 	BinPath string
@@ -45,19 +101,163 @@ type Process struct {
 	// HTTP port used to communicate with phantomjs.
 	Port int
 
+	// Number of following ports that Open tries when Port is in use. Port
+	// is updated to the port that was opened.
+	PortRetries int
+
+	// Additional command line flags passed to phantomjs, such as
+	// "--ignore-ssl-errors=true".
+	Flags []string
+
+	// Additional environment variables for the process in "key=value" form.
+	Env []string
+
+	// How long Open waits for the process to respond. Defaults to
+	// DefaultStartTimeout.
+	StartTimeout time.Duration
+
+	// Checks whether the process is ready during Open. Defaults to
+	// HTTPProbe("/ping").
+	Probe Probe
+
+	// Shared secret sent with every request. The shim rejects requests
+	// without it, so other local users cannot drive the browser. If empty,
+	// Open generates a random token. Set this to the token of a shim started
+	// elsewhere when using Host.
+	Token string
+
+	// Host that the shim's HTTP server is reached at. Defaults to localhost.
+	// Set this to use a shim running on another machine, in which case the
+	// process should not be opened or closed locally.
+	Host string
+
+	// Directory that PhantomJS caches responses in. If empty, the disk cache
+	// is disabled and only the in-memory cache is used.
+	DiskCachePath string
+
+	// Records the process while it runs so that it can be reaped if the
+	// program crashes. If nil, nothing is recorded.
+	Janitor *Janitor
+
 	// Output from the process.
 	Stdout io.Writer
 	Stderr io.Writer
-}
 
-// NewProcess returns a new instance of Process.
-func NewProcess(port int) *Process {
-	return &Process{
+	// Called with each line of output from the process, in addition to
+	// writing it to Stdout and Stderr. Use LogOutput to route the lines
+	// through a logger.
+	OnStdoutLine func(OutputLine)
+	OnStderrLine func(OutputLine)
+
+	// If true, lines matching NoisyOutput are not passed to OnStdoutLine
+	// and OnStderrLine.
+	FilterOutput bool
+
+	// Recognize block pages, such as captchas, after the open methods load a
+	// page. Set to DefaultDetectors to use the built-in detectors. If empty,
+	// no detection is done.
+	Detectors []Detector
+
+	// Maximum time that Evaluate and EvaluateJavaScript wait for a page
+	// script. Zero means no limit.
+	//
+	// A script still running at the deadline, such as an infinite loop on a
+	// hostile page, blocks the whole process. PhantomJS checks for long
+	// running scripts every few seconds, at which point the script is
+	// stopped and the page's loading is aborted. Calls made to the process
+	// in the meantime wait for the script to be stopped.
+	ScriptTimeout time.Duration
+
+	// Called before RenderWithOptions and RenderAll render a page with each
+	// font family that the page requests but is not available, as reported
+	// by MissingFonts. Use it to warn about renders that will use the wrong
+	// fonts. If nil, fonts are not checked.
+	OnFontFallback func(page *WebPage, f FontFallback)
+
+	// Called after the process is closed, including when a pool or
+	// supervisor replaces it, such as to forget state kept for its pages,
+	// which are no longer open.
+	OnClose func(p *Process)
+
+	// Middleware wrapped around every RPC call, such as for metrics or
+	// authentication. The first middleware is the outermost.
+	Middleware []RPCMiddleware
+
+	// Transport used to send RPC requests to the process.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Logger receives structured logs for every RPC call and for process
+	// lifecycle events. RPC calls are logged at debug level. If nil, nothing
+	// is logged.
+	Logger *slog.Logger
+}
+
+// NewProcess returns a new instance of Process configured by opts. The process
+// uses DefaultBinPath and DefaultPort unless an option sets them.
+func NewProcess(opts ...Option) *Process {
+	p := &Process{
 		BinPath: DefaultBinPath,
-		Port:    port,
+		Port:    DefaultPort,
 		Stdout:  os.Stdout,
 		Stderr:  os.Stderr,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Clone returns a new, unopened process with the same configuration and
+// registered routes as p but on a port that is currently free and with its
+// own Token, so that workers can be scaled out from a validated template.
+// Runtime state, such as the running command and debug output, is not
+// copied.
+func (p *Process) Clone() *Process {
+	port, err := freePort()
+	if err != nil {
+		port = p.Port + 1
+	}
+	var routes map[string]string
+	for path, js := range p.routes {
+		if routes == nil {
+			routes = make(map[string]string)
+		}
+		routes[path] = js
+	}
+	return &Process{
+		BinPath:        p.BinPath,
+		Port:           port,
+		PortRetries:    p.PortRetries,
+		Flags:          append([]string(nil), p.Flags...),
+		Env:            append([]string(nil), p.Env...),
+		StartTimeout:   p.StartTimeout,
+		Probe:          p.Probe,
+		Host:           p.Host,
+		DiskCachePath:  p.DiskCachePath,
+		Janitor:        p.Janitor,
+		Stdout:         p.Stdout,
+		Stderr:         p.Stderr,
+		OnStdoutLine:   p.OnStdoutLine,
+		OnStderrLine:   p.OnStderrLine,
+		FilterOutput:   p.FilterOutput,
+		Detectors:      append([]Detector(nil), p.Detectors...),
+		ScriptTimeout:  p.ScriptTimeout,
+		OnFontFallback: p.OnFontFallback,
+		Middleware:     append([]RPCMiddleware(nil), p.Middleware...),
+		Transport:      p.Transport,
+		Logger:         p.Logger,
+		routes:         routes,
+	}
+}
+
+// Spawn clones p and opens the clone.
+func (p *Process) Spawn() (*Process, error) {
+	other := p.Clone()
+	if err := other.Open(); err != nil {
+		return nil, err
+	}
+	return other, nil
 }
 
 // Path returns a temporary path that the process is run from.
@@ -65,9 +265,28 @@ func (p *Process) Path() string {
 	return p.path
 }
 
-// Open start the phantomjs process with the shim script.
+// Open start the phantomjs process with the shim script. Returns a
+// *PortInUseError if Port is taken and no retry succeeds.
 func (p *Process) Open() error {
+	for i := 0; ; i++ {
+		err := p.open()
+		if errors.Is(err, ErrPortInUse) && i < p.PortRetries {
+			p.log(slog.LevelWarn, "phantomjs port in use", "port", p.Port, "next", p.Port+1)
+			p.Port++
+			continue
+		}
+		return err
+	}
+}
+
+// open starts the process on Port.
+func (p *Process) open() error {
 	if err := func() error {
+		// Fail fast if another server holds the port.
+		if portInUse(p.Port) {
+			return &PortInUseError{Port: p.Port}
+		}
+
 		// Generate temporary path to run script from.
 		path, err := ioutil.TempDir("", "phantomjs-")
 		if err != nil {
@@ -75,29 +294,87 @@ func (p *Process) Open() error {
 		}
 		p.path = path
 
+		// Generate the shared secret passed to the shim.
+		if p.Token == "" {
+			token, err := generateToken()
+			if err != nil {
+				return err
+			}
+			p.Token = token
+		}
+
 		// Write shim script.
 		scriptPath := filepath.Join(path, "shim.js")
-		if err := ioutil.WriteFile(scriptPath, []byte(shim), 0600); err != nil {
+		if err := ioutil.WriteFile(scriptPath, []byte(p.script()), 0600); err != nil {
 			return err
 		}
 
 		// Start external process.
-		cmd := exec.Command(p.BinPath, scriptPath)
-		cmd.Env = []string{fmt.Sprintf("PORT=%d", p.Port)}
-		cmd.Stdout = p.Stdout
-		cmd.Stderr = p.Stderr
+		t := time.Now()
+		var args []string
+		if p.DiskCachePath != "" {
+			args = append(args, "--disk-cache=true", "--disk-cache-path="+p.DiskCachePath)
+		}
+		args = append(args, p.Flags...)
+		cmd := exec.Command(p.BinPath, append(args, scriptPath)...)
+		cmd.Env = append([]string{fmt.Sprintf("PORT=%d", p.Port), "TOKEN=" + p.Token}, p.Env...)
+		onStdoutLine, filter := p.OnStdoutLine, p.FilterOutput
+		if probe, ok := p.Probe.(*outputProbe); ok {
+			probe.reset(p)
+			if probe.pattern == nil {
+				cmd.Env = append(cmd.Env, "READY_LINE="+DefaultReadyLine)
+			}
+			onStdoutLine = func(l OutputLine) {
+				probe.line(p, l)
+				if p.OnStdoutLine != nil && !(p.FilterOutput && l.Noisy()) {
+					p.OnStdoutLine(l)
+				}
+			}
+			filter = false
+		}
+		cmd.Stdout = p.outputWriter(p.Stdout, StreamStdout, onStdoutLine, filter)
+		cmd.Stderr = p.outputWriter(p.Stderr, StreamStderr, p.OnStderrLine, p.FilterOutput)
+		cmd.WaitDelay = processWaitDelay
+		prepareCommand(cmd)
 		if err := cmd.Start(); err != nil {
 			return err
 		}
-		p.cmd = cmd
+		p.cmd, p.tree = cmd, nil
+		exited := make(chan struct{})
+		p.exited = exited
+		go func() {
+			cmd.Wait()
+			close(exited)
+		}()
+		tree, err := attachProcessTree(cmd)
+		if err != nil {
+			return err
+		}
+		p.tree = tree
+		if p.Janitor != nil {
+			if err := p.Janitor.record(p); err != nil {
+				return err
+			}
+		}
+		p.pid.Store(int64(cmd.Process.Pid))
+		p.log(slog.LevelInfo, "phantomjs process started", "pid", cmd.Process.Pid, "port", p.Port, "path", path)
 
 		// Wait until process is available.
 		if err := p.wait(); err != nil {
 			return err
 		}
+		p.log(slog.LevelInfo, "phantomjs process ready", "pid", cmd.Process.Pid, "duration", time.Since(t))
+
+		// Resume receiving shim events for the debug writer and handler.
+		p.debugMu.Lock()
+		defer p.debugMu.Unlock()
+		if p.debugWriter != nil || p.eventHandler != nil {
+			return p.restartDebug()
+		}
 		return nil
 
 	}(); err != nil {
+		p.log(slog.LevelError, "phantomjs process failed to open", "error", err)
 		p.Close()
 		return err
 	}
@@ -105,15 +382,40 @@ func (p *Process) Open() error {
 	return nil
 }
 
-// Close stops the process.
+// Close stops the process and the processes it spawned, using a process
+// group on Unix and a job object on Windows. It does not block if the process
+// has already exited or was closed.
 func (p *Process) Close() (err error) {
-	// Kill process.
+	// Stop dumping debug output.
+	if d := p.debug.Swap(nil); d != nil {
+		d.stop()
+	}
+
+	// Kill process and any processes it spawned.
 	if p.cmd != nil {
-		if e := p.cmd.Process.Kill(); e != nil && err == nil {
+		if e := p.kill(); e != nil && err == nil {
 			err = e
 		}
-		p.cmd.Wait()
+		<-p.exited
+		if p.Janitor != nil {
+			if e := p.Janitor.forget(p.cmd.Process.Pid); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+
+	p.pid.Store(0)
+
+	// Forget the pages of the process.
+	p.refMu.Lock()
+	p.refs = nil
+	p.refMu.Unlock()
+
+	// Pass on the last lines of output.
+	for _, w := range p.lines {
+		w.Flush()
 	}
+	p.lines = nil
 
 	// Remove shim file.
 	if p.path != "" {
@@ -122,46 +424,129 @@ func (p *Process) Close() (err error) {
 		}
 	}
 
+	p.log(slog.LevelInfo, "phantomjs process closed", "port", p.Port)
+	if p.OnClose != nil {
+		p.OnClose(p)
+	}
 	return err
 }
 
+// kill kills the process tree, or only the process if the tree could not be
+// attached. Killing a process that has already exited is not an error.
+func (p *Process) kill() error {
+	if p.tree != nil {
+		return p.tree.kill()
+	}
+	if err := p.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// outputWriter returns the writer for an output stream of the process. Lines
+// are passed to fn, if set, as well as written to w. Noisy lines are not
+// passed if filter is true.
+func (p *Process) outputWriter(w io.Writer, stream string, fn func(OutputLine), filter bool) io.Writer {
+	if fn == nil {
+		return w
+	}
+	lw := &lineWriter{stream: stream, filter: filter, fn: fn}
+	p.lines = append(p.lines, lw)
+	if w == nil {
+		return lw
+	}
+	return io.MultiWriter(w, lw)
+}
+
+// log writes a log record to the process logger, if set.
+func (p *Process) log(level slog.Level, msg string, args ...interface{}) {
+	if p.Logger == nil {
+		return
+	}
+	p.Logger.Log(context.Background(), level, msg, args...)
+}
+
+// PID returns the operating system process ID of PhantomJS. Zero if the
+// process is not open or was not started locally.
+func (p *Process) PID() int {
+	return int(p.pid.Load())
+}
+
 // URL returns the process' API URL.
 func (p *Process) URL() string {
-	return fmt.Sprintf("http://localhost:%d", p.Port)
+	host := p.Host
+	if host == "" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(p.Port))
 }
 
 // wait continually checks the process until it gets a response or times out.
 func (p *Process) wait() error {
-	ticker := time.NewTicker(1000 * time.Millisecond)
-	defer ticker.Stop()
+	interval := minProbeInterval
+	poll := time.NewTimer(interval)
+	defer poll.Stop()
 
-	timer := time.NewTimer(30 * time.Second)
-	defer ticker.Stop()
+	timeout := p.StartTimeout
+	if timeout <= 0 {
+		timeout = DefaultStartTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-timer.C:
 			return errors.New("timeout")
-		case <-ticker.C:
-			if err := p.ping(); err == nil {
+		case <-p.exited:
+			if p.cmd.ProcessState.ExitCode() == shimExitPortInUse {
+				return &PortInUseError{Port: p.Port}
+			}
+			return errors.New("process exited: " + p.cmd.ProcessState.String())
+		case <-poll.C:
+			ctx, cancel := context.WithTimeout(context.Background(), maxProbeInterval)
+			err := p.probe().Ready(ctx, p)
+			cancel()
+			if err == nil {
 				return nil
 			}
+
+			// Back off until the probe interval reaches its maximum.
+			if interval *= 2; interval > maxProbeInterval {
+				interval = maxProbeInterval
+			}
+			poll.Reset(interval)
 		}
 	}
 }
 
 // ping checks the process to see if it is up.
-func (p *Process) ping() error {
-	// Send request.
-	resp, err := http.Get(p.URL() + "/ping")
-	if err != nil {
+func (p *Process) ping(ctx context.Context) error {
+	return httpProbe("/ping").Ready(ctx, p)
+}
+
+// ClearCache clears the process' in-memory cache and removes the contents of
+// DiskCachePath, if set, so that following requests start from a cold cache.
+// Pages that are loading while the cache is cleared may still use cached
+// responses.
+func (p *Process) ClearCache() error {
+	if err := p.doJSON(context.Background(), "POST", "/process/ClearCache", nil, nil); err != nil {
 		return err
 	}
-	resp.Body.Close()
 
-	// Verify successful status code.
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	if p.DiskCachePath == "" {
+		return nil
+	}
+	names, err := ioutil.ReadDir(p.DiskCachePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, fi := range names {
+		if err := os.RemoveAll(filepath.Join(p.DiskCachePath, fi.Name())); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -171,32 +556,182 @@ func (p *Process) CreateWebPage() (*WebPage, error) {
 	var resp struct {
 		Ref refJSON `json:"ref"`
 	}
-	if err := p.doJSON("POST", "/webpage/Create", nil, &resp); err != nil {
+	if err := p.doJSON(context.Background(), "POST", "/webpage/Create", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &WebPage{ref: newRef(p, resp.Ref.ID)}, nil
+}
+
+// CreateNamedWebPage returns a new web page registered under name so that it
+// can be looked up with Page. The name is held by the PhantomJS process, so
+// clients sharing a process through Host see the same pages. The name is
+// released when the page is closed. Returns ErrPageExists if the name is in
+// use.
+func (p *Process) CreateNamedWebPage(name string) (*WebPage, error) {
+	var resp struct {
+		Exists bool    `json:"exists"`
+		Ref    refJSON `json:"ref"`
+	}
+	if err := p.doJSON(context.Background(), "POST", "/webpage/CreateNamed", map[string]interface{}{"name": name}, &resp); err != nil {
+		return nil, err
+	} else if resp.Exists {
+		return nil, ErrPageExists
+	}
+	return &WebPage{ref: newRef(p, resp.Ref.ID)}, nil
+}
+
+// Page returns the page registered under name by CreateNamedWebPage. Returns
+// ErrPageNotFound if no page has the name.
+func (p *Process) Page(name string) (*WebPage, error) {
+	var resp struct {
+		Found bool    `json:"found"`
+		Ref   refJSON `json:"ref"`
+	}
+	if err := p.doJSON(context.Background(), "POST", "/process/Page", map[string]interface{}{"name": name}, &resp); err != nil {
 		return nil, err
+	} else if !resp.Found {
+		return nil, ErrPageNotFound
 	}
 	return &WebPage{ref: newRef(p, resp.Ref.ID)}, nil
 }
 
+// PageInfo describes a page that is open in the process.
+type PageInfo struct {
+	// Reference ID, as returned by Ref.ID.
+	ID string
+
+	URL   string
+	Title string
+
+	// Name given to CreateNamedWebPage, if any.
+	Name string
+}
+
+// Pages returns every page open in the process, including pages created by
+// other clients and pages opened by page scripts.
+func (p *Process) Pages() ([]PageInfo, error) {
+	var resp struct {
+		Pages []struct {
+			ID    string `json:"id"`
+			URL   string `json:"url"`
+			Title string `json:"title"`
+			Name  string `json:"name"`
+		} `json:"pages"`
+	}
+	if err := p.doJSON(context.Background(), "POST", "/process/Pages", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	a := make([]PageInfo, len(resp.Pages))
+	for i, v := range resp.Pages {
+		a[i] = PageInfo{ID: v.ID, URL: v.URL, Title: v.Title, Name: v.Name}
+	}
+	return a, nil
+}
+
+// AttachPage returns the open page with a reference ID, such as a page left
+// open by a client that exited without closing it. Returns ErrPageNotFound if
+// no page has the ID.
+func (p *Process) AttachPage(id string) (*WebPage, error) {
+	var resp struct {
+		Found bool `json:"found"`
+	}
+	if err := p.doJSON(context.Background(), "POST", "/process/AttachPage", map[string]interface{}{"id": id}, &resp); err != nil {
+		return nil, err
+	} else if !resp.Found {
+		return nil, ErrPageNotFound
+	}
+	return &WebPage{ref: newRef(p, id)}, nil
+}
+
+// DoJSON sends an RPC request to the shim. If req is not nil it is encoded as
+// the JSON request body and if resp is not nil the JSON response is decoded
+// into it. A response with an "error" field is returned as an error. A nil
+// ctx is treated as context.Background().
+//
+// DoJSON is a low-level escape hatch for calling routes that are not wrapped
+// by this package, such as routes added to a custom shim.
+func (p *Process) DoJSON(ctx context.Context, method, path string, req, resp interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return p.doJSON(ctx, method, path, req, resp)
+}
+
 // doJSON sends an HTTP request to url and encodes and decodes the req/resp as JSON.
-func (p *Process) doJSON(method, path string, req, resp interface{}) error {
+func (p *Process) doJSON(ctx context.Context, method, path string, req, resp interface{}) error {
+	if p.Logger == nil {
+		return p.do(ctx, method, path, req, resp)
+	}
+
+	t := time.Now()
+	err := p.do(ctx, method, path, req, resp)
+	args := []interface{}{"method", method, "path", path, "duration", time.Since(t)}
+	if ref := refIDOf(req); ref != "" {
+		args = append(args, "ref", ref)
+	}
+	if err != nil {
+		p.Logger.Log(ctx, slog.LevelWarn, "phantomjs rpc failed", append(args, "error", err)...)
+	} else {
+		p.Logger.Log(ctx, slog.LevelDebug, "phantomjs rpc", args...)
+	}
+	return err
+}
+
+// do sends an HTTP request to url and encodes and decodes the req/resp as JSON.
+func (p *Process) do(ctx context.Context, method, path string, req, resp interface{}) error {
 	// Encode request.
-	var r io.Reader
+	call := &RPCCall{Method: method, Path: path, Ref: refIDOf(req), Header: make(http.Header)}
 	if req != nil {
 		buf, err := json.Marshal(req)
 		if err != nil {
 			return err
 		}
-		r = bytes.NewReader(buf)
+		call.Request = buf
+	}
+
+	// Send request through the middleware chain.
+	h := p.send
+	if d := p.debug.Load(); d != nil {
+		h = d.middleware(h)
+	}
+	for i := len(p.Middleware) - 1; i >= 0; i-- {
+		h = p.Middleware[i](h)
+	}
+	if err := h(ctx, call); err != nil {
+		return err
+	}
+
+	// Decode response if reference passed in.
+	if resp != nil {
+		if err := json.Unmarshal(call.Response, resp); err != nil {
+			return fmt.Errorf("unmarshal error: err=%s, body=%s", err, call.Response)
+		}
+	}
+
+	return nil
+}
+
+// send sends an RPC call to the shim and sets its response. Errors reported
+// by the shim are returned as errors.
+func (p *Process) send(ctx context.Context, call *RPCCall) error {
+	var r io.Reader
+	if call.Request != nil {
+		r = bytes.NewReader(call.Request)
 	}
 
 	// Create request.
-	httpRequest, err := http.NewRequest(method, p.URL()+path, r)
+	httpRequest, err := http.NewRequestWithContext(ctx, call.Method, p.URL()+call.Path, r)
 	if err != nil {
 		return err
 	}
+	p.authorize(httpRequest)
+	for key, values := range call.Header {
+		httpRequest.Header[key] = values
+	}
 
 	// Send request.
-	httpResponse, err := http.DefaultClient.Do(httpRequest)
+	httpResponse, err := p.client().Do(httpRequest)
 	if err != nil {
 		return err
 	}
@@ -207,10 +742,13 @@ func (p *Process) doJSON(method, path string, req, resp interface{}) error {
 	if err != nil {
 		return err
 	}
+	call.Response = body
 
 	// Check response code.
 	if httpResponse.StatusCode == http.StatusNotFound {
-		return fmt.Errorf("not found: %s", path)
+		return fmt.Errorf("not found: %s", call.Path)
+	} else if httpResponse.StatusCode == http.StatusForbidden {
+		return ErrUnauthorized
 	}
 
 	// If an error was returned then return it.
@@ -220,15 +758,41 @@ func (p *Process) doJSON(method, path string, req, resp interface{}) error {
 	} else if errResp.Error != "" {
 		return errors.New(errResp.Error)
 	}
+	return nil
+}
 
-	// Decode response if reference passed in.
-	if resp != nil {
-		if err := json.Unmarshal(body, resp); err != nil {
-			return fmt.Errorf("unmarshal error: err=%s, body=%s", err, body)
+// refIDOf returns the reference id from an RPC request, if any.
+func refIDOf(req interface{}) string {
+	if m, ok := req.(map[string]interface{}); ok {
+		if id, ok := m["ref"].(string); ok {
+			return id
 		}
 	}
+	return ""
+}
 
-	return nil
+// authorize adds the process' token to an RPC request.
+func (p *Process) authorize(req *http.Request) {
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+}
+
+// generateToken returns a random shared secret.
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// client returns an HTTP client that uses the process' transport.
+func (p *Process) client() *http.Client {
+	if p.Transport == nil {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: p.Transport}
 }
 
 type errorResponse struct {
@@ -237,7 +801,7 @@ type errorResponse struct {
 
 // DefaultProcess is a global, shared process.
 // It must be opened before use.
-var DefaultProcess = NewProcess(DefaultPort)
+var DefaultProcess = NewProcess()
 
 // CreateWebPage returns a new instance of a "webpage" using the default process.
 func CreateWebPage() (*WebPage, error) {
@@ -245,35 +809,107 @@ func CreateWebPage() (*WebPage, error) {
 }
 
 // WebPage represents an object returned from "webpage.create()".
+//
+// A page may be shared by several goroutines. Its calls are queued and sent
+// to PhantomJS one at a time in order, including calls made through other
+// handles to the page such as those returned by Process.Page and Pages; use
+// Do for sequences of calls that must not be interleaved with the calls of
+// other goroutines.
 type WebPage struct {
 	ref *Ref
+	ctx context.Context
+
+	// If true, calls skip the page's operation queue because the caller
+	// already holds it or deliberately runs alongside other calls.
+	unqueued bool
 }
 
-// Open opens a URL.
-func (p *WebPage) Open(url string) error {
-	req := map[string]interface{}{
-		"ref": p.ref.id,
-		"url": url,
+// Ref returns the page's reference within the process.
+func (p *WebPage) Ref() *Ref {
+	return p.ref
+}
+
+// WithContext returns a shallow copy of the page that sends its RPC calls
+// with ctx. The context is propagated to the process' Transport, so it can
+// be used for cancellation and tracing. The copy refers to the same page.
+func (p *WebPage) WithContext(ctx context.Context) *WebPage {
+	if ctx == nil {
+		panic("nil context")
 	}
-	var resp struct {
-		Status string `json:"status"`
+	return &WebPage{ref: p.ref, ctx: ctx, unqueued: p.unqueued}
+}
+
+// Context returns the page's context. Defaults to context.Background().
+func (p *WebPage) Context() context.Context {
+	return p.context()
+}
+
+// context returns the page's context or the background context.
+func (p *WebPage) context() context.Context {
+	if p.ctx != nil {
+		return p.ctx
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/Open", req, &resp); err != nil {
-		return err
+	return context.Background()
+}
+
+// SetDefaultTimeout sets the maximum time of each call made on the page, such
+// as Open, Evaluate, Click and SendKeys, and the default time that waits such
+// as Login and NextPage wait for their condition. Scripts are bounded by d
+// instead of the process' ScriptTimeout. A zero duration removes the timeout.
+//
+// The timeout is shared by all handles to the page. It is overridden per call
+// by a context with a deadline passed to WithContext and by waits given an
+// explicit timeout. Calls that exceed it return ErrTimeout, or
+// ErrScriptTimeout for scripts.
+func (p *WebPage) SetDefaultTimeout(d time.Duration) {
+	p.ref.timeout.Store(int64(d))
+}
+
+// DefaultTimeout returns the page's default timeout. Zero if unset.
+func (p *WebPage) DefaultTimeout() time.Duration {
+	return time.Duration(p.ref.timeout.Load())
+}
+
+// timeoutOr returns the page's default timeout, or d if unset.
+func (p *WebPage) timeoutOr(d time.Duration) time.Duration {
+	if timeout := p.DefaultTimeout(); timeout > 0 {
+		return timeout
+	}
+	return d
+}
+
+// doJSON sends an RPC call for the page with its context, bounded by the
+// page's default timeout unless the context has a deadline.
+func (p *WebPage) doJSON(method, path string, req, resp interface{}) error {
+	ctx, timeout := p.context(), p.DefaultTimeout()
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return p.call(ctx, method, path, req, resp)
 	}
 
-	if resp.Status != "success" {
-		return errors.New("failed")
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := p.call(ctx, method, path, req, resp); err != nil {
+		if ctx.Err() == context.DeadlineExceeded && p.context().Err() == nil {
+			return ErrTimeout
+		}
+		return err
 	}
 	return nil
 }
 
+// Open opens a URL. Returns an *OpenError if the page fails to load. Use
+// OpenURL to inspect the response of a successful load.
+func (p *WebPage) Open(url string) error {
+	_, err := p.OpenURL(url)
+	return err
+}
+
 // CanGoBack returns true if the page can be navigated back.
 func (p *WebPage) CanGoBack() (bool, error) {
 	var resp struct {
 		Value bool `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/CanGoBack", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/CanGoBack", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return false, err
 	}
 	return resp.Value, nil
@@ -284,7 +920,7 @@ func (p *WebPage) CanGoForward() (bool, error) {
 	var resp struct {
 		Value bool `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/CanGoForward", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/CanGoForward", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return false, err
 	}
 	return resp.Value, nil
@@ -296,7 +932,7 @@ func (p *WebPage) ClipRect() (Rect, error) {
 	var resp struct {
 		Value rectJSON `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/ClipRect", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/ClipRect", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return Rect{}, err
 	}
 	return Rect{
@@ -319,7 +955,7 @@ func (p *WebPage) SetClipRect(rect Rect) error {
 			Height: rect.Height,
 		},
 	}
-	return p.ref.process.doJSON("POST", "/webpage/SetClipRect", req, nil)
+	return p.doJSON("POST", "/webpage/SetClipRect", req, nil)
 }
 
 // Content returns content of the webpage enclosed in an HTML/XML element.
@@ -327,7 +963,7 @@ func (p *WebPage) Content() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/Content", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/Content", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -335,7 +971,7 @@ func (p *WebPage) Content() (string, error) {
 
 // SetContent sets the content of the webpage.
 func (p *WebPage) SetContent(content string) error {
-	return p.ref.process.doJSON("POST", "/webpage/SetContent", map[string]interface{}{"ref": p.ref.id, "content": content}, nil)
+	return p.doJSON("POST", "/webpage/SetContent", map[string]interface{}{"ref": p.ref.id, "content": content}, nil)
 }
 
 // Cookies returns a list of cookies visible to the current URL.
@@ -343,7 +979,7 @@ func (p *WebPage) Cookies() ([]*http.Cookie, error) {
 	var resp struct {
 		Value []cookieJSON `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/Cookies", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/Cookies", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return nil, err
 	}
 
@@ -361,7 +997,7 @@ func (p *WebPage) SetCookies(cookies []*http.Cookie) error {
 		a[i] = encodeCookieJSON(cookies[i])
 	}
 	req := map[string]interface{}{"ref": p.ref.id, "cookies": a}
-	return p.ref.process.doJSON("POST", "/webpage/SetCookies", req, nil)
+	return p.doJSON("POST", "/webpage/SetCookies", req, nil)
 }
 
 // CustomHeaders returns a list of additional headers sent with the web page.
@@ -369,7 +1005,7 @@ func (p *WebPage) CustomHeaders() (http.Header, error) {
 	var resp struct {
 		Value map[string]string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/CustomHeaders", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/CustomHeaders", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return nil, err
 	}
 
@@ -391,7 +1027,7 @@ func (p *WebPage) SetCustomHeaders(header http.Header) error {
 		m[key] = header.Get(key)
 	}
 	req := map[string]interface{}{"ref": p.ref.id, "headers": m}
-	return p.ref.process.doJSON("POST", "/webpage/SetCustomHeaders", req, nil)
+	return p.doJSON("POST", "/webpage/SetCustomHeaders", req, nil)
 }
 
 // FocusedFrameName returns the name of the currently focused frame.
@@ -399,7 +1035,7 @@ func (p *WebPage) FocusedFrameName() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/FocusedFrameName", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/FocusedFrameName", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -410,7 +1046,7 @@ func (p *WebPage) FrameContent() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/FrameContent", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/FrameContent", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -418,7 +1054,7 @@ func (p *WebPage) FrameContent() (string, error) {
 
 // SetFrameContent sets the content of the current frame.
 func (p *WebPage) SetFrameContent(content string) error {
-	return p.ref.process.doJSON("POST", "/webpage/SetFrameContent", map[string]interface{}{"ref": p.ref.id, "content": content}, nil)
+	return p.doJSON("POST", "/webpage/SetFrameContent", map[string]interface{}{"ref": p.ref.id, "content": content}, nil)
 }
 
 // FrameName returns the name of the current frame.
@@ -426,7 +1062,7 @@ func (p *WebPage) FrameName() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/FrameName", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/FrameName", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -437,7 +1073,7 @@ func (p *WebPage) FramePlainText() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/FramePlainText", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/FramePlainText", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -448,7 +1084,7 @@ func (p *WebPage) FrameTitle() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/FrameTitle", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/FrameTitle", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -459,7 +1095,7 @@ func (p *WebPage) FrameURL() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/FrameURL", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/FrameURL", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -470,7 +1106,7 @@ func (p *WebPage) FrameCount() (int, error) {
 	var resp struct {
 		Value int `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/FrameCount", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/FrameCount", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return 0, err
 	}
 	return resp.Value, nil
@@ -481,7 +1117,7 @@ func (p *WebPage) FrameNames() ([]string, error) {
 	var resp struct {
 		Value []string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/FrameNames", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/FrameNames", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return nil, err
 	}
 	return resp.Value, nil
@@ -493,7 +1129,7 @@ func (p *WebPage) LibraryPath() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/LibraryPath", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/LibraryPath", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -501,7 +1137,7 @@ func (p *WebPage) LibraryPath() (string, error) {
 
 // SetLibraryPath sets the library path used by InjectJS().
 func (p *WebPage) SetLibraryPath(path string) error {
-	return p.ref.process.doJSON("POST", "/webpage/SetLibraryPath", map[string]interface{}{"ref": p.ref.id, "path": path}, nil)
+	return p.doJSON("POST", "/webpage/SetLibraryPath", map[string]interface{}{"ref": p.ref.id, "path": path}, nil)
 }
 
 // NavigationLocked returns true if the navigation away from the page is disabled.
@@ -509,7 +1145,7 @@ func (p *WebPage) NavigationLocked() (bool, error) {
 	var resp struct {
 		Value bool `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/NavigationLocked", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/NavigationLocked", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return false, err
 	}
 	return resp.Value, nil
@@ -517,7 +1153,7 @@ func (p *WebPage) NavigationLocked() (bool, error) {
 
 // SetNavigationLocked sets whether navigation away from the page should be disabled.
 func (p *WebPage) SetNavigationLocked(value bool) error {
-	return p.ref.process.doJSON("POST", "/webpage/SetNavigationLocked", map[string]interface{}{"ref": p.ref.id, "value": value}, nil)
+	return p.doJSON("POST", "/webpage/SetNavigationLocked", map[string]interface{}{"ref": p.ref.id, "value": value}, nil)
 }
 
 // OfflineStoragePath returns the path used by offline storage.
@@ -525,7 +1161,7 @@ func (p *WebPage) OfflineStoragePath() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/OfflineStoragePath", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/OfflineStoragePath", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -536,7 +1172,7 @@ func (p *WebPage) OfflineStorageQuota() (int, error) {
 	var resp struct {
 		Value int `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/OfflineStorageQuota", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/OfflineStorageQuota", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return 0, err
 	}
 	return resp.Value, nil
@@ -547,7 +1183,7 @@ func (p *WebPage) OwnsPages() (bool, error) {
 	var resp struct {
 		Value bool `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/OwnsPages", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/OwnsPages", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return false, err
 	}
 	return resp.Value, nil
@@ -555,7 +1191,7 @@ func (p *WebPage) OwnsPages() (bool, error) {
 
 // SetOwnsPages sets whether this page owns pages opened in other windows.
 func (p *WebPage) SetOwnsPages(v bool) error {
-	return p.ref.process.doJSON("POST", "/webpage/SetOwnsPages", map[string]interface{}{"ref": p.ref.id, "value": v}, nil)
+	return p.doJSON("POST", "/webpage/SetOwnsPages", map[string]interface{}{"ref": p.ref.id, "value": v}, nil)
 }
 
 // PageWindowNames returns an list of owned window names.
@@ -563,7 +1199,7 @@ func (p *WebPage) PageWindowNames() ([]string, error) {
 	var resp struct {
 		Value []string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/PageWindowNames", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/PageWindowNames", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return nil, err
 	}
 	return resp.Value, nil
@@ -574,14 +1210,14 @@ func (p *WebPage) Pages() ([]*WebPage, error) {
 	var resp struct {
 		Refs []refJSON `json:"refs"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/Pages", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/Pages", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return nil, err
 	}
 
 	// Convert reference IDs to web pages.
 	a := make([]*WebPage, len(resp.Refs))
 	for i, ref := range resp.Refs {
-		a[i] = &WebPage{ref: newRef(p.ref.process, ref.ID)}
+		a[i] = &WebPage{ref: newRef(p.ref.process, ref.ID), ctx: p.ctx}
 	}
 	return a, nil
 }
@@ -591,7 +1227,7 @@ func (p *WebPage) PaperSize() (PaperSize, error) {
 	var resp struct {
 		Value paperSizeJSON `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/PaperSize", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/PaperSize", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return PaperSize{}, err
 	}
 	return decodePaperSizeJSON(resp.Value), nil
@@ -600,15 +1236,16 @@ func (p *WebPage) PaperSize() (PaperSize, error) {
 // SetPaperSize sets the size of the web page when rendered as a PDF.
 func (p *WebPage) SetPaperSize(size PaperSize) error {
 	req := map[string]interface{}{"ref": p.ref.id, "size": encodePaperSizeJSON(size)}
-	return p.ref.process.doJSON("POST", "/webpage/SetPaperSize", req, nil)
+	return p.doJSON("POST", "/webpage/SetPaperSize", req, nil)
 }
 
-// PlainText returns the plain text representation of the page.
+// PlainText returns the plain text representation of the page. Use
+// TextContent for text laid out for indexing.
 func (p *WebPage) PlainText() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/PlainText", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/PlainText", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -620,7 +1257,7 @@ func (p *WebPage) ScrollPosition() (Position, error) {
 		Top  int `json:"top"`
 		Left int `json:"left"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/ScrollPosition", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/ScrollPosition", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return Position{}, err
 	}
 	return Position{Top: resp.Top, Left: resp.Left}, nil
@@ -628,7 +1265,7 @@ func (p *WebPage) ScrollPosition() (Position, error) {
 
 // SetScrollPosition sets the current scroll position of the page.
 func (p *WebPage) SetScrollPosition(pos Position) error {
-	return p.ref.process.doJSON("POST", "/webpage/SetScrollPosition", map[string]interface{}{"ref": p.ref.id, "top": pos.Top, "left": pos.Left}, nil)
+	return p.doJSON("POST", "/webpage/SetScrollPosition", map[string]interface{}{"ref": p.ref.id, "top": pos.Top, "left": pos.Left}, nil)
 }
 
 // Settings returns the settings used on the web page.
@@ -636,7 +1273,7 @@ func (p *WebPage) Settings() (WebPageSettings, error) {
 	var resp struct {
 		Settings webPageSettingsJSON `json:"settings"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/Settings", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/Settings", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return WebPageSettings{}, err
 	}
 	return WebPageSettings{
@@ -671,7 +1308,7 @@ func (p *WebPage) SetSettings(settings WebPageSettings) error {
 			ResourceTimeout:               int(settings.ResourceTimeout / time.Millisecond),
 		},
 	}
-	return p.ref.process.doJSON("POST", "/webpage/SetSettings", req, nil)
+	return p.doJSON("POST", "/webpage/SetSettings", req, nil)
 }
 
 // Title returns the title of the web page.
@@ -679,7 +1316,7 @@ func (p *WebPage) Title() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/Title", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/Title", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -690,7 +1327,7 @@ func (p *WebPage) URL() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/URL", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/URL", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -702,7 +1339,7 @@ func (p *WebPage) ViewportSize() (width, height int, err error) {
 		Width  int `json:"width"`
 		Height int `json:"height"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/ViewportSize", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/ViewportSize", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return 0, 0, err
 	}
 	return resp.Width, resp.Height, nil
@@ -710,7 +1347,7 @@ func (p *WebPage) ViewportSize() (width, height int, err error) {
 
 // SetViewportSize sets the size of the viewport.
 func (p *WebPage) SetViewportSize(width, height int) error {
-	return p.ref.process.doJSON("POST", "/webpage/SetViewportSize", map[string]interface{}{"ref": p.ref.id, "width": width, "height": height}, nil)
+	return p.doJSON("POST", "/webpage/SetViewportSize", map[string]interface{}{"ref": p.ref.id, "width": width, "height": height}, nil)
 }
 
 // WindowName returns the window name of the web page.
@@ -718,7 +1355,7 @@ func (p *WebPage) WindowName() (string, error) {
 	var resp struct {
 		Value string `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/WindowName", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/WindowName", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return "", err
 	}
 	return resp.Value, nil
@@ -729,7 +1366,7 @@ func (p *WebPage) ZoomFactor() (float64, error) {
 	var resp struct {
 		Value float64 `json:"value"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/ZoomFactor", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/ZoomFactor", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
 		return 0, err
 	}
 	return resp.Value, nil
@@ -737,7 +1374,7 @@ func (p *WebPage) ZoomFactor() (float64, error) {
 
 // SetZoomFactor sets the zoom factor when rendering the page.
 func (p *WebPage) SetZoomFactor(factor float64) error {
-	return p.ref.process.doJSON("POST", "/webpage/SetZoomFactor", map[string]interface{}{"ref": p.ref.id, "value": factor}, nil)
+	return p.doJSON("POST", "/webpage/SetZoomFactor", map[string]interface{}{"ref": p.ref.id, "value": factor}, nil)
 }
 
 // AddCookie adds a cookie to the page.
@@ -747,7 +1384,7 @@ func (p *WebPage) AddCookie(cookie *http.Cookie) (bool, error) {
 		ReturnValue bool `json:"returnValue"`
 	}
 	req := map[string]interface{}{"ref": p.ref.id, "cookie": encodeCookieJSON(cookie)}
-	if err := p.ref.process.doJSON("POST", "/webpage/AddCookie", req, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/AddCookie", req, &resp); err != nil {
 		return false, err
 	}
 	return resp.ReturnValue, nil
@@ -755,12 +1392,16 @@ func (p *WebPage) AddCookie(cookie *http.Cookie) (bool, error) {
 
 // ClearCookies deletes all cookies visible to the current URL.
 func (p *WebPage) ClearCookies() error {
-	return p.ref.process.doJSON("POST", "/webpage/ClearCookies", map[string]interface{}{"ref": p.ref.id}, nil)
+	return p.doJSON("POST", "/webpage/ClearCookies", map[string]interface{}{"ref": p.ref.id}, nil)
 }
 
 // Close releases the web page and its resources.
 func (p *WebPage) Close() error {
-	return p.ref.process.doJSON("POST", "/webpage/Close", map[string]interface{}{"ref": p.ref.id}, nil)
+	if err := p.doJSON("POST", "/webpage/Close", map[string]interface{}{"ref": p.ref.id}, nil); err != nil {
+		return err
+	}
+	p.ref.process.forgetRef(p.ref.id)
+	return nil
 }
 
 // DeleteCookie removes a cookie with a matching name.
@@ -770,7 +1411,7 @@ func (p *WebPage) DeleteCookie(name string) (bool, error) {
 		ReturnValue bool `json:"returnValue"`
 	}
 	req := map[string]interface{}{"ref": p.ref.id, "name": name}
-	if err := p.ref.process.doJSON("POST", "/webpage/DeleteCookie", req, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/DeleteCookie", req, &resp); err != nil {
 		return false, err
 	}
 	return resp.ReturnValue, nil
@@ -779,29 +1420,95 @@ func (p *WebPage) DeleteCookie(name string) (bool, error) {
 // EvaluateAsync executes a JavaScript function and returns immediately.
 // Execution is delayed by delay. No value is returned.
 func (p *WebPage) EvaluateAsync(script string, delay time.Duration) error {
-	return p.ref.process.doJSON("POST", "/webpage/EvaluateAsync", map[string]interface{}{"ref": p.ref.id, "script": script, "delay": int(delay / time.Millisecond)}, nil)
+	return p.doJSON("POST", "/webpage/EvaluateAsync", map[string]interface{}{"ref": p.ref.id, "script": script, "delay": int(delay / time.Millisecond)}, nil)
 }
 
 // EvaluateJavaScript executes a JavaScript function.
 // Returns the value returned by the function.
 func (p *WebPage) EvaluateJavaScript(script string) (interface{}, error) {
-	var resp struct {
-		ReturnValue interface{} `json:"returnValue"`
-	}
-	if err := p.ref.process.doJSON("POST", "/webpage/EvaluateJavaScript", map[string]interface{}{"ref": p.ref.id, "script": script}, &resp); err != nil {
+	var v interface{}
+	if err := p.evaluateWith("/webpage/EvaluateJavaScript", script, &v); err != nil {
 		return nil, err
 	}
-	return resp.ReturnValue, nil
+	return v, nil
 }
 
 // Evaluate executes a JavaScript function in the context of the web page.
 // Returns the value returned by the function.
 func (p *WebPage) Evaluate(script string) (interface{}, error) {
+	var v interface{}
+	if err := p.evaluateInto(script, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// evaluateInto executes a JavaScript function in the context of the web page
+// and decodes its return value into v.
+func (p *WebPage) evaluateInto(script string, v interface{}) error {
+	return p.evaluateWith("/webpage/Evaluate", script, v)
+}
+
+// evaluateWith runs script through an evaluate endpoint, enforcing the
+// page's default timeout or the process' ScriptTimeout, and decodes its
+// return value into v. A missing return value leaves v unchanged.
+func (p *WebPage) evaluateWith(path, script string, v interface{}) error {
+	ctx, timeout := p.context(), p.timeoutOr(p.ref.process.ScriptTimeout)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req := map[string]interface{}{
+		"ref":     p.ref.id,
+		"script":  script,
+		"timeout": int64(timeout / time.Millisecond),
+	}
+	var resp struct {
+		ReturnValue   json.RawMessage `json:"returnValue"`
+		ScriptTimeout bool            `json:"scriptTimeout"`
+	}
+	if err := p.call(ctx, "POST", path, req, &resp); err != nil {
+		if ctx.Err() == context.DeadlineExceeded && p.context().Err() == nil {
+			return ErrScriptTimeout
+		}
+		return err
+	} else if resp.ScriptTimeout {
+		return ErrScriptTimeout
+	} else if len(resp.ReturnValue) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.ReturnValue, v)
+}
+
+// EvaluateInFrame executes a JavaScript function in a child frame of the
+// current frame and returns its value. The frame is selected by name (string)
+// or position (int). Any args are passed to the function as arguments.
+//
+// The switch to the frame and back happens in a single call, so the current
+// frame seen by other users of the page does not change.
+func (p *WebPage) EvaluateInFrame(frame interface{}, script string, args ...interface{}) (interface{}, error) {
+	switch frame.(type) {
+	case string, int:
+	default:
+		return nil, fmt.Errorf("invalid frame type: %T", frame)
+	}
+
+	req := map[string]interface{}{
+		"ref":    p.ref.id,
+		"frame":  frame,
+		"script": script,
+		"args":   args,
+	}
 	var resp struct {
+		Found       bool        `json:"found"`
 		ReturnValue interface{} `json:"returnValue"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/Evaluate", map[string]interface{}{"ref": p.ref.id, "script": script}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/EvaluateInFrame", req, &resp); err != nil {
 		return nil, err
+	} else if !resp.Found {
+		return nil, ErrFrameNotFound
 	}
 	return resp.ReturnValue, nil
 }
@@ -812,35 +1519,35 @@ func (p *WebPage) Page(name string) (*WebPage, error) {
 	var resp struct {
 		Ref refJSON `json:"ref"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/Page", map[string]interface{}{"ref": p.ref.id, "name": name}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/Page", map[string]interface{}{"ref": p.ref.id, "name": name}, &resp); err != nil {
 		return nil, err
 	}
 	if resp.Ref.ID == "" {
 		return nil, nil
 	}
-	return &WebPage{ref: newRef(p.ref.process, resp.Ref.ID)}, nil
+	return &WebPage{ref: newRef(p.ref.process, resp.Ref.ID), ctx: p.ctx}, nil
 }
 
 // GoBack navigates back to the previous page.
 func (p *WebPage) GoBack() error {
-	return p.ref.process.doJSON("POST", "/webpage/GoBack", map[string]interface{}{"ref": p.ref.id}, nil)
+	return p.doJSON("POST", "/webpage/GoBack", map[string]interface{}{"ref": p.ref.id}, nil)
 }
 
 // GoForward navigates to the next page.
 func (p *WebPage) GoForward() error {
-	return p.ref.process.doJSON("POST", "/webpage/GoForward", map[string]interface{}{"ref": p.ref.id}, nil)
+	return p.doJSON("POST", "/webpage/GoForward", map[string]interface{}{"ref": p.ref.id}, nil)
 }
 
 // Go navigates to the page in history by relative offset.
 // A positive index moves forward, a negative index moves backwards.
 func (p *WebPage) Go(index int) error {
-	return p.ref.process.doJSON("POST", "/webpage/Go", map[string]interface{}{"ref": p.ref.id, "index": index}, nil)
+	return p.doJSON("POST", "/webpage/Go", map[string]interface{}{"ref": p.ref.id, "index": index}, nil)
 }
 
 // IncludeJS includes an external script from url.
 // Returns after the script has been loaded.
 func (p *WebPage) IncludeJS(url string) error {
-	return p.ref.process.doJSON("POST", "/webpage/IncludeJS", map[string]interface{}{"ref": p.ref.id, "url": url}, nil)
+	return p.doJSON("POST", "/webpage/IncludeJS", map[string]interface{}{"ref": p.ref.id, "url": url}, nil)
 }
 
 // InjectJS injects an external script from the local filesystem.
@@ -851,7 +1558,7 @@ func (p *WebPage) InjectJS(filename string) error {
 	var resp struct {
 		ReturnValue bool `json:"returnValue"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/InjectJS", map[string]interface{}{"ref": p.ref.id, "filename": filename}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/InjectJS", map[string]interface{}{"ref": p.ref.id, "filename": filename}, &resp); err != nil {
 		return err
 	}
 	if !resp.ReturnValue {
@@ -862,7 +1569,7 @@ func (p *WebPage) InjectJS(filename string) error {
 
 // Reload reloads the current web page.
 func (p *WebPage) Reload() error {
-	return p.ref.process.doJSON("POST", "/webpage/Reload", map[string]interface{}{"ref": p.ref.id}, nil)
+	return p.doJSON("POST", "/webpage/Reload", map[string]interface{}{"ref": p.ref.id}, nil)
 }
 
 // RenderBase64 renders the web page to a base64 encoded string.
@@ -870,7 +1577,7 @@ func (p *WebPage) RenderBase64(format string) (string, error) {
 	var resp struct {
 		ReturnValue string `json:"returnValue"`
 	}
-	if err := p.ref.process.doJSON("POST", "/webpage/RenderBase64", map[string]interface{}{"ref": p.ref.id, "format": format}, &resp); err != nil {
+	if err := p.doJSON("POST", "/webpage/RenderBase64", map[string]interface{}{"ref": p.ref.id, "format": format}, &resp); err != nil {
 		return "", err
 	}
 	return resp.ReturnValue, nil
@@ -880,7 +1587,7 @@ func (p *WebPage) RenderBase64(format string) (string, error) {
 // This supports the "PDF", "PNG", "JPEG", "BMP", "PPM", and "GIF" formats.
 func (p *WebPage) Render(filename, format string, quality int) error {
 	req := map[string]interface{}{"ref": p.ref.id, "filename": filename, "format": format, "quality": quality}
-	return p.ref.process.doJSON("POST", "/webpage/Render", req, nil)
+	return p.doJSON("POST", "/webpage/Render", req, nil)
 }
 
 // SendMouseEvent sends a mouse event as if it came from the user.
@@ -890,7 +1597,7 @@ func (p *WebPage) Render(filename, format string, quality int) error {
 // or "click". The mouseX and mouseY specify the position of the mouse on the
 // screen. The button argument specifies the mouse button clicked (e.g. "left").
 func (p *WebPage) SendMouseEvent(eventType string, mouseX, mouseY int, button string) error {
-	return p.ref.process.doJSON("POST", "/webpage/SendMouseEvent", map[string]interface{}{"ref": p.ref.id, "eventType": eventType, "mouseX": mouseX, "mouseY": mouseY, "button": button}, nil)
+	return p.doJSON("POST", "/webpage/SendMouseEvent", map[string]interface{}{"ref": p.ref.id, "eventType": eventType, "mouseX": mouseX, "mouseY": mouseY, "button": button}, nil)
 }
 
 // SendKeyboardEvent sends a keyboard event as if it came from the user.
@@ -898,52 +1605,60 @@ func (p *WebPage) SendMouseEvent(eventType string, mouseX, mouseY int, button st
 //
 // The eventType can be "keyup", "keypress", or "keydown".
 //
-// The key argument is a string or a key listed here:
-// https://github.com/ariya/phantomjs/commit/cab2635e66d74b7e665c44400b8b20a8f225153a
+// The key argument is a string of characters to type. Use SendKeyCode for
+// special keys such as those in the keys package.
 //
 // Keyboard modifiers can be joined together using the bitwise OR operator.
 func (p *WebPage) SendKeyboardEvent(eventType string, key string, modifier int) error {
-	return p.ref.process.doJSON("POST", "/webpage/SendKeyboardEvent", map[string]interface{}{"ref": p.ref.id, "eventType": eventType, "key": key, "modifier": modifier}, nil)
+	return p.doJSON("POST", "/webpage/SendKeyboardEvent", map[string]interface{}{"ref": p.ref.id, "eventType": eventType, "key": key, "modifier": modifier}, nil)
+}
+
+// SendKeyCode sends a keyboard event for a key code, such as keys.Enter, as
+// if it came from the user. It is not a synthetic event.
+//
+// The eventType can be "keyup", "keypress", or "keydown".
+func (p *WebPage) SendKeyCode(eventType string, code int, modifier int) error {
+	return p.doJSON("POST", "/webpage/SendKeyboardEvent", map[string]interface{}{"ref": p.ref.id, "eventType": eventType, "key": code, "modifier": modifier}, nil)
 }
 
 // SetContentAndURL sets the content and URL of the page.
 func (p *WebPage) SetContentAndURL(content, url string) error {
-	return p.ref.process.doJSON("POST", "/webpage/SetContentAndURL", map[string]interface{}{"ref": p.ref.id, "content": content, "url": url}, nil)
+	return p.doJSON("POST", "/webpage/SetContentAndURL", map[string]interface{}{"ref": p.ref.id, "content": content, "url": url}, nil)
 }
 
 // Stop stops the web page.
 func (p *WebPage) Stop() error {
-	return p.ref.process.doJSON("POST", "/webpage/Stop", map[string]interface{}{"ref": p.ref.id}, nil)
+	return p.doJSON("POST", "/webpage/Stop", map[string]interface{}{"ref": p.ref.id}, nil)
 }
 
 // SwitchToFocusedFrame changes the current frame to the frame that is in focus.
 func (p *WebPage) SwitchToFocusedFrame() error {
-	return p.ref.process.doJSON("POST", "/webpage/SwitchToFocusedFrame", map[string]interface{}{"ref": p.ref.id}, nil)
+	return p.doJSON("POST", "/webpage/SwitchToFocusedFrame", map[string]interface{}{"ref": p.ref.id}, nil)
 }
 
 // SwitchToFrameName changes the current frame to a frame with a given name.
 func (p *WebPage) SwitchToFrameName(name string) error {
-	return p.ref.process.doJSON("POST", "/webpage/SwitchToFrameName", map[string]interface{}{"ref": p.ref.id, "name": name}, nil)
+	return p.doJSON("POST", "/webpage/SwitchToFrameName", map[string]interface{}{"ref": p.ref.id, "name": name}, nil)
 }
 
 // SwitchToFramePosition changes the current frame to the frame at the given position.
 func (p *WebPage) SwitchToFramePosition(pos int) error {
-	return p.ref.process.doJSON("POST", "/webpage/SwitchToFramePosition", map[string]interface{}{"ref": p.ref.id, "position": pos}, nil)
+	return p.doJSON("POST", "/webpage/SwitchToFramePosition", map[string]interface{}{"ref": p.ref.id, "position": pos}, nil)
 }
 
 // SwitchToMainFrame switches the current frame to the main frame.
 func (p *WebPage) SwitchToMainFrame() error {
-	return p.ref.process.doJSON("POST", "/webpage/SwitchToMainFrame", map[string]interface{}{"ref": p.ref.id}, nil)
+	return p.doJSON("POST", "/webpage/SwitchToMainFrame", map[string]interface{}{"ref": p.ref.id}, nil)
 }
 
 // SwitchToParentFrame switches the current frame to the parent of the current frame.
 func (p *WebPage) SwitchToParentFrame() error {
-	return p.ref.process.doJSON("POST", "/webpage/SwitchToParentFrame", map[string]interface{}{"ref": p.ref.id}, nil)
+	return p.doJSON("POST", "/webpage/SwitchToParentFrame", map[string]interface{}{"ref": p.ref.id}, nil)
 }
 
 // UploadFile uploads a file to a form element specified by selector.
 func (p *WebPage) UploadFile(selector, filename string) error {
-	return p.ref.process.doJSON("POST", "/webpage/UploadFile", map[string]interface{}{"ref": p.ref.id, "selector": selector, "filename": filename}, nil)
+	return p.doJSON("POST", "/webpage/UploadFile", map[string]interface{}{"ref": p.ref.id, "selector": selector, "filename": filename}, nil)
 }
 
 // OpenWebPageSettings represents the settings object passed to WebPage.Open().
@@ -955,11 +1670,32 @@ type OpenWebPageSettings struct {
 type Ref struct {
 	process *Process
 	id      string
+	timeout atomic.Int64
+	ops     *opQueue
 }
 
-// newRef returns a new instance of a referenced object within the process.
+// newRef returns the reference to an object within the process. Handles to
+// the same object share a reference, and with it the object's operation queue
+// and default timeout.
 func newRef(p *Process, id string) *Ref {
-	return &Ref{process: p, id: id}
+	p.refMu.Lock()
+	defer p.refMu.Unlock()
+	if r := p.refs[id]; r != nil {
+		return r
+	}
+	if p.refs == nil {
+		p.refs = make(map[string]*Ref)
+	}
+	r := &Ref{process: p, id: id, ops: newOpQueue()}
+	p.refs[id] = r
+	return r
+}
+
+// forgetRef removes the reference to a closed object.
+func (p *Process) forgetRef(id string) {
+	p.refMu.Lock()
+	defer p.refMu.Unlock()
+	delete(p.refs, id)
 }
 
 // ID returns the reference identifier.
@@ -967,6 +1703,11 @@ func (r *Ref) ID() string {
 	return r.id
 }
 
+// Process returns the process that the referenced object belongs to.
+func (r *Ref) Process() *Process {
+	return r.process
+}
+
 // refJSON is a struct for encoding refs as JSON.
 type refJSON struct {
 	ID string `json:"id"`
@@ -1148,20 +1889,59 @@ type webPageSettingsJSON struct {
 
 // shim is the included javascript used to communicate with PhantomJS.
 const shim = `
+var fs = require('fs');
 var system = require("system")
 var webpage = require('webpage');
 var webserver = require('webserver');
 
+// Shared secret that clients must send, if set.
+var token = system.env["TOKEN"] || '';
+
 /*
  * HTTP API
  */
 
-// Serves RPC API.
+// Serves RPC API. Requests are dispatched by name so that a reload can
+// replace the router.
 var server = webserver.create();
-server.listen(system.env["PORT"], function(request, response) {
+var serving = server.listen(system.env["PORT"], function(request, response) {
+	handleRequest(request, response);
+});
+
+// Exit with a distinct code if the port is taken, so the client can report it.
+if (!serving) {
+	console.error('phantomjs shim: cannot listen on port ' + system.env["PORT"]);
+	phantom.exit(3);
+}
+
+// Announce readiness for clients that watch the output instead of polling.
+if (system.env["READY_LINE"]) {
+	console.log(system.env["READY_LINE"]);
+}
+
+// Routes a request to its handler.
+function handleRequest(request, response) {
+	rpcCalls++;
+	if (!authorized(request)) {
+		response.statusCode = 403;
+		response.write(JSON.stringify({error: "unauthorized"}));
+		response.closeGracefully();
+		return;
+	}
 	try {
 		switch (request.url) {
 			case '/ping': return handlePing(request, response);
+			case '/process/ClearCache': return handleProcessClearCache(request, response);
+			case '/process/SetFilterList': return handleProcessSetFilterList(request, response);
+			case '/process/RemoveFilterList': return handleProcessRemoveFilterList(request, response);
+			case '/process/SetProxy': return handleProcessSetProxy(request, response);
+			case '/process/Page': return handleProcessPage(request, response);
+			case '/process/Pages': return handleProcessPages(request, response);
+			case '/process/AttachPage': return handleProcessAttachPage(request, response);
+			case '/process/Stats': return handleProcessStats(request, response);
+			case '/process/SetDebug': return handleProcessSetDebug(request, response);
+			case '/process/DebugEvents': return handleProcessDebugEvents(request, response);
+			case '/process/Reload': return handleProcessReload(request, response);
 			case '/webpage/CanGoBack': return handleWebpageCanGoBack(request, response);
 			case '/webpage/CanGoForward': return handleWebpageCanGoForward(request, response);
 			case '/webpage/ClipRect': return handleWebpageClipRect(request, response);
@@ -1171,6 +1951,7 @@ server.listen(system.env["PORT"], function(request, response) {
 			case '/webpage/CustomHeaders': return handleWebpageCustomHeaders(request, response);
 			case '/webpage/SetCustomHeaders': return handleWebpageSetCustomHeaders(request, response);
 			case '/webpage/Create': return handleWebpageCreate(request, response);
+			case '/webpage/CreateNamed': return handleWebpageCreateNamed(request, response);
 			case '/webpage/Content': return handleWebpageContent(request, response);
 			case '/webpage/SetContent': return handleWebpageSetContent(request, response);
 			case '/webpage/FocusedFrameName': return handleWebpageFocusedFrameName(request, response);
@@ -1234,14 +2015,51 @@ server.listen(system.env["PORT"], function(request, response) {
 			case '/webpage/SwitchToMainFrame': return handleWebpageSwitchToMainFrame(request, response);
 			case '/webpage/SwitchToParentFrame': return handleWebpageSwitchToParentFrame(request, response);
 			case '/webpage/UploadFile': return handleWebpageUploadFile(request, response);
-			default: return handleNotFound(request, response);
+			case '/webpage/SetResourceCapture': return handleWebpageSetResourceCapture(request, response);
+			case '/webpage/ResourceURLs': return handleWebpageResourceURLs(request, response);
+			case '/webpage/ResourceResponses': return handleWebpageResourceResponses(request, response);
+			case '/webpage/Resource': return handleWebpageResource(request, response);
+			case '/webpage/SetURLRewrites': return handleWebpageSetURLRewrites(request, response);
+			case '/webpage/OpenTiming': return handleWebpageOpenTiming(request, response);
+			case '/webpage/LoadedResources': return handleWebpageLoadedResources(request, response);
+			case '/webpage/ConsoleMessages': return handleWebpageConsoleMessages(request, response);
+			case '/webpage/RecentEvents': return handleWebpageRecentEvents(request, response);
+			case '/webpage/JSErrors': return handleWebpageJSErrors(request, response);
+			case '/webpage/EvaluateInFrame': return handleWebpageEvaluateInFrame(request, response);
+			case '/webpage/RenderWithOptions': return handleWebpageRenderWithOptions(request, response);
+			case '/webpage/RenderAll': return handleWebpageRenderAll(request, response);
+			case '/webpage/History': return handleWebpageHistory(request, response);
+			case '/webpage/WatchMutations': return handleWebpageWatchMutations(request, response);
+			case '/webpage/NextMutations': return handleWebpageNextMutations(request, response);
+			case '/webpage/UnwatchMutations': return handleWebpageUnwatchMutations(request, response);
+			case '/webpage/AddInitScript': return handleWebpageAddInitScript(request, response);
+			case '/webpage/UseScripts': return handleWebpageUseScripts(request, response);
+			case '/webpage/ExposeFunction': return handleWebpageExposeFunction(request, response);
+			case '/webpage/NextCalls': return handleWebpageNextCalls(request, response);
+			case '/webpage/ResolveCall': return handleWebpageResolveCall(request, response);
+			case '/webpage/SetFilterLists': return handleWebpageSetFilterLists(request, response);
+			case '/webpage/BlockStats': return handleWebpageBlockStats(request, response);
+			case '/webpage/WaitNetworkIdle': return handleWebpageWaitNetworkIdle(request, response);
+			case '/webpage/WatchProgress': return handleWebpageWatchProgress(request, response);
+			case '/webpage/NextProgress': return handleWebpageNextProgress(request, response);
+			case '/webpage/UnwatchProgress': return handleWebpageUnwatchProgress(request, response);
+			default: return handleCustomRoute(request, response);
 		}
 	} catch(e) {
 		response.statusCode = 500;
 		response.write(JSON.stringify({url: request.url, error: e.message}));
 		response.closeGracefully();
 	}
-});
+}
+
+// Returns true if the request carries the shared secret, or none is required.
+function authorized(request) {
+	if (!token) return true;
+	for (var name in request.headers) {
+		if (name.toLowerCase() === 'authorization') return request.headers[name] === 'Bearer ' + token;
+	}
+	return false;
+}
 
 function handlePing(request, response) {
 	response.statusCode = 200;
@@ -1249,6 +2067,108 @@ function handlePing(request, response) {
 	response.closeGracefully();
 }
 
+function handleProcessClearCache(request, response) {
+	// The memory cache is shared by all pages so a temporary page is used.
+	var page = webpage.create();
+	page.clearMemoryCache();
+	page.close();
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleProcessSetFilterList(request, response) {
+	var msg = JSON.parse(request.post);
+	filterLists[msg.name] = compileFilterList(msg.rules);
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleProcessRemoveFilterList(request, response) {
+	var msg = JSON.parse(request.post);
+	delete filterLists[msg.name];
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleProcessPage(request, response) {
+	var msg = JSON.parse(request.post);
+	var id = pageNames[msg.name];
+	response.write(JSON.stringify(id ? {found: true, ref: {id: id}} : {found: false}));
+	response.closeGracefully();
+}
+
+function handleProcessPages(request, response) {
+	var ids = {};
+	for (var name in pageNames) {
+		ids[pageNames[name]] = name;
+	}
+	var pages = [];
+	for (var id in refs) {
+		if (refs.hasOwnProperty(id) && isPage(refs[id])) {
+			pages.push({id: id, url: refs[id].url, title: refs[id].title, name: ids[id] || ''});
+		}
+	}
+	response.write(JSON.stringify({pages: pages}));
+	response.closeGracefully();
+}
+
+function handleProcessAttachPage(request, response) {
+	var msg = JSON.parse(request.post);
+	response.write(JSON.stringify({found: isPage(ref(msg.id))}));
+	response.closeGracefully();
+}
+
+function handleProcessStats(request, response) {
+	var pages = 0;
+	for (var id in refs) {
+		if (refs.hasOwnProperty(id) && isPage(refs[id])) pages++;
+	}
+	var queued = 0;
+	for (var wid in watchers) {
+		queued += watchers[wid].queue.length;
+	}
+	response.write(JSON.stringify({
+		pid: system.pid,
+		started: startTime,
+		now: Date.now(),
+		calls: rpcCalls - 1,
+		pages: pages,
+		queuedEvents: queued,
+	}));
+	response.closeGracefully();
+}
+
+function handleProcessSetDebug(request, response) {
+	var msg = JSON.parse(request.post);
+	debugging = !!msg.enabled;
+	debugEvents = [];
+	debugDropped = 0;
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleProcessDebugEvents(request, response) {
+	response.write(JSON.stringify({events: debugEvents, dropped: debugDropped}));
+	debugEvents = [];
+	debugDropped = 0;
+	response.closeGracefully();
+}
+
+function handleProcessReload(request, response) {
+	var msg = JSON.parse(request.post);
+	(0, eval)(msg.script); // global scope, so declarations replace the shim's
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleProcessSetProxy(request, response) {
+	var msg = JSON.parse(request.post);
+	// An empty host restores the system proxy configuration.
+	phantom.setProxy(msg.host, msg.port || 0, msg.type || 'http', msg.username || '', msg.password || '');
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
 function handleWebpageCanGoBack(request, response) {
 	var page = ref(JSON.parse(request.post).ref);
 	response.write(JSON.stringify({value: page.canGoBack}));
@@ -1304,18 +2224,73 @@ function handleWebpageSetCustomHeaders(request, response) {
 }
 
 function handleWebpageCreate(request, response) {
-	var ref = createRef(webpage.create());
+	var ref = createPage();
 	response.statusCode = 200;
 	response.write(JSON.stringify({ref: ref}));
 	response.closeGracefully();
 }
 
+function handleWebpageCreateNamed(request, response) {
+	var msg = JSON.parse(request.post);
+	if (pageNames[msg.name]) {
+		response.write(JSON.stringify({exists: true}));
+		response.closeGracefully();
+		return;
+	}
+	var ref = createPage();
+	pageNames[msg.name] = ref.id;
+	response.write(JSON.stringify({ref: ref}));
+	response.closeGracefully();
+}
+
 function handleWebpageOpen(request, response) {
 	var msg = JSON.parse(request.post)
 	var page = ref(msg.ref)
-	page.open(msg.url, function(status) {
-		response.write(JSON.stringify({status: status}));
+	var nav = opens[msg.ref] = {start: Date.now(), end: 0, url: msg.url, expect: msg.url, redirects: [], pending: {}, inflight: 0, activity: Date.now()};
+	if (loads[msg.ref]) loads[msg.ref] = {requests: {}, ids: []};
+
+	var waitUntil = msg.waitUntil || 'load', timer;
+	var respond = function(status) {
+		if (nav.end !== 0) return;
+		nav.end = Date.now();
+		clearInterval(timer);
+		response.write(JSON.stringify({
+			status: status,
+			url: page.url && page.url !== 'about:blank' ? page.url : nav.url,
+			statusCode: nav.statusCode || 0,
+			statusText: nav.statusText || '',
+			headers: nav.headers || [],
+			redirects: nav.redirects,
+			networkError: nav.error || '',
+			networkErrorCode: nav.errorCode || 0,
+			timedOut: !!nav.timedOut,
+			start: nav.start,
+			end: nav.end,
+		}));
 		response.closeGracefully();
+	};
+
+	// Respond once the new document has been parsed.
+	if (waitUntil === 'domready') {
+		timer = setInterval(function() {
+			if (nav.initialized && page.evaluate(function() { return document.readyState; }) !== 'loading') {
+				respond('success');
+			}
+		}, 10);
+	}
+
+	page.open(msg.url, function(status) {
+		if (status !== 'success' || waitUntil !== 'networkidle') {
+			return respond(status);
+		}
+
+		// Respond once no more than maxInflight requests have been pending
+		// for idleTime.
+		timer = setInterval(function() {
+			if (nav.inflight <= (msg.maxInflight || 0) && Date.now() - nav.activity >= (msg.idleTime || 0)) {
+				respond(status);
+			}
+		}, 10);
 	})
 }
 
@@ -1583,7 +2558,32 @@ function handleWebpageClose(request, response) {
 	// Close page.
 	var page = ref(msg.ref);
 	page.close();
-	delete(refs, msg.ref);
+	delete refs[msg.ref];
+	delete captures[msg.ref];
+	delete rewrites[msg.ref];
+	delete opens[msg.ref];
+	delete network[msg.ref];
+	delete loads[msg.ref];
+	delete messages[msg.ref];
+	delete histories[msg.ref];
+	delete recentEvents[msg.ref];
+	for (var id in watchers) {
+		if (watchers[id].ref === msg.ref) closeWatcher(id);
+	}
+	delete observing[msg.ref];
+	for (var id in progressWatchers) {
+		if (progressWatchers[id].ref === msg.ref) closeProgressWatcher(id);
+	}
+	delete reporting[msg.ref];
+	delete initScripts[msg.ref];
+	delete bundles[msg.ref];
+	delete blocking[msg.ref];
+	delete scriptGuards[msg.ref];
+	closeBridge(msg.ref);
+	for (var name in pageNames) {
+		if (pageNames[name] === msg.ref) delete pageNames[name];
+	}
+	unlisten(msg.ref);
 
 	// Close and dereference owned pages.
 	for (var i = 0; i < page.pages.length; i++) {
@@ -1606,16 +2606,16 @@ function handleWebpageEvaluateAsync(request, response) {
 function handleWebpageEvaluateJavaScript(request, response) {
 	var msg = JSON.parse(request.post);
 	var page = ref(msg.ref);
-	var returnValue = page.evaluateJavaScript(msg.script);
-	response.write(JSON.stringify({returnValue: returnValue}));
+	var returnValue = guardScript(msg.ref, page, msg.timeout, function() { return page.evaluateJavaScript(msg.script); });
+	response.write(JSON.stringify(returnValue === scriptTimeout ? {scriptTimeout: true} : {returnValue: returnValue}));
 	response.closeGracefully();
 }
 
 function handleWebpageEvaluate(request, response) {
 	var msg = JSON.parse(request.post);
 	var page = ref(msg.ref);
-	var returnValue = page.evaluate(msg.script);
-	response.write(JSON.stringify({returnValue: returnValue}));
+	var returnValue = guardScript(msg.ref, page, msg.timeout, function() { return page.evaluate(msg.script); });
+	response.write(JSON.stringify(returnValue === scriptTimeout ? {scriptTimeout: true} : {returnValue: returnValue}));
 	response.closeGracefully();
 }
 
@@ -1635,6 +2635,7 @@ function handleWebpagePage(request, response) {
 function handleWebpageGoBack(request, response) {
 	var msg = JSON.parse(request.post);
 	var page = ref(msg.ref);
+	navigateHistory(msg.ref, page, -1);
 	page.goBack();
 	response.write(JSON.stringify({}));
 	response.closeGracefully();
@@ -1643,6 +2644,7 @@ function handleWebpageGoBack(request, response) {
 function handleWebpageGoForward(request, response) {
 	var msg = JSON.parse(request.post);
 	var page = ref(msg.ref);
+	navigateHistory(msg.ref, page, 1);
 	page.goForward();
 	response.write(JSON.stringify({}));
 	response.closeGracefully();
@@ -1651,6 +2653,7 @@ function handleWebpageGoForward(request, response) {
 function handleWebpageGo(request, response) {
 	var msg = JSON.parse(request.post);
 	var page = ref(msg.ref);
+	navigateHistory(msg.ref, page, msg.index);
 	page.go(msg.index);
 	response.write(JSON.stringify({}));
 	response.closeGracefully();
@@ -1777,6 +2780,432 @@ function handleWebpageUploadFile(request, response) {
 	response.closeGracefully();
 }
 
+function handleWebpageSetResourceCapture(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	var listening = !!captures[msg.ref];
+	captures[msg.ref] = {
+		urlPatterns: (msg.urlPatterns || []).map(function(s) { return new RegExp(s); }),
+		contentTypes: msg.contentTypes || [],
+		urls: [],
+		resources: {},
+		requests: {},
+		responses: [],
+		seen: {},
+	};
+	if (!listening) {
+		listen(msg.ref, page, 'ResourceRequested', function(req) {
+			var capture = captures[msg.ref];
+			if (capture) {
+				capture.requests[req.id] = {method: req.method, headers: req.headers};
+			}
+		});
+		listen(msg.ref, page, 'ResourceReceived', function(res) {
+			var capture = captures[msg.ref];
+			if ((res.stage === 'end' || res.redirectURL) && capture && matchesCapture(capture, res)) {
+				captureResource(capture, res);
+			}
+		});
+	}
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleWebpageResourceURLs(request, response) {
+	var msg = JSON.parse(request.post);
+	var capture = captures[msg.ref];
+	response.write(JSON.stringify({value: capture ? capture.urls : []}));
+	response.closeGracefully();
+}
+
+function handleWebpageResourceResponses(request, response) {
+	var msg = JSON.parse(request.post);
+	var capture = captures[msg.ref];
+	response.write(JSON.stringify({value: capture ? capture.responses : []}));
+	response.closeGracefully();
+}
+
+function handleWebpageResource(request, response) {
+	var msg = JSON.parse(request.post);
+	var capture = captures[msg.ref];
+	var resource = capture ? capture.resources[msg.url] : null;
+	if (!resource) {
+		response.write(JSON.stringify({found: false}));
+		response.closeGracefully();
+		return;
+	}
+
+	var write = function() {
+		response.write(JSON.stringify({found: true, headers: resource.headers, body: resource.body, fetchError: resource.error}));
+		response.closeGracefully();
+	};
+	if (resource.done) {
+		write();
+	} else {
+		resource.callbacks.push(write);
+	}
+}
+
+function handleWebpageSetURLRewrites(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	var listening = !!rewrites[msg.ref];
+	rewrites[msg.ref] = msg.rewrites.map(function(r) { return {pattern: new RegExp(r.pattern), url: r.url}; });
+	if (!listening) {
+		listen(msg.ref, page, 'ResourceRequested', function(req, networkRequest) {
+			var a = rewrites[msg.ref] || [];
+			for (var i = 0; i < a.length; i++) {
+				if (a[i].pattern.test(req.url)) {
+					networkRequest.changeUrl(a[i].url);
+					return;
+				}
+			}
+		});
+	}
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleWebpageOpenTiming(request, response) {
+	var msg = JSON.parse(request.post);
+	var nav = opens[msg.ref] || {start: 0, end: 0};
+	response.write(JSON.stringify({start: nav.start, end: nav.end}));
+	response.closeGracefully();
+}
+
+function handleWebpageLoadedResources(request, response) {
+	var msg = JSON.parse(request.post);
+	var load = loads[msg.ref] || {requests: {}, ids: []};
+	response.write(JSON.stringify({value: load.ids.map(function(id) { return load.requests[id]; })}));
+	response.closeGracefully();
+}
+
+function handleWebpageConsoleMessages(request, response) {
+	var msg = JSON.parse(request.post);
+	response.write(JSON.stringify({value: (messages[msg.ref] || {console: []}).console}));
+	response.closeGracefully();
+}
+
+function handleWebpageRecentEvents(request, response) {
+	var msg = JSON.parse(request.post);
+	response.write(JSON.stringify({events: recentEvents[msg.ref] || []}));
+	response.closeGracefully();
+}
+
+function handleWebpageJSErrors(request, response) {
+	var msg = JSON.parse(request.post);
+	response.write(JSON.stringify({value: (messages[msg.ref] || {errors: []}).errors}));
+	response.closeGracefully();
+}
+
+function handleWebpageEvaluateInFrame(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	if (!page.switchToFrame(msg.frame)) {
+		response.write(JSON.stringify({found: false}));
+		response.closeGracefully();
+		return;
+	}
+	try {
+		var returnValue = page.evaluate.apply(page, [msg.script].concat(msg.args || []));
+	} finally {
+		page.switchToParentFrame();
+	}
+	response.write(JSON.stringify({found: true, returnValue: returnValue}));
+	response.closeGracefully();
+}
+
+function handleWebpageRenderWithOptions(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	var data = renderWithOptions(page, msg.options);
+	response.write(JSON.stringify(data === null ? {found: false} : {found: true, data: data}));
+	response.closeGracefully();
+}
+
+function handleWebpageRenderAll(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	var a = [];
+	for (var i = 0; i < msg.specs.length; i++) {
+		var data = renderWithOptions(page, msg.specs[i]);
+		if (data === null) {
+			response.write(JSON.stringify({found: false}));
+			response.closeGracefully();
+			return;
+		}
+		a.push(data);
+	}
+	response.write(JSON.stringify({found: true, value: a}));
+	response.closeGracefully();
+}
+
+function handleWebpageHistory(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	var h = histories[msg.ref] || {entries: [], index: -1};
+	if (h.entries[h.index]) h.entries[h.index].title = page.title;
+	response.write(JSON.stringify({entries: h.entries, index: h.index}));
+	response.closeGracefully();
+}
+
+function handleWebpageWatchMutations(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	var id = String(++watcherID);
+	var w = watchers[id] = {ref: msg.ref, selector: msg.selector, options: msg.options || {}, queue: [], waiting: null};
+	if (!observeMutations(page, id, w)) {
+		delete watchers[id];
+		response.write(JSON.stringify({found: false}));
+		response.closeGracefully();
+		return;
+	}
+
+	if (!observing[msg.ref]) {
+		observing[msg.ref] = true;
+		listen(msg.ref, page, 'Callback', function(data) {
+			var w = data && data.mutationWatcher && watchers[data.mutationWatcher];
+			if (!w) return;
+			w.queue = w.queue.concat(data.records);
+			flushWatcher(w);
+		});
+		listen(msg.ref, page, 'LoadFinished', function() {
+			for (var id in watchers) {
+				if (watchers[id].ref === msg.ref) observeMutations(page, id, watchers[id]);
+			}
+		});
+	}
+	response.write(JSON.stringify({found: true, id: id}));
+	response.closeGracefully();
+}
+
+function handleWebpageNextMutations(request, response) {
+	var msg = JSON.parse(request.post);
+	var w = watchers[msg.id];
+	if (!w) {
+		response.write(JSON.stringify({closed: true}));
+		response.closeGracefully();
+		return;
+	}
+
+	// Release an earlier request that was abandoned by the client without
+	// handing it any queued records.
+	if (w.waiting) {
+		w.waiting.write(JSON.stringify({records: []}));
+		w.waiting.closeGracefully();
+	}
+	w.waiting = response;
+	if (w.queue.length > 0) return flushWatcher(w);
+	setTimeout(function() {
+		if (w.waiting === response) flushWatcher(w, true);
+	}, msg.timeout);
+}
+
+function handleWebpageUnwatchMutations(request, response) {
+	var msg = JSON.parse(request.post);
+	var w = watchers[msg.id];
+	if (w) {
+		ref(w.ref).evaluate(function(id) {
+			var observers = window.__phantomObservers || {};
+			if (observers[id]) observers[id].disconnect();
+			delete observers[id];
+		}, msg.id);
+		closeWatcher(msg.id);
+	}
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleWebpageAddInitScript(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	if (!initScripts[msg.ref]) {
+		initScripts[msg.ref] = [];
+		listen(msg.ref, page, 'Initialized', function() {
+			(initScripts[msg.ref] || []).forEach(function(script) { page.evaluate(script); });
+		});
+	}
+	initScripts[msg.ref].push(msg.script);
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleWebpageUseScripts(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	var b = bundles[msg.ref] || [];
+
+	// Skip scripts that are already used, and reject the whole bundle if one
+	// is used with another version.
+	var added = [];
+	for (var i = 0; i < msg.scripts.length; i++) {
+		var s = msg.scripts[i], used = b.concat(added).filter(function(u) { return u.name === s.name; })[0];
+		if (!used) {
+			added.push(s);
+		} else if (used.version !== s.version) {
+			response.write(JSON.stringify({conflict: s.name}));
+			response.closeGracefully();
+			return;
+		}
+	}
+
+	if (!bundles[msg.ref]) {
+		bundles[msg.ref] = b;
+		listen(msg.ref, page, 'LoadFinished', function() {
+			if (bundles[msg.ref]) injectBundle(page, bundles[msg.ref]);
+		});
+	}
+	b.push.apply(b, added);
+
+	// Scripts that fail are not used for later documents.
+	var failures = injectBundle(page, b);
+	failures.map(function(f) { return b[f.index]; }).forEach(function(s) {
+		b.splice(b.indexOf(s), 1);
+	});
+	response.write(JSON.stringify({scriptError: failures.length ? failures[0].error : ''}));
+	response.closeGracefully();
+}
+
+function handleWebpageExposeFunction(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	if (!bridges[msg.ref]) {
+		var b = bridges[msg.ref] = {names: [], queue: [], waiting: null};
+		listen(msg.ref, page, 'Callback', function(data) {
+			if (!data || !data.exposedCall) return;
+			b.queue.push({id: data.id, name: data.exposedCall, args: data.args || []});
+			flushBridge(b);
+		});
+		listen(msg.ref, page, 'Initialized', function() {
+			installBridge(page, b.names);
+		});
+	}
+	if (bridges[msg.ref].names.indexOf(msg.name) === -1) bridges[msg.ref].names.push(msg.name);
+	installBridge(page, [msg.name]);
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleWebpageNextCalls(request, response) {
+	var msg = JSON.parse(request.post);
+	var b = bridges[msg.ref];
+	if (!b) {
+		response.write(JSON.stringify({closed: true}));
+		response.closeGracefully();
+		return;
+	}
+
+	if (b.waiting) {
+		b.waiting.write(JSON.stringify({calls: []}));
+		b.waiting.closeGracefully();
+	}
+	b.waiting = response;
+	if (b.queue.length > 0) return flushBridge(b);
+	setTimeout(function() {
+		if (b.waiting === response) flushBridge(b, true);
+	}, msg.timeout);
+}
+
+function handleWebpageResolveCall(request, response) {
+	var msg = JSON.parse(request.post);
+	if (refs[msg.ref]) {
+		ref(msg.ref).evaluate(function(id, error, result) {
+			var b = window.__phantomExposed;
+			if (b) b.resolve(id, error, result);
+		}, msg.id, msg.error || '', msg.result === undefined ? null : msg.result);
+	}
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleWebpageSetFilterLists(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	if (!blocking[msg.ref]) {
+		blocking[msg.ref] = {lists: [], count: 0, hosts: {}, recent: []};
+		listen(msg.ref, page, 'ResourceRequested', function(req, networkRequest) {
+			blockRequest(msg.ref, page, req, networkRequest);
+		});
+	}
+	blocking[msg.ref].lists = msg.names;
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
+
+function handleWebpageBlockStats(request, response) {
+	var msg = JSON.parse(request.post);
+	var b = blocking[msg.ref] || {count: 0, hosts: {}, recent: []};
+	response.write(JSON.stringify({count: b.count, hosts: b.hosts, recent: b.recent}));
+	response.closeGracefully();
+}
+
+// Responds once no more than maxInflight of the page's requests have been
+// pending for idleTime, or with idle false once timeout expires. The quiet
+// period starts no earlier than the request, so requests that scripts make
+// shortly after an action are waited for.
+function handleWebpageWaitNetworkIdle(request, response) {
+	var msg = JSON.parse(request.post);
+	var start = Date.now(), deadline = start + (msg.timeout || 0);
+	var timer = setInterval(function() {
+		var net = network[msg.ref];
+		var idle = !net || (net.inflight <= (msg.maxInflight || 0) && Date.now() - Math.max(net.activity, start) >= (msg.idleTime || 0));
+		if (!idle && (!msg.timeout || Date.now() < deadline)) return;
+		clearInterval(timer);
+		response.write(JSON.stringify({idle: idle}));
+		response.closeGracefully();
+	}, 10);
+}
+
+function handleWebpageWatchProgress(request, response) {
+	var msg = JSON.parse(request.post);
+	var page = ref(msg.ref);
+	var id = String(++progressWatcherID);
+	progressWatchers[id] = {ref: msg.ref, queue: [], waiting: null};
+
+	if (!reporting[msg.ref]) {
+		reporting[msg.ref] = true;
+		listen(msg.ref, page, 'Callback', function(data) {
+			if (!data || data.type !== 'progress') return;
+			var report = {percent: data.percent, step: data.step, message: data.message, current: data.current, total: data.total};
+			for (var id in progressWatchers) {
+				var w = progressWatchers[id];
+				if (w.ref !== msg.ref) continue;
+				w.queue.push(report);
+				if (w.queue.length > maxQueuedProgress) w.queue.shift();
+				flushWatcher(w);
+			}
+		});
+	}
+	response.write(JSON.stringify({id: id}));
+	response.closeGracefully();
+}
+
+function handleWebpageNextProgress(request, response) {
+	var msg = JSON.parse(request.post);
+	var w = progressWatchers[msg.id];
+	if (!w) {
+		response.write(JSON.stringify({closed: true}));
+		response.closeGracefully();
+		return;
+	}
+
+	if (w.waiting) {
+		w.waiting.write(JSON.stringify({records: []}));
+		w.waiting.closeGracefully();
+	}
+	w.waiting = response;
+	if (w.queue.length > 0) return flushWatcher(w);
+	setTimeout(function() {
+		if (w.waiting === response) flushWatcher(w, true);
+	}, msg.timeout);
+}
+
+function handleWebpageUnwatchProgress(request, response) {
+	var msg = JSON.parse(request.post);
+	closeProgressWatcher(msg.id);
+	response.write(JSON.stringify({}));
+	response.closeGracefully();
+}
 
 function handleNotFound(request, response) {
 	response.statusCode = 404;
@@ -1810,12 +3239,28 @@ function createRef(value) {
 	return {id: refID.toString()};
 }
 
+// Creates a web page with its event tracking and returns its ref.
+function createPage() {
+	var page = webpage.create();
+	var ref = createRef(page);
+	trackOpens(ref.id, page);
+	trackNetwork(ref.id, page);
+	trackLoads(ref.id, page);
+	trackMessages(ref.id, page);
+	trackHistory(ref.id, page);
+	trackDebug(ref.id, page);
+	return ref;
+}
+
+// Holds the ids of named pages by name.
+var pageNames = {};
+
 // Removes a reference to a value, if any.
 function deleteRef(value) {
 	for (var key in refs) {
 		if (refs.hasOwnProperty(key)) {
 			if (refs[key] === value) {
-				delete(refs, key);
+				delete refs[key];
 			}
 		}
 	}
@@ -1825,5 +3270,832 @@ function deleteRef(value) {
 function ref(id) {
 	return refs[id];
 }
+
+// Returns true if a referenced value is a web page.
+function isPage(value) {
+	return !!value && typeof value.open === 'function' && typeof value.evaluate === 'function';
+}
+
+
+/*
+ * EVENTS
+ */
+
+// Holds the listeners of each page's callbacks, keyed by "ref:name".
+var listeners = {};
+
+// Adds fn as a listener of a page callback, such as "ResourceReceived" for
+// page.onResourceReceived. Listeners are called in the order they were added.
+function listen(id, page, name, fn) {
+	var key = id + ':' + name;
+	if (!listeners[key]) {
+		listeners[key] = [];
+		page['on' + name] = function() {
+			var args = arguments;
+			(listeners[key] || []).forEach(function(fn) { fn.apply(null, args); });
+		};
+	}
+	listeners[key].push(fn);
+}
+
+// Removes all listeners of a page.
+function unlisten(id) {
+	for (var key in listeners) {
+		if (key.indexOf(id + ':') === 0) {
+			delete listeners[key];
+		}
+	}
+}
+
+
+/*
+ * RESOURCES
+ */
+
+// Holds the resource capture settings and captured resources by page ref.
+var captures = {};
+
+// Holds the URL rewrites by page ref.
+var rewrites = {};
+
+// Holds the timing and main document response of each page's last open by
+// page ref.
+var opens = {};
+
+// Follows the main document of each open through redirects and records its
+// final response or network error. Also counts the requests in flight so
+// opens can wait for the network to become idle.
+function trackOpens(id, page) {
+	var finish = function(nav, reqId) {
+		if (nav.pending[reqId]) {
+			delete nav.pending[reqId];
+			nav.inflight--;
+			nav.activity = Date.now();
+		}
+	};
+	listen(id, page, 'Initialized', function() {
+		if (opens[id]) opens[id].initialized = true;
+	});
+	listen(id, page, 'ResourceRequested', function(req) {
+		var nav = opens[id];
+		if (nav && nav.end === 0) {
+			nav.pending[req.id] = true;
+			nav.inflight++;
+			nav.activity = Date.now();
+		}
+		if (nav && nav.end === 0 && nav.expect !== undefined && (nav.mainId === undefined || req.url === nav.expect)) {
+			nav.mainId = req.id;
+			nav.url = req.url;
+			delete nav.expect;
+		}
+	});
+	listen(id, page, 'ResourceReceived', function(res) {
+		var nav = opens[id];
+		if (nav && (res.stage === 'end' || res.redirectURL)) finish(nav, res.id);
+		if (!nav || res.id !== nav.mainId || res.stage !== 'start') return;
+		if (res.redirectURL) {
+			nav.redirects.push({url: res.url, statusCode: res.status});
+			nav.expect = res.redirectURL;
+			return;
+		}
+		nav.statusCode = res.status;
+		nav.statusText = res.statusText;
+		nav.headers = res.headers;
+	});
+	var fail = function(err) {
+		var nav = opens[id];
+		if (nav) finish(nav, err.id);
+		if (!nav || err.id !== nav.mainId) return;
+		nav.error = err.errorString;
+		nav.errorCode = err.errorCode;
+		if (err.status) nav.statusCode = err.status;
+		if (err.statusText) nav.statusText = err.statusText;
+	};
+	listen(id, page, 'ResourceError', fail);
+	listen(id, page, 'ResourceTimeout', function(req) {
+		var nav = opens[id];
+		if (nav && req.id === nav.mainId) nav.timedOut = true;
+		fail(req);
+	});
+}
+
+// Holds the requests in flight of each page by page ref. Unlike opens, they
+// are counted after the page has loaded too.
+var network = {};
+
+// Counts the requests a page has in flight and records when one last started
+// or finished.
+function trackNetwork(id, page) {
+	var net = network[id] = {pending: {}, inflight: 0, activity: Date.now()};
+	var finish = function(res) {
+		if (!net.pending[res.id]) return;
+		delete net.pending[res.id];
+		net.inflight--;
+		net.activity = Date.now();
+	};
+	listen(id, page, 'ResourceRequested', function(req) {
+		net.pending[req.id] = true;
+		net.inflight++;
+		net.activity = Date.now();
+	});
+	listen(id, page, 'ResourceReceived', function(res) {
+		if (res.stage === 'end' || res.redirectURL) finish(res);
+	});
+	listen(id, page, 'ResourceError', finish);
+	listen(id, page, 'ResourceTimeout', finish);
+}
+
+// Holds the requests made by each page since its last open by page ref.
+var loads = {};
+
+// Records the requests made by a page and their outcomes.
+function trackLoads(id, page) {
+	loads[id] = {requests: {}, ids: []};
+	listen(id, page, 'ResourceRequested', function(req) {
+		var load = loads[id];
+		if (!load || req.url.indexOf('data:') === 0) return;
+		load.ids.push(req.id);
+		load.requests[req.id] = {
+			url: req.url,
+			method: req.method,
+			requestHeaders: req.headers,
+			start: req.time,
+			size: 0,
+		};
+	});
+	listen(id, page, 'ResourceReceived', function(res) {
+		var r = loads[id] && loads[id].requests[res.id];
+		if (!r) return;
+		r.status = res.status;
+		r.contentType = res.contentType;
+		r.size = Math.max(r.size, res.bodySize || 0);
+		(res.headers || []).forEach(function(h) {
+			if (h.name.toLowerCase() === 'content-length') r.size = Math.max(r.size, parseInt(h.value, 10) || 0);
+		});
+		if (res.stage === 'end') r.end = res.time;
+	});
+	var fail = function(err) {
+		var r = loads[id] && loads[id].requests[err.id];
+		if (!r) return;
+		r.error = err.errorString || 'request failed';
+		if (err.status) r.status = err.status;
+		if (!r.end) r.end = new Date();
+	};
+	listen(id, page, 'ResourceError', fail);
+	listen(id, page, 'ResourceTimeout', fail);
+}
+
+// Returns true if a received resource is selected by the capture settings.
+function matchesCapture(capture, res) {
+	for (var i = 0; i < capture.urlPatterns.length; i++) {
+		if (capture.urlPatterns[i].test(res.url)) return true;
+	}
+	for (var i = 0; i < capture.contentTypes.length; i++) {
+		if ((res.contentType || '').indexOf(capture.contentTypes[i]) === 0) return true;
+	}
+	return false;
+}
+
+// Records a received resource and fetches its body again in the background.
+// Redirects have no body and are not fetched.
+function captureResource(capture, res) {
+	var key = res.id + ' ' + res.url;
+	if (capture.seen[key]) return;
+	capture.seen[key] = true;
+
+	var req = capture.requests[res.id] || {};
+	capture.responses.push({
+		url: res.url,
+		method: req.method,
+		requestHeaders: req.headers,
+		status: res.status,
+		statusText: res.statusText,
+		headers: res.headers,
+		redirectURL: res.redirectURL,
+		time: res.time,
+	});
+
+	var resource = {headers: res.headers, done: false, callbacks: []};
+	if (!capture.resources[res.url]) {
+		capture.urls.push(res.url);
+	}
+	capture.resources[res.url] = resource;
+	if (res.redirectURL) {
+		resource.body = '';
+		resource.done = true;
+		return;
+	}
+
+	var xhr = new XMLHttpRequest();
+	xhr.open('GET', res.url, true);
+	xhr.overrideMimeType('text/plain; charset=x-user-defined');
+	xhr.onreadystatechange = function() {
+		if (xhr.readyState !== 4) return;
+		if (xhr.status === 0) {
+			resource.error = 'fetch failed: ' + res.url;
+		} else {
+			resource.body = binaryToBase64(xhr.responseText);
+		}
+		resource.done = true;
+		resource.callbacks.forEach(function(fn) { fn(); });
+		resource.callbacks = [];
+	};
+	xhr.send();
+}
+
+// Encodes a string of bytes received with the x-user-defined charset.
+function binaryToBase64(s) {
+	var bin = '';
+	for (var i = 0; i < s.length; i++) {
+		bin += String.fromCharCode(s.charCodeAt(i) & 0xff);
+	}
+	return btoa(bin);
+}
+
+
+/*
+ * RENDERING
+ */
+
+// Counter used to generate unique render file names.
+var renderID = 0;
+
+// Renders a page with the given options and returns the output encoded as
+// base64. Returns null if the clip selector matches no element. The page's
+// clip rect and zoom factor are restored afterward.
+function renderWithOptions(page, opts) {
+	var clipRect = page.clipRect, zoomFactor = page.zoomFactor;
+	try {
+		if (opts.scale) page.zoomFactor = opts.scale;
+		if (opts.selector) {
+			var rect = page.evaluate(function(selector) {
+				var el = document.querySelector(selector);
+				if (!el) return null;
+				var r = el.getBoundingClientRect();
+				return {top: r.top + window.pageYOffset, left: r.left + window.pageXOffset, width: r.width, height: r.height};
+			}, opts.selector);
+			if (!rect) return null;
+			var z = page.zoomFactor;
+			page.clipRect = {
+				top: Math.floor(rect.top * z),
+				left: Math.floor(rect.left * z),
+				width: Math.ceil(rect.width * z),
+				height: Math.ceil(rect.height * z),
+			};
+		}
+
+		// PhantomJS can only render PDFs to files so all formats are
+		// rendered to a temporary file next to the shim.
+		var dir = fs.absolute(system.args[0]).split(fs.separator).slice(0, -1).join(fs.separator);
+		var path = dir + fs.separator + 'render-' + (++renderID) + '.' + opts.format.toLowerCase();
+		page.render(path, {format: opts.format, quality: opts.quality, onlyViewport: !!opts.onlyViewport});
+		try {
+			return binaryToBase64(fs.read(path, 'b'));
+		} finally {
+			fs.remove(path);
+		}
+	} finally {
+		page.clipRect = clipRect;
+		page.zoomFactor = zoomFactor;
+	}
+}
+
+/*
+ * MESSAGES
+ */
+
+// The maximum number of console messages and errors kept per page.
+var MAX_MESSAGES = 1000;
+
+// Holds the console messages and JavaScript errors of each page by page ref.
+var messages = {};
+
+// Buffers the console messages and uncaught errors of a page. The oldest
+// entries are dropped once MAX_MESSAGES is reached.
+function trackMessages(id, page) {
+	var m = messages[id] = {console: [], errors: []};
+	var push = function(a, v) {
+		a.push(v);
+		if (a.length > MAX_MESSAGES) a.shift();
+	};
+	listen(id, page, 'ConsoleMessage', function(message, line, source) {
+		push(m.console, {message: String(message), line: line || 0, source: source || '', time: new Date()});
+	});
+	listen(id, page, 'Error', function(message, trace) {
+		push(m.errors, {
+			message: String(message),
+			trace: (trace || []).map(function(t) { return {file: t.file || '', line: t.line || 0, function: t.function || ''}; }),
+			time: new Date(),
+		});
+	});
+}
+
+
+/*
+ * HISTORY
+ */
+
+// Holds the back/forward history of each page by page ref.
+var histories = {};
+
+// Records the URLs visited by a page. URL changes caused by navigateHistory
+// move within the history. Other changes to an adjacent entry's URL are
+// treated as script-driven back or forward navigations, and any remaining
+// change is a new entry that replaces the forward history.
+function trackHistory(id, page) {
+	var h = histories[id] = {entries: [], index: -1, delta: 0};
+	listen(id, page, 'UrlChanged', function(url) {
+		if (h.delta !== 0) {
+			h.index = Math.max(0, Math.min(h.entries.length - 1, h.index + h.delta));
+			h.delta = 0;
+			h.entries[h.index].url = url;
+			return;
+		}
+
+		var current = h.entries[h.index];
+		if (current && current.url === url) return;
+		if (h.entries[h.index - 1] && h.entries[h.index - 1].url === url) {
+			h.index--;
+			return;
+		}
+		if (h.entries[h.index + 1] && h.entries[h.index + 1].url === url) {
+			h.index++;
+			return;
+		}
+		if (current) current.title = page.title;
+		h.entries = h.entries.slice(0, h.index + 1);
+		h.entries.push({url: url, title: ''});
+		h.index++;
+	});
+	listen(id, page, 'LoadFinished', function() {
+		if (h.entries[h.index]) h.entries[h.index].title = page.title;
+	});
+}
+
+// Marks a page as moving delta entries through its history.
+function navigateHistory(id, page, delta) {
+	var h = histories[id];
+	if (h && h.entries[h.index + delta]) h.delta = delta;
+}
+
+
+/*
+ * MUTATIONS
+ */
+
+// Holds the mutation watchers by id.
+var watchers = {};
+var watcherID = 0;
+
+// Holds the refs of pages with mutation listeners installed.
+var observing = {};
+
+// Installs a MutationObserver for a watcher on the page's current document.
+// Returns false if the watcher's selector matches no element.
+function observeMutations(page, id, w) {
+	return page.evaluate(function(id, selector, options) {
+		var el = document.querySelector(selector);
+		var Observer = window.MutationObserver || window.WebKitMutationObserver;
+		if (!el || !Observer) return false;
+
+		function summary(node) {
+			if (!node) return null;
+			return {
+				tag: node.nodeType === 1 ? node.tagName.toLowerCase() : node.nodeName,
+				id: node.id || '',
+				className: typeof node.className === 'string' ? node.className : '',
+				text: (node.textContent || '').replace(/\s+/g, ' ').trim().slice(0, 200)
+			};
+		}
+
+		var observers = window.__phantomObservers = window.__phantomObservers || {};
+		if (observers[id]) observers[id].disconnect();
+		observers[id] = new Observer(function(records) {
+			(window.callPhantom || window.__phantomCallback)({mutationWatcher: id, records: records.map(function(r) {
+				return {
+					type: r.type,
+					target: summary(r.target),
+					added: Array.prototype.map.call(r.addedNodes, summary),
+					removed: Array.prototype.map.call(r.removedNodes, summary),
+					attributeName: r.attributeName || '',
+					value: r.type === 'attributes' ? (r.target.getAttribute(r.attributeName) || '') : (r.type === 'characterData' ? r.target.data : '')
+				};
+			})});
+		});
+		observers[id].observe(el, {childList: true, subtree: true, attributes: !!options.attributes, characterData: !!options.characterData});
+		return true;
+	}, id, w.selector, w.options);
+}
+
+// Sends a watcher's queued records to its waiting request, if any. Empty
+// responses are only sent when force is true.
+function flushWatcher(w, force) {
+	if (!w.waiting || (w.queue.length === 0 && !force)) return;
+	var response = w.waiting;
+	w.waiting = null;
+	response.write(JSON.stringify({records: w.queue}));
+	response.closeGracefully();
+	w.queue = [];
+}
+
+// Removes a watcher and releases its waiting request.
+function closeWatcher(id) {
+	var w = watchers[id];
+	delete watchers[id];
+	if (w && w.waiting) {
+		w.waiting.write(JSON.stringify({closed: true}));
+		w.waiting.closeGracefully();
+	}
+}
+
+
+/*
+ * SCRIPTS
+ */
+
+// Holds the scripts evaluated in each new document of a page by page ref.
+var initScripts = {};
+
+// Holds the helper scripts used by each page by page ref, in the order they
+// are injected.
+var bundles = {};
+
+// Injects helper scripts into the page's current document, skipping scripts
+// that the document already has. A script is present if it was injected
+// before or if its check expression evaluates to its version. Scripts that
+// fail to run do not stop the rest; their indexes and errors are returned in
+// order.
+function injectBundle(page, scripts) {
+	return page.evaluate(function(scripts) {
+		var loaded = window.__phantomScripts = window.__phantomScripts || {}, failures = [];
+		for (var i = 0; i < scripts.length; i++) {
+			var s = scripts[i];
+			if (loaded[s.name] === s.version) continue;
+			try {
+				if (s.check && String((0, eval)(s.check)) === s.version) {
+					loaded[s.name] = s.version;
+					continue;
+				}
+			} catch (e) {}
+
+			try {
+				(0, eval)(s.source);
+			} catch (e) {
+				failures.push({index: i, error: s.name + ': ' + e});
+				continue;
+			}
+			loaded[s.name] = s.version;
+		}
+		return failures;
+	}, scripts) || [];
+}
+
+
+/*
+ * BLOCKING
+ */
+
+// The maximum number of recently blocked URLs kept per page.
+var MAX_BLOCKED_URLS = 100;
+
+// Holds the compiled filter lists by name.
+var filterLists = {};
+
+// Holds the filter lists and blocked request stats by page ref.
+var blocking = {};
+
+// Compiles filter rules into blocking and exception indexes. Rules anchored to
+// a host are indexed by it so that most rules are skipped for a request.
+function compileFilterList(rules) {
+	var list = {block: {hosts: {}, rules: []}, allow: {hosts: {}, rules: []}};
+	rules.forEach(function(r) {
+		try {
+			r.re = new RegExp(r.pattern, r.matchCase ? '' : 'i');
+		} catch (e) {
+			return;
+		}
+		var index = r.exception ? list.allow : list.block;
+		if (r.host) {
+			(index.hosts[r.host] = index.hosts[r.host] || []).push(r);
+		} else {
+			index.rules.push(r);
+		}
+	});
+	return list;
+}
+
+// Aborts a page request if it matches the page's filter lists.
+function blockRequest(id, page, req, networkRequest) {
+	var b = blocking[id];
+	if (!b || b.lists.length === 0 || !/^https?:/i.test(req.url)) return;
+
+	// Never block the main document of an open.
+	var nav = opens[id];
+	if (nav && nav.mainId === req.id) return;
+
+	var host = urlHost(req.url);
+	var pageHost = urlHost(nav && nav.url ? nav.url : page.url);
+	var info = {
+		url: req.url,
+		host: host,
+		pageHost: pageHost,
+		thirdParty: baseDomain(host) !== baseDomain(pageHost),
+		type: requestType(req),
+	};
+
+	var lists = b.lists.map(function(name) { return filterLists[name]; }).filter(Boolean);
+	var blocked = lists.some(function(l) { return matchFilterIndex(l.block, info); });
+	if (!blocked || lists.some(function(l) { return matchFilterIndex(l.allow, info); })) return;
+
+	networkRequest.abort();
+	b.count++;
+	b.hosts[host] = (b.hosts[host] || 0) + 1;
+	b.recent.push(req.url);
+	if (b.recent.length > MAX_BLOCKED_URLS) b.recent.shift();
+}
+
+// Returns true if any rule in the index matches the request.
+function matchFilterIndex(index, info) {
+	for (var h = info.host; h; h = h.indexOf('.') === -1 ? '' : h.slice(h.indexOf('.') + 1)) {
+		var a = index.hosts[h] || [];
+		for (var i = 0; i < a.length; i++) {
+			if (matchFilterRule(a[i], info)) return true;
+		}
+	}
+	for (var j = 0; j < index.rules.length; j++) {
+		if (matchFilterRule(index.rules[j], info)) return true;
+	}
+	return false;
+}
+
+// Returns true if the rule's options and pattern match the request.
+function matchFilterRule(r, info) {
+	var onDomain = function(d) { return info.pageHost === d || info.pageHost.slice(-d.length - 1) === '.' + d; };
+	if (r.thirdParty === 1 && !info.thirdParty) return false;
+	if (r.thirdParty === -1 && info.thirdParty) return false;
+	if (r.types && r.types.indexOf(info.type) === -1) return false;
+	if (r.notTypes && r.notTypes.indexOf(info.type) !== -1) return false;
+	if (r.domains && !r.domains.some(onDomain)) return false;
+	if (r.notDomains && r.notDomains.some(onDomain)) return false;
+	return r.re.test(info.url);
+}
+
+// Returns the lowercase host name of a URL.
+function urlHost(url) {
+	var m = /^[a-z][a-z0-9+.-]*:\/\/(?:[^\/?#@]*@)?([^\/?#:]*)/i.exec(url || '');
+	return m ? m[1].toLowerCase() : '';
+}
+
+// Returns an approximation of the registrable domain of a host.
+function baseDomain(host) {
+	var labels = host.split('.');
+	var n = (labels.length > 2 && labels[labels.length - 1].length === 2 && labels[labels.length - 2].length <= 3) ? 3 : 2;
+	return labels.slice(-n).join('.');
+}
+
+// Infers the type of a request from its URL and Accept header.
+function requestType(req) {
+	var accept = '';
+	var xhr = false;
+	(req.headers || []).forEach(function(h) {
+		var name = h.name.toLowerCase();
+		if (name === 'accept') accept = h.value;
+		if (name === 'x-requested-with') xhr = true;
+	});
+	if (xhr) return 'xmlhttprequest';
+
+	var path = req.url.split(/[?#]/)[0].toLowerCase();
+	var ext = path.slice(path.lastIndexOf('/') + 1).split('.').pop();
+	if (ext === 'js' || ext === 'mjs') return 'script';
+	if (ext === 'css') return 'stylesheet';
+	if (/^(png|jpe?g|gif|webp|svg|ico|bmp)$/.test(ext)) return 'image';
+	if (/^(woff2?|ttf|otf|eot)$/.test(ext)) return 'font';
+	if (/^(mp3|mp4|ogg|wav|webm)$/.test(ext)) return 'media';
+	if (accept.indexOf('text/css') === 0) return 'stylesheet';
+	if (accept.indexOf('image/') === 0) return 'image';
+	return 'other';
+}
+
+
+/*
+ * STATS
+ */
+
+// Time the shim started, in milliseconds since the epoch.
+var startTime = Date.now();
+
+// Number of RPC requests received.
+var rpcCalls = 0;
+
+
+/*
+ * SCRIPT TIMEOUTS
+ */
+
+// Returned by guardScript when a script is stopped.
+var scriptTimeout = {};
+
+// Holds the running guarded script of each page by page ref.
+var scriptGuards = {};
+
+// Calls fn, which runs a script on the page, and stops the script if it is
+// still running after timeout milliseconds. PhantomJS reports long running
+// scripts every few seconds so the script may run longer than the timeout.
+// Returns scriptTimeout if the script was stopped.
+function guardScript(id, page, timeout, fn) {
+	if (!timeout) return fn();
+	if (!scriptGuards[id]) {
+		listen(id, page, 'LongRunningScript', function() {
+			var g = scriptGuards[id];
+			if (g && g.start && Date.now() - g.start >= g.timeout) {
+				g.stopped = true;
+				page.stopJavaScript();
+			}
+		});
+	}
+
+	var g = scriptGuards[id] = {start: Date.now(), timeout: timeout, stopped: false};
+	try {
+		var returnValue = fn();
+	} finally {
+		g.start = 0;
+	}
+	if (!g.stopped) return returnValue;
+
+	// Abort anything the script started loading.
+	page.stop();
+	return scriptTimeout;
+}
+
+
+/*
+ * DEBUG
+ */
+
+// The maximum number of debug events buffered between polls.
+var MAX_DEBUG_EVENTS = 1000;
+
+// Set while a client is dumping debug output.
+var debugging = false;
+
+// Holds the page events recorded since the last poll and the number dropped.
+var debugEvents = [];
+var debugDropped = 0;
+
+// The maximum number of recent events kept per page for replay.
+var MAX_RECENT_EVENTS = 200;
+
+// Holds the most recent events of each page by page ref.
+var recentEvents = {};
+
+// Records the events of a page for replay, and for the debug dump while
+// debugging is enabled.
+function trackDebug(id, page) {
+	var recent = recentEvents[id] = [];
+	var record = function(name, data) {
+		var e = {time: Date.now(), ref: id, event: name, data: data};
+		recent.push(e);
+		if (recent.length > MAX_RECENT_EVENTS) recent.shift();
+
+		if (!debugging) return;
+		debugEvents.push(e);
+		if (debugEvents.length > MAX_DEBUG_EVENTS) {
+			debugEvents.shift();
+			debugDropped++;
+		}
+	};
+	listen(id, page, 'LoadStarted', function() { record('LoadStarted', {url: page.url}); });
+	listen(id, page, 'LoadFinished', function(status) { record('LoadFinished', {status: status, url: page.url}); });
+	listen(id, page, 'UrlChanged', function(url) { record('UrlChanged', {url: url}); });
+	listen(id, page, 'NavigationRequested', function(url, type, willNavigate, main) {
+		record('NavigationRequested', {url: url, type: type, willNavigate: willNavigate, main: main});
+	});
+	listen(id, page, 'ResourceRequested', function(req) { record('ResourceRequested', {id: req.id, method: req.method, url: req.url}); });
+	listen(id, page, 'ResourceReceived', function(res) {
+		if (res.stage === 'end') record('ResourceReceived', {id: res.id, url: res.url, status: res.status, contentType: res.contentType});
+	});
+	listen(id, page, 'ResourceError', function(err) {
+		record('ResourceError', {id: err.id, url: err.url, errorCode: err.errorCode, errorString: err.errorString});
+	});
+	listen(id, page, 'ResourceTimeout', function(req) { record('ResourceTimeout', {id: req.id, url: req.url}); });
+	listen(id, page, 'ConsoleMessage', function(msg, line, source) { record('ConsoleMessage', {message: msg, line: line, source: source}); });
+	listen(id, page, 'Error', function(msg) { record('Error', {message: msg}); });
+	listen(id, page, 'Alert', function(msg) { record('Alert', {message: msg}); });
+}
+
+/*
+ * EXPOSED FUNCTIONS
+ */
+
+// Holds the exposed function names and queued calls of each page by page ref.
+var bridges = {};
+
+// Defines window functions that forward their calls to the shim through
+// callPhantom. Calls are identified by a random document id and a sequence
+// number so that results for an earlier document are ignored.
+function installBridge(page, names) {
+	page.evaluate(function(names) {
+		var b = window.__phantomExposed;
+		if (!b) {
+			b = window.__phantomExposed = {doc: String(Math.random()).slice(2), seq: 0, pending: {}};
+			b.resolve = function(id, error, result) {
+				var call = b.pending[id];
+				if (!call) return;
+				delete b.pending[id];
+				var err = error ? new Error(error) : null;
+				if (call.callback) return call.callback(err, err ? undefined : result);
+				if (err) call.reject(err); else call.resolve(result);
+			};
+		}
+
+		names.forEach(function(name) {
+			window[name] = function() {
+				var args = Array.prototype.slice.call(arguments);
+				var call = {callback: typeof args[args.length - 1] === 'function' ? args.pop() : null};
+				var promise;
+				if (!call.callback && typeof Promise !== 'undefined') {
+					promise = new Promise(function(resolve, reject) {
+						call.resolve = resolve;
+						call.reject = reject;
+					});
+				} else if (!call.callback) {
+					call.callback = function() {};
+				}
+
+				var id = b.doc + ':' + (++b.seq);
+				b.pending[id] = call;
+				(window.callPhantom || window.__phantomCallback)({exposedCall: name, id: id, args: args});
+				return promise;
+			};
+		});
+	}, names);
+}
+
+// Sends a bridge's queued calls to its waiting request, if any. Empty
+// responses are only sent when force is true.
+function flushBridge(b, force) {
+	if (!b.waiting || (b.queue.length === 0 && !force)) return;
+	var response = b.waiting;
+	b.waiting = null;
+	response.write(JSON.stringify({calls: b.queue}));
+	response.closeGracefully();
+	b.queue = [];
+}
+
+// Removes a page's bridge and releases its waiting request.
+function closeBridge(id) {
+	var b = bridges[id];
+	delete bridges[id];
+	if (b && b.waiting) {
+		b.waiting.write(JSON.stringify({closed: true}));
+		b.waiting.closeGracefully();
+	}
+}
+
+/*
+ * PROGRESS
+ */
+
+// Holds the progress watchers by id. Watchers queue records like mutation
+// watchers and are flushed with flushWatcher.
+var progressWatchers = {};
+var progressWatcherID = 0;
+
+// Holds the refs of pages with progress listeners installed.
+var reporting = {};
+
+// Maximum number of progress reports queued for a watcher. Older reports are
+// dropped since only recent progress matters.
+var maxQueuedProgress = 100;
+
+// Removes a progress watcher and releases its waiting request.
+function closeProgressWatcher(id) {
+	var w = progressWatchers[id];
+	delete progressWatchers[id];
+	if (w && w.waiting) {
+		w.waiting.write(JSON.stringify({closed: true}));
+		w.waiting.closeGracefully();
+	}
+}
+
+/*
+ * CUSTOM ROUTES
+ */
+
+// Holds the handlers registered with Process.RegisterRoute by path.
+var customRoutes = {};
+
+// Serves a registered route. A handler's return value, if any, is written as
+// the JSON response.
+function handleCustomRoute(request, response) {
+	var fn = customRoutes[request.url];
+	if (!fn) return handleNotFound(request, response);
+
+	var msg = request.post ? JSON.parse(request.post) : {};
+	var result = fn(msg, request, response);
+	if (result !== undefined) {
+		response.write(JSON.stringify(result));
+		response.closeGracefully();
+	}
+}
 `
-Proce
@@ -0,0 +1,108 @@
+package phantomtest
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Fixtures serves canned responses to pages so that tests do not depend on
+// the network. Requests whose URL matches a fixture's pattern are redirected
+// to a local server that returns the fixture's content.
+//
+//	f := phantomtest.NewFixtures(t)
+//	f.Add(`^https://api\.example\.com/users`, "application/json", []byte(`[]`))
+//	f.AddFile(`\.png$`, "testdata/pixel.png")
+//	if err := f.Apply(page); err != nil {
+//		t.Fatal(err)
+//	}
+type Fixtures struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	fixtures []*fixture
+}
+
+// fixture is a canned response.
+type fixture struct {
+	pattern     string
+	contentType string
+	body        []byte
+}
+
+// NewFixtures starts a fixture server. It is closed when the test completes.
+func NewFixtures(tb testing.TB) *Fixtures {
+	f := &Fixtures{}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	tb.Cleanup(f.srv.Close)
+	return f
+}
+
+// Add serves body for requests whose URL matches pattern, a JavaScript
+// regular expression. Patterns are checked in the order they were added.
+func (f *Fixtures) Add(pattern, contentType string, body []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fixtures = append(f.fixtures, &fixture{pattern: pattern, contentType: contentType, body: body})
+}
+
+// AddFile serves the contents of filename for requests whose URL matches
+// pattern. The content type is determined by the file's extension.
+func (f *Fixtures) AddFile(pattern, filename string) error {
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	f.Add(pattern, mime.TypeByExtension(filepath.Ext(filename)), body)
+	return nil
+}
+
+// Rewrites returns the URL rewrites that redirect requests to the fixtures.
+func (f *Fixtures) Rewrites() []phantomjs.URLRewrite {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	a := make([]phantomjs.URLRewrite, len(f.fixtures))
+	for i, fx := range f.fixtures {
+		a[i] = phantomjs.URLRewrite{Pattern: fx.pattern, URL: f.srv.URL + "/fixtures/" + strconv.Itoa(i)}
+	}
+	return a
+}
+
+// Apply installs the fixtures on page. Fixtures added afterward require
+// another call to Apply.
+func (f *Fixtures) Apply(page *phantomjs.WebPage) error {
+	return page.SetURLRewrites(f.Rewrites())
+}
+
+// serveHTTP returns the fixture identified by the request path.
+func (f *Fixtures) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	i, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/fixtures/"))
+
+	f.mu.Lock()
+	var fx *fixture
+	if err == nil && i >= 0 && i < len(f.fixtures) {
+		fx = f.fixtures[i]
+	}
+	f.mu.Unlock()
+
+	if fx == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Pages may request fixtures from any origin.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if fx.contentType != "" {
+		w.Header().Set("Content-Type", fx.contentType)
+	}
+	w.Write(fx.body)
+}
@@ -0,0 +1,217 @@
+// Package phantomtest provides utilities for testing code that uses the
+// phantomjs package.
+//
+// Tests are skipped automatically when the phantomjs binary is not installed.
+// A single process can be shared by all tests in a test binary by calling
+// Main from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		phantomtest.Main(m)
+//	}
+//
+// Golden screenshots are stored under testdata and can be regenerated by
+// passing the -phantomtest.update flag to go test.
+package phantomtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/images"
+)
+
+// update is set to regenerate golden images instead of comparing them.
+var update = flag.Bool("phantomtest.update", false, "update golden screenshots")
+
+// shared holds the process shared across the test binary.
+var shared struct {
+	once    sync.Once
+	process *phantomjs.Process
+	err     error
+}
+
+// Main runs the tests and closes the shared process, if one was opened.
+// It is intended to be called from TestMain.
+func Main(m *testing.M) {
+	code := m.Run()
+	if shared.process != nil {
+		shared.process.Close()
+	}
+	os.Exit(code)
+}
+
+// Available returns true if the phantomjs binary can be found in the PATH.
+func Available() bool {
+	_, err := exec.LookPath(phantomjs.DefaultBinPath)
+	return err == nil
+}
+
+// SkipIfUnavailable skips the test if the phantomjs binary is not installed.
+func SkipIfUnavailable(tb testing.TB) {
+	tb.Helper()
+	if !Available() {
+		tb.Skipf("%s binary not found", phantomjs.DefaultBinPath)
+	}
+}
+
+// FreePort returns a TCP port that is currently available on localhost.
+func FreePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// NewProcess opens a dedicated process on a free port.
+// The process is closed when the test completes.
+func NewProcess(tb testing.TB) *phantomjs.Process {
+	tb.Helper()
+	SkipIfUnavailable(tb)
+
+	p, err := openProcess()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { p.Close() })
+	return p
+}
+
+// SharedProcess returns a process shared by all tests in the test binary.
+// It is opened on first use and closed by Main.
+func SharedProcess(tb testing.TB) *phantomjs.Process {
+	tb.Helper()
+	SkipIfUnavailable(tb)
+
+	shared.once.Do(func() {
+		shared.process, shared.err = openProcess()
+	})
+	if shared.err != nil {
+		tb.Fatal(shared.err)
+	}
+	return shared.process
+}
+
+// WebPage returns a new web page on the shared process.
+// The page is closed when the test completes.
+func WebPage(tb testing.TB) *phantomjs.WebPage {
+	tb.Helper()
+
+	page, err := SharedProcess(tb).CreateWebPage()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { page.Close() })
+	return page
+}
+
+// openProcess opens a new process on a free port.
+func openProcess() (*phantomjs.Process, error) {
+	port, err := FreePort()
+	if err != nil {
+		return nil, err
+	}
+
+	p := phantomjs.NewProcess(phantomjs.WithPort(port))
+	p.Stdout, p.Stderr = ioutil.Discard, ioutil.Discard
+	if err := p.Open(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Screenshot renders page as a PNG and decodes it.
+func Screenshot(tb testing.TB, page *phantomjs.WebPage) image.Image {
+	tb.Helper()
+
+	data, err := page.RenderBase64("PNG")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	buf, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return img
+}
+
+// AssertGolden renders page and compares it to testdata/<name>.png.
+//
+// The test fails if the fraction of differing pixels exceeds tolerance or the
+// image dimensions differ. When the -phantomtest.update flag is set the
+// golden file is rewritten instead.
+func AssertGolden(tb testing.TB, page *phantomjs.WebPage, name string, tolerance float64) {
+	tb.Helper()
+
+	img := Screenshot(tb, page)
+	path := filepath.Join("testdata", name+".png")
+
+	// Rewrite golden file, if requested.
+	if *update {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			tb.Fatal(err)
+		} else if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			tb.Fatal(err)
+		} else if err := ioutil.WriteFile(path, buf.Bytes(), 0666); err != nil {
+			tb.Fatal(err)
+		}
+		return
+	}
+
+	golden, err := readPNG(path)
+	if err != nil {
+		tb.Fatalf("cannot read golden image (run with -phantomtest.update to create): %s", err)
+	}
+
+	diff, err := CompareImages(golden, img)
+	if err != nil {
+		tb.Fatalf("%s: %s", path, err)
+	} else if diff > tolerance {
+		tb.Fatalf("%s: screenshot differs by %.2f%% (tolerance %.2f%%)", path, diff*100, tolerance*100)
+	}
+}
+
+// CompareImages returns the fraction of pixels that differ between a and b.
+// Returns an error wrapping images.ErrSizeMismatch if the images have
+// different dimensions.
+func CompareImages(a, b image.Image) (float64, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	diff, err := images.Compare(a, b)
+	if errors.Is(err, images.ErrSizeMismatch) {
+		return 1, fmt.Errorf("%w: %dx%d != %dx%d", err, ab.Dx(), ab.Dy(), bb.Dx(), bb.Dy())
+	} else if err != nil {
+		return 1, err
+	} else if ab.Empty() {
+		return 0, nil
+	}
+	return float64(diff.Pixels) / float64(ab.Dx()*ab.Dy()), nil
+}
+
+// readPNG reads and decodes a PNG file.
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
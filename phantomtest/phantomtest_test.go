@@ -0,0 +1,99 @@
+package phantomtest_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs/images"
+	"github.com/benbjohnson/phantomjs/phantomtest"
+)
+
+func TestMain(m *testing.M) {
+	phantomtest.Main(m)
+}
+
+// Ensure image comparison returns the fraction of differing pixels.
+func TestCompareImages(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	b := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	b.Set(1, 1, color.White)
+
+	if diff, err := phantomtest.CompareImages(a, b); err != nil {
+		t.Fatal(err)
+	} else if diff != 0.25 {
+		t.Fatalf("unexpected diff: %v", diff)
+	}
+
+	if _, err := phantomtest.CompareImages(a, image.NewRGBA(image.Rect(0, 0, 3, 2))); !errors.Is(err, images.ErrSizeMismatch) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a free port can be allocated.
+func TestFreePort(t *testing.T) {
+	if port, err := phantomtest.FreePort(); err != nil {
+		t.Fatal(err)
+	} else if port <= 0 {
+		t.Fatalf("unexpected port: %d", port)
+	}
+}
+
+// Ensure pages from the shared process can render screenshots.
+func TestWebPage(t *testing.T) {
+	page := phantomtest.WebPage(t)
+	if err := page.SetViewportSize(10, 10); err != nil {
+		t.Fatal(err)
+	} else if err := page.SetContent(`<html><body style="background:red"></body></html>`); err != nil {
+		t.Fatal(err)
+	}
+
+	img := phantomtest.Screenshot(t, page)
+	if b := img.Bounds(); b.Dx() != 10 || b.Dy() != 10 {
+		t.Fatalf("unexpected bounds: %v", b)
+	}
+}
+
+// Ensure fixtures are served from their rewrite URLs.
+func TestFixtures_Rewrites(t *testing.T) {
+	f := phantomtest.NewFixtures(t)
+	f.Add(`^https://api\.example\.com/`, "application/json", []byte(`{"ok":true}`))
+
+	rewrites := f.Rewrites()
+	if len(rewrites) != 1 || rewrites[0].Pattern != `^https://api\.example\.com/` {
+		t.Fatalf("unexpected rewrites: %#v", rewrites)
+	}
+
+	resp, err := http.Get(rewrites[0].URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != `{"ok":true}` {
+		t.Fatalf("unexpected body: %q", body)
+	} else if resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+}
+
+// Ensure pages load fixtures instead of remote resources.
+func TestFixtures_Apply(t *testing.T) {
+	page := phantomtest.WebPage(t)
+
+	f := phantomtest.NewFixtures(t)
+	f.Add(`^http://example\.invalid/$`, "text/html", []byte(`<html><body>FIXTURE</body></html>`))
+	if err := f.Apply(page); err != nil {
+		t.Fatal(err)
+	} else if err := page.Open("http://example.invalid/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if text, err := page.PlainText(); err != nil {
+		t.Fatal(err)
+	} else if text != "FIXTURE" {
+		t.Fatalf("unexpected text: %q", text)
+	}
+}
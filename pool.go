@@ -0,0 +1,283 @@
+package phantomjs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPoolClosed is returned when checking out a page from a closed pool.
+	ErrPoolClosed = errors.New("pool closed")
+)
+
+// Pool distributes web pages across a set of processes and limits the number
+// of pages that can be checked out at the same time.
+//
+// Pages are created on checkout and closed when they are returned so that no
+// state leaks between users of the pool.
+type Pool struct {
+	processes []*Process
+	sem       chan struct{}
+
+	// Retires processes after they have created a number of pages. If nil,
+	// processes are never retired.
+	Rotation *RotationPolicy
+
+	// Called after each task run by WithPage, such as for metrics. It must
+	// not block.
+	OnTask func(TaskStats)
+
+	mu        sync.Mutex
+	next      int
+	closed    bool
+	usage     map[*Process]*processUsage
+	wake      chan struct{} // closed when a retired process is reopened
+	rotations sync.WaitGroup
+}
+
+// RotationPolicy limits how many pages a process of a pool creates before it
+// is retired. PhantomJS leaks memory and becomes unstable after a few
+// hundred pages, so long-running pools should restart their processes.
+//
+// A retired process receives no new pages. Once its checked out pages are
+// returned it is closed and reopened, after which it receives pages again.
+type RotationPolicy struct {
+	// Number of pages a process creates before it is retired.
+	MaxPages int
+
+	// Called after a retired process is reopened, with the error if it
+	// failed to open. A process that fails to open stays retired.
+	OnRotate func(p *Process, err error)
+}
+
+// TaskStats represents a task run on a page by Pool.WithPage.
+type TaskStats struct {
+	// Process that the task's page was created on.
+	Process *Process
+
+	// Time spent waiting for the page and running the task.
+	Wait     time.Duration
+	Duration time.Duration
+
+	// Error returned by the task, or the error returning its page if the
+	// task succeeded.
+	Err error
+
+	// Set if the task panicked.
+	Panicked bool
+}
+
+// processUsage tracks the pages of a process in a pool.
+type processUsage struct {
+	created  int
+	active   int
+	retiring bool
+}
+
+// NewPool returns a pool that creates pages on processes, allowing at most
+// maxPages pages to be checked out at once. The processes are opened by
+// Pool.Open unless they are already open.
+func NewPool(maxPages int, processes ...*Process) *Pool {
+	if maxPages <= 0 {
+		maxPages = 1
+	}
+	return &Pool{
+		processes: processes,
+		sem:       make(chan struct{}, maxPages),
+	}
+}
+
+// Open opens all processes in the pool.
+func (p *Pool) Open() error {
+	for i, process := range p.processes {
+		if err := process.Open(); err != nil {
+			for _, other := range p.processes[:i] {
+				other.Close()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Close marks the pool as closed and closes all of its processes.
+func (p *Pool) Close() (err error) {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	// Wait for retired processes to be reopened before closing them.
+	p.rotations.Wait()
+
+	for _, process := range p.processes {
+		if e := process.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Processes returns the processes managed by the pool.
+func (p *Pool) Processes() []*Process {
+	return p.processes
+}
+
+// MaxPages returns the maximum number of pages that can be checked out.
+func (p *Pool) MaxPages() int {
+	return cap(p.sem)
+}
+
+// Active returns the number of pages currently checked out.
+func (p *Pool) Active() int {
+	return len(p.sem)
+}
+
+// Get checks out a new page, waiting until a slot is available or ctx is done.
+// The returned page uses ctx for its RPC calls. It must be returned with Put.
+func (p *Pool) Get(ctx context.Context) (*WebPage, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	process, err := p.process(ctx)
+	if err != nil {
+		<-p.sem
+		return nil, err
+	}
+
+	page, err := process.CreateWebPage()
+	if err != nil {
+		p.release(process)
+		<-p.sem
+		return nil, err
+	}
+	return page.WithContext(ctx), nil
+}
+
+// Put closes page and releases its slot in the pool.
+func (p *Pool) Put(page *WebPage) error {
+	defer func() { <-p.sem }()
+	defer p.release(page.ref.process)
+	return page.WithContext(context.Background()).Close()
+}
+
+// WithPage checks out a page, calls fn with it and returns the page, so that
+// the page is closed and its slot released even if fn panics. The page uses
+// ctx for its RPC calls. Returns fn's error, or the error returning the page
+// if fn succeeds. The task is reported to OnTask, if set.
+func (p *Pool) WithPage(ctx context.Context, fn func(page *WebPage) error) (err error) {
+	start := time.Now()
+	page, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	task := TaskStats{Process: page.ref.process, Wait: time.Since(start)}
+	start = time.Now()
+	completed := false
+	defer func() {
+		task.Duration = time.Since(start)
+		if e := p.Put(page); e != nil && err == nil {
+			err = e
+		}
+		task.Err, task.Panicked = err, !completed
+		if p.OnTask != nil {
+			p.OnTask(task)
+		}
+	}()
+
+	err = fn(page)
+	completed = true
+	return err
+}
+
+// process returns the next process in round-robin order, skipping retired
+// processes. If every process is retired, it waits until one is reopened or
+// ctx is done.
+func (p *Pool) process(ctx context.Context) (*Process, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		} else if len(p.processes) == 0 {
+			p.mu.Unlock()
+			return nil, errors.New("pool has no processes")
+		}
+		if p.usage == nil {
+			p.usage = make(map[*Process]*processUsage)
+			p.wake = make(chan struct{})
+		}
+
+		for range p.processes {
+			process := p.processes[p.next%len(p.processes)]
+			p.next++
+
+			u := p.usage[process]
+			if u == nil {
+				u = &processUsage{}
+				p.usage[process] = u
+			}
+			if u.retiring {
+				continue
+			}
+
+			u.created++
+			u.active++
+			if p.Rotation != nil && p.Rotation.MaxPages > 0 && u.created >= p.Rotation.MaxPages {
+				u.retiring = true
+			}
+			p.mu.Unlock()
+			return process, nil
+		}
+
+		wake := p.wake
+		p.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release records that a page of process was returned, and reopens the
+// process once a retired process has no pages left.
+func (p *Pool) release(process *Process) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u := p.usage[process]
+	if u == nil {
+		return
+	}
+	u.active--
+	if u.retiring && u.active == 0 && !p.closed {
+		p.rotations.Add(1)
+		go p.rotate(process)
+	}
+}
+
+// rotate closes and reopens a retired process.
+func (p *Pool) rotate(process *Process) {
+	defer p.rotations.Done()
+
+	process.Close()
+	err := process.Open()
+
+	p.mu.Lock()
+	if err == nil {
+		p.usage[process] = &processUsage{}
+	}
+	close(p.wake)
+	p.wake = make(chan struct{})
+	p.mu.Unlock()
+
+	if p.Rotation.OnRotate != nil {
+		p.Rotation.OnRotate(process, err)
+	}
+}
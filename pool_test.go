@@ -0,0 +1,174 @@
+package phantomjs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Ensure the pool limits the number of checked out pages.
+func TestPool_Get(t *testing.T) {
+	var created, closed int32
+	p, srv := NewStubProcess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			atomic.AddInt32(&created, 1)
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Close":
+			atomic.AddInt32(&closed, 1)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	pool := phantomjs.NewPool(1, p)
+	page, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if n := pool.Active(); n != 1 {
+		t.Fatalf("unexpected active count: %d", n)
+	}
+
+	// Second checkout should block until the context expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Returning the page closes it and frees the slot.
+	if err := pool.Put(page); err != nil {
+		t.Fatal(err)
+	} else if n := pool.Active(); n != 0 {
+		t.Fatalf("unexpected active count: %d", n)
+	} else if created != 1 || closed != 1 {
+		t.Fatalf("unexpected counts: created=%d closed=%d", created, closed)
+	}
+}
+
+// Ensure WithPage returns its page and reports the task, even on panic.
+func TestPool_WithPage(t *testing.T) {
+	var closed int32
+	p, srv := NewStubProcess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Close":
+			atomic.AddInt32(&closed, 1)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	var tasks []phantomjs.TaskStats
+	pool := phantomjs.NewPool(1, p)
+	pool.OnTask = func(task phantomjs.TaskStats) { tasks = append(tasks, task) }
+
+	if err := pool.WithPage(context.Background(), func(page *phantomjs.WebPage) error {
+		if n := pool.Active(); n != 1 {
+			t.Fatalf("unexpected active count: %d", n)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	errTask := errors.New("task failed")
+	if err := pool.WithPage(context.Background(), func(page *phantomjs.WebPage) error {
+		return errTask
+	}); err != errTask {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Fatalf("unexpected panic: %v", v)
+			}
+		}()
+		pool.WithPage(context.Background(), func(page *phantomjs.WebPage) error {
+			panic("boom")
+		})
+	}()
+
+	if n := pool.Active(); n != 0 {
+		t.Fatalf("unexpected active count: %d", n)
+	} else if closed != 3 {
+		t.Fatalf("unexpected closed count: %d", closed)
+	} else if len(tasks) != 3 {
+		t.Fatalf("unexpected task count: %d", len(tasks))
+	} else if tasks[0].Process != p || tasks[0].Err != nil || tasks[0].Panicked {
+		t.Fatalf("unexpected task: %+v", tasks[0])
+	} else if tasks[1].Err != errTask || tasks[1].Panicked {
+		t.Fatalf("unexpected task: %+v", tasks[1])
+	} else if !tasks[2].Panicked {
+		t.Fatalf("unexpected task: %+v", tasks[2])
+	}
+}
+
+// Ensure a process is restarted after creating the policy's number of pages.
+func TestPool_Rotation(t *testing.T) {
+	p := NewFakeShimProcess(t)
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := make(chan error, 1)
+	pool := phantomjs.NewPool(2, p)
+	pool.Rotation = &phantomjs.RotationPolicy{
+		MaxPages: 2,
+		OnRotate: func(_ *phantomjs.Process, err error) { rotated <- err },
+	}
+	defer pool.Close()
+
+	stats, err := p.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check out the process' last page; the next checkout waits for it.
+	page0, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if err := pool.Put(page0); err != nil {
+		t.Fatal(err)
+	}
+	page1, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Get(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Returning the page restarts the process.
+	if err := pool.Put(page1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-rotated:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("process not rotated")
+	}
+
+	page2, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Put(page2)
+	if other, err := p.Stats(); err != nil {
+		t.Fatal(err)
+	} else if other.PID == stats.PID {
+		t.Fatal("process not restarted")
+	}
+}
@@ -0,0 +1,53 @@
+package phantomjs
+
+import (
+	"errors"
+	"net"
+	"strconv"
+)
+
+var (
+	// ErrPortInUse is returned by Open when the process' port is taken by
+	// another server. The returned error is a *PortInUseError.
+	ErrPortInUse = errors.New("port in use")
+)
+
+// shimExitPortInUse is the exit code of the shim when it cannot listen on
+// its port.
+const shimExitPortInUse = 3
+
+// PortInUseError is returned by Open when the process' port is taken by
+// another server.
+type PortInUseError struct {
+	Port int
+}
+
+// Error returns the conflicting port.
+func (e *PortInUseError) Error() string {
+	return "port in use: " + strconv.Itoa(e.Port)
+}
+
+// Is returns true if target is ErrPortInUse.
+func (e *PortInUseError) Is(target error) bool {
+	return target == ErrPortInUse
+}
+
+// portInUse returns true if port cannot be listened on.
+func portInUse(port int) bool {
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return true
+	}
+	ln.Close()
+	return false
+}
+
+// freePort returns a port that no server is listening on.
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
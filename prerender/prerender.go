@@ -0,0 +1,300 @@
+// Package prerender implements an HTTP service that returns pages after their
+// JavaScript has run.
+//
+// The handler serves GET /render?url=...&wait=...&format=... where format is
+// one of "html" (default), "png", "jpeg" or "pdf" and wait is an optional
+// duration (e.g. "500ms") to wait after the page loads before capturing it.
+//
+// Rendered output is cached as directed by the page's Cache-Control header,
+// with the X-Prerender-Cache response header set to "hit", "stale" or "miss".
+// Requests with "Cache-Control: no-cache" are always rendered.
+//
+// GET /livez and GET /readyz serve liveness and readiness probes for
+// orchestrators. /livez checks that the pool's processes respond and /readyz
+// that they can evaluate a script.
+//
+//	pool := phantomjs.NewPool(4, phantomjs.NewProcess())
+//	if err := pool.Open(); err != nil {
+//		log.Fatal(err)
+//	}
+//	defer pool.Close()
+//	http.ListenAndServe(":8080", prerender.NewHandler(pool))
+package prerender
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/rendercache"
+)
+
+// Default settings.
+const (
+	DefaultTimeout        = 30 * time.Second
+	DefaultMaxWait        = 10 * time.Second
+	DefaultCacheTTL       = 5 * time.Minute
+	DefaultCacheSize      = 64 << 20
+	DefaultViewportWidth  = 1280
+	DefaultViewportHeight = 800
+)
+
+// Output formats.
+const (
+	FormatHTML = "html"
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatPDF  = "pdf"
+)
+
+// Handler serves rendered pages from a pool.
+type Handler struct {
+	mux  *http.ServeMux
+	pool *phantomjs.Pool
+
+	// Cache stores rendered output. If nil, output is not cached.
+	// Defaults to an in-memory cache with a TTL of DefaultCacheTTL that
+	// holds up to DefaultCacheSize bytes. Renders are bounded by Timeout,
+	// and also by the cache's Timeout if it is set.
+	Cache *rendercache.Cache
+
+	// Maximum time to render a single request, including the wait.
+	Timeout time.Duration
+
+	// Upper bound on the wait parameter.
+	MaxWait time.Duration
+
+	// Viewport size used for rendering.
+	ViewportWidth  int
+	ViewportHeight int
+}
+
+// NewHandler returns a new handler that renders pages from pool.
+// Concurrency is limited by the pool's maximum page count.
+func NewHandler(pool *phantomjs.Pool) *Handler {
+	store := rendercache.NewMemoryStore()
+	store.MaxBytes = DefaultCacheSize
+	cache := rendercache.New(store)
+	cache.TTL = DefaultCacheTTL
+	cache.Timeout = 0 // bounded by h.Timeout in render
+
+	h := &Handler{
+		mux:            http.NewServeMux(),
+		pool:           pool,
+		Cache:          cache,
+		Timeout:        DefaultTimeout,
+		MaxWait:        DefaultMaxWait,
+		ViewportWidth:  DefaultViewportWidth,
+		ViewportHeight: DefaultViewportHeight,
+	}
+	h.mux.HandleFunc("/render", h.handleRender)
+	h.mux.Handle("/livez", phantomjs.HealthHandler(pool.Live))
+	h.mux.Handle("/readyz", phantomjs.HealthHandler(pool.Healthy))
+	return h
+}
+
+// ServeHTTP dispatches requests to the handler's routes.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// handleRender handles GET /render.
+func (h *Handler) handleRender(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := parseRequest(r.URL.Query(), h.MaxWait)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	render := func(ctx context.Context) (*rendercache.Entry, error) {
+		return h.render(ctx, req)
+	}
+
+	// Serve from cache, if available.
+	var entry *rendercache.Entry
+	status := rendercache.StatusMiss
+	if h.Cache == nil {
+		entry, err = render(r.Context())
+	} else if strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+		entry, err = h.Cache.Refresh(r.Context(), h.key(req), render)
+	} else {
+		entry, status, err = h.Cache.Get(r.Context(), h.key(req), render)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "render timeout", http.StatusGatewayTimeout)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("X-Prerender-Cache", status)
+	writeEntry(w, entry)
+}
+
+// key returns the cache key for the request.
+func (h *Handler) key(req *request) string {
+	return rendercache.Key(req.url, map[string]interface{}{
+		"format":   req.format,
+		"wait":     req.wait.String(),
+		"viewport": []int{h.ViewportWidth, h.ViewportHeight},
+	})
+}
+
+// render checks out a page from the pool and renders the request within the
+// handler's timeout.
+func (h *Handler) render(ctx context.Context, req *request) (*rendercache.Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	page, err := h.pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer h.pool.Put(page)
+
+	if err := page.SetViewportSize(h.ViewportWidth, h.ViewportHeight); err != nil {
+		return nil, err
+	}
+	res, err := page.OpenURL(req.url)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	// Wait for additional scripts to run.
+	if req.wait > 0 {
+		timer := time.NewTimer(req.wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	entry, err := renderPage(page, req.format)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	entry.StatusCode = res.StatusCode
+	entry.CacheControl = rendercache.CacheControl(res.Header)
+	return entry, nil
+}
+
+// renderPage captures the page in the given format.
+func renderPage(page *phantomjs.WebPage, format string) (*rendercache.Entry, error) {
+	switch format {
+	case FormatPNG, FormatJPEG:
+		data, err := page.RenderBase64(strings.ToUpper(format))
+		if err != nil {
+			return nil, err
+		}
+		buf, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, err
+		}
+		return &rendercache.Entry{ContentType: "image/" + format, Body: buf}, nil
+
+	case FormatPDF:
+		// PhantomJS can only write PDFs to disk.
+		dir, err := ioutil.TempDir("", "prerender-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "page.pdf")
+		if err := page.Render(path, "PDF", 100); err != nil {
+			return nil, err
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &rendercache.Entry{ContentType: "application/pdf", Body: buf}, nil
+
+	default:
+		content, err := page.Content()
+		if err != nil {
+			return nil, err
+		}
+		return &rendercache.Entry{ContentType: "text/html; charset=utf-8", Body: []byte(content)}, nil
+	}
+}
+
+// writeEntry writes a rendered entry to w with the status of the page.
+func writeEntry(w http.ResponseWriter, entry *rendercache.Entry) {
+	w.Header().Set("Content-Type", entry.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(entry.Body)))
+	if entry.StatusCode != 0 {
+		w.WriteHeader(entry.StatusCode)
+	}
+	w.Write(entry.Body)
+}
+
+// request represents the parsed parameters of a render request.
+type request struct {
+	url    string
+	wait   time.Duration
+	format string
+}
+
+// parseRequest validates the query parameters of a render request.
+func parseRequest(q url.Values, maxWait time.Duration) (*request, error) {
+	req := &request{format: FormatHTML}
+
+	// Only allow absolute HTTP URLs.
+	u, err := url.Parse(q.Get("url"))
+	if err != nil || q.Get("url") == "" {
+		return nil, errors.New("url required")
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("url must be http or https")
+	}
+	req.url = u.String()
+
+	// Parse wait as a duration or as milliseconds.
+	if s := q.Get("wait"); s != "" {
+		if ms, err := strconv.Atoi(s); err == nil {
+			req.wait = time.Duration(ms) * time.Millisecond
+		} else if req.wait, err = time.ParseDuration(s); err != nil {
+			return nil, errors.New("invalid wait")
+		}
+		if req.wait < 0 {
+			return nil, errors.New("invalid wait")
+		} else if req.wait > maxWait {
+			req.wait = maxWait
+		}
+	}
+
+	switch format := strings.ToLower(q.Get("format")); format {
+	case "", FormatHTML:
+	case "jpg":
+		req.format = FormatJPEG
+	case FormatPNG, FormatJPEG, FormatPDF:
+		req.format = format
+	default:
+		return nil, errors.New("invalid format")
+	}
+
+	return req, nil
+}
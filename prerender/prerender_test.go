@@ -0,0 +1,231 @@
+package prerender_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/prerender"
+)
+
+// Ensure the handler renders HTML and caches the result.
+func TestHandler_Render(t *testing.T) {
+	var opens int32
+	pool := NewStubPool(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Open":
+			atomic.AddInt32(&opens, 1)
+			w.Write([]byte(`{"status":"success"}`))
+		case "/webpage/Content":
+			w.Write([]byte(`{"value":"<html><body>RENDERED</body></html>"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+
+	s := httptest.NewServer(prerender.NewHandler(pool))
+	defer s.Close()
+
+	for i, status := range []string{"miss", "hit"} {
+		resp, err := http.Get(s.URL + "/render?url=http://example.com&wait=10ms")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%d: unexpected status: %d: %s", i, resp.StatusCode, body)
+		} else if string(body) != "<html><body>RENDERED</body></html>" {
+			t.Fatalf("%d: unexpected body: %s", i, body)
+		} else if v := resp.Header.Get("X-Prerender-Cache"); v != status {
+			t.Fatalf("%d: unexpected cache status: %s", i, v)
+		}
+	}
+
+	if opens != 1 {
+		t.Fatalf("unexpected open count: %d", opens)
+	}
+}
+
+// Ensure the page's Cache-Control and status and the request's no-cache are
+// respected.
+func TestHandler_CacheControl(t *testing.T) {
+	var opens int32
+	pool := NewStubPool(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Open":
+			var req struct {
+				URL string `json:"url"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			atomic.AddInt32(&opens, 1)
+			switch req.URL {
+			case "http://example.com/private":
+				w.Write([]byte(`{"status":"success","statusCode":200,"headers":[{"name":"Cache-Control","value":"private, max-age=60"}]}`))
+				return
+			case "http://example.com/missing":
+				w.Write([]byte(`{"status":"success","statusCode":404,"headers":[{"name":"Cache-Control","value":"max-age=60"}]}`))
+				return
+			}
+			w.Write([]byte(`{"status":"success","headers":[{"name":"Cache-Control","value":"max-age=60"}]}`))
+		case "/webpage/Content":
+			w.Write([]byte(`{"value":"<html></html>"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+
+	s := httptest.NewServer(prerender.NewHandler(pool))
+	defer s.Close()
+
+	for i, tt := range []struct {
+		url        string
+		noCache    bool
+		status     string
+		statusCode int
+	}{
+		{url: "http://example.com/private", status: "miss", statusCode: 200},
+		{url: "http://example.com/private", status: "miss", statusCode: 200},
+		{url: "http://example.com/public", status: "miss", statusCode: 200},
+		{url: "http://example.com/public", status: "hit", statusCode: 200},
+		{url: "http://example.com/public", noCache: true, status: "miss", statusCode: 200},
+		{url: "http://example.com/missing", status: "miss", statusCode: 404},
+		{url: "http://example.com/missing", status: "miss", statusCode: 404},
+	} {
+		req, _ := http.NewRequest("GET", s.URL+"/render?url="+tt.url, nil)
+		if tt.noCache {
+			req.Header.Set("Cache-Control", "no-cache")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.statusCode {
+			t.Fatalf("%d: unexpected status: %d", i, resp.StatusCode)
+		} else if v := resp.Header.Get("X-Prerender-Cache"); v != tt.status {
+			t.Fatalf("%d: unexpected cache status: %s", i, v)
+		}
+	}
+
+	if opens != 6 {
+		t.Fatalf("unexpected open count: %d", opens)
+	}
+}
+
+// Ensure cached renders are bounded by the handler's Timeout as it is set
+// when the render runs.
+func TestHandler_Timeout(t *testing.T) {
+	pool := NewStubPool(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Open":
+			time.Sleep(300 * time.Millisecond)
+			w.Write([]byte(`{"status":"success"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+
+	h := prerender.NewHandler(pool)
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	for _, tt := range []struct {
+		timeout time.Duration
+		status  int
+	}{
+		{timeout: 100 * time.Millisecond, status: http.StatusGatewayTimeout},
+		{timeout: 10 * time.Second, status: http.StatusOK},
+	} {
+		h.Timeout = tt.timeout
+		resp, err := http.Get(s.URL + "/render?url=http://example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Fatalf("%s: unexpected status: %d", tt.timeout, resp.StatusCode)
+		}
+	}
+}
+
+// Ensure invalid requests are rejected.
+func TestHandler_BadRequest(t *testing.T) {
+	s := httptest.NewServer(prerender.NewHandler(phantomjs.NewPool(1)))
+	defer s.Close()
+
+	for _, q := range []string{"", "?url=file:///etc/passwd", "?url=http://example.com&format=gif", "?url=http://example.com&wait=x"} {
+		resp, err := http.Get(s.URL + "/render" + q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%q: unexpected status: %d", q, resp.StatusCode)
+		}
+	}
+}
+
+// Ensure the probes report whether the pool can render.
+func TestHandler_Health(t *testing.T) {
+	pool := NewStubPool(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Evaluate":
+			w.Write([]byte(`{"returnValue":2}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+
+	s := httptest.NewServer(prerender.NewHandler(pool))
+	defer s.Close()
+
+	for _, path := range []string{"/livez", "/readyz"} {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: unexpected status: %d", path, resp.StatusCode)
+		}
+	}
+
+	// A pool whose process is not running is not live.
+	s2 := httptest.NewServer(prerender.NewHandler(phantomjs.NewPool(1, phantomjs.NewProcess(phantomjs.WithPort(1)))))
+	defer s2.Close()
+	resp, err := http.Get(s2.URL + "/livez")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+}
+
+// NewStubPool returns a pool whose single process is served by fn.
+func NewStubPool(t *testing.T, fn http.HandlerFunc) *phantomjs.Pool {
+	srv := httptest.NewServer(fn)
+	t.Cleanup(srv.Close)
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	return phantomjs.NewPool(1, phantomjs.NewProcess(phantomjs.WithPort(portN)))
+}
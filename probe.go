@@ -0,0 +1,135 @@
+package phantomjs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Probe polling intervals. Open polls quickly at first and backs off, so
+// processes that start fast are ready without waiting a full interval.
+const (
+	minProbeInterval = 10 * time.Millisecond
+	maxProbeInterval = 500 * time.Millisecond
+)
+
+// DefaultReadyLine is the line that the shim prints to stdout once it is
+// listening, if OutputProbe is used without a pattern.
+const DefaultReadyLine = "phantomjs shim ready"
+
+// errNotReady is returned by probes that have not seen the process start.
+var errNotReady = errors.New("not ready")
+
+// Probe reports whether a starting process is ready to serve requests. Open
+// calls Ready repeatedly until it returns nil or the process' StartTimeout
+// expires.
+type Probe interface {
+	Ready(ctx context.Context, p *Process) error
+}
+
+// HTTPProbe returns a probe that sends an authorized GET request to path on
+// the process and expects a 200 OK response. The default probe is
+// HTTPProbe("/ping").
+func HTTPProbe(path string) Probe {
+	return httpProbe(path)
+}
+
+type httpProbe string
+
+func (path httpProbe) Ready(ctx context.Context, p *Process) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.URL()+string(path), nil)
+	if err != nil {
+		return err
+	}
+	p.authorize(req)
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// TCPProbe returns a probe that only checks that the process' port accepts
+// connections.
+func TCPProbe() Probe {
+	return tcpProbe{}
+}
+
+type tcpProbe struct{}
+
+func (tcpProbe) Ready(ctx context.Context, p *Process) error {
+	host := p.Host
+	if host == "" {
+		host = "localhost"
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(p.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// OutputProbe returns a probe that waits for a line of the process' stdout to
+// match pattern. If pattern is nil, the shim is asked to print
+// DefaultReadyLine once it is listening. Lines are matched before
+// Process.FilterOutput is applied.
+func OutputProbe(pattern *regexp.Regexp) Probe {
+	return &outputProbe{pattern: pattern, seen: make(map[*Process]bool)}
+}
+
+type outputProbe struct {
+	pattern *regexp.Regexp
+
+	mu   sync.Mutex
+	seen map[*Process]bool
+}
+
+func (o *outputProbe) Ready(ctx context.Context, p *Process) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.seen[p] {
+		return errNotReady
+	}
+	delete(o.seen, p)
+	return nil
+}
+
+// reset forgets earlier output of p, such as before it is reopened.
+func (o *outputProbe) reset(p *Process) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.seen, p)
+}
+
+// line records a line of output from p.
+func (o *outputProbe) line(p *Process, l OutputLine) {
+	matched := l.Raw == DefaultReadyLine
+	if o.pattern != nil {
+		matched = o.pattern.MatchString(l.Raw)
+	}
+	if matched {
+		o.mu.Lock()
+		o.seen[p] = true
+		o.mu.Unlock()
+	}
+}
+
+// probe returns the process' probe or the default probe.
+func (p *Process) probe() Probe {
+	if p.Probe != nil {
+		return p.Probe
+	}
+	return HTTPProbe("/ping")
+}
@@ -0,0 +1,63 @@
+//go:build !windows
+
+package phantomjs
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// processTree represents a process and the processes it spawns.
+type processTree struct {
+	pgid int
+}
+
+// prepareCommand runs the process in its own process group so that its
+// children can be killed with it.
+func prepareCommand(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// attachProcessTree returns the tree of a started process.
+func attachProcessTree(cmd *exec.Cmd) (*processTree, error) {
+	return &processTree{pgid: cmd.Process.Pid}, nil
+}
+
+// kill kills every process in the tree. Killing a tree whose processes have
+// all exited is not an error.
+func (t *processTree) kill() error {
+	if err := syscall.Kill(-t.pgid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
+
+// processRunning returns true if a process with pid exists.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// isShimProcess returns true if the command line of the process refers to
+// the entry's shim directory.
+func isShimProcess(e *JanitorEntry) bool {
+	if e.Path == "" {
+		return false
+	}
+	if buf, err := ioutil.ReadFile("/proc/" + strconv.Itoa(e.PID) + "/cmdline"); err == nil {
+		return strings.Contains(string(buf), e.Path)
+	}
+	out, err := exec.Command("ps", "-p", strconv.Itoa(e.PID), "-o", "command=").Output()
+	return err == nil && strings.Contains(string(out), e.Path)
+}
+
+// killOrphan kills the process group of an orphaned process.
+func killOrphan(pid int) error {
+	return (&processTree{pgid: pid}).kill()
+}
@@ -0,0 +1,202 @@
+//go:build !windows
+
+package phantomjs_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Ensure Close kills the processes spawned by the process.
+func TestProcess_Close_ProcessTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phantomjs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Use a fake binary that spawns a helper and never serves.
+	pidPath := filepath.Join(dir, "pid")
+	binPath := filepath.Join(dir, "phantomjs")
+	script := "#!/bin/sh\nsleep 300 &\necho $! > " + pidPath + "\nsleep 300\n"
+	if err := ioutil.WriteFile(binPath, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	p := phantomjs.NewProcess(phantomjs.WithBinPath(binPath), phantomjs.WithPort(freePort(t)), phantomjs.WithTimeout(1500*time.Millisecond))
+	p.Stdout, p.Stderr = ioutil.Discard, ioutil.Discard
+	if err := p.Open(); err == nil || err.Error() != "timeout" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf, err := ioutil.ReadFile(pidPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); processAlive(pid); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("helper process still running: %d", pid)
+		}
+	}
+}
+
+// Ensure Close does not block or fail once the process has exited.
+func TestProcess_Close_Exited(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phantomjs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Use a fake binary that leaves a helper holding its output open.
+	binPath := filepath.Join(dir, "phantomjs")
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/sh\nsleep 300 &\nexit 0\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	p := phantomjs.NewProcess(phantomjs.WithBinPath(binPath), phantomjs.WithPort(freePort(t)), phantomjs.WithTimeout(10*time.Second))
+	p.Stdout, p.Stderr = ioutil.Discard, ioutil.Discard
+	if err := p.Open(); err == nil || !strings.HasPrefix(err.Error(), "process exited") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- p.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("close blocked")
+	}
+}
+
+// processAlive returns true if pid is running and not a zombie.
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil {
+		return false
+	}
+	buf, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	// The state follows the parenthesized command name.
+	s := string(buf)
+	if i := strings.LastIndex(s, ")"); i != -1 && i+2 < len(s) {
+		return s[i+2] != 'Z'
+	}
+	return true
+}
+
+// Ensure the janitor kills processes left behind by an exited owner.
+func TestJanitor_Reap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phantomjs-janitor-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create a shim directory and an orphan whose command line refers to it.
+	shimPath, err := ioutil.TempDir("", "phantomjs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(shimPath)
+	orphan := exec.Command("sh", "-c", "sleep 300; true", filepath.Join(shimPath, "shim.js"))
+	orphan.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := orphan.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer orphan.Process.Kill()
+
+	// Use the PID of a process that has exited as the owner.
+	owner := exec.Command("true")
+	if err := owner.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the orphan and a process whose owner is still running.
+	write := func(name string, e phantomjs.JanitorEntry) {
+		buf, _ := json.Marshal(e)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), buf, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("1.json", phantomjs.JanitorEntry{PID: orphan.Process.Pid, Owner: owner.Process.Pid, Path: shimPath})
+	write("2.json", phantomjs.JanitorEntry{PID: orphan.Process.Pid, Owner: os.Getpid(), Path: shimPath})
+
+	entries, err := phantomjs.NewJanitor(dir).Reap()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 1 || entries[0].PID != orphan.Process.Pid {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+
+	done := make(chan struct{})
+	go func() { orphan.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("orphan still running")
+	}
+
+	if _, err := os.Stat(shimPath); !os.IsNotExist(err) {
+		t.Fatalf("shim directory not removed: %v", err)
+	} else if _, err := os.Stat(filepath.Join(dir, "1.json")); !os.IsNotExist(err) {
+		t.Fatalf("state file not removed: %v", err)
+	} else if _, err := os.Stat(filepath.Join(dir, "2.json")); err != nil {
+		t.Fatalf("state file of running owner removed: %v", err)
+	}
+}
+
+// Ensure processes are recorded while open and forgotten on close.
+func TestProcess_Janitor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phantomjs-janitor-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Use a fake binary that reports whether its state file exists.
+	binPath := filepath.Join(dir, "phantomjs")
+	script := "#!/bin/sh\nsleep 1\nls " + dir + "\nsleep 300\n"
+	if err := ioutil.WriteFile(binPath, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout []string
+	p := phantomjs.NewProcess(phantomjs.WithBinPath(binPath), phantomjs.WithPort(freePort(t)), phantomjs.WithTimeout(2500*time.Millisecond), phantomjs.WithJanitor(phantomjs.NewJanitor(dir)))
+	p.Stdout, p.Stderr = ioutil.Discard, ioutil.Discard
+	p.OnStdoutLine = func(l phantomjs.OutputLine) { stdout = append(stdout, l.Text) }
+	if err := p.Open(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	var recorded bool
+	for _, name := range stdout {
+		recorded = recorded || strings.HasSuffix(name, ".json")
+	}
+	if !recorded {
+		t.Fatalf("state file not recorded: %v", stdout)
+	}
+	if fis, err := ioutil.ReadDir(dir); err != nil {
+		t.Fatal(err)
+	} else if len(fis) != 1 {
+		t.Fatalf("state file not removed: %d files", len(fis))
+	}
+}
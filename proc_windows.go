@@ -0,0 +1,116 @@
+//go:build windows
+
+package phantomjs
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// processTree represents a process and the processes it spawns.
+type processTree struct {
+	job windows.Handle
+}
+
+// prepareCommand is a no-op on Windows; the process is added to a job
+// object once it has started.
+func prepareCommand(cmd *exec.Cmd) {}
+
+// attachProcessTree adds a started process to a new job object. Processes
+// it spawns are added to the job too, and the job kills them all if the
+// handle is closed, such as when this process exits.
+func attachProcessTree(cmd *exec.Cmd) (*processTree, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+
+	h, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	defer windows.CloseHandle(h)
+	if err := windows.AssignProcessToJobObject(job, h); err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	return &processTree{job: job}, nil
+}
+
+// kill kills every process in the tree. Killing a tree whose processes have
+// all exited is not an error.
+func (t *processTree) kill() error {
+	if t.job == 0 {
+		return nil
+	}
+	err := windows.TerminateJobObject(t.job, 1)
+	windows.CloseHandle(t.job)
+	t.job = 0
+	return err
+}
+
+// processRunning returns true if a process with pid is running.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// stillActive is the exit code of a running process.
+const stillActive = 259
+
+// isShimProcess returns true if the process' image has the name of the
+// entry's binary. Windows does not expose the command line of other
+// processes, so the shim directory cannot be checked.
+func isShimProcess(e *JanitorEntry) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(e.PID))
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(h)
+
+	buf := make([]uint16, windows.MAX_PATH)
+	n := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &n); err != nil {
+		return false
+	}
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(windows.UTF16ToString(buf[:n]))), ".exe")
+	return name == strings.TrimSuffix(strings.ToLower(filepath.Base(e.BinPath)), ".exe")
+}
+
+// killOrphan terminates an orphaned process. Processes it spawned were
+// killed with its job object when the owning program exited.
+func killOrphan(pid int) error {
+	h, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(h)
+	return windows.TerminateProcess(h, 1)
+}
@@ -0,0 +1,96 @@
+package phantomjs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// progressPollTimeout is how long the shim holds a request open while
+// waiting for progress reports.
+const progressPollTimeout = 1 * time.Second
+
+// Progress represents a progress report sent by a page script. Scripts report
+// progress by calling window.callPhantom with an object whose type is
+// "progress" and any of the other fields:
+//
+//	window.callPhantom({type: 'progress', step: 'charts', current: 3, total: 8});
+type Progress struct {
+	// Completion from 0 to 100. If the script does not report it, it is
+	// computed from Current and Total.
+	Percent float64
+
+	// Name of the current step and a free-form description.
+	Step    string
+	Message string
+
+	// Number of units of work done and the total number of units.
+	Current int
+	Total   int
+}
+
+// progressJSON is a struct for decoding progress reports from the shim.
+type progressJSON struct {
+	Percent *float64 `json:"percent"`
+	Step    string   `json:"step"`
+	Message string   `json:"message"`
+	Current float64  `json:"current"`
+	Total   float64  `json:"total"`
+}
+
+// OnProgress calls fn with each progress report sent by the page's scripts,
+// so that long multi-step scripts can report their progress to Go. Reports
+// are received in the order they were sent, from any document the page loads
+// until stop is called or the page's context is done. fn is called from a
+// single goroutine.
+func (p *WebPage) OnProgress(fn func(Progress)) (stop func(), err error) {
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := p.doJSON("POST", "/webpage/WatchProgress", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(p.context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := map[string]interface{}{
+			"ref":     p.ref.id,
+			"id":      resp.ID,
+			"timeout": int(progressPollTimeout / time.Millisecond),
+		}
+		for {
+			var next struct {
+				Closed  bool           `json:"closed"`
+				Records []progressJSON `json:"records"`
+			}
+			if err := p.ref.process.doJSON(ctx, "POST", "/webpage/NextProgress", req, &next); err != nil || next.Closed {
+				return
+			}
+			for _, r := range next.Records {
+				fn(r.progress())
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			p.ref.process.doJSON(context.Background(), "POST", "/webpage/UnwatchProgress", map[string]interface{}{"ref": p.ref.id, "id": resp.ID}, nil)
+		})
+	}, nil
+}
+
+// progress returns the decoded report.
+func (r *progressJSON) progress() Progress {
+	v := Progress{Step: r.Step, Message: r.Message, Current: int(r.Current), Total: int(r.Total)}
+	if r.Percent != nil {
+		v.Percent = *r.Percent
+	} else if r.Total > 0 {
+		v.Percent = min(100, r.Current/r.Total*100)
+	}
+	return v
+}
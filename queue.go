@@ -0,0 +1,127 @@
+package phantomjs
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// opQueue serializes the operations of a page, so that goroutines sharing a
+// page cannot interleave calls that change its state, such as its current
+// frame or clip rect. Operations run one at a time in the order they were
+// queued. Every handle to a page shares its queue.
+type opQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	waiters []chan struct{}
+	pending atomic.Int64
+}
+
+// newOpQueue returns a new, empty queue.
+func newOpQueue() *opQueue {
+	return &opQueue{}
+}
+
+// acquire waits until the operations queued earlier have completed. Returns
+// the context's error if ctx is done first.
+func (q *opQueue) acquire(ctx context.Context) error {
+	q.pending.Add(1)
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	q.waiters = append(q.waiters, ready)
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Leave the queue, unless the operation was started in the meantime.
+	q.mu.Lock()
+	for i, ch := range q.waiters {
+		if ch == ready {
+			q.waiters = append(q.waiters[:i:i], q.waiters[i+1:]...)
+			q.mu.Unlock()
+			q.pending.Add(-1)
+			return ctx.Err()
+		}
+	}
+	q.mu.Unlock()
+	q.release()
+	return ctx.Err()
+}
+
+// release completes the running operation and starts the next one.
+func (q *opQueue) release() {
+	q.mu.Lock()
+	if len(q.waiters) > 0 {
+		close(q.waiters[0])
+		q.waiters = q.waiters[1:]
+	} else {
+		q.busy = false
+	}
+	q.mu.Unlock()
+	q.pending.Add(-1)
+}
+
+// QueueLen returns the number of operations on the page that are running or
+// waiting for earlier operations to complete.
+func (p *WebPage) QueueLen() int {
+	return int(p.ref.ops.pending.Load())
+}
+
+// Flush waits until the operations queued on the page before the call have
+// completed. It is intended for tests that make calls from several
+// goroutines. Returns the page context's error if it is done first.
+func (p *WebPage) Flush() error {
+	if p.unqueued {
+		return nil
+	} else if err := p.ref.ops.acquire(p.context()); err != nil {
+		return err
+	}
+	p.ref.ops.release()
+	return nil
+}
+
+// Do calls fn with exclusive use of the page, so that a sequence of calls,
+// such as switching to a frame and evaluating a script in it, is not
+// interleaved with the calls of other goroutines:
+//
+//	err := page.Do(func(page *phantomjs.WebPage) error {
+//		if err := page.SwitchToFrameName("content"); err != nil {
+//			return err
+//		}
+//		defer page.SwitchToMainFrame()
+//		_, err := page.Evaluate(`function() { return document.title; }`)
+//		return err
+//	})
+//
+// fn must make its calls on the page it is given, which is not valid once fn
+// returns. Calls made on other copies of the page wait until fn returns.
+func (p *WebPage) Do(fn func(page *WebPage) error) error {
+	if p.unqueued {
+		return fn(p)
+	} else if err := p.ref.ops.acquire(p.context()); err != nil {
+		return err
+	}
+	defer p.ref.ops.release()
+	return fn(&WebPage{ref: p.ref, ctx: p.ctx, unqueued: true})
+}
+
+// call sends an RPC call for the page with ctx once the operations queued
+// earlier have completed.
+func (p *WebPage) call(ctx context.Context, method, path string, req, resp interface{}) error {
+	if !p.unqueued {
+		if err := p.ref.ops.acquire(ctx); err != nil {
+			return err
+		}
+		defer p.ref.ops.release()
+	}
+	return p.ref.process.doJSON(ctx, method, path, req, resp)
+}
@@ -0,0 +1,58 @@
+package phantomjs
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// shimVarRegexp matches single line declarations of top-level shim variables.
+var shimVarRegexp = regexp.MustCompile(`^var ([A-Za-z_$][A-Za-z0-9_$]*) = (.*;)$`)
+
+// Reload updates a running shim to the one built into the package and sends
+// routes registered since the process was opened, without restarting the
+// process. Open pages and other shim state are kept.
+//
+// The shim's functions are replaced, including the request router, so
+// changed and added handlers take effect. Added top-level variables are
+// initialized but existing ones keep their values, and other top-level
+// statements are not run again; such changes require a restart.
+func (p *Process) Reload(ctx context.Context) error {
+	return p.Inject(ctx, reloadScript(shim)+p.routesScript())
+}
+
+// Inject evaluates js in the global scope of the running shim. Function
+// declarations replace shim functions of the same name, so helpers can be
+// changed while iterating on the shim without restarting the process:
+//
+//	err := p.Inject(ctx, `function handlePing(request, response) {
+//		response.write('pong');
+//		response.closeGracefully();
+//	}`)
+//
+// An exception thrown by js is returned as an error.
+func (p *Process) Inject(ctx context.Context, js string) error {
+	return p.doJSON(ctx, "POST", "/process/Reload", map[string]interface{}{"script": js}, nil)
+}
+
+// reloadScript returns the top-level function declarations of script and
+// guarded initializers for its single line variables.
+func reloadScript(script string) string {
+	var b strings.Builder
+	var fn bool
+	for _, line := range strings.Split(script, "\n") {
+		switch {
+		case fn:
+			b.WriteString(line + "\n")
+			fn = line != "}"
+		case strings.HasPrefix(line, "function "):
+			b.WriteString(line + "\n")
+			fn = !strings.HasSuffix(line, "}")
+		default:
+			if m := shimVarRegexp.FindStringSubmatch(line); m != nil {
+				b.WriteString("if (typeof " + m[1] + " === 'undefined') { var " + m[1] + " = " + m[2] + " }\n")
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,190 @@
+package phantomjs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// DefaultRenderFormat is the format used by RenderOptions if none is set.
+const DefaultRenderFormat = "PNG"
+
+// cssDPI is the resolution of a CSS pixel at a zoom factor of 1.
+const cssDPI = 96
+
+// RenderOptions represents the settings for rendering a page with
+// RenderWithOptions. All settings are applied for the render only; the page's
+// clip rect and zoom factor are left unchanged.
+type RenderOptions struct {
+	// Output format: "PNG", "JPEG", "PDF", "BMP", "PPM" or "GIF".
+	// Defaults to DefaultRenderFormat.
+	Format string
+
+	// Quality of JPEG output, from 0 to 100.
+	Quality int
+
+	// Zoom factor applied while rendering, such as 2 for high-DPI
+	// screenshots and sharper PDFs.
+	Scale float64
+
+	// Resolution to render at. Converted to a scale relative to 96 DPI.
+	// Ignored if Scale is set.
+	DPI int
+
+	// If true, only the visible viewport is rendered instead of the full
+	// page.
+	OnlyViewport bool
+
+	// CSS selector of an element to clip the output to. Returns
+	// ErrElementNotFound if no element matches.
+	Selector string
+
+	// Compression level of PNG output, such as png.BestCompression for
+	// smaller files. If set, the PNG rendered by PhantomJS is re-encoded in
+	// Go. Zero keeps PhantomJS's encoding.
+	PNGCompression png.CompressionLevel
+
+	// Encoder, if set, encodes the output in Go. The page is rendered as PNG
+	// and the decoded image is passed to Encoder with Quality, and Format is
+	// ignored. This allows formats PhantomJS cannot produce, such as WebP
+	// with a third-party encoder:
+	//
+	//	opts.Encoder = func(w io.Writer, img image.Image, quality int) error {
+	//		return webp.Encode(w, img, &webp.Options{Quality: float32(quality)})
+	//	}
+	Encoder ImageEncoder
+}
+
+// ImageEncoder encodes a rendered image for RenderOptions.Encoder. quality is
+// RenderOptions.Quality.
+type ImageEncoder func(w io.Writer, img image.Image, quality int) error
+
+// EncodeJPEG is an ImageEncoder that encodes JPEG with Go's encoder, whose
+// output size and quality are more predictable than PhantomJS's. A zero
+// quality uses jpeg.DefaultQuality.
+func EncodeJPEG(w io.Writer, img image.Image, quality int) error {
+	if quality <= 0 {
+		quality = jpeg.DefaultQuality
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+// scale returns the zoom factor for the options. Returns zero to keep the
+// page's zoom factor.
+func (opts *RenderOptions) scale() float64 {
+	if opts.Scale > 0 {
+		return opts.Scale
+	} else if opts.DPI > 0 {
+		return float64(opts.DPI) / cssDPI
+	}
+	return 0
+}
+
+// renderOptionsJSON is a struct for encoding render options as JSON.
+type renderOptionsJSON struct {
+	Format       string  `json:"format"`
+	Quality      int     `json:"quality,omitempty"`
+	Scale        float64 `json:"scale,omitempty"`
+	OnlyViewport bool    `json:"onlyViewport,omitempty"`
+	Selector     string  `json:"selector,omitempty"`
+}
+
+// encode returns the options encoded for the shim.
+func (opts *RenderOptions) encode() renderOptionsJSON {
+	format := strings.ToUpper(opts.Format)
+	if format == "" || opts.Encoder != nil {
+		format = DefaultRenderFormat
+	}
+	return renderOptionsJSON{
+		Format:       format,
+		Quality:      opts.Quality,
+		Scale:        opts.scale(),
+		OnlyViewport: opts.OnlyViewport,
+		Selector:     opts.Selector,
+	}
+}
+
+// postProcess encodes the output rendered by PhantomJS as set by the
+// options' Go-side settings.
+func (opts *RenderOptions) postProcess(data []byte) ([]byte, error) {
+	if opts.Encoder == nil && (opts.PNGCompression == png.DefaultCompression || opts.encode().Format != "PNG") {
+		return data, nil
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if opts.Encoder != nil {
+		err = opts.Encoder(&buf, img, opts.Quality)
+	} else {
+		err = (&png.Encoder{CompressionLevel: opts.PNGCompression}).Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderWithOptions renders the page in a single call and returns the output.
+// Unlike RenderBase64, it supports PDF output.
+func (p *WebPage) RenderWithOptions(opts RenderOptions) ([]byte, error) {
+	p.checkFonts()
+
+	var resp struct {
+		Found bool   `json:"found"`
+		Data  string `json:"data"`
+	}
+	if err := p.doJSON("POST", "/webpage/RenderWithOptions", map[string]interface{}{"ref": p.ref.id, "options": opts.encode()}, &resp); err != nil {
+		return nil, err
+	} else if !resp.Found {
+		return nil, ErrElementNotFound
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Data)
+	if err != nil {
+		return nil, err
+	}
+	return opts.postProcess(data)
+}
+
+// RenderSpec describes one of the outputs rendered by RenderAll.
+type RenderSpec = RenderOptions
+
+// RenderAll renders the page once per spec in a single call and returns the
+// outputs in the same order. No page scripts run between renders, so every
+// output reflects the same DOM state. Returns ErrElementNotFound if the
+// selector of any spec matches no element.
+func (p *WebPage) RenderAll(specs []RenderSpec) ([][]byte, error) {
+	p.checkFonts()
+
+	a := make([]renderOptionsJSON, len(specs))
+	for i := range specs {
+		a[i] = specs[i].encode()
+	}
+
+	var resp struct {
+		Found bool     `json:"found"`
+		Value []string `json:"value"`
+	}
+	if err := p.doJSON("POST", "/webpage/RenderAll", map[string]interface{}{"ref": p.ref.id, "specs": a}, &resp); err != nil {
+		return nil, err
+	} else if !resp.Found {
+		return nil, ErrElementNotFound
+	}
+
+	outputs := make([][]byte, len(resp.Value))
+	for i, s := range resp.Value {
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		} else if outputs[i], err = specs[i].postProcess(data); err != nil {
+			return nil, err
+		}
+	}
+	return outputs, nil
+}
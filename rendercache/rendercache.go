@@ -0,0 +1,340 @@
+// Package rendercache caches rendered output so that hot URLs are not
+// rendered again on every request.
+//
+// Entries are stored in a pluggable Store, such as a MemoryStore or a
+// DiskStore, under a key derived from the URL and the render options. Their
+// lifetime defaults to the cache's TTL and follows the Cache-Control header
+// of the rendered page if it has one. Once an entry expires, it is served
+// stale for a while longer and rendered again in the background:
+//
+//	cache := rendercache.New(rendercache.NewMemoryStore())
+//	cache.StaleWhileRevalidate = time.Minute
+//
+//	key := rendercache.Key(url, opts)
+//	entry, status, err := cache.Get(ctx, key, func(ctx context.Context) (*rendercache.Entry, error) {
+//		// Render url with opts.
+//	})
+package rendercache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default settings.
+const (
+	// Time that an entry is fresh for.
+	DefaultTTL = 5 * time.Minute
+
+	// Maximum time of a render.
+	DefaultTimeout = time.Minute
+)
+
+// Statuses returned by Cache.Get.
+const (
+	// The entry was fresh.
+	StatusHit = "hit"
+
+	// The entry was stale and is being rendered again in the background.
+	StatusStale = "stale"
+
+	// The entry was missing or expired and was rendered.
+	StatusMiss = "miss"
+)
+
+var (
+	// ErrNotFound is returned by a Store when no entry exists for a key.
+	ErrNotFound = errors.New("entry not found")
+
+	// ErrNoEntry is returned when a render function returns neither an
+	// entry nor an error.
+	ErrNoEntry = errors.New("render returned no entry")
+)
+
+// Entry represents a cached rendered output.
+type Entry struct {
+	ContentType string
+	Body        []byte
+
+	// HTTP status of the rendered page's response. Entries with a status
+	// outside of 2xx are returned but not stored. Zero is treated as 200.
+	StatusCode int
+
+	// Cache-Control header of the rendered page's response, such as
+	// "max-age=60". Set by the render function. Optional.
+	CacheControl string
+
+	// Time the entry was rendered, stops being fresh and stops being
+	// served stale. Set by the cache.
+	Created    time.Time
+	Expires    time.Time
+	StaleUntil time.Time
+}
+
+// RenderFunc renders an entry that is missing or expired.
+type RenderFunc func(ctx context.Context) (*Entry, error)
+
+// Key returns a cache key for rendering url with options, which is any value
+// that can be encoded as JSON, such as a struct of render settings. Options
+// that encode to the same JSON share entries.
+func Key(url string, options interface{}) string {
+	buf, _ := json.Marshal(options)
+	h := sha256.New()
+	h.Write([]byte(url))
+	h.Write([]byte{0})
+	h.Write(buf)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Cache renders entries on demand and stores them in a Store.
+type Cache struct {
+	store Store
+
+	// Time that an entry is fresh for if the page's Cache-Control does not
+	// set one. Defaults to DefaultTTL.
+	TTL time.Duration
+
+	// Time that an expired entry is served while it is rendered again in
+	// the background, if the page's Cache-Control does not set one. Zero
+	// renders expired entries before returning them.
+	StaleWhileRevalidate time.Duration
+
+	// Maximum time of a render. Renders are shared by the callers waiting
+	// for them and are not canceled by any one of them. Zero leaves renders
+	// to be bounded by the render function, which must then apply its own
+	// timeout. New sets it to DefaultTimeout.
+	Timeout time.Duration
+
+	// Upper bound on the lifetimes set by Cache-Control. Zero means no
+	// bound.
+	MaxTTL time.Duration
+
+	// If true, the Cache-Control of entries is ignored and every entry uses
+	// TTL and StaleWhileRevalidate.
+	IgnoreCacheControl bool
+
+	// Receives errors that cannot be returned to the caller, such as failed
+	// background renders and store failures. If nil, nothing is logged.
+	Logger *slog.Logger
+
+	// Now returns the current time. Used for testing.
+	Now func() time.Time
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call represents a render in progress. Concurrent requests for a key share
+// its render.
+type call struct {
+	done  chan struct{}
+	entry *Entry
+	err   error
+}
+
+// New returns a new cache that stores entries in store.
+func New(store Store) *Cache {
+	return &Cache{store: store, TTL: DefaultTTL, Timeout: DefaultTimeout, Now: time.Now}
+}
+
+// Store returns the cache's store.
+func (c *Cache) Store() Store {
+	return c.store
+}
+
+// Get returns the entry for key and its status. A fresh entry is returned as
+// is. A stale entry is returned while fn renders it again in the background.
+// Otherwise fn renders the entry, which is stored unless its status is not 2xx
+// or its Cache-Control is no-store, no-cache or private.
+//
+// Concurrent calls for the same key share a single render, which runs within
+// the cache's Timeout rather than the context of the call that started it.
+// Canceling ctx only stops the caller from waiting.
+func (c *Cache) Get(ctx context.Context, key string, fn RenderFunc) (*Entry, string, error) {
+	entry, err := c.store.Get(ctx, key)
+	if err != nil && err != ErrNotFound {
+		c.log("render cache read failed", "key", key, "error", err)
+	}
+
+	if entry != nil {
+		now := c.Now()
+		if now.Before(entry.Expires) {
+			return entry, StatusHit, nil
+		} else if now.Before(entry.StaleUntil) {
+			c.start(ctx, key, func(ctx context.Context) (*Entry, error) {
+				entry, err := fn(ctx)
+				if err != nil {
+					c.log("render cache revalidation failed", "key", key, "error", err)
+				}
+				return entry, err
+			})
+			return entry, StatusStale, nil
+		}
+	}
+
+	entry, err = c.wait(ctx, c.start(ctx, key, fn))
+	if err != nil {
+		return nil, "", err
+	}
+	return entry, StatusMiss, nil
+}
+
+// Refresh renders the entry for key with fn and stores it, such as for a
+// request with "Cache-Control: no-cache".
+func (c *Cache) Refresh(ctx context.Context, key string, fn RenderFunc) (*Entry, error) {
+	return c.wait(ctx, c.start(ctx, key, fn))
+}
+
+// Delete removes the entry for key.
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if err := c.store.Delete(ctx, key); err != nil && err != ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// start renders key in the background unless a render is in progress, and
+// returns the render. The render keeps the values of ctx but not its
+// cancellation, so that it is shared by all of its callers.
+func (c *Cache) start(ctx context.Context, key string, fn RenderFunc) *call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cl := c.calls[key]; cl != nil {
+		return cl
+	}
+	if c.calls == nil {
+		c.calls = make(map[string]*call)
+	}
+
+	ctx, cancel := context.WithoutCancel(ctx), context.CancelFunc(func() {})
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+
+	cl := &call{done: make(chan struct{})}
+	c.calls[key] = cl
+	go func() {
+		defer func() {
+			cancel()
+			c.mu.Lock()
+			delete(c.calls, key)
+			c.mu.Unlock()
+			close(cl.done)
+		}()
+
+		if cl.entry, cl.err = fn(ctx); cl.err == nil && cl.entry == nil {
+			cl.err = ErrNoEntry
+		} else if cl.err == nil && storable(cl.entry) && c.expire(cl.entry) {
+			if err := c.store.Set(ctx, key, cl.entry); err != nil {
+				c.log("render cache write failed", "key", key, "error", err)
+			}
+		}
+	}()
+	return cl
+}
+
+// wait returns the result of a render, or the context's error if ctx is done
+// first.
+func (c *Cache) wait(ctx context.Context, cl *call) (*Entry, error) {
+	select {
+	case <-cl.done:
+		return cl.entry, cl.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// storable returns true if entry has a successful status.
+func storable(entry *Entry) bool {
+	return entry.StatusCode == 0 || (entry.StatusCode >= 200 && entry.StatusCode < 300)
+}
+
+// expire sets the lifetime of a rendered entry. Returns false if the entry
+// must not be stored.
+func (c *Cache) expire(entry *Entry) bool {
+	ttl, swr := c.TTL, c.StaleWhileRevalidate
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	if !c.IgnoreCacheControl && entry.CacheControl != "" {
+		cc := parseCacheControl(entry.CacheControl)
+		for _, name := range []string{"no-store", "no-cache", "private"} {
+			if _, ok := cc[name]; ok {
+				return false
+			}
+		}
+
+		if v, ok := cc.seconds("s-maxage"); ok {
+			ttl = v
+		} else if v, ok := cc.seconds("max-age"); ok {
+			ttl = v
+		}
+		if v, ok := cc.seconds("stale-while-revalidate"); ok {
+			swr = v
+		}
+		if c.MaxTTL > 0 {
+			ttl, swr = min(ttl, c.MaxTTL), min(swr, c.MaxTTL)
+		}
+	}
+	if ttl <= 0 && swr <= 0 {
+		return false
+	}
+
+	entry.Created = c.Now()
+	entry.Expires = entry.Created.Add(ttl)
+	entry.StaleUntil = entry.Expires.Add(swr)
+	return true
+}
+
+// log writes a warning to the cache's logger, if set.
+func (c *Cache) log(msg string, args ...interface{}) {
+	if c.Logger == nil {
+		return
+	}
+	c.Logger.Warn(msg, args...)
+}
+
+// CacheControl returns the Cache-Control header of a page's response, for
+// setting Entry.CacheControl.
+func CacheControl(header http.Header) string {
+	return strings.Join(header.Values("Cache-Control"), ", ")
+}
+
+// cacheControl represents the directives of a Cache-Control header.
+type cacheControl map[string]string
+
+// parseCacheControl returns the directives of a Cache-Control header.
+// Directive names are lowercased.
+func parseCacheControl(s string) cacheControl {
+	cc := make(cacheControl)
+	for _, part := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return cc
+}
+
+// seconds returns a directive's value as a duration in seconds.
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
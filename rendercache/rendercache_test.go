@@ -0,0 +1,222 @@
+package rendercache_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs/rendercache"
+)
+
+// Ensure entries are served fresh, then stale while they are rendered again,
+// then rendered before they are returned.
+func TestCache_Get(t *testing.T) {
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := rendercache.NewMemoryStore()
+	store.Now = func() time.Time { return now }
+	cache := rendercache.New(store)
+	cache.TTL, cache.StaleWhileRevalidate = time.Minute, time.Minute
+	cache.Now = store.Now
+
+	var renders int32
+	render := func(ctx context.Context) (*rendercache.Entry, error) {
+		n := atomic.AddInt32(&renders, 1)
+		return &rendercache.Entry{ContentType: "text/plain", Body: []byte{'0' + byte(n)}}, nil
+	}
+	get := func(status, body string) {
+		t.Helper()
+		entry, st, err := cache.Get(context.Background(), "key", render)
+		if err != nil {
+			t.Fatal(err)
+		} else if st != status || string(entry.Body) != body {
+			t.Fatalf("unexpected entry: %s %q", st, entry.Body)
+		}
+	}
+
+	get(rendercache.StatusMiss, "1")
+	get(rendercache.StatusHit, "1")
+
+	// An expired entry is served while it is rendered in the background.
+	now = now.Add(90 * time.Second)
+	get(rendercache.StatusStale, "1")
+	for i := 0; ; i++ {
+		if entry, err := store.Get(context.Background(), "key"); err != nil {
+			t.Fatal(err)
+		} else if string(entry.Body) == "2" {
+			break
+		} else if i == 100 {
+			t.Fatal("entry not revalidated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	get(rendercache.StatusHit, "2")
+
+	// An entry past its stale window is rendered again.
+	now = now.Add(3 * time.Minute)
+	get(rendercache.StatusMiss, "3")
+
+	// Errors are returned and not cached.
+	errRender := errors.New("render failed")
+	if err := cache.Delete(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	} else if _, _, err := cache.Get(context.Background(), "key", func(ctx context.Context) (*rendercache.Entry, error) {
+		return nil, errRender
+	}); err != errRender {
+		t.Fatalf("unexpected error: %v", err)
+	} else if n := store.Len(); n != 0 {
+		t.Fatalf("unexpected entry count: %d", n)
+	}
+
+	// Render functions that return no entry fail without a panic.
+	if _, _, err := cache.Get(context.Background(), "key", func(ctx context.Context) (*rendercache.Entry, error) {
+		return nil, nil
+	}); err != rendercache.ErrNoEntry {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Pages with an error status are returned and not cached.
+	if entry, _, err := cache.Get(context.Background(), "key", func(ctx context.Context) (*rendercache.Entry, error) {
+		return &rendercache.Entry{StatusCode: 404, Body: []byte("not found")}, nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if entry.StatusCode != 404 {
+		t.Fatalf("unexpected status: %d", entry.StatusCode)
+	} else if n := store.Len(); n != 0 {
+		t.Fatalf("unexpected entry count: %d", n)
+	}
+}
+
+// Ensure concurrent misses share a single render.
+func TestCache_Get_Concurrent(t *testing.T) {
+	cache := rendercache.New(rendercache.NewMemoryStore())
+
+	var renders int32
+	release := make(chan struct{})
+	render := func(ctx context.Context) (*rendercache.Entry, error) {
+		atomic.AddInt32(&renders, 1)
+		<-release
+		return &rendercache.Entry{Body: []byte("OK")}, nil
+	}
+
+	errs := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, _, err := cache.Get(context.Background(), "key", render)
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if renders != 1 {
+		t.Fatalf("unexpected render count: %d", renders)
+	}
+}
+
+// Ensure a caller that stops waiting does not cancel a shared render.
+func TestCache_Get_Cancel(t *testing.T) {
+	cache := rendercache.New(rendercache.NewMemoryStore())
+
+	started, release := make(chan struct{}), make(chan struct{})
+	render := func(ctx context.Context) (*rendercache.Entry, error) {
+		close(started)
+		select {
+		case <-release:
+			return &rendercache.Entry{Body: []byte("OK")}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		_, _, err := cache.Get(ctx, "key", render)
+		errs <- err
+	}()
+	<-started
+
+	entries := make(chan *rendercache.Entry)
+	go func() {
+		entry, _, _ := cache.Get(context.Background(), "key", render)
+		entries <- entry
+	}()
+
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(release)
+	if entry := <-entries; entry == nil || string(entry.Body) != "OK" {
+		t.Fatalf("unexpected entry: %#v", entry)
+	}
+}
+
+// Ensure the lifetime of entries follows their Cache-Control.
+func TestCache_CacheControl(t *testing.T) {
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		cacheControl string
+		stored       bool
+		expires      time.Duration
+		staleUntil   time.Duration
+	}{
+		{cacheControl: "", stored: true, expires: 5 * time.Minute, staleUntil: 5 * time.Minute},
+		{cacheControl: "max-age=60", stored: true, expires: time.Minute, staleUntil: time.Minute},
+		{cacheControl: "public, max-age=60, s-maxage=120", stored: true, expires: 2 * time.Minute, staleUntil: 2 * time.Minute},
+		{cacheControl: "max-age=60, stale-while-revalidate=30", stored: true, expires: time.Minute, staleUntil: 90 * time.Second},
+		{cacheControl: "max-age=86400", stored: true, expires: time.Hour, staleUntil: time.Hour},
+		{cacheControl: "max-age=0", stored: false},
+		{cacheControl: "no-store"},
+		{cacheControl: "No-Cache"},
+		{cacheControl: "private, max-age=60"},
+	} {
+		store := rendercache.NewMemoryStore()
+		cache := rendercache.New(store)
+		cache.MaxTTL = time.Hour
+		cache.Now = func() time.Time { return now }
+
+		if _, _, err := cache.Get(context.Background(), "key", func(ctx context.Context) (*rendercache.Entry, error) {
+			return &rendercache.Entry{CacheControl: tt.cacheControl}, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		entry, err := store.Get(context.Background(), "key")
+		if !tt.stored {
+			if err != rendercache.ErrNotFound {
+				t.Fatalf("%q: unexpected error: %v", tt.cacheControl, err)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("%q: %s", tt.cacheControl, err)
+		}
+		if d := entry.Expires.Sub(now); d != tt.expires {
+			t.Fatalf("%q: unexpected expiry: %s", tt.cacheControl, d)
+		} else if d := entry.StaleUntil.Sub(now); d != tt.staleUntil {
+			t.Fatalf("%q: unexpected stale window: %s", tt.cacheControl, d)
+		}
+	}
+}
+
+// Ensure keys depend on the URL and options.
+func TestKey(t *testing.T) {
+	type options struct {
+		Format string
+		Wait   time.Duration
+	}
+	a := rendercache.Key("http://example.com", options{Format: "png"})
+	if b := rendercache.Key("http://example.com", options{Format: "png"}); a != b {
+		t.Fatal("expected equal keys")
+	} else if b := rendercache.Key("http://example.com", options{Format: "pdf"}); a == b {
+		t.Fatal("expected keys to differ by options")
+	} else if b := rendercache.Key("http://example.org", options{Format: "png"}); a == b {
+		t.Fatal("expected keys to differ by URL")
+	}
+}
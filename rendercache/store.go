@@ -0,0 +1,239 @@
+package rendercache
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Store stores the entries of a cache. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Get returns the entry for key. Returns ErrNotFound if there is none.
+	Get(ctx context.Context, key string) (*Entry, error)
+
+	// Set stores entry under key. The store may remove the entry once its
+	// StaleUntil has passed.
+	Set(ctx context.Context, key string, entry *Entry) error
+
+	// Delete removes the entry for key.
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore is a Store that keeps entries in memory. Once it holds more
+// than MaxEntries entries or MaxBytes bytes of bodies, the least recently
+// used entries are removed.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *memoryEntry
+	lru     *list.List               // most recently used first
+	size    int
+
+	// Maximum number of entries and total size of their bodies. Zero means
+	// no limit.
+	MaxEntries int
+	MaxBytes   int
+
+	// Now returns the current time. Used for testing.
+	Now func() time.Time
+}
+
+// NewMemoryStore returns a new, empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*list.Element), lru: list.New(), Now: time.Now}
+}
+
+// memoryEntry is an element of a MemoryStore's LRU list.
+type memoryEntry struct {
+	key   string
+	entry *Entry
+}
+
+// Get returns the entry for key.
+func (s *MemoryStore) Get(ctx context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(elem)
+		return elem.Value.(*memoryEntry).entry, nil
+	}
+	return nil, ErrNotFound
+}
+
+// Set stores entry under key. Entries that can no longer be served are
+// removed as new entries are added, followed by the least recently used
+// entries while the store is over its limits.
+func (s *MemoryStore) Set(ctx context.Context, key string, entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	for _, elem := range s.entries {
+		if !now.Before(elem.Value.(*memoryEntry).entry.StaleUntil) {
+			s.remove(elem)
+		}
+	}
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	s.entries[key] = s.lru.PushFront(&memoryEntry{key: key, entry: entry})
+	s.size += len(entry.Body)
+
+	for s.lru.Len() > 0 && ((s.MaxEntries > 0 && s.lru.Len() > s.MaxEntries) || (s.MaxBytes > 0 && s.size > s.MaxBytes)) {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Delete removes the entry for key.
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	return nil
+}
+
+// remove removes an element from the store. Must be called with the lock held.
+func (s *MemoryStore) remove(elem *list.Element) {
+	e := s.lru.Remove(elem).(*memoryEntry)
+	delete(s.entries, e.key)
+	s.size -= len(e.entry.Body)
+}
+
+// Len returns the number of stored entries.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Size returns the total size of the stored entries' bodies.
+func (s *MemoryStore) Size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// DiskStore is a Store that keeps entries in files in a local directory, so
+// that they survive restarts and can be shared by processes on the same
+// host. Each entry is a file named after the hash of its key.
+type DiskStore struct {
+	Path string
+
+	// Now returns the current time. Used for testing.
+	Now func() time.Time
+}
+
+// NewDiskStore returns a store that keeps entries in the directory at path.
+func NewDiskStore(path string) *DiskStore {
+	return &DiskStore{Path: path, Now: time.Now}
+}
+
+// diskEntryHeader is the first line of an entry's file. It is followed by the
+// entry's body.
+type diskEntryHeader struct {
+	ContentType  string    `json:"contentType"`
+	StatusCode   int       `json:"statusCode,omitempty"`
+	CacheControl string    `json:"cacheControl,omitempty"`
+	Created      time.Time `json:"created"`
+	Expires      time.Time `json:"expires"`
+	StaleUntil   time.Time `json:"staleUntil"`
+}
+
+// Get reads the entry for key. Entries that can no longer be served are
+// removed.
+func (s *DiskStore) Get(ctx context.Context, key string) (*Entry, error) {
+	filename := s.filename(key)
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var hdr diskEntryHeader
+	if err := json.Unmarshal(line, &hdr); err != nil {
+		return nil, err
+	} else if !s.Now().Before(hdr.StaleUntil) {
+		os.Remove(filename)
+		return nil, ErrNotFound
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{
+		ContentType:  hdr.ContentType,
+		StatusCode:   hdr.StatusCode,
+		Body:         body,
+		CacheControl: hdr.CacheControl,
+		Created:      hdr.Created,
+		Expires:      hdr.Expires,
+		StaleUntil:   hdr.StaleUntil,
+	}, nil
+}
+
+// Set writes entry to the key's file.
+func (s *DiskStore) Set(ctx context.Context, key string, entry *Entry) error {
+	line, err := json.Marshal(diskEntryHeader{
+		ContentType:  entry.ContentType,
+		StatusCode:   entry.StatusCode,
+		CacheControl: entry.CacheControl,
+		Created:      entry.Created,
+		Expires:      entry.Expires,
+		StaleUntil:   entry.StaleUntil,
+	})
+	if err != nil {
+		return err
+	} else if err := os.MkdirAll(s.Path, 0777); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see partial entries.
+	f, err := os.CreateTemp(s.Path, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	} else if _, err := f.Write(entry.Body); err != nil {
+		f.Close()
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.filename(key))
+}
+
+// Delete removes the key's file.
+func (s *DiskStore) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(s.filename(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// filename returns the path of the key's file.
+func (s *DiskStore) filename(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Path, hex.EncodeToString(h[:]))
+}
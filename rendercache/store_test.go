@@ -0,0 +1,96 @@
+package rendercache_test
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs/rendercache"
+)
+
+// Ensure the least recently used entries are removed once the store is full.
+func TestMemoryStore_Limits(t *testing.T) {
+	store := rendercache.NewMemoryStore()
+	store.MaxEntries, store.MaxBytes = 3, 10
+	ctx := context.Background()
+	set := func(key string, size int) {
+		t.Helper()
+		entry := &rendercache.Entry{Body: make([]byte, size), StaleUntil: time.Now().Add(time.Hour)}
+		if err := store.Set(ctx, key, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	has := func(keys ...string) {
+		t.Helper()
+		for _, key := range keys {
+			if _, err := store.Get(ctx, key); err != nil {
+				t.Fatalf("%s: %v", key, err)
+			}
+		}
+	}
+
+	set("a", 1)
+	set("b", 1)
+	set("c", 1)
+	has("a")
+	set("d", 1)
+	if _, err := store.Get(ctx, "b"); err != rendercache.ErrNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	has("a", "c", "d")
+
+	set("e", 10)
+	if n, size := store.Len(), store.Size(); n != 1 || size != 10 {
+		t.Fatalf("unexpected store: %d entries, %d bytes", n, size)
+	}
+	has("e")
+}
+
+// Ensure entries can be written to and read from disk.
+func TestDiskStore(t *testing.T) {
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := rendercache.NewDiskStore(t.TempDir())
+	store.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	entry := &rendercache.Entry{
+		ContentType:  "text/html",
+		StatusCode:   203,
+		Body:         []byte("<html>\n</html>"),
+		CacheControl: "max-age=60",
+		Created:      now,
+		Expires:      now.Add(time.Minute),
+		StaleUntil:   now.Add(2 * time.Minute),
+	}
+	if _, err := store.Get(ctx, "key"); err != rendercache.ErrNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := store.Set(ctx, "key", entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if other, err := store.Get(ctx, "key"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(other, entry) {
+		t.Fatalf("unexpected entry: %#v", other)
+	}
+
+	// Entries are removed once they can no longer be served.
+	now = now.Add(2 * time.Minute)
+	if _, err := store.Get(ctx, "key"); err != rendercache.ErrNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	} else if fis, err := os.ReadDir(store.Path); err != nil {
+		t.Fatal(err)
+	} else if len(fis) != 0 {
+		t.Fatalf("unexpected files: %d", len(fis))
+	}
+
+	if err := store.Set(ctx, "key", &rendercache.Entry{StaleUntil: now.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	} else if err := store.Delete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	} else if _, err := store.Get(ctx, "key"); err != rendercache.ErrNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+// Package rendergrpc implements the gRPC RenderService defined in the
+// renderpb package using a page pool.
+//
+//	s := grpc.NewServer()
+//	renderpb.RegisterRenderServiceServer(s, rendergrpc.NewServer(pool))
+//	grpc_health_v1.RegisterHealthServer(s, rendergrpc.NewHealthServer(pool))
+//	s.Serve(ln)
+package rendergrpc
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/jobs"
+	"github.com/benbjohnson/phantomjs/renderpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Default settings.
+const (
+	DefaultTimeout = 30 * time.Second
+	DefaultMaxWait = 10 * time.Second
+)
+
+// Ensure server implements the generated interface.
+var _ renderpb.RenderServiceServer = (*Server)(nil)
+
+// Server implements renderpb.RenderServiceServer by rendering pages from a pool.
+type Server struct {
+	renderpb.UnimplementedRenderServiceServer
+
+	pool *phantomjs.Pool
+
+	// Maximum time to handle a single call, including the wait.
+	// Shorter client deadlines take precedence.
+	Timeout time.Duration
+
+	// Upper bound on the requested wait.
+	MaxWait time.Duration
+}
+
+// NewServer returns a new server that renders pages from pool.
+func NewServer(pool *phantomjs.Pool) *Server {
+	return &Server{
+		pool:    pool,
+		Timeout: DefaultTimeout,
+		MaxWait: DefaultMaxWait,
+	}
+}
+
+// RenderHTML returns the page's HTML after its scripts have run.
+func (s *Server) RenderHTML(ctx context.Context, req *renderpb.RenderRequest) (*renderpb.RenderHTMLResponse, error) {
+	resp := &renderpb.RenderHTMLResponse{}
+	if err := s.withPage(ctx, req, jobs.Output{Format: jobs.FormatHTML}, func(page *phantomjs.WebPage) (err error) {
+		if resp.Html, err = page.Content(); err != nil {
+			return err
+		}
+		resp.Url, err = page.URL()
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RenderPNG returns a PNG screenshot of the page.
+func (s *Server) RenderPNG(ctx context.Context, req *renderpb.RenderRequest) (*renderpb.RenderImageResponse, error) {
+	out := jobs.Output{Format: jobs.FormatPNG}
+	resp := &renderpb.RenderImageResponse{}
+	if err := s.withPage(ctx, req, out, func(page *phantomjs.WebPage) (err error) {
+		resp.Data, err = jobs.Capture(page, out)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RenderPDF returns the page rendered as a PDF.
+func (s *Server) RenderPDF(ctx context.Context, req *renderpb.RenderPDFRequest) (*renderpb.RenderPDFResponse, error) {
+	paperSize := &phantomjs.PaperSize{Format: req.GetPaperFormat(), Orientation: req.GetOrientation()}
+	if paperSize.Format == "" {
+		paperSize.Format = "A4"
+	}
+	if m := req.GetMargin(); m != "" {
+		paperSize.Margin = &phantomjs.PaperSizeMargin{Top: m, Bottom: m, Left: m, Right: m}
+	}
+
+	out := jobs.Output{Format: jobs.FormatPDF, PaperSize: paperSize}
+	resp := &renderpb.RenderPDFResponse{}
+	if err := s.withPage(ctx, req.GetPage(), out, func(page *phantomjs.WebPage) (err error) {
+		resp.Data, err = jobs.Capture(page, out)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Evaluate runs a JavaScript function in the page and returns its result.
+func (s *Server) Evaluate(ctx context.Context, req *renderpb.EvaluateRequest) (*renderpb.EvaluateResponse, error) {
+	if req.GetScript() == "" {
+		return nil, status.Error(codes.InvalidArgument, "script required")
+	}
+
+	resp := &renderpb.EvaluateResponse{}
+	if err := s.withPage(ctx, req.GetPage(), jobs.Output{}, func(page *phantomjs.WebPage) error {
+		v, err := page.Evaluate(req.GetScript())
+		if err != nil {
+			return err
+		}
+		if resp.Result, err = structpb.NewValue(v); err != nil {
+			return status.Errorf(codes.Internal, "cannot convert result: %s", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Ensure health server implements the generated interface.
+var _ grpc_health_v1.HealthServer = (*HealthServer)(nil)
+
+// HealthServer implements the standard gRPC health service by checking that
+// the pool's processes can evaluate a script. It serves the overall status,
+// named by an empty service, and the RenderService status. Watch is not
+// supported.
+type HealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+
+	pool *phantomjs.Pool
+
+	// Maximum time to check the pool. Shorter client deadlines take
+	// precedence.
+	Timeout time.Duration
+}
+
+// NewHealthServer returns a new health server that checks pool.
+func NewHealthServer(pool *phantomjs.Pool) *HealthServer {
+	return &HealthServer{
+		pool:    pool,
+		Timeout: phantomjs.DefaultHealthTimeout,
+	}
+}
+
+// Check returns SERVING if the pool is healthy and NOT_SERVING otherwise.
+func (s *HealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if svc := req.GetService(); svc != "" && svc != renderpb.RenderService_ServiceDesc.ServiceName {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	resp := &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}
+	if err := s.pool.Healthy(ctx); err != nil {
+		resp.Status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	return resp, nil
+}
+
+// withPage checks out a page, loads the request's URL and calls fn with it.
+// Errors are converted to gRPC status errors.
+func (s *Server) withPage(ctx context.Context, req *renderpb.RenderRequest, out jobs.Output, fn func(*phantomjs.WebPage) error) error {
+	job, err := s.job(req, out)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	page, err := s.pool.Get(ctx)
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+	defer s.pool.Put(page)
+
+	if err := jobs.Load(ctx, page, job); err != nil {
+		return toStatus(ctx, err)
+	} else if err := fn(page); err != nil {
+		return toStatus(ctx, err)
+	}
+	return nil
+}
+
+// job validates req and converts it to a job.
+func (s *Server) job(req *renderpb.RenderRequest, out jobs.Output) (*jobs.Job, error) {
+	u, err := url.Parse(req.GetUrl())
+	if err != nil || req.GetUrl() == "" {
+		return nil, errors.New("url required")
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("url must be http or https")
+	} else if req.GetWaitMs() < 0 {
+		return nil, errors.New("invalid wait")
+	}
+
+	wait := time.Duration(req.GetWaitMs()) * time.Millisecond
+	if wait > s.MaxWait {
+		wait = s.MaxWait
+	}
+
+	out.ViewportWidth, out.ViewportHeight = int(req.GetViewportWidth()), int(req.GetViewportHeight())
+	return &jobs.Job{URL: u.String(), Output: out, Wait: wait}, nil
+}
+
+// toStatus converts err to a gRPC status error.
+func toStatus(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, "render timeout")
+	case ctx.Err() == context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case err == phantomjs.ErrPoolClosed:
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Unknown, err.Error())
+	}
+}
@@ -0,0 +1,145 @@
+package rendergrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/rendergrpc"
+	"github.com/benbjohnson/phantomjs/renderpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Ensure HTML can be rendered over gRPC.
+func TestServer_RenderHTML(t *testing.T) {
+	client := NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Open":
+			w.Write([]byte(`{"status":"success"}`))
+		case "/webpage/Content":
+			w.Write([]byte(`{"value":"<html>RENDERED</html>"}`))
+		case "/webpage/URL":
+			w.Write([]byte(`{"value":"http://example.com/final"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+
+	resp, err := client.RenderHTML(context.Background(), &renderpb.RenderRequest{Url: "http://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	} else if resp.Html != "<html>RENDERED</html>" || resp.Url != "http://example.com/final" {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+}
+
+// Ensure evaluation results are returned as protobuf values.
+func TestServer_Evaluate(t *testing.T) {
+	client := NewClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Open":
+			w.Write([]byte(`{"status":"success"}`))
+		case "/webpage/Evaluate":
+			var req struct {
+				Script string `json:"script"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Script != "function() { return 1 }" {
+				t.Errorf("unexpected script: %s", req.Script)
+			}
+			w.Write([]byte(`{"returnValue":{"n":1,"s":"x"}}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+
+	resp, err := client.Evaluate(context.Background(), &renderpb.EvaluateRequest{
+		Page:   &renderpb.RenderRequest{Url: "http://example.com"},
+		Script: "function() { return 1 }",
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if m := resp.Result.GetStructValue().AsMap(); m["n"] != float64(1) || m["s"] != "x" {
+		t.Fatalf("unexpected result: %v", m)
+	}
+}
+
+// Ensure invalid requests return InvalidArgument.
+func TestServer_InvalidArgument(t *testing.T) {
+	client := NewClient(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	if _, err := client.RenderPNG(context.Background(), &renderpb.RenderRequest{Url: "file:///etc/passwd"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, err := client.Evaluate(context.Background(), &renderpb.EvaluateRequest{Page: &renderpb.RenderRequest{Url: "http://example.com"}}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the health server reports the pool's health.
+func TestHealthServer_Check(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Evaluate":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"engine stuck"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	s := rendergrpc.NewHealthServer(phantomjs.NewPool(1, phantomjs.NewProcess(phantomjs.WithPort(portN))))
+
+	if resp, err := s.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "phantomjs.render.v1.RenderService"}); err != nil {
+		t.Fatal(err)
+	} else if resp.GetStatus() != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("unexpected status: %s", resp.GetStatus())
+	}
+
+	if _, err := s.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "other"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// NewClient returns a client connected to an in-memory server whose pool is
+// backed by a stub process served by fn.
+func NewClient(tb testing.TB, fn http.HandlerFunc) renderpb.RenderServiceClient {
+	srv := httptest.NewServer(fn)
+	tb.Cleanup(srv.Close)
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	pool := phantomjs.NewPool(1, phantomjs.NewProcess(phantomjs.WithPort(portN)))
+
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	renderpb.RegisterRenderServiceServer(s, rendergrpc.NewServer(pool))
+	go s.Serve(ln)
+	tb.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { conn.Close() })
+	return renderpb.NewRenderServiceClient(conn)
+}
@@ -0,0 +1,5 @@
+// Package renderpb contains the protobuf definition and generated gRPC code
+// for the RenderService. See the rendergrpc package for the server.
+package renderpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative render.proto
@@ -0,0 +1,653 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: render.proto
+
+package renderpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// RenderRequest identifies the page to load and how to load it.
+type RenderRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Absolute http or https URL to load.
+	Url string `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	// Viewport size in pixels. The page default is used if unset.
+	ViewportWidth  int32 `protobuf:"varint,2,opt,name=viewport_width,json=viewportWidth,proto3" json:"viewport_width,omitempty"`
+	ViewportHeight int32 `protobuf:"varint,3,opt,name=viewport_height,json=viewportHeight,proto3" json:"viewport_height,omitempty"`
+	// Milliseconds to wait after the page loads before capturing it.
+	WaitMs int64 `protobuf:"varint,4,opt,name=wait_ms,json=waitMs,proto3" json:"wait_ms,omitempty"`
+}
+
+func (x *RenderRequest) Reset() {
+	*x = RenderRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_render_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RenderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderRequest) ProtoMessage() {}
+
+func (x *RenderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_render_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderRequest.ProtoReflect.Descriptor instead.
+func (*RenderRequest) Descriptor() ([]byte, []int) {
+	return file_render_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RenderRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetViewportWidth() int32 {
+	if x != nil {
+		return x.ViewportWidth
+	}
+	return 0
+}
+
+func (x *RenderRequest) GetViewportHeight() int32 {
+	if x != nil {
+		return x.ViewportHeight
+	}
+	return 0
+}
+
+func (x *RenderRequest) GetWaitMs() int64 {
+	if x != nil {
+		return x.WaitMs
+	}
+	return 0
+}
+
+type RenderHTMLResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// URL of the page after any redirects.
+	Url  string `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Html string `protobuf:"bytes,2,opt,name=html,proto3" json:"html,omitempty"`
+}
+
+func (x *RenderHTMLResponse) Reset() {
+	*x = RenderHTMLResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_render_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RenderHTMLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderHTMLResponse) ProtoMessage() {}
+
+func (x *RenderHTMLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_render_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderHTMLResponse.ProtoReflect.Descriptor instead.
+func (*RenderHTMLResponse) Descriptor() ([]byte, []int) {
+	return file_render_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RenderHTMLResponse) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *RenderHTMLResponse) GetHtml() string {
+	if x != nil {
+		return x.Html
+	}
+	return ""
+}
+
+type RenderImageResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *RenderImageResponse) Reset() {
+	*x = RenderImageResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_render_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RenderImageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderImageResponse) ProtoMessage() {}
+
+func (x *RenderImageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_render_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderImageResponse.ProtoReflect.Descriptor instead.
+func (*RenderImageResponse) Descriptor() ([]byte, []int) {
+	return file_render_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RenderImageResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type RenderPDFRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Page *RenderRequest `protobuf:"bytes,1,opt,name=page,proto3" json:"page,omitempty"`
+	// Paper format such as "A4" or "Letter". Defaults to "A4".
+	PaperFormat string `protobuf:"bytes,2,opt,name=paper_format,json=paperFormat,proto3" json:"paper_format,omitempty"`
+	// Either "portrait" or "landscape". Defaults to "portrait".
+	Orientation string `protobuf:"bytes,3,opt,name=orientation,proto3" json:"orientation,omitempty"`
+	// CSS margin such as "1cm".
+	Margin string `protobuf:"bytes,4,opt,name=margin,proto3" json:"margin,omitempty"`
+}
+
+func (x *RenderPDFRequest) Reset() {
+	*x = RenderPDFRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_render_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RenderPDFRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderPDFRequest) ProtoMessage() {}
+
+func (x *RenderPDFRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_render_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderPDFRequest.ProtoReflect.Descriptor instead.
+func (*RenderPDFRequest) Descriptor() ([]byte, []int) {
+	return file_render_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RenderPDFRequest) GetPage() *RenderRequest {
+	if x != nil {
+		return x.Page
+	}
+	return nil
+}
+
+func (x *RenderPDFRequest) GetPaperFormat() string {
+	if x != nil {
+		return x.PaperFormat
+	}
+	return ""
+}
+
+func (x *RenderPDFRequest) GetOrientation() string {
+	if x != nil {
+		return x.Orientation
+	}
+	return ""
+}
+
+func (x *RenderPDFRequest) GetMargin() string {
+	if x != nil {
+		return x.Margin
+	}
+	return ""
+}
+
+type RenderPDFResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *RenderPDFResponse) Reset() {
+	*x = RenderPDFResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_render_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RenderPDFResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderPDFResponse) ProtoMessage() {}
+
+func (x *RenderPDFResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_render_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderPDFResponse.ProtoReflect.Descriptor instead.
+func (*RenderPDFResponse) Descriptor() ([]byte, []int) {
+	return file_render_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RenderPDFResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type EvaluateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Page *RenderRequest `protobuf:"bytes,1,opt,name=page,proto3" json:"page,omitempty"`
+	// JavaScript function source, e.g. "function() { return document.title; }".
+	Script string `protobuf:"bytes,2,opt,name=script,proto3" json:"script,omitempty"`
+}
+
+func (x *EvaluateRequest) Reset() {
+	*x = EvaluateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_render_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EvaluateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvaluateRequest) ProtoMessage() {}
+
+func (x *EvaluateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_render_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvaluateRequest.ProtoReflect.Descriptor instead.
+func (*EvaluateRequest) Descriptor() ([]byte, []int) {
+	return file_render_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EvaluateRequest) GetPage() *RenderRequest {
+	if x != nil {
+		return x.Page
+	}
+	return nil
+}
+
+func (x *EvaluateRequest) GetScript() string {
+	if x != nil {
+		return x.Script
+	}
+	return ""
+}
+
+type EvaluateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Result *structpb.Value `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+}
+
+func (x *EvaluateResponse) Reset() {
+	*x = EvaluateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_render_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EvaluateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvaluateResponse) ProtoMessage() {}
+
+func (x *EvaluateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_render_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvaluateResponse.ProtoReflect.Descriptor instead.
+func (*EvaluateResponse) Descriptor() ([]byte, []int) {
+	return file_render_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *EvaluateResponse) GetResult() *structpb.Value {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+var File_render_proto protoreflect.FileDescriptor
+
+var file_render_proto_rawDesc = []byte{
+	0x0a, 0x0c, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13,
+	0x70, 0x68, 0x61, 0x6e, 0x74, 0x6f, 0x6d, 0x6a, 0x73, 0x2e, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x2e, 0x76, 0x31, 0x1a, 0x1c, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2f, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x22, 0x8a, 0x01, 0x0a, 0x0d, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x72, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x75, 0x72, 0x6c, 0x12, 0x25, 0x0a, 0x0e, 0x76, 0x69, 0x65, 0x77, 0x70, 0x6f, 0x72,
+	0x74, 0x5f, 0x77, 0x69, 0x64, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x76,
+	0x69, 0x65, 0x77, 0x70, 0x6f, 0x72, 0x74, 0x57, 0x69, 0x64, 0x74, 0x68, 0x12, 0x27, 0x0a, 0x0f,
+	0x76, 0x69, 0x65, 0x77, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x76, 0x69, 0x65, 0x77, 0x70, 0x6f, 0x72, 0x74, 0x48,
+	0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x77, 0x61, 0x69, 0x74, 0x5f, 0x6d, 0x73,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x77, 0x61, 0x69, 0x74, 0x4d, 0x73, 0x22, 0x3a,
+	0x0a, 0x12, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x48, 0x54, 0x4d, 0x4c, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x72, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x75, 0x72, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x74, 0x6d, 0x6c, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x74, 0x6d, 0x6c, 0x22, 0x29, 0x0a, 0x13, 0x52, 0x65,
+	0x6e, 0x64, 0x65, 0x72, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0xa7, 0x01, 0x0a, 0x10, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x50, 0x44, 0x46, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x36, 0x0a, 0x04, 0x70, 0x61,
+	0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x70, 0x68, 0x61, 0x6e, 0x74,
+	0x6f, 0x6d, 0x6a, 0x73, 0x2e, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x52,
+	0x65, 0x6e, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x04, 0x70, 0x61,
+	0x67, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x70, 0x61, 0x70, 0x65, 0x72, 0x5f, 0x66, 0x6f, 0x72, 0x6d,
+	0x61, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x70, 0x61, 0x70, 0x65, 0x72, 0x46,
+	0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x6f, 0x72, 0x69, 0x65, 0x6e, 0x74, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x6f, 0x72, 0x69, 0x65,
+	0x6e, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x61, 0x72, 0x67, 0x69,
+	0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x61, 0x72, 0x67, 0x69, 0x6e, 0x22,
+	0x27, 0x0a, 0x11, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x50, 0x44, 0x46, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x61, 0x0a, 0x0f, 0x45, 0x76, 0x61, 0x6c,
+	0x75, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x36, 0x0a, 0x04, 0x70,
+	0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x70, 0x68, 0x61, 0x6e,
+	0x74, 0x6f, 0x6d, 0x6a, 0x73, 0x2e, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e,
+	0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x04, 0x70,
+	0x61, 0x67, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x22, 0x42, 0x0a, 0x10, 0x45,
+	0x76, 0x61, 0x6c, 0x75, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x2e, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x32,
+	0xfa, 0x02, 0x0a, 0x0d, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x12, 0x59, 0x0a, 0x0a, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x48, 0x54, 0x4d, 0x4c, 0x12,
+	0x22, 0x2e, 0x70, 0x68, 0x61, 0x6e, 0x74, 0x6f, 0x6d, 0x6a, 0x73, 0x2e, 0x72, 0x65, 0x6e, 0x64,
+	0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x27, 0x2e, 0x70, 0x68, 0x61, 0x6e, 0x74, 0x6f, 0x6d, 0x6a, 0x73, 0x2e,
+	0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x48, 0x54, 0x4d, 0x4c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x59, 0x0a, 0x09,
+	0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x50, 0x4e, 0x47, 0x12, 0x22, 0x2e, 0x70, 0x68, 0x61, 0x6e,
+	0x74, 0x6f, 0x6d, 0x6a, 0x73, 0x2e, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e,
+	0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x28, 0x2e,
+	0x70, 0x68, 0x61, 0x6e, 0x74, 0x6f, 0x6d, 0x6a, 0x73, 0x2e, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72,
+	0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x5a, 0x0a, 0x09, 0x52, 0x65, 0x6e, 0x64, 0x65,
+	0x72, 0x50, 0x44, 0x46, 0x12, 0x25, 0x2e, 0x70, 0x68, 0x61, 0x6e, 0x74, 0x6f, 0x6d, 0x6a, 0x73,
+	0x2e, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65,
+	0x72, 0x50, 0x44, 0x46, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x26, 0x2e, 0x70, 0x68,
+	0x61, 0x6e, 0x74, 0x6f, 0x6d, 0x6a, 0x73, 0x2e, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x2e, 0x76,
+	0x31, 0x2e, 0x52, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x50, 0x44, 0x46, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x57, 0x0a, 0x08, 0x45, 0x76, 0x61, 0x6c, 0x75, 0x61, 0x74, 0x65, 0x12,
+	0x24, 0x2e, 0x70, 0x68, 0x61, 0x6e, 0x74, 0x6f, 0x6d, 0x6a, 0x73, 0x2e, 0x72, 0x65, 0x6e, 0x64,
+	0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x76, 0x61, 0x6c, 0x75, 0x61, 0x74, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x25, 0x2e, 0x70, 0x68, 0x61, 0x6e, 0x74, 0x6f, 0x6d, 0x6a,
+	0x73, 0x2e, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x76, 0x61, 0x6c,
+	0x75, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x2b, 0x5a, 0x29,
+	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x62, 0x65, 0x6e, 0x62, 0x6a,
+	0x6f, 0x68, 0x6e, 0x73, 0x6f, 0x6e, 0x2f, 0x70, 0x68, 0x61, 0x6e, 0x74, 0x6f, 0x6d, 0x6a, 0x73,
+	0x2f, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_render_proto_rawDescOnce sync.Once
+	file_render_proto_rawDescData = file_render_proto_rawDesc
+)
+
+func file_render_proto_rawDescGZIP() []byte {
+	file_render_proto_rawDescOnce.Do(func() {
+		file_render_proto_rawDescData = protoimpl.X.CompressGZIP(file_render_proto_rawDescData)
+	})
+	return file_render_proto_rawDescData
+}
+
+var file_render_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_render_proto_goTypes = []any{
+	(*RenderRequest)(nil),       // 0: phantomjs.render.v1.RenderRequest
+	(*RenderHTMLResponse)(nil),  // 1: phantomjs.render.v1.RenderHTMLResponse
+	(*RenderImageResponse)(nil), // 2: phantomjs.render.v1.RenderImageResponse
+	(*RenderPDFRequest)(nil),    // 3: phantomjs.render.v1.RenderPDFRequest
+	(*RenderPDFResponse)(nil),   // 4: phantomjs.render.v1.RenderPDFResponse
+	(*EvaluateRequest)(nil),     // 5: phantomjs.render.v1.EvaluateRequest
+	(*EvaluateResponse)(nil),    // 6: phantomjs.render.v1.EvaluateResponse
+	(*structpb.Value)(nil),      // 7: google.protobuf.Value
+}
+var file_render_proto_depIdxs = []int32{
+	0, // 0: phantomjs.render.v1.RenderPDFRequest.page:type_name -> phantomjs.render.v1.RenderRequest
+	0, // 1: phantomjs.render.v1.EvaluateRequest.page:type_name -> phantomjs.render.v1.RenderRequest
+	7, // 2: phantomjs.render.v1.EvaluateResponse.result:type_name -> google.protobuf.Value
+	0, // 3: phantomjs.render.v1.RenderService.RenderHTML:input_type -> phantomjs.render.v1.RenderRequest
+	0, // 4: phantomjs.render.v1.RenderService.RenderPNG:input_type -> phantomjs.render.v1.RenderRequest
+	3, // 5: phantomjs.render.v1.RenderService.RenderPDF:input_type -> phantomjs.render.v1.RenderPDFRequest
+	5, // 6: phantomjs.render.v1.RenderService.Evaluate:input_type -> phantomjs.render.v1.EvaluateRequest
+	1, // 7: phantomjs.render.v1.RenderService.RenderHTML:output_type -> phantomjs.render.v1.RenderHTMLResponse
+	2, // 8: phantomjs.render.v1.RenderService.RenderPNG:output_type -> phantomjs.render.v1.RenderImageResponse
+	4, // 9: phantomjs.render.v1.RenderService.RenderPDF:output_type -> phantomjs.render.v1.RenderPDFResponse
+	6, // 10: phantomjs.render.v1.RenderService.Evaluate:output_type -> phantomjs.render.v1.EvaluateResponse
+	7, // [7:11] is the sub-list for method output_type
+	3, // [3:7] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_render_proto_init() }
+func file_render_proto_init() {
+	if File_render_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_render_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*RenderRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_render_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*RenderHTMLResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_render_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*RenderImageResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_render_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*RenderPDFRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_render_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*RenderPDFResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_render_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*EvaluateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_render_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*EvaluateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_render_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_render_proto_goTypes,
+		DependencyIndexes: file_render_proto_depIdxs,
+		MessageInfos:      file_render_proto_msgTypes,
+	}.Build()
+	File_render_proto = out.File
+	file_render_proto_rawDesc = nil
+	file_render_proto_goTypes = nil
+	file_render_proto_depIdxs = nil
+}
@@ -0,0 +1,247 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: render.proto
+
+package renderpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RenderService_RenderHTML_FullMethodName = "/phantomjs.render.v1.RenderService/RenderHTML"
+	RenderService_RenderPNG_FullMethodName  = "/phantomjs.render.v1.RenderService/RenderPNG"
+	RenderService_RenderPDF_FullMethodName  = "/phantomjs.render.v1.RenderService/RenderPDF"
+	RenderService_Evaluate_FullMethodName   = "/phantomjs.render.v1.RenderService/Evaluate"
+)
+
+// RenderServiceClient is the client API for RenderService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RenderService renders web pages using a pool of PhantomJS pages.
+type RenderServiceClient interface {
+	// RenderHTML returns the page's HTML after its scripts have run.
+	RenderHTML(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (*RenderHTMLResponse, error)
+	// RenderPNG returns a PNG screenshot of the page.
+	RenderPNG(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (*RenderImageResponse, error)
+	// RenderPDF returns the page rendered as a PDF.
+	RenderPDF(ctx context.Context, in *RenderPDFRequest, opts ...grpc.CallOption) (*RenderPDFResponse, error)
+	// Evaluate runs a JavaScript function in the page and returns its result.
+	Evaluate(ctx context.Context, in *EvaluateRequest, opts ...grpc.CallOption) (*EvaluateResponse, error)
+}
+
+type renderServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRenderServiceClient(cc grpc.ClientConnInterface) RenderServiceClient {
+	return &renderServiceClient{cc}
+}
+
+func (c *renderServiceClient) RenderHTML(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (*RenderHTMLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RenderHTMLResponse)
+	err := c.cc.Invoke(ctx, RenderService_RenderHTML_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *renderServiceClient) RenderPNG(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (*RenderImageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RenderImageResponse)
+	err := c.cc.Invoke(ctx, RenderService_RenderPNG_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *renderServiceClient) RenderPDF(ctx context.Context, in *RenderPDFRequest, opts ...grpc.CallOption) (*RenderPDFResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RenderPDFResponse)
+	err := c.cc.Invoke(ctx, RenderService_RenderPDF_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *renderServiceClient) Evaluate(ctx context.Context, in *EvaluateRequest, opts ...grpc.CallOption) (*EvaluateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EvaluateResponse)
+	err := c.cc.Invoke(ctx, RenderService_Evaluate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RenderServiceServer is the server API for RenderService service.
+// All implementations must embed UnimplementedRenderServiceServer
+// for forward compatibility.
+//
+// RenderService renders web pages using a pool of PhantomJS pages.
+type RenderServiceServer interface {
+	// RenderHTML returns the page's HTML after its scripts have run.
+	RenderHTML(context.Context, *RenderRequest) (*RenderHTMLResponse, error)
+	// RenderPNG returns a PNG screenshot of the page.
+	RenderPNG(context.Context, *RenderRequest) (*RenderImageResponse, error)
+	// RenderPDF returns the page rendered as a PDF.
+	RenderPDF(context.Context, *RenderPDFRequest) (*RenderPDFResponse, error)
+	// Evaluate runs a JavaScript function in the page and returns its result.
+	Evaluate(context.Context, *EvaluateRequest) (*EvaluateResponse, error)
+	mustEmbedUnimplementedRenderServiceServer()
+}
+
+// UnimplementedRenderServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRenderServiceServer struct{}
+
+func (UnimplementedRenderServiceServer) RenderHTML(context.Context, *RenderRequest) (*RenderHTMLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenderHTML not implemented")
+}
+func (UnimplementedRenderServiceServer) RenderPNG(context.Context, *RenderRequest) (*RenderImageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenderPNG not implemented")
+}
+func (UnimplementedRenderServiceServer) RenderPDF(context.Context, *RenderPDFRequest) (*RenderPDFResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenderPDF not implemented")
+}
+func (UnimplementedRenderServiceServer) Evaluate(context.Context, *EvaluateRequest) (*EvaluateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Evaluate not implemented")
+}
+func (UnimplementedRenderServiceServer) mustEmbedUnimplementedRenderServiceServer() {}
+func (UnimplementedRenderServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeRenderServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RenderServiceServer will
+// result in compilation errors.
+type UnsafeRenderServiceServer interface {
+	mustEmbedUnimplementedRenderServiceServer()
+}
+
+func RegisterRenderServiceServer(s grpc.ServiceRegistrar, srv RenderServiceServer) {
+	// If the following call pancis, it indicates UnimplementedRenderServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RenderService_ServiceDesc, srv)
+}
+
+func _RenderService_RenderHTML_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RenderServiceServer).RenderHTML(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RenderService_RenderHTML_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RenderServiceServer).RenderHTML(ctx, req.(*RenderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RenderService_RenderPNG_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RenderServiceServer).RenderPNG(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RenderService_RenderPNG_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RenderServiceServer).RenderPNG(ctx, req.(*RenderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RenderService_RenderPDF_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenderPDFRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RenderServiceServer).RenderPDF(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RenderService_RenderPDF_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RenderServiceServer).RenderPDF(ctx, req.(*RenderPDFRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RenderService_Evaluate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvaluateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RenderServiceServer).Evaluate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RenderService_Evaluate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RenderServiceServer).Evaluate(ctx, req.(*EvaluateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RenderService_ServiceDesc is the grpc.ServiceDesc for RenderService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RenderService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "phantomjs.render.v1.RenderService",
+	HandlerType: (*RenderServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RenderHTML",
+			Handler:    _RenderService_RenderHTML_Handler,
+		},
+		{
+			MethodName: "RenderPNG",
+			Handler:    _RenderService_RenderPNG_Handler,
+		},
+		{
+			MethodName: "RenderPDF",
+			Handler:    _RenderService_RenderPDF_Handler,
+		},
+		{
+			MethodName: "Evaluate",
+			Handler:    _RenderService_Evaluate_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "render.proto",
+}
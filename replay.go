@@ -0,0 +1,96 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PageEvent represents an event recorded by the shim for a page, such as a
+// resource request, console message or load.
+type PageEvent struct {
+	Time time.Time
+
+	// Reference ID of the page. Set for events passed to a handler set with
+	// Process.SetEventHandler.
+	Ref string
+
+	// Name of the PhantomJS callback without its "on" prefix, such as
+	// "ResourceError" or "ConsoleMessage".
+	Event string
+
+	// Arguments of the event, such as the URL and status of a resource.
+	Data map[string]interface{}
+}
+
+// String returns the event as a single line.
+func (e PageEvent) String() string {
+	buf, _ := json.Marshal(e.Data)
+	return e.Time.Format("15:04:05.000") + " " + e.Event + " " + string(buf)
+}
+
+// RecentEvents returns the page's most recent resource, load, console and
+// error events, oldest first. Only the most recent 200 events are kept, so
+// they show what happened before a failure such as a blank render.
+func (p *WebPage) RecentEvents() ([]PageEvent, error) {
+	var resp struct {
+		Events []struct {
+			Time  int64                  `json:"time"`
+			Event string                 `json:"event"`
+			Data  map[string]interface{} `json:"data"`
+		} `json:"events"`
+	}
+	if err := p.doJSON("POST", "/webpage/RecentEvents", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+		return nil, err
+	}
+
+	a := make([]PageEvent, len(resp.Events))
+	for i, e := range resp.Events {
+		a[i] = PageEvent{Time: msTime(e.Time), Event: e.Event, Data: e.Data}
+	}
+	return a, nil
+}
+
+// ReplayError wraps an error with the events that preceded it.
+type ReplayError struct {
+	Err    error
+	Events []PageEvent
+}
+
+// Error returns the message of the wrapped error.
+func (e *ReplayError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *ReplayError) Unwrap() error {
+	return e.Err
+}
+
+// Dump writes the error and its events to w, one event per line.
+func (e *ReplayError) Dump(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%s\nrecent events (%d):\n", e.Err, len(e.Events)); err != nil {
+		return err
+	}
+	for _, ev := range e.Events {
+		if _, err := fmt.Fprintln(w, "  "+ev.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithRecentEvents wraps err in a ReplayError holding the page's recent
+// events, for error reports. Returns nil if err is nil. If the events cannot
+// be read, such as when the process has exited, err is returned unchanged.
+func (p *WebPage) WithRecentEvents(err error) error {
+	if err == nil {
+		return nil
+	}
+	events, e := p.RecentEvents()
+	if e != nil {
+		return err
+	}
+	return &ReplayError{Err: err, Events: events}
+}
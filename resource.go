@@ -0,0 +1,171 @@
+package phantomjs
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrResourceNotCaptured is returned by Resource when no matching
+	// resource was received since capture was enabled.
+	ErrResourceNotCaptured = errors.New("resource not captured")
+)
+
+// ResourceCapture selects the resources whose response bodies are kept by
+// the page. A resource is captured if it matches any URL pattern or any
+// content type.
+//
+// PhantomJS does not expose response bodies so the shim fetches each matching
+// resource again once the page has received it. Resources that cannot be
+// requested twice, such as POST responses, may differ from what the page saw.
+type ResourceCapture struct {
+	// JavaScript regular expressions matched against resource URLs.
+	URLPatterns []string
+
+	// Content types matched by prefix, such as "application/json".
+	ContentTypes []string
+}
+
+// SetResourceCapture starts capturing the bodies of resources selected by c.
+// Previously captured resources are discarded. Call it before Open to
+// capture the resources requested while the page loads.
+func (p *WebPage) SetResourceCapture(c ResourceCapture) error {
+	req := map[string]interface{}{
+		"ref":          p.ref.id,
+		"urlPatterns":  c.URLPatterns,
+		"contentTypes": c.ContentTypes,
+	}
+	return p.doJSON("POST", "/webpage/SetResourceCapture", req, nil)
+}
+
+// ResourceURLs returns the URLs of the captured resources in the order they
+// were received.
+func (p *WebPage) ResourceURLs() ([]string, error) {
+	var resp struct {
+		Value []string `json:"value"`
+	}
+	if err := p.doJSON("POST", "/webpage/ResourceURLs", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Value, nil
+}
+
+// ResourceResponse describes a response received by a page for a captured
+// resource, along with the request that it answered.
+type ResourceResponse struct {
+	URL           string
+	Method        string
+	RequestHeader http.Header
+	Status        int
+	StatusText    string
+	Header        http.Header
+
+	// Location that the response redirected to, if it was a redirect.
+	RedirectURL string
+
+	// Time the response was received.
+	Time time.Time
+}
+
+// ResourceResponses returns the responses of the captured resources in the
+// order they were received, including redirects.
+func (p *WebPage) ResourceResponses() ([]ResourceResponse, error) {
+	var resp struct {
+		Value []struct {
+			URL            string       `json:"url"`
+			Method         string       `json:"method"`
+			RequestHeaders []headerJSON `json:"requestHeaders"`
+			Status         int          `json:"status"`
+			StatusText     string       `json:"statusText"`
+			Headers        []headerJSON `json:"headers"`
+			RedirectURL    string       `json:"redirectURL"`
+			Time           time.Time    `json:"time"`
+		} `json:"value"`
+	}
+	if err := p.doJSON("POST", "/webpage/ResourceResponses", map[string]interface{}{"ref": p.ref.id}, &resp); err != nil {
+		return nil, err
+	}
+
+	a := make([]ResourceResponse, len(resp.Value))
+	for i, v := range resp.Value {
+		a[i] = ResourceResponse{
+			URL:           v.URL,
+			Method:        v.Method,
+			RequestHeader: decodeHeaderJSON(v.RequestHeaders),
+			Status:        v.Status,
+			StatusText:    v.StatusText,
+			Header:        decodeHeaderJSON(v.Headers),
+			RedirectURL:   v.RedirectURL,
+			Time:          v.Time,
+		}
+	}
+	return a, nil
+}
+
+// Resource returns the body and response headers of a captured resource.
+// If the resource is still being fetched then Resource waits for it.
+// Returns ErrResourceNotCaptured if no resource with url was captured.
+func (p *WebPage) Resource(url string) ([]byte, http.Header, error) {
+	req := map[string]interface{}{
+		"ref": p.ref.id,
+		"url": url,
+	}
+	var resp struct {
+		Found   bool         `json:"found"`
+		Body    string       `json:"body"`
+		Err     string       `json:"fetchError"`
+		Headers []headerJSON `json:"headers"`
+	}
+	if err := p.doJSON("POST", "/webpage/Resource", req, &resp); err != nil {
+		return nil, nil, err
+	} else if !resp.Found {
+		return nil, nil, ErrResourceNotCaptured
+	} else if resp.Err != "" {
+		return nil, nil, errors.New(resp.Err)
+	}
+
+	body, err := base64.StdEncoding.DecodeString(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, decodeHeaderJSON(resp.Headers), nil
+}
+
+// headerJSON is a struct for decoding the name/value header lists used by
+// PhantomJS network events.
+type headerJSON struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func decodeHeaderJSON(a []headerJSON) http.Header {
+	header := make(http.Header, len(a))
+	for _, h := range a {
+		header.Add(h.Name, h.Value)
+	}
+	return header
+}
+
+// URLRewrite redirects requests whose URL matches Pattern to URL before they
+// are sent. Pattern is a JavaScript regular expression.
+type URLRewrite struct {
+	Pattern string
+	URL     string
+}
+
+// SetURLRewrites replaces the page's URL rewrites. Rewrites are checked in
+// order and the first match is applied. Rewriting the URL of the main
+// document also changes the page's URL.
+func (p *WebPage) SetURLRewrites(rewrites []URLRewrite) error {
+	a := make([]map[string]string, len(rewrites))
+	for i, r := range rewrites {
+		a[i] = map[string]string{"pattern": r.Pattern, "url": r.URL}
+	}
+	req := map[string]interface{}{
+		"ref":      p.ref.id,
+		"rewrites": a,
+	}
+	return p.doJSON("POST", "/webpage/SetURLRewrites", req, nil)
+}
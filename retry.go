@@ -0,0 +1,113 @@
+package phantomjs
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Default retry policy settings.
+const (
+	DefaultRetryAttempts   = 3
+	DefaultRetryDelay      = 500 * time.Millisecond
+	DefaultRetryMultiplier = 2
+	DefaultRetryJitter     = 0.2
+)
+
+// RetryPolicy controls how OpenWithRetry retries transient failures.
+// Zero fields use the defaults.
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first.
+	MaxAttempts int
+
+	// Delay before the first retry. Each following delay is multiplied by
+	// Multiplier, up to MaxDelay if it is set.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+
+	// Fraction of each delay that is randomized, between 0 and 1, so that
+	// pages retrying together spread out. Use a negative value to disable.
+	Jitter float64
+
+	// Options used for each attempt.
+	Options OpenOptions
+
+	// Retryable reports whether an attempt should be retried. Defaults to
+	// IsTransientOpenError.
+	Retryable func(result *OpenResult, err error) bool
+}
+
+// IsTransientOpenError returns true if an open failed in a way that may
+// succeed when retried: the page failed to load, the main document timed out
+// or the server responded with a 5xx status. Errors from the process itself
+// are not transient.
+func IsTransientOpenError(result *OpenResult, err error) bool {
+	if _, ok := err.(*OpenError); ok {
+		return true
+	}
+	return err == nil && result != nil && (result.TimedOut || result.StatusCode >= 500)
+}
+
+// OpenWithRetry opens a URL, retrying transient failures with exponential
+// backoff. The page is stopped and reset to a blank document between
+// attempts. Returns the result of the last attempt. A 5xx response on the
+// last attempt is returned with an *OpenError.
+//
+// Waiting between attempts is interrupted when the page's context is done.
+func (p *WebPage) OpenWithRetry(url string, policy RetryPolicy) (*OpenResult, error) {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultRetryAttempts
+	}
+	if policy.InitialDelay <= 0 {
+		policy.InitialDelay = DefaultRetryDelay
+	}
+	if policy.Multiplier <= 0 {
+		policy.Multiplier = DefaultRetryMultiplier
+	}
+	if policy.Jitter == 0 {
+		policy.Jitter = DefaultRetryJitter
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsTransientOpenError
+	}
+
+	delay := policy.InitialDelay
+	for attempt := 1; ; attempt++ {
+		result, err := p.OpenWithOptions(url, policy.Options)
+		if !policy.Retryable(result, err) {
+			return result, err
+		} else if attempt >= policy.MaxAttempts {
+			if err == nil {
+				err = &OpenError{Result: result}
+			}
+			return result, err
+		}
+
+		// Reset the page so the next attempt starts from a clean document.
+		p.Stop()
+		p.OpenURL("about:blank")
+
+		timer := time.NewTimer(jitter(delay, policy.Jitter))
+		select {
+		case <-p.context().Done():
+			timer.Stop()
+			return result, p.context().Err()
+		case <-timer.C:
+		}
+
+		delay = time.Duration(float64(delay) * policy.Multiplier)
+		if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+	}
+}
+
+// jitter randomizes d by up to the fraction f in either direction.
+func jitter(d time.Duration, f float64) time.Duration {
+	if f <= 0 {
+		return d
+	} else if f > 1 {
+		f = 1
+	}
+	return time.Duration(float64(d) * (1 - f + 2*f*rand.Float64()))
+}
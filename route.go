@@ -0,0 +1,90 @@
+package phantomjs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrInvalidRoute is returned by RegisterRoute when the path does not
+	// start with a slash.
+	ErrInvalidRoute = errors.New("invalid route")
+
+	// ErrRouteExists is returned by RegisterRoute when the path is already
+	// served by the shim.
+	ErrRouteExists = errors.New("route already registered")
+)
+
+// Route represents a custom shim endpoint registered with RegisterRoute.
+type Route struct {
+	process *Process
+	path    string
+}
+
+// Path returns the route's path.
+func (r *Route) Path() string {
+	return r.path
+}
+
+// Call sends req to the route as JSON and decodes the handler's result into
+// resp, if resp is not nil.
+func (r *Route) Call(ctx context.Context, req, resp interface{}) error {
+	return r.process.DoJSON(ctx, "POST", r.path, req, resp)
+}
+
+// RegisterRoute adds an endpoint at path to the shim, for automation that the
+// package does not cover. The route is merged into the shim when the process
+// is opened; routes registered afterwards are sent by Reload.
+//
+// js is a JavaScript function expression that is called with the decoded
+// request body, the request and the response. A returned value other than
+// undefined is written as the JSON response; otherwise the function must
+// write and close the response itself. It may use the shim's helpers, such as
+// ref(id) to look up a page:
+//
+//	route, err := p.RegisterRoute("/custom/Title", `function(msg) {
+//		return {title: ref(msg.ref).title};
+//	}`)
+func (p *Process) RegisterRoute(path, js string) (*Route, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, ErrInvalidRoute
+	} else if _, ok := p.routes[path]; ok || strings.Contains(shim, "case '"+path+"':") {
+		return nil, ErrRouteExists
+	}
+
+	if p.routes == nil {
+		p.routes = make(map[string]string)
+	}
+	p.routes[path] = js
+	return &Route{process: p, path: path}, nil
+}
+
+// script returns the shim with the registered routes appended.
+func (p *Process) script() string {
+	return shim + p.routesScript()
+}
+
+// routesScript returns the JavaScript that adds the registered routes to the
+// shim.
+func (p *Process) routesScript() string {
+	if len(p.routes) == 0 {
+		return ""
+	}
+
+	paths := make([]string, 0, len(p.routes))
+	for path := range p.routes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	b.WriteString("\n// Routes registered with Process.RegisterRoute.\n")
+	for _, path := range paths {
+		key, _ := json.Marshal(path)
+		b.WriteString("customRoutes[" + string(key) + "] = (" + p.routes[path] + ");\n")
+	}
+	return b.String()
+}
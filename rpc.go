@@ -0,0 +1,54 @@
+package phantomjs
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RPCCall represents an RPC call sent to the shim.
+type RPCCall struct {
+	// HTTP method and shim route, such as "/webpage/Open".
+	Method string
+	Path   string
+
+	// Ref of the page the call is made on, if any.
+	Ref string
+
+	// Encoded JSON request body. Nil if the call has no body.
+	Request []byte
+
+	// Headers sent with the request. Middleware may add headers, such as
+	// for authentication.
+	Header http.Header
+
+	// Encoded JSON response body. Set once the call has been sent.
+	Response []byte
+}
+
+// RPCHandler sends an RPC call and sets its response.
+type RPCHandler func(ctx context.Context, call *RPCCall) error
+
+// RPCMiddleware wraps an RPCHandler. Middleware can inspect or modify the call
+// before and after passing it on, or return without calling next to fail or
+// answer the call itself, such as to inject faults in tests.
+type RPCMiddleware func(next RPCHandler) RPCHandler
+
+// RPCHooks returns middleware that calls before ahead of every RPC call and
+// after once it completes, with the call's duration and error. Either
+// function may be nil.
+func RPCHooks(before func(ctx context.Context, call *RPCCall), after func(ctx context.Context, call *RPCCall, d time.Duration, err error)) RPCMiddleware {
+	return func(next RPCHandler) RPCHandler {
+		return func(ctx context.Context, call *RPCCall) error {
+			if before != nil {
+				before(ctx, call)
+			}
+			t := time.Now()
+			err := next(ctx, call)
+			if after != nil {
+				after(ctx, call, time.Since(t), err)
+			}
+			return err
+		}
+	}
+}
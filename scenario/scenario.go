@@ -0,0 +1,337 @@
+// Package scenario runs scripted browser scenarios against a page.
+//
+// A scenario is an ordered list of steps. Each step runs with its own
+// timeout and the run stops at the first failing step:
+//
+//	s := &scenario.Scenario{
+//		Name: "login",
+//		Steps: []scenario.Step{
+//			scenario.Navigate("https://example.com/login"),
+//			scenario.Fill("#email", "user@example.com"),
+//			scenario.Fill("#password", "secret"),
+//			scenario.Click("button[type=submit]"),
+//			scenario.WaitFor(".dashboard"),
+//			scenario.Assert("greeting shown", `function() { return document.querySelector(".greeting") !== null; }`),
+//			scenario.Screenshot("dashboard"),
+//		},
+//	}
+//	report, err := s.Run(ctx, page)
+package scenario
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Default scenario settings.
+const (
+	DefaultStepTimeout  = 30 * time.Second
+	DefaultPollInterval = 100 * time.Millisecond
+)
+
+var (
+	// ErrAssertionFailed is returned by an Assert step whose script does not
+	// return true.
+	ErrAssertionFailed = errors.New("assertion failed")
+)
+
+// Step represents a single action in a scenario. Steps can be created with
+// the constructors in this package or declared directly for custom actions.
+type Step struct {
+	// Description used in logs and reports.
+	Name string
+
+	// Maximum time for the step. Defaults to the scenario's StepTimeout.
+	Timeout time.Duration
+
+	// Performs the step. The page sends its calls with a context that ends
+	// when the step times out. Output, such as screenshots, is stored on
+	// result.
+	Do func(ctx context.Context, page *phantomjs.WebPage, result *StepResult) error
+}
+
+// Scenario represents an ordered list of steps.
+type Scenario struct {
+	Name  string
+	Steps []Step
+
+	// Default maximum time for each step.
+	StepTimeout time.Duration
+
+	// How often WaitFor steps check the page.
+	PollInterval time.Duration
+
+	// Logger receives the start and outcome of each step, if set.
+	Logger *slog.Logger
+}
+
+// Report represents the outcome of running a scenario.
+type Report struct {
+	Name     string
+	Passed   bool
+	Started  time.Time
+	Duration time.Duration
+
+	// Results of every step, in order. Steps after a failure are skipped.
+	Steps []StepResult
+}
+
+// StepResult represents the outcome of a single step.
+type StepResult struct {
+	Name     string
+	Started  time.Time
+	Duration time.Duration
+
+	// Error returned by the step, if it failed.
+	Err error
+
+	// True if the step did not run because an earlier step failed.
+	Skipped bool
+
+	// PNG output of Screenshot steps.
+	Screenshot []byte
+}
+
+// Run executes the scenario's steps in order on page. The report is returned
+// even if a step fails, along with the error of the failing step.
+func (s *Scenario) Run(ctx context.Context, page *phantomjs.WebPage) (*Report, error) {
+	report := &Report{Name: s.Name, Started: time.Now(), Steps: make([]StepResult, len(s.Steps))}
+	defer func() { report.Duration = time.Since(report.Started) }()
+
+	var err error
+	for i, step := range s.Steps {
+		result := &report.Steps[i]
+		result.Name = step.Name
+		if err != nil {
+			result.Skipped = true
+			continue
+		}
+
+		s.log(slog.LevelInfo, "scenario step started", "step", i, "name", step.Name)
+		result.Started = time.Now()
+		result.Err = s.runStep(ctx, page, step, result)
+		result.Duration = time.Since(result.Started)
+
+		if result.Err != nil {
+			err = fmt.Errorf("step %d (%s): %w", i, step.Name, result.Err)
+			s.log(slog.LevelError, "scenario step failed", "step", i, "name", step.Name, "duration", result.Duration, "error", result.Err)
+			continue
+		}
+		s.log(slog.LevelInfo, "scenario step passed", "step", i, "name", step.Name, "duration", result.Duration)
+	}
+
+	report.Passed = err == nil
+	return report, err
+}
+
+// runStep runs a single step within its timeout.
+func (s *Scenario) runStep(ctx context.Context, page *phantomjs.WebPage, step Step, result *StepResult) error {
+	timeout := step.Timeout
+	if timeout <= 0 {
+		timeout = s.StepTimeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultStepTimeout
+	}
+	ctx, cancel := context.WithTimeout(withPollInterval(ctx, s.PollInterval), timeout)
+	defer cancel()
+
+	if err := step.Do(ctx, page.WithContext(ctx), result); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// log writes to the scenario's logger, if set.
+func (s *Scenario) log(level slog.Level, msg string, args ...interface{}) {
+	if s.Logger == nil {
+		return
+	}
+	s.Logger.Log(context.Background(), level, msg, append([]interface{}{"scenario", s.Name}, args...)...)
+}
+
+// Navigate returns a step that opens url and fails if the page does not load.
+func Navigate(url string) Step {
+	return Step{
+		Name: "navigate " + url,
+		Do: func(ctx context.Context, page *phantomjs.WebPage, result *StepResult) error {
+			return page.Open(url)
+		},
+	}
+}
+
+// WaitFor returns a step that waits until selector matches an element.
+func WaitFor(selector string) Step {
+	return Step{
+		Name: "wait for " + selector,
+		Do: func(ctx context.Context, page *phantomjs.WebPage, result *StepResult) error {
+			ticker := time.NewTicker(pollInterval(ctx))
+			defer ticker.Stop()
+			for {
+				if found, err := evaluateBool(page, existsScript, selector); err != nil {
+					return err
+				} else if found {
+					return nil
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+}
+
+// Click returns a step that scrolls the element matched by selector into view
+// and clicks its center with a native mouse event.
+func Click(selector string) Step {
+	return Step{
+		Name: "click " + selector,
+		Do: func(ctx context.Context, page *phantomjs.WebPage, result *StepResult) error {
+			v, err := page.Evaluate(fmt.Sprintf(centerScript, mustJSON(selector)))
+			if err != nil {
+				return err
+			}
+			point, ok := v.([]interface{})
+			if !ok || len(point) != 2 {
+				return phantomjs.ErrElementNotFound
+			}
+			x, _ := point[0].(float64)
+			y, _ := point[1].(float64)
+			return page.SendMouseEvent("click", int(x), int(y), "left")
+		},
+	}
+}
+
+// Fill returns a step that sets the value of the form field matched by
+// selector and dispatches input and change events.
+func Fill(selector, value string) Step {
+	return Step{
+		Name: "fill " + selector,
+		Do: func(ctx context.Context, page *phantomjs.WebPage, result *StepResult) error {
+			if found, err := evaluateBool(page, fillScript, selector, value); err != nil {
+				return err
+			} else if !found {
+				return phantomjs.ErrElementNotFound
+			}
+			return nil
+		},
+	}
+}
+
+// Screenshot returns a step that renders the page as a PNG and stores it on
+// the step's result.
+func Screenshot(name string) Step {
+	return Step{
+		Name: "screenshot " + name,
+		Do: func(ctx context.Context, page *phantomjs.WebPage, result *StepResult) error {
+			data, err := page.RenderBase64("PNG")
+			if err != nil {
+				return err
+			}
+			result.Screenshot, err = base64.StdEncoding.DecodeString(data)
+			return err
+		},
+	}
+}
+
+// Assert returns a step that evaluates a JavaScript function and fails with
+// ErrAssertionFailed unless it returns true.
+func Assert(description, script string) Step {
+	return Step{
+		Name: "assert " + description,
+		Do: func(ctx context.Context, page *phantomjs.WebPage, result *StepResult) error {
+			v, err := page.Evaluate(script)
+			if err != nil {
+				return err
+			} else if v != true {
+				return ErrAssertionFailed
+			}
+			return nil
+		},
+	}
+}
+
+// evaluateBool formats script with JSON-encoded args, evaluates it and
+// returns whether it returned true.
+func evaluateBool(page *phantomjs.WebPage, script string, args ...interface{}) (bool, error) {
+	a := make([]interface{}, len(args))
+	for i := range args {
+		a[i] = mustJSON(args[i])
+	}
+	v, err := page.Evaluate(fmt.Sprintf(script, a...))
+	if err != nil {
+		return false, err
+	}
+	return v == true, nil
+}
+
+// mustJSON returns v encoded as JSON.
+func mustJSON(v interface{}) string {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(buf)
+}
+
+// pollIntervalKey is the context key for the scenario's poll interval.
+type pollIntervalKey struct{}
+
+// withPollInterval returns a context carrying the poll interval for WaitFor.
+func withPollInterval(ctx context.Context, d time.Duration) context.Context {
+	if d <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, pollIntervalKey{}, d)
+}
+
+// pollInterval returns the poll interval set on ctx or the default.
+func pollInterval(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(pollIntervalKey{}).(time.Duration); ok {
+		return d
+	}
+	return DefaultPollInterval
+}
+
+// existsScript returns true if a selector matches an element.
+const existsScript = `function() {
+	return document.querySelector(%s) !== null;
+}`
+
+// centerScript scrolls an element into view and returns the viewport
+// coordinates of its center, or null if it does not exist.
+const centerScript = `function() {
+	var el = document.querySelector(%s);
+	if (!el) return null;
+	el.scrollIntoView();
+	var r = el.getBoundingClientRect();
+	return [Math.floor(r.left + r.width / 2), Math.floor(r.top + r.height / 2)];
+}`
+
+// fillScript sets a form field's value and fires the events that frameworks
+// listen for. Returns false if the field does not exist.
+const fillScript = `function() {
+	var el = document.querySelector(%s);
+	if (!el) return false;
+	el.focus();
+	el.value = %s;
+	['input', 'change'].forEach(function(type) {
+		var e = document.createEvent('HTMLEvents');
+		e.initEvent(type, true, false);
+		el.dispatchEvent(e);
+	});
+	return true;
+}`
@@ -0,0 +1,141 @@
+package scenario_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/scenario"
+)
+
+// Ensure steps run in order and their results are reported.
+func TestScenario_Run(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	var polls int
+	page := NewStubPage(t, func(path string, req map[string]interface{}) string {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, path)
+		switch path {
+		case "/webpage/Evaluate":
+			script := req["script"].(string)
+			switch {
+			case strings.Contains(script, "!== null"):
+				// Element appears on the second poll.
+				polls++
+				return `{"returnValue":` + strconv.FormatBool(polls > 1) + `}`
+			case strings.Contains(script, "scrollIntoView"):
+				return `{"returnValue":[10,20]}`
+			default:
+				return `{"returnValue":true}`
+			}
+		case "/webpage/Open":
+			return `{"status":"success"}`
+		case "/webpage/RenderBase64":
+			return `{"returnValue":"UE5H"}`
+		}
+		return `{}`
+	})
+
+	s := &scenario.Scenario{
+		Name:         "login",
+		PollInterval: time.Millisecond,
+		Steps: []scenario.Step{
+			scenario.Navigate("http://a/"),
+			scenario.WaitFor("#form"),
+			scenario.Fill("#email", "a@b.c"),
+			scenario.Click("#submit"),
+			scenario.Assert("logged in", `function() { return true; }`),
+			scenario.Screenshot("done"),
+		},
+	}
+	report, err := s.Run(context.Background(), page)
+	if err != nil {
+		t.Fatal(err)
+	} else if !report.Passed || len(report.Steps) != 6 {
+		t.Fatalf("unexpected report: %#v", report)
+	} else if report.Steps[1].Name != "wait for #form" {
+		t.Fatalf("unexpected step name: %s", report.Steps[1].Name)
+	} else if string(report.Steps[5].Screenshot) != "PNG" {
+		t.Fatalf("unexpected screenshot: %q", report.Steps[5].Screenshot)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(calls, ",") != "/webpage/Create,/webpage/Open,/webpage/Evaluate,/webpage/Evaluate,/webpage/Evaluate,/webpage/Evaluate,/webpage/SendMouseEvent,/webpage/Evaluate,/webpage/RenderBase64" {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+}
+
+// Ensure a failing step stops the run and later steps are skipped.
+func TestScenario_Run_Failure(t *testing.T) {
+	page := NewStubPage(t, func(path string, req map[string]interface{}) string {
+		if path == "/webpage/Evaluate" {
+			return `{"returnValue":false}`
+		}
+		return `{}`
+	})
+
+	s := &scenario.Scenario{
+		Steps: []scenario.Step{
+			scenario.Assert("false", `function() { return false; }`),
+			scenario.Screenshot("never"),
+		},
+	}
+	report, err := s.Run(context.Background(), page)
+	if !errors.Is(err, scenario.ErrAssertionFailed) {
+		t.Fatalf("unexpected error: %v", err)
+	} else if report.Passed || report.Steps[0].Err != scenario.ErrAssertionFailed || !report.Steps[1].Skipped {
+		t.Fatalf("unexpected report: %#v", report)
+	}
+}
+
+// Ensure steps that exceed their timeout fail with a deadline error.
+func TestScenario_Run_Timeout(t *testing.T) {
+	page := NewStubPage(t, func(path string, req map[string]interface{}) string {
+		return `{"returnValue":false}`
+	})
+
+	s := &scenario.Scenario{
+		StepTimeout:  20 * time.Millisecond,
+		PollInterval: time.Millisecond,
+		Steps:        []scenario.Step{scenario.WaitFor("#never")},
+	}
+	if _, err := s.Run(context.Background(), page); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// NewStubPage returns a page on a stub shim. The handler returns the response
+// body for each RPC path and decoded request.
+func NewStubPage(tb testing.TB, fn func(path string, req map[string]interface{}) string) *phantomjs.WebPage {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/webpage/Create" {
+			fn(r.URL.Path, nil)
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+			return
+		}
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte(fn(r.URL.Path, req)))
+	}))
+	tb.Cleanup(srv.Close)
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	page, err := phantomjs.NewProcess(phantomjs.WithPort(portN)).CreateWebPage()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return page
+}
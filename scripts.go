@@ -0,0 +1,69 @@
+package phantomjs
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidScript is returned by UseScripts when a script has no name
+	// or source.
+	ErrInvalidScript = errors.New("script requires a name and source")
+
+	// ErrScriptConflict is returned by UseScripts when a script is used with
+	// a different version than the page already uses.
+	ErrScriptConflict = errors.New("script version conflict")
+)
+
+// Script represents a helper library injected into pages by UseScripts, such
+// as jQuery or a set of utility functions.
+type Script struct {
+	// Name of the library. A page uses one version of each name.
+	Name string
+
+	// Version of the library, such as "3.7.1". Optional.
+	Version string
+
+	// JavaScript source of the library. It is evaluated in the global scope
+	// of each document.
+	Source string
+
+	// JavaScript expression that evaluates to the version of the library
+	// already loaded by a document, such as "jQuery.fn.jquery". If it
+	// evaluates to Version, the document's own copy is used and the script
+	// is not injected. Optional.
+	Check string
+}
+
+// UseScripts injects the scripts of bundle into the page's current document,
+// in order, and into every document the page loads afterwards once it has
+// finished loading. Each script is injected at most once per document, so
+// helpers that depend on each other can be declared by several callers.
+//
+// Scripts already used by the page are skipped. Returns ErrScriptConflict
+// without injecting any script if one is already used with another version,
+// and an error wrapping ErrInjectionFailed if a script throws in the current
+// document. The remaining scripts are still injected, but the one that threw
+// is not used for later documents.
+func (p *WebPage) UseScripts(bundle ...Script) error {
+	a := make([]map[string]string, len(bundle))
+	for i, s := range bundle {
+		if s.Name == "" || s.Source == "" {
+			return ErrInvalidScript
+		}
+		a[i] = map[string]string{"name": s.Name, "version": s.Version, "source": s.Source, "check": s.Check}
+	}
+
+	var resp struct {
+		Conflict string `json:"conflict"`
+		Error    string `json:"scriptError"`
+	}
+	if err := p.doJSON("POST", "/webpage/UseScripts", map[string]interface{}{"ref": p.ref.id, "scripts": a}, &resp); err != nil {
+		return err
+	} else if resp.Conflict != "" {
+		return fmt.Errorf("%s: %w", resp.Conflict, ErrScriptConflict)
+	} else if resp.Error != "" {
+		return fmt.Errorf("%w: %s", ErrInjectionFailed, resp.Error)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultLoginTimeout is the default time to wait for a login to succeed.
+const DefaultLoginTimeout = 30 * time.Second
+
+// waitPollInterval is how often waitFor checks its condition.
+const waitPollInterval = 100 * time.Millisecond
+
+var (
+	// ErrLoginFailed is returned by Login when the success check does not
+	// pass before the timeout.
+	ErrLoginFailed = errors.New("login failed")
+)
+
+// LoginSpec describes a form-based login.
+type LoginSpec struct {
+	// URL of the login page.
+	URL string
+
+	// Selectors of the username field, password field and submit button.
+	UserSelector   string
+	PassSelector   string
+	SubmitSelector string
+
+	// Credentials to enter.
+	Username string
+	Password string
+
+	// JavaScript function that returns true once the login succeeded, such
+	// as when an account menu is shown. Defaults to checking that the
+	// password field is gone.
+	Success string
+
+	// Maximum time to wait for Success after submitting.
+	// Defaults to the page's default timeout or DefaultLoginTimeout.
+	Timeout time.Duration
+}
+
+// Login opens spec.URL, enters the credentials, clicks the submit button and
+// waits for spec.Success. The fields receive input and change events as if
+// typed, and the button receives a real mouse click.
+//
+// Returns ErrElementNotFound if a selector matches no element and
+// ErrLoginFailed if the login does not succeed in time. Use ExportSession
+// afterwards to reuse the login in other pages.
+func (p *WebPage) Login(spec LoginSpec) error {
+	if err := p.Open(spec.URL); err != nil {
+		return err
+	}
+
+	req, err := json.Marshal(map[string]string{
+		"user":     spec.UserSelector,
+		"pass":     spec.PassSelector,
+		"submit":   spec.SubmitSelector,
+		"username": spec.Username,
+		"password": spec.Password,
+	})
+	if err != nil {
+		return err
+	}
+	var v *struct{ X, Y float64 }
+	if err := p.evaluateInto(fmt.Sprintf(loginScript, req), &v); err != nil {
+		return err
+	} else if v == nil {
+		return ErrElementNotFound
+	} else if err := p.SendMouseEvent("click", round(v.X), round(v.Y), "left"); err != nil {
+		return err
+	}
+
+	success := spec.Success
+	if success == "" {
+		sel, _ := json.Marshal(spec.PassSelector)
+		success = fmt.Sprintf(`function() { return document.querySelector(%s) === null; }`, sel)
+	}
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = p.timeoutOr(DefaultLoginTimeout)
+	}
+	return p.waitFor(success, timeout, ErrLoginFailed)
+}
+
+// waitFor evaluates script until it returns true. Errors while the page is
+// navigating are ignored. Returns errTimeout if the timeout expires first.
+func (p *WebPage) waitFor(script string, timeout time.Duration, errTimeout error) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var ok bool
+		if err := p.evaluateInto(script, &ok); err == nil && ok {
+			return nil
+		}
+
+		select {
+		case <-p.context().Done():
+			return p.context().Err()
+		case <-timer.C:
+			return errTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// Session represents the cookies and web storage of a logged in page. It can
+// be encoded as JSON, so a login can be shared between pages, processes and
+// pool workers.
+type Session struct {
+	// URL of the page the session was exported from. Its origin owns the
+	// storage.
+	URL string
+
+	Cookies        []*http.Cookie
+	LocalStorage   map[string]string
+	SessionStorage map[string]string
+}
+
+// ExportSession returns the cookies visible to the page's current URL and the
+// local and session storage of its origin.
+func (p *WebPage) ExportSession() (*Session, error) {
+	u, err := p.URL()
+	if err != nil {
+		return nil, err
+	}
+	cookies, err := p.Cookies()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{URL: u, Cookies: cookies}
+	var v struct {
+		Local   map[string]string `json:"local"`
+		Session map[string]string `json:"session"`
+	}
+	if err := p.evaluateInto(exportStorageScript, &v); err != nil {
+		return nil, err
+	}
+	s.LocalStorage, s.SessionStorage = v.Local, v.Session
+	return s, nil
+}
+
+// ImportSession adds the session's cookies to the page. If the session holds
+// storage, the page opens s.URL unless it is already on the same origin, and
+// the storage is written there. Pages opened afterwards see the session.
+func (p *WebPage) ImportSession(s *Session) error {
+	for _, c := range s.Cookies {
+		if ok, err := p.AddCookie(c); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("cookie rejected: %s", c.Name)
+		}
+	}
+	if len(s.LocalStorage) == 0 && len(s.SessionStorage) == 0 {
+		return nil
+	}
+
+	current, err := p.URL()
+	if err != nil {
+		return err
+	} else if !sameOrigin(current, s.URL) {
+		if err := p.Open(s.URL); err != nil {
+			return err
+		}
+	}
+
+	buf, err := json.Marshal(map[string]interface{}{"local": s.LocalStorage, "session": s.SessionStorage})
+	if err != nil {
+		return err
+	}
+	_, err = p.Evaluate(fmt.Sprintf(importStorageScript, buf))
+	return err
+}
+
+// sameOrigin returns true if a and b have the same scheme and host.
+func sameOrigin(a, b string) bool {
+	u, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	v, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == v.Scheme && u.Host == v.Host
+}
+
+// loginScript fills the credential fields, dispatching input and change
+// events, and returns the viewport coordinates of the submit button's center.
+// Returns null if an element is missing.
+const loginScript = `function() {
+	var req = %s;
+	var user = document.querySelector(req.user), pass = document.querySelector(req.pass), submit = document.querySelector(req.submit);
+	if (!user || !pass || !submit) return null;
+
+	var fill = function(el, value) {
+		el.focus();
+		el.value = value;
+		['input', 'change'].forEach(function(type) {
+			var e = document.createEvent('HTMLEvents');
+			e.initEvent(type, true, true);
+			el.dispatchEvent(e);
+		});
+	};
+	fill(user, req.username);
+	fill(pass, req.password);
+
+	submit.scrollIntoView();
+	var r = submit.getBoundingClientRect();
+	return {x: r.left + r.width / 2, y: r.top + r.height / 2};
+}`
+
+// exportStorageScript returns the contents of local and session storage.
+const exportStorageScript = `function() {
+	var copy = function(storage) {
+		var m = {};
+		for (var i = 0; i < storage.length; i++) {
+			var key = storage.key(i);
+			m[key] = storage.getItem(key);
+		}
+		return m;
+	};
+	return {local: copy(localStorage), session: copy(sessionStorage)};
+}`
+
+// importStorageScript writes items to local and session storage.
+const importStorageScript = `function() {
+	var req = %s;
+	for (var k in req.local || {}) localStorage.setItem(k, req.local[k]);
+	for (var k in req.session || {}) sessionStorage.setItem(k, req.session[k]);
+}`
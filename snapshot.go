@@ -0,0 +1,180 @@
+package phantomjs
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Types of changes reported by DiffSnapshots.
+const (
+	ChangeAdded      = "added"
+	ChangeRemoved    = "removed"
+	ChangeText       = "text"
+	ChangeAttributes = "attributes"
+)
+
+// Snapshot represents the element structure of a page at a point in time. It
+// can be encoded as JSON and stored, so that later snapshots of the same page
+// can be compared with DiffSnapshots.
+type Snapshot struct {
+	URL  string
+	Time time.Time
+	Root *SnapshotNode
+}
+
+// SnapshotNode represents an element in a snapshot.
+type SnapshotNode struct {
+	// Lowercase tag name.
+	Tag string
+
+	Attrs map[string]string
+
+	// Text of the element's own text nodes with whitespace collapsed. Empty
+	// for script and style elements.
+	Text string
+
+	// Child elements in document order.
+	Children []*SnapshotNode
+}
+
+// NodeChange represents a difference between two snapshots.
+type NodeChange struct {
+	// Type of change, such as ChangeAdded.
+	Type string
+
+	// CSS selector of the node. Removed nodes are located in the old
+	// snapshot and other nodes in the new snapshot.
+	Selector string
+
+	// Node in the old and new snapshots. Old is nil for added nodes and New
+	// is nil for removed nodes. Children are included.
+	Old *SnapshotNode
+	New *SnapshotNode
+}
+
+// Snapshot returns the current element structure of the page, starting at the
+// document element.
+func (p *WebPage) Snapshot() (*Snapshot, error) {
+	var v struct {
+		URL  string        `json:"url"`
+		Root *SnapshotNode `json:"root"`
+	}
+	if err := p.evaluateInto(snapshotScript, &v); err != nil {
+		return nil, err
+	}
+	return &Snapshot{URL: v.URL, Time: time.Now(), Root: v.Root}, nil
+}
+
+// DiffSnapshots returns the changes from snapshot a to snapshot b in document
+// order. Children are matched by tag and id, so an element inserted into a
+// list is reported as a single addition. The descendants of added and removed
+// elements are not reported separately.
+func DiffSnapshots(a, b *Snapshot) []NodeChange {
+	var changes []NodeChange
+	diffNodes(a.Root, b.Root, "", "", &changes)
+	return changes
+}
+
+// diffNodes appends the changes between two nodes and their descendants. The
+// paths are the selectors of the nodes' parents in each snapshot.
+func diffNodes(a, b *SnapshotNode, pathA, pathB string, changes *[]NodeChange) {
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		*changes = append(*changes, NodeChange{Type: ChangeAdded, Selector: nodeSelector(pathB, b, 1), New: b})
+		return
+	case b == nil || nodeKey(a) != nodeKey(b):
+		*changes = append(*changes, NodeChange{Type: ChangeRemoved, Selector: nodeSelector(pathA, a, 1), Old: a})
+		if b != nil {
+			*changes = append(*changes, NodeChange{Type: ChangeAdded, Selector: nodeSelector(pathB, b, 1), New: b})
+		}
+		return
+	}
+	diffNode(a, b, nodeSelector(pathA, a, 1), nodeSelector(pathB, b, 1), changes)
+}
+
+// diffNode appends the changes between two matched nodes, which have the
+// selectors selA and selB, and their children.
+func diffNode(a, b *SnapshotNode, selA, selB string, changes *[]NodeChange) {
+	if !reflect.DeepEqual(a.Attrs, b.Attrs) && (len(a.Attrs) > 0 || len(b.Attrs) > 0) {
+		*changes = append(*changes, NodeChange{Type: ChangeAttributes, Selector: selB, Old: a, New: b})
+	}
+	if a.Text != b.Text {
+		*changes = append(*changes, NodeChange{Type: ChangeText, Selector: selB, Old: a, New: b})
+	}
+
+	// Align the children on their longest common subsequence of keys.
+	n, m := len(a.Children), len(b.Children)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if nodeKey(a.Children[i]) == nodeKey(b.Children[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && nodeKey(a.Children[i]) == nodeKey(b.Children[j]):
+			diffNode(a.Children[i], b.Children[j], nodeSelector(selA, a.Children[i], i+1), nodeSelector(selB, b.Children[j], j+1), changes)
+			i, j = i+1, j+1
+		case j < m && (i == n || lcs[i][j+1] >= lcs[i+1][j]):
+			*changes = append(*changes, NodeChange{Type: ChangeAdded, Selector: nodeSelector(selB, b.Children[j], j+1), New: b.Children[j]})
+			j++
+		default:
+			*changes = append(*changes, NodeChange{Type: ChangeRemoved, Selector: nodeSelector(selA, a.Children[i], i+1), Old: a.Children[i]})
+			i++
+		}
+	}
+}
+
+// nodeKey returns the key that children are matched by.
+func nodeKey(n *SnapshotNode) string {
+	return n.Tag + "#" + n.Attrs["id"]
+}
+
+// cssIdentRegexp matches ids that can be used in a selector unescaped.
+var cssIdentRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// nodeSelector returns the selector of the nth child of the element at
+// parent. Elements with an id are selected by id alone.
+func nodeSelector(parent string, n *SnapshotNode, nth int) string {
+	if id := n.Attrs["id"]; cssIdentRegexp.MatchString(id) {
+		return n.Tag + "#" + id
+	} else if parent == "" {
+		return n.Tag
+	}
+	return parent + " > " + n.Tag + ":nth-child(" + strconv.Itoa(nth) + ")"
+}
+
+// snapshotScript returns the page's URL and its element tree.
+const snapshotScript = `function() {
+	var walk = function(el) {
+		var node = {tag: el.tagName.toLowerCase(), attrs: {}, text: '', children: []};
+		for (var i = 0; i < el.attributes.length; i++) {
+			node.attrs[el.attributes[i].name] = el.attributes[i].value;
+		}
+		var text = [];
+		for (var c = el.firstChild; c; c = c.nextSibling) {
+			if (c.nodeType === 1) node.children.push(walk(c));
+			else if (c.nodeType === 3) text.push(c.nodeValue);
+		}
+		if (node.tag !== 'script' && node.tag !== 'style') {
+			node.text = text.join(' ').replace(/\s+/g, ' ').replace(/^ | $/g, '');
+		}
+		return node;
+	};
+	return {url: location.href, root: document.documentElement ? walk(document.documentElement) : null};
+}`
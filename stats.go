@@ -0,0 +1,83 @@
+package phantomjs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats represents the runtime state of a PhantomJS process.
+type Stats struct {
+	// Process ID of PhantomJS.
+	PID int
+
+	// Time since the shim started.
+	Uptime time.Duration
+
+	// Number of RPC calls served, not counting the Stats call itself.
+	Calls int
+
+	// Number of open pages, including pages opened by page scripts.
+	Pages int
+
+	// Number of events waiting to be received by clients, such as
+	// mutation records.
+	QueuedEvents int
+
+	// Resident memory of the process in bytes. PhantomJS does not report
+	// its memory usage so this is read from /proc, and is zero if the
+	// process was not opened locally or /proc is unavailable.
+	MemoryRSS int64
+}
+
+// Stats returns the runtime state of the process.
+func (p *Process) Stats() (*Stats, error) {
+	var resp struct {
+		PID          int   `json:"pid"`
+		Started      int64 `json:"started"`
+		Now          int64 `json:"now"`
+		Calls        int   `json:"calls"`
+		Pages        int   `json:"pages"`
+		QueuedEvents int   `json:"queuedEvents"`
+	}
+	if err := p.doJSON(context.Background(), "POST", "/process/Stats", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		PID:          resp.PID,
+		Uptime:       msDuration(resp.Started, resp.Now),
+		Calls:        resp.Calls,
+		Pages:        resp.Pages,
+		QueuedEvents: resp.QueuedEvents,
+	}
+	if p.cmd != nil {
+		stats.MemoryRSS = readRSS(resp.PID)
+	}
+	return stats, nil
+}
+
+// readRSS returns the resident set size of a process from /proc. Returns
+// zero if it cannot be read.
+func readRSS(pid int) int64 {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The line has the form "VmRSS:     12345 kB".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "VmRSS:" {
+			kb, _ := strconv.ParseInt(fields[1], 10, 64)
+			return kb * 1024
+		}
+	}
+	return 0
+}
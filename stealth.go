@@ -0,0 +1,95 @@
+package phantomjs
+
+// StealthUserAgent is the user agent set by Stealth. It identifies as a
+// desktop Chrome browser.
+const StealthUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// Stealth makes the page harder to identify as headless. It replaces the
+// PhantomJS user agent and patches each new document before its scripts run:
+// the PhantomJS globals are hidden, navigator.webdriver is removed, plugins
+// and languages are populated and the WebGL vendor is spoofed.
+//
+// Call Stealth before Open. The patches hide common fingerprints only; the
+// engine's feature set still differs from a real browser.
+func (p *WebPage) Stealth() error {
+	settings, err := p.Settings()
+	if err != nil {
+		return err
+	}
+	settings.UserAgent = StealthUserAgent
+	if err := p.SetSettings(settings); err != nil {
+		return err
+	}
+	return p.addInitScript(stealthScript)
+}
+
+// addInitScript evaluates script in every document the page loads, before the
+// document's own scripts run.
+func (p *WebPage) addInitScript(script string) error {
+	return p.doJSON("POST", "/webpage/AddInitScript", map[string]interface{}{"ref": p.ref.id, "script": script}, nil)
+}
+
+// stealthScript patches the properties that commonly reveal PhantomJS.
+const stealthScript = `function() {
+	function define(obj, name, value) {
+		try {
+			Object.defineProperty(obj, name, {get: function() { return value; }, configurable: true});
+		} catch (e) {}
+	}
+
+	// Hide the PhantomJS globals. The callback is kept under an
+	// unadvertised name so the shim can still receive page messages.
+	try {
+		Object.defineProperty(window, '__phantomCallback', {value: window.callPhantom, enumerable: false});
+	} catch (e) {}
+	['callPhantom', '_phantom', '__phantomas'].forEach(function(name) {
+		try { delete window[name]; } catch (e) {}
+		if (window[name] !== undefined) define(window, name, undefined);
+	});
+
+	define(navigator, 'webdriver', undefined);
+	define(navigator, 'languages', ['en-US', 'en']);
+	define(navigator, 'language', 'en-US');
+	define(navigator, 'platform', 'Win32');
+	define(navigator, 'vendor', 'Google Inc.');
+	define(navigator, 'hardwareConcurrency', 8);
+
+	// Populate plugins and MIME types, which are empty in PhantomJS.
+	var mimeTypes = [{type: 'application/pdf', suffixes: 'pdf', description: 'Portable Document Format'}];
+	var plugins = ['Chrome PDF Plugin', 'Chrome PDF Viewer', 'Native Client'].map(function(name) {
+		var plugin = {name: name, filename: name.toLowerCase().replace(/ /g, '-') + '.plugin', description: name, length: mimeTypes.length};
+		for (var i = 0; i < mimeTypes.length; i++) plugin[i] = mimeTypes[i];
+		return plugin;
+	});
+	plugins.item = function(i) { return this[i] || null; };
+	plugins.namedItem = function(name) {
+		for (var i = 0; i < this.length; i++) if (this[i].name === name) return this[i];
+		return null;
+	};
+	plugins.refresh = function() {};
+	mimeTypes.item = plugins.item;
+	mimeTypes.namedItem = function(type) {
+		for (var i = 0; i < this.length; i++) if (this[i].type === type) return this[i];
+		return null;
+	};
+	define(navigator, 'plugins', plugins);
+	define(navigator, 'mimeTypes', mimeTypes);
+
+	if (!window.chrome) {
+		window.chrome = {runtime: {}, app: {isInstalled: false}, csi: function() {}, loadTimes: function() {}};
+	}
+
+	// Report a common GPU for the WEBGL_debug_renderer_info parameters.
+	if (window.WebGLRenderingContext) {
+		var getParameter = WebGLRenderingContext.prototype.getParameter;
+		WebGLRenderingContext.prototype.getParameter = function(name) {
+			if (name === 37445) return 'Intel Inc.';
+			if (name === 37446) return 'Intel Iris OpenGL Engine';
+			return getParameter.apply(this, arguments);
+		};
+	}
+
+	// Headless browsers report a zero-sized outer window.
+	if (!window.outerWidth) define(window, 'outerWidth', window.innerWidth);
+	if (!window.outerHeight) define(window, 'outerHeight', window.innerHeight + 85);
+}`
@@ -0,0 +1,475 @@
+package phantomjs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSupervisorClosed is returned when checking out a page from a closed
+	// supervisor.
+	ErrSupervisorClosed = errors.New("supervisor closed")
+)
+
+// Default supervisor settings.
+const (
+	DefaultSupervisorCheckInterval = 10 * time.Second
+	DefaultDrainTimeout            = 30 * time.Second
+)
+
+// Reasons that a supervisor recycles a process.
+const (
+	RecycleAge       = "age"
+	RecyclePages     = "pages"
+	RecycleMemory    = "memory"
+	RecycleUnhealthy = "unhealthy"
+	RecycleManual    = "manual"
+)
+
+// Types of supervisor events.
+const (
+	// A process was opened.
+	EventProcessStarted = "started"
+
+	// A process is being replaced. The event's Reason is set.
+	EventProcessRecycling = "recycling"
+
+	// A replaced process has no checked out pages, or the drain timeout
+	// expired. The event's Forced field is set in the latter case.
+	EventProcessDrained = "drained"
+
+	// A process was closed.
+	EventProcessStopped = "stopped"
+
+	// A process could not be opened, checked or closed. The event's Err
+	// field is set.
+	EventSupervisorError = "error"
+)
+
+// SupervisorEvent represents a change to the processes of a supervisor.
+type SupervisorEvent struct {
+	Type    string
+	Process *Process
+	Time    time.Time
+
+	// Reason for recycling the process, such as RecycleAge.
+	Reason string
+
+	// Set if pages were still checked out when the drain timeout expired.
+	Forced bool
+
+	Err error
+}
+
+// Supervisor owns a fixed number of processes, hands out pages from them and
+// replaces processes that reach an age, page count or memory limit or stop
+// responding.
+//
+// Processes are replaced one at a time. The replacement is opened before the
+// old process stops receiving new pages, and the old process is closed once
+// its checked out pages are returned, so callers do not see downtime.
+type Supervisor struct {
+	// Returns a new, unopened process. It is called for every process,
+	// including replacements, and each process must use a port that is not
+	// in use by the supervisor's other processes. Required.
+	New func() *Process
+
+	// Number of processes to run.
+	Size int
+
+	// Limits after which a process is recycled. Zero means no limit.
+	// MaxPageCount is the number of pages created through the supervisor
+	// and MaxMemory is the process' resident memory in bytes.
+	MaxAge       time.Duration
+	MaxPageCount int
+	MaxMemory    int64
+
+	// How often the limits and the health of processes are checked.
+	// Defaults to DefaultSupervisorCheckInterval.
+	CheckInterval time.Duration
+
+	// Maximum time to wait for a recycled process' pages to be returned
+	// before it is closed. Defaults to DefaultDrainTimeout.
+	DrainTimeout time.Duration
+
+	// Called on every change to the supervisor's processes, such as for
+	// metrics. It must not block.
+	OnEvent func(SupervisorEvent)
+
+	// Receives logs of supervisor events. If nil, nothing is logged.
+	Logger *slog.Logger
+
+	recycleMu sync.Mutex // serializes recycles
+
+	mu     sync.Mutex
+	procs  []*supervised
+	next   int
+	closed bool
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// supervised represents a process owned by a supervisor.
+type supervised struct {
+	process  *Process
+	started  time.Time
+	pages    int
+	active   int
+	draining bool
+	drained  chan struct{}
+	stopped  bool // closed by Supervisor.Close
+}
+
+// NewSupervisor returns a supervisor that runs size processes created by fn.
+func NewSupervisor(size int, fn func() *Process) *Supervisor {
+	return &Supervisor{New: fn, Size: size}
+}
+
+// Open opens the supervisor's processes and starts monitoring them.
+func (s *Supervisor) Open() error {
+	for i := 0; i < s.Size; i++ {
+		sp, err := s.start()
+		if err != nil {
+			s.mu.Lock()
+			procs := s.procs
+			s.procs = nil
+			s.mu.Unlock()
+			for _, sp := range procs {
+				sp.process.Close()
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.procs = append(s.procs, sp)
+		s.mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel, s.done = cancel, make(chan struct{})
+	go s.monitor(ctx)
+	return nil
+}
+
+// Close stops monitoring and closes all processes. Checked out pages are not
+// waited for. Returns once any recycle in progress has returned.
+func (s *Supervisor) Close() (err error) {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+
+	// Mark the processes as stopped and stop waiting for draining ones, so
+	// that a recycle in progress does not close them again.
+	s.mu.Lock()
+	s.closed = true
+	procs := s.procs
+	s.procs = nil
+	for _, sp := range procs {
+		sp.stopped = true
+		if sp.draining && sp.active > 0 {
+			close(sp.drained)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sp := range procs {
+		if e := s.stop(sp); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	s.recycleMu.Lock()
+	s.recycleMu.Unlock()
+	return err
+}
+
+// Processes returns the processes that currently receive new pages.
+func (s *Supervisor) Processes() []*Process {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var a []*Process
+	for _, sp := range s.procs {
+		if !sp.draining {
+			a = append(a, sp.process)
+		}
+	}
+	return a
+}
+
+// Get creates a page on the process with the fewest checked out pages. The
+// returned page uses ctx for its RPC calls. It must be returned with Put.
+func (s *Supervisor) Get(ctx context.Context) (*WebPage, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrSupervisorClosed
+	}
+	var sp *supervised
+	for i := range s.procs {
+		candidate := s.procs[(s.next+i)%len(s.procs)]
+		if !candidate.draining && (sp == nil || candidate.active < sp.active) {
+			sp = candidate
+		}
+	}
+	if sp == nil {
+		s.mu.Unlock()
+		return nil, errors.New("supervisor has no processes")
+	}
+	s.next++
+	sp.active++
+	sp.pages++
+	s.mu.Unlock()
+
+	page, err := sp.process.CreateWebPage()
+	if err != nil {
+		s.release(sp.process)
+		return nil, err
+	}
+	return page.WithContext(ctx), nil
+}
+
+// Put closes page and releases it from its process.
+func (s *Supervisor) Put(page *WebPage) error {
+	defer s.release(page.ref.process)
+	return page.WithContext(context.Background()).Close()
+}
+
+// release decrements the checked out pages of a process and signals a
+// draining process once it has none.
+func (s *Supervisor) release(p *Process) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sp := range s.procs {
+		if sp.process != p {
+			continue
+		}
+		sp.active--
+		if sp.draining && sp.active == 0 {
+			close(sp.drained)
+		}
+		return
+	}
+}
+
+// Recycle replaces every process in turn, such as after a configuration
+// change. It returns once the old processes are closed.
+func (s *Supervisor) Recycle(ctx context.Context) error {
+	s.mu.Lock()
+	procs := append([]*supervised(nil), s.procs...)
+	s.mu.Unlock()
+
+	for _, sp := range procs {
+		if err := s.recycle(ctx, sp, RecycleManual); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// monitor checks the processes until ctx is canceled.
+func (s *Supervisor) monitor(ctx context.Context) {
+	defer close(s.done)
+
+	interval := s.CheckInterval
+	if interval <= 0 {
+		interval = DefaultSupervisorCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		procs := append([]*supervised(nil), s.procs...)
+		s.mu.Unlock()
+
+		for _, sp := range procs {
+			if reason := s.check(ctx, sp, interval); reason != "" {
+				s.recycle(ctx, sp, reason)
+			}
+		}
+		s.replenish()
+	}
+}
+
+// replenish starts processes until the supervisor runs Size of them, such as
+// after an unhealthy process was removed because its replacement failed to
+// open.
+func (s *Supervisor) replenish() {
+	s.recycleMu.Lock()
+	defer s.recycleMu.Unlock()
+
+	for {
+		s.mu.Lock()
+		n := 0
+		for _, sp := range s.procs {
+			if !sp.draining {
+				n++
+			}
+		}
+		closed := s.closed
+		s.mu.Unlock()
+		if closed || n >= s.Size {
+			return
+		}
+
+		sp, err := s.start()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			s.stop(sp)
+			return
+		}
+		s.procs = append(s.procs, sp)
+		s.mu.Unlock()
+	}
+}
+
+// check returns the reason a process should be recycled, if any.
+func (s *Supervisor) check(ctx context.Context, sp *supervised, timeout time.Duration) string {
+	s.mu.Lock()
+	draining, pages := sp.draining, sp.pages
+	s.mu.Unlock()
+
+	switch {
+	case draining:
+		return ""
+	case s.MaxAge > 0 && time.Since(sp.started) >= s.MaxAge:
+		return RecycleAge
+	case s.MaxPageCount > 0 && pages >= s.MaxPageCount:
+		return RecyclePages
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := sp.process.Live(ctx); err != nil {
+		return RecycleUnhealthy
+	}
+	if s.MaxMemory > 0 {
+		if stats, err := sp.process.Stats(); err == nil && stats.MemoryRSS >= s.MaxMemory {
+			return RecycleMemory
+		}
+	}
+	return ""
+}
+
+// recycle opens a replacement for a process, drains the process and closes
+// it. An unhealthy process is removed even if its replacement fails to open,
+// and the monitor starts the missing process on a later check.
+func (s *Supervisor) recycle(ctx context.Context, old *supervised, reason string) error {
+	s.recycleMu.Lock()
+	defer s.recycleMu.Unlock()
+
+	s.mu.Lock()
+	if old.draining || s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+	s.emit(SupervisorEvent{Type: EventProcessRecycling, Process: old.process, Reason: reason})
+
+	repl, err := s.start()
+	if err != nil && reason != RecycleUnhealthy {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		if repl != nil {
+			s.stop(repl)
+		}
+		return ErrSupervisorClosed
+	}
+	if repl != nil {
+		s.procs = append(s.procs, repl)
+	}
+	old.draining = true
+	old.drained = make(chan struct{})
+	if old.active == 0 {
+		close(old.drained)
+	}
+	s.mu.Unlock()
+
+	// Wait for checked out pages to be returned.
+	timeout := s.DrainTimeout
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var forced bool
+	select {
+	case <-old.drained:
+	case <-timer.C:
+		forced = true
+	case <-ctx.Done():
+		forced = true
+	}
+
+	// Remove the process unless the supervisor was closed while draining and
+	// has closed it.
+	s.mu.Lock()
+	if old.stopped {
+		s.mu.Unlock()
+		return ErrSupervisorClosed
+	}
+	for i, sp := range s.procs {
+		if sp == old {
+			s.procs = append(s.procs[:i:i], s.procs[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+	s.emit(SupervisorEvent{Type: EventProcessDrained, Process: old.process, Forced: forced})
+	return s.stop(old)
+}
+
+// start opens a new process.
+func (s *Supervisor) start() (*supervised, error) {
+	p := s.New()
+	if err := p.Open(); err != nil {
+		s.emit(SupervisorEvent{Type: EventSupervisorError, Process: p, Err: err})
+		return nil, err
+	}
+	s.emit(SupervisorEvent{Type: EventProcessStarted, Process: p})
+	return &supervised{process: p, started: time.Now()}, nil
+}
+
+// stop closes a process.
+func (s *Supervisor) stop(sp *supervised) error {
+	err := sp.process.Close()
+	if err != nil {
+		s.emit(SupervisorEvent{Type: EventSupervisorError, Process: sp.process, Err: err})
+	}
+	s.emit(SupervisorEvent{Type: EventProcessStopped, Process: sp.process})
+	return err
+}
+
+// emit sends an event to OnEvent and the logger.
+func (s *Supervisor) emit(e SupervisorEvent) {
+	e.Time = time.Now()
+	if s.OnEvent != nil {
+		s.OnEvent(e)
+	}
+	if s.Logger != nil {
+		level := slog.LevelInfo
+		if e.Type == EventSupervisorError {
+			level = slog.LevelError
+		}
+		s.Logger.Log(context.Background(), level, "phantomjs supervisor "+e.Type, "port", e.Process.Port, "reason", e.Reason, "forced", e.Forced, "error", e.Err)
+	}
+}
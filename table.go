@@ -0,0 +1,126 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ExtractTable returns the cells of the first table matching selector as
+// rows of text. Rows of the table's thead come first and rows of its tfoot
+// come last, as in the DOM's table.rows collection. Cells spanning several columns or rows are repeated in each
+// position they cover so that every row has the same number of columns.
+//
+// Returns ErrElementNotFound if no table matches selector.
+func (p *WebPage) ExtractTable(selector string) ([][]string, error) {
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows *[][]string
+	if err := p.evaluateInto(fmt.Sprintf(tableScript, sel), &rows); err != nil {
+		return nil, err
+	} else if rows == nil {
+		return nil, ErrElementNotFound
+	}
+	return *rows, nil
+}
+
+// ExtractTableRecords returns the rows of the first table matching selector
+// keyed by column header. The header is the last row of the table's thead or,
+// if it has none, the first row of the table. Columns with empty headers are
+// omitted.
+func (p *WebPage) ExtractTableRecords(selector string) ([]map[string]string, error) {
+	sel, err := json.Marshal(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var v *struct {
+		HeaderRows int        `json:"headerRows"`
+		Rows       [][]string `json:"rows"`
+	}
+	if err := p.evaluateInto(fmt.Sprintf(tableRecordsScript, sel), &v); err != nil {
+		return nil, err
+	} else if v == nil {
+		return nil, ErrElementNotFound
+	} else if len(v.Rows) == 0 {
+		return nil, nil
+	}
+
+	n := v.HeaderRows
+	if n == 0 {
+		n = 1
+	}
+	header := v.Rows[n-1]
+	records := make([]map[string]string, 0, len(v.Rows)-n)
+	for _, row := range v.Rows[n:] {
+		record := make(map[string]string, len(header))
+		for i, name := range header {
+			if name != "" && i < len(row) {
+				record[name] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// tableGridScript defines grid(), which lays out a table's cells as a
+// rectangular grid of text, and headerRows(), which counts the thead rows.
+const tableGridScript = `
+	function grid(table) {
+		var rows = [], spans = [];
+		for (var r = 0; r < table.rows.length; r++) {
+			var tr = table.rows[r], row = [], col = 0;
+			var place = function() {
+				while (spans[col] && spans[col].rows > 0) {
+					row[col] = spans[col].text;
+					spans[col].rows--;
+					col++;
+				}
+			};
+			for (var c = 0; c < tr.cells.length; c++) {
+				place();
+				var cell = tr.cells[c];
+				var text = (cell.textContent || '').replace(/\s+/g, ' ').trim();
+				var colspan = Math.max(cell.colSpan || 1, 1), rowspan = Math.max(cell.rowSpan || 1, 1);
+				for (var i = 0; i < colspan; i++) {
+					row[col] = text;
+					if (rowspan > 1) spans[col] = {text: text, rows: rowspan - 1};
+					col++;
+				}
+			}
+			place();
+			rows.push(row);
+		}
+
+		var width = 0;
+		for (var r = 0; r < rows.length; r++) width = Math.max(width, rows[r].length);
+		for (var r = 0; r < rows.length; r++) {
+			for (var c = 0; c < width; c++) {
+				if (rows[r][c] === undefined) rows[r][c] = '';
+			}
+		}
+		return rows;
+	}
+
+	function headerRows(table) {
+		return table.tHead ? table.tHead.rows.length : 0;
+	}
+`
+
+// tableScript returns the grid of the table matching a JSON-encoded selector.
+const tableScript = `function() {` + tableGridScript + `
+	var table = document.querySelector(%s);
+	if (!table || table.tagName !== 'TABLE') return null;
+	return grid(table);
+}`
+
+// tableRecordsScript returns the grid and header row count of the table
+// matching a JSON-encoded selector.
+const tableRecordsScript = `function() {` + tableGridScript + `
+	var table = document.querySelector(%s);
+	if (!table || table.tagName !== 'TABLE') return null;
+	return {headerRows: headerRows(table), rows: grid(table)};
+}`
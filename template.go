@@ -0,0 +1,78 @@
+package phantomjs
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"time"
+)
+
+// DefaultTemplateTimeout is the default time RenderTemplate waits for the
+// assets of a template to load.
+const DefaultTemplateTimeout = 30 * time.Second
+
+var (
+	// ErrTemplateTimeout is returned by RenderTemplate when the assets of the
+	// template do not finish loading in time.
+	ErrTemplateTimeout = errors.New("template assets did not load")
+)
+
+// TemplateOptions represents the settings of RenderTemplate.
+type TemplateOptions struct {
+	// URL that relative asset URLs in the template, such as stylesheets,
+	// fonts and images, are resolved against. Usually a directory URL ending
+	// in a slash. If blank, the template can only use absolute URLs.
+	BaseURL string
+
+	// Local directory of assets served with ServeDir for the duration of
+	// the render and used as the base URL. Ignored if BaseURL is set.
+	AssetDir string
+
+	// Maximum time to wait for the assets to load.
+	// Defaults to the page's default timeout or DefaultTemplateTimeout.
+	Timeout time.Duration
+
+	// Settings of the render, such as Format "PDF" for documents.
+	Render RenderOptions
+}
+
+// RenderTemplate executes tmpl with data, loads the resulting HTML into the
+// page and renders it, for generating documents such as invoices and reports
+// from Go. The render waits until the template's assets have loaded and the
+// network has been idle for DefaultNetworkIdleTime.
+func (p *WebPage) RenderTemplate(tmpl *template.Template, data interface{}, opts TemplateOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	if opts.BaseURL == "" && opts.AssetDir != "" {
+		assets, err := ServeDir(opts.AssetDir)
+		if err != nil {
+			return nil, err
+		}
+		defer assets.Close()
+		opts.BaseURL = assets.URL()
+	}
+
+	var err error
+	if opts.BaseURL != "" {
+		err = p.SetContentAndURL(buf.String(), opts.BaseURL)
+	} else {
+		err = p.SetContent(buf.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = p.timeoutOr(DefaultTemplateTimeout)
+	}
+	if idle, err := p.waitNetworkIdle(DefaultNetworkIdleTime, 0, timeout); err != nil {
+		return nil, err
+	} else if !idle {
+		return nil, ErrTemplateTimeout
+	}
+	return p.RenderWithOptions(opts.Render)
+}
@@ -0,0 +1,252 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TextMatch represents a match of FindText.
+type TextMatch struct {
+	// Matched text and the text of its capturing groups.
+	Text       string
+	Submatches []string
+
+	// Text of the text node that contains the match, with whitespace
+	// collapsed.
+	NodeText string
+
+	// CSS selector and bounding box, in page coordinates, of the element
+	// that contains the text node.
+	Selector string
+	Rect     Rect
+}
+
+// FindText returns the matches of re in the rendered text of the page, in
+// document order. Each text node is searched separately with its whitespace
+// collapsed, so text split across elements, such as "<b>$</b>12", does not
+// match as a whole. Text of scripts, styles and elements that are not
+// rendered is skipped.
+//
+// Matches carry the selector and position of their element, for extracting
+// values near a label:
+//
+//	matches, err := page.FindText(regexp.MustCompile(`^Price:`))
+//	// Read the next sibling of matches[0].Selector.
+func (p *WebPage) FindText(re *regexp.Regexp) ([]TextMatch, error) {
+	var nodes []struct {
+		Text     string   `json:"text"`
+		Selector string   `json:"selector"`
+		Rect     rectJSON `json:"rect"`
+	}
+	if err := p.evaluateInto(textNodesScript, &nodes); err != nil {
+		return nil, err
+	}
+
+	var matches []TextMatch
+	for _, n := range nodes {
+		for _, m := range re.FindAllStringSubmatch(n.Text, -1) {
+			matches = append(matches, TextMatch{
+				Text:       m[0],
+				Submatches: m[1:],
+				NodeText:   n.Text,
+				Selector:   n.Selector,
+				Rect:       Rect{Top: n.Rect.Top, Left: n.Rect.Left, Width: n.Rect.Width, Height: n.Rect.Height},
+			})
+		}
+	}
+	return matches, nil
+}
+
+// textNodesScript returns the rendered text nodes of the page with the
+// selectors and bounding boxes of their elements. Selectors use the same form
+// as the selectors of DiffSnapshots.
+const textNodesScript = `function() {
+	var selector = ` + selectorFunc + `;
+
+	var nodes = [];
+	var walker = document.createTreeWalker(document.body || document.documentElement, NodeFilter.SHOW_TEXT, null, false);
+	for (var node = walker.nextNode(); node; node = walker.nextNode()) {
+		var el = node.parentElement, tag = el.tagName;
+		if (tag === 'SCRIPT' || tag === 'STYLE' || tag === 'NOSCRIPT') continue;
+		var text = node.nodeValue.replace(/\s+/g, ' ').replace(/^ | $/g, '');
+		if (!text) continue;
+		var r = el.getBoundingClientRect();
+		if (r.width === 0 && r.height === 0) continue;
+		nodes.push({
+			text: text,
+			selector: selector(el),
+			rect: {top: Math.round(r.top + window.pageYOffset), left: Math.round(r.left + window.pageXOffset), width: Math.round(r.width), height: Math.round(r.height)},
+		});
+	}
+	return nodes;
+}`
+
+// selectorFunc is a JavaScript function expression that returns the selector
+// of an element, in the form returned by DiffSnapshots.
+const selectorFunc = `function selector(el) {
+	if (el.id && /^[A-Za-z_][A-Za-z0-9_-]*$/.test(el.id)) return el.tagName.toLowerCase() + '#' + el.id;
+	var parent = el.parentElement;
+	if (!parent) return el.tagName.toLowerCase();
+	var nth = 1;
+	for (var s = el.previousElementSibling; s; s = s.previousElementSibling) nth++;
+	return selector(parent) + ' > ' + el.tagName.toLowerCase() + ':nth-child(' + nth + ')';
+}`
+
+// TextOptions represents the options of WebPage.TextContent.
+type TextOptions struct {
+	// Mark headings with "#" by level, prefix list items with "-" or their
+	// number and indent nested lists. Otherwise headings and list items are
+	// plain lines.
+	Headings bool
+	Lists    bool
+
+	// Append the target of links after their text, as in "docs
+	// (https://example.com/docs)".
+	Links bool
+
+	// Selectors of elements to leave out, such as "nav", "footer" or
+	// "[role=banner]".
+	Exclude []string
+}
+
+// TextContent returns the rendered text of the page's body for indexing.
+// Unlike PlainText, block elements are separated by blank lines, whitespace
+// within them is collapsed, and scripts, styles, form controls, hidden and
+// excluded elements are skipped. Table rows are written as lines of
+// tab-separated cells.
+func (p *WebPage) TextContent(opts TextOptions) (string, error) {
+	buf, err := json.Marshal(map[string]interface{}{
+		"headings": opts.Headings,
+		"lists":    opts.Lists,
+		"links":    opts.Links,
+		"exclude":  strings.Join(opts.Exclude, ", "),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var s string
+	if err := p.evaluateInto(fmt.Sprintf(textContentScript, buf), &s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// textContentScript returns the text of the body, formatted as set by the
+// options.
+const textContentScript = `function() {
+	var opts = %s;
+	var BLOCK = /^(ADDRESS|ARTICLE|ASIDE|BLOCKQUOTE|BODY|DD|DETAILS|DIV|DL|DT|FIELDSET|FIGCAPTION|FIGURE|FOOTER|FORM|H[1-6]|HEADER|HR|LI|MAIN|NAV|OL|P|PRE|SECTION|SUMMARY|TABLE|UL)$/;
+	var SKIP = /^(SCRIPT|STYLE|NOSCRIPT|TEMPLATE|HEAD|INPUT|SELECT|TEXTAREA|BUTTON|IFRAME|SVG|CANVAS)$/i;
+
+	var excluded = [];
+	if (opts.exclude) {
+		var nodes = document.querySelectorAll(opts.exclude);
+		for (var i = 0; i < nodes.length; i++) excluded.push(nodes[i]);
+	}
+
+	var repeat = function(s, n) { return new Array(n + 1).join(s); };
+	var collapse = function(s) { return s.replace(/\s+/g, ' ').replace(/^ | $/g, ''); };
+	var skip = function(el) {
+		if (SKIP.test(el.tagName) || excluded.indexOf(el) !== -1) return true;
+		var style = window.getComputedStyle(el);
+		return style.display === 'none' || style.visibility === 'hidden';
+	};
+
+	// Returns the text of an inline node. Line breaks are kept as newlines.
+	var inlineNode = function(c) {
+		if (c.nodeType === 3) return c.nodeValue.replace(/\s+/g, ' ');
+		if (c.nodeType !== 1 || skip(c)) return '';
+		if (c.tagName === 'BR') return '\n';
+		var s = inline(c);
+		if (c.tagName === 'A' && opts.links && c.getAttribute('href') && !/^(javascript:|#)/i.test(c.getAttribute('href'))) {
+			s = collapse(s);
+			return s && s !== c.href ? s + ' (' + c.href + ')' : c.href;
+		}
+		return s;
+	};
+	var inline = function(el) {
+		var out = '';
+		for (var c = el.firstChild; c; c = c.nextSibling) out += inlineNode(c);
+		return out;
+	};
+
+	// Returns the blocks of an element's children. Runs of inline content
+	// between child blocks form paragraphs.
+	var blocks = function(el) {
+		var out = [], buf = '';
+		var flush = function() {
+			var s = buf.replace(/[ \t]+/g, ' ').replace(/ ?\n ?/g, '\n').replace(/^\s+|\s+$/g, '');
+			if (s) out.push(s);
+			buf = '';
+		};
+		for (var c = el.firstChild; c; c = c.nextSibling) {
+			if (c.nodeType === 1 && BLOCK.test(c.tagName) && !skip(c)) {
+				flush();
+				out = out.concat(block(c));
+			} else {
+				buf += inlineNode(c);
+			}
+		}
+		flush();
+		return out;
+	};
+
+	// Returns the blocks of an element.
+	var block = function(el) {
+		var t = el.tagName, s;
+		if (/^H[1-6]$/.test(t)) {
+			s = collapse(inline(el));
+			if (s && opts.headings) s = repeat('#', Number(t.charAt(1))) + ' ' + s;
+			return s ? [s] : [];
+		}
+		switch (t) {
+		case 'HR':
+			return [];
+		case 'PRE':
+			s = el.textContent.replace(/^\n+|\s+$/g, '');
+			return s ? [s] : [];
+		case 'UL': case 'OL':
+			s = list(el);
+			return s ? [s] : [];
+		case 'TABLE':
+			s = table(el);
+			return s ? [s] : [];
+		default:
+			return blocks(el);
+		}
+	};
+
+	var list = function(el) {
+		var lines = [], n = el.tagName === 'OL' && el.start ? el.start : 1;
+		for (var c = el.firstChild; c; c = c.nextSibling) {
+			if (c.nodeType !== 1 || c.tagName !== 'LI' || skip(c)) continue;
+			var s = blocks(c).join('\n');
+			if (!opts.lists) {
+				if (s) lines.push(s);
+				continue;
+			}
+			var marker = el.tagName === 'OL' ? (n++) + '. ' : '- ';
+			lines.push(marker + s.split('\n').join('\n' + repeat(' ', marker.length)));
+		}
+		return lines.join('\n');
+	};
+
+	var table = function(el) {
+		var lines = [];
+		for (var i = 0; i < el.rows.length; i++) {
+			if (skip(el.rows[i])) continue;
+			var cells = [];
+			for (var j = 0; j < el.rows[i].cells.length; j++) {
+				if (!skip(el.rows[i].cells[j])) cells.push(collapse(inline(el.rows[i].cells[j])));
+			}
+			if (cells.join('')) lines.push(cells.join('\t'));
+		}
+		return lines.join('\n');
+	};
+
+	if (!document.body || skip(document.body)) return '';
+	return block(document.body).join('\n\n');
+}`
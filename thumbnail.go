@@ -0,0 +1,170 @@
+package phantomjs
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+// Thumbnail modes for RenderThumbnail.
+const (
+	// ThumbnailCover scales the viewport to cover the thumbnail and crops
+	// the overflow equally from both sides.
+	ThumbnailCover = "cover"
+
+	// ThumbnailContain scales the viewport to fit within the thumbnail and
+	// fills the remaining space with white.
+	ThumbnailContain = "contain"
+
+	// ThumbnailTopCrop scales the full page to cover the thumbnail and keeps
+	// its top, which suits the above-the-fold content of long pages.
+	ThumbnailTopCrop = "topcrop"
+)
+
+var (
+	// ErrInvalidThumbnail is returned by RenderThumbnail when the size is not
+	// positive or the mode is unknown.
+	ErrInvalidThumbnail = errors.New("invalid thumbnail size or mode")
+)
+
+// RenderThumbnail renders the page and returns a PNG thumbnail of exactly
+// width by height pixels, for link previews. The page is rendered at full
+// resolution and downsampled in Go by averaging, so thumbnails are
+// consistent regardless of the page's zoom factor. mode selects how the
+// render is fitted, such as ThumbnailCover.
+func (p *WebPage) RenderThumbnail(width, height int, mode string) ([]byte, error) {
+	if width <= 0 || height <= 0 {
+		return nil, ErrInvalidThumbnail
+	}
+	opts := RenderOptions{Format: "PNG", OnlyViewport: true}
+	switch mode {
+	case ThumbnailCover, ThumbnailContain:
+	case ThumbnailTopCrop:
+		opts.OnlyViewport = false
+	default:
+		return nil, ErrInvalidThumbnail
+	}
+
+	data, err := p.RenderWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, thumbnail(src, width, height, mode)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnail fits src into a width by height image as set by mode.
+func thumbnail(src image.Image, width, height int, mode string) *image.RGBA {
+	sb := src.Bounds()
+	sw, sh := float64(sb.Dx()), float64(sb.Dy())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if sb.Empty() {
+		return dst
+	}
+
+	if mode == ThumbnailContain {
+		s := min(float64(width)/sw, float64(height)/sh)
+		w, h := max(1, int(sw*s+0.5)), max(1, int(sh*s+0.5))
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		off := image.Pt((width-w)/2, (height-h)/2)
+		draw.Draw(dst, image.Rectangle{Min: off, Max: off.Add(image.Pt(w, h))}, resize(src, w, h), image.Point{}, draw.Src)
+		return dst
+	}
+
+	// Crop the source to the thumbnail's aspect ratio, then scale it.
+	s := max(float64(width)/sw, float64(height)/sh)
+	cw, ch := min(sb.Dx(), int(float64(width)/s+0.5)), min(sb.Dy(), int(float64(height)/s+0.5))
+	crop := image.Rect(0, 0, cw, ch).Add(sb.Min).Add(image.Pt((sb.Dx()-cw)/2, 0))
+	if mode != ThumbnailTopCrop {
+		crop = crop.Add(image.Pt(0, (sb.Dy()-ch)/2))
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, cw, ch))
+	draw.Draw(rgba, rgba.Bounds(), src, crop.Min, draw.Src)
+	draw.Draw(dst, dst.Bounds(), resize(rgba, width, height), image.Point{}, draw.Src)
+	return dst
+}
+
+// resize scales src to w by h. Each destination pixel is the average of the
+// source pixels it covers, weighted by coverage.
+func resize(src image.Image, w, h int) *image.RGBA {
+	sb := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || sb.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, sb.Dx(), sb.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, sb.Min, draw.Src)
+	}
+	sw, sh := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+	xw, yw := resizeWeights(sw, w), resizeWeights(sh, h)
+
+	// Scale rows horizontally, then columns vertically.
+	tmp := make([]float64, sh*w*4)
+	for y := 0; y < sh; y++ {
+		for x, ws := range xw {
+			var c [4]float64
+			for _, wt := range ws {
+				i := rgba.PixOffset(wt.index, y)
+				for k := range c {
+					c[k] += float64(rgba.Pix[i+k]) * wt.weight
+				}
+			}
+			copy(tmp[(y*w+x)*4:], c[:])
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, ws := range yw {
+		for x := 0; x < w; x++ {
+			var c [4]float64
+			for _, wt := range ws {
+				i := (wt.index*w + x) * 4
+				for k := range c {
+					c[k] += tmp[i+k] * wt.weight
+				}
+			}
+			i := dst.PixOffset(x, y)
+			for k := range c {
+				dst.Pix[i+k] = uint8(min(255, c[k]+0.5))
+			}
+		}
+	}
+	return dst
+}
+
+// resizeWeight is the share of a source pixel in a destination pixel.
+type resizeWeight struct {
+	index  int
+	weight float64
+}
+
+// resizeWeights returns the source pixels covered by each of n destination
+// pixels along a dimension of src pixels, with weights that sum to one.
+func resizeWeights(src, n int) [][]resizeWeight {
+	scale := float64(src) / float64(n)
+	weights := make([][]resizeWeight, n)
+	for i := range weights {
+		lo, hi := float64(i)*scale, float64(i+1)*scale
+		if scale < 1 {
+			// Upscaling: sample the nearest source pixel.
+			weights[i] = []resizeWeight{{index: min(src-1, int(lo+scale/2)), weight: 1}}
+			continue
+		}
+		for j := int(lo); j < src && float64(j) < hi; j++ {
+			w := min(hi, float64(j+1)) - max(lo, float64(j))
+			if w > 0 {
+				weights[i] = append(weights[i], resizeWeight{index: j, weight: w / scale})
+			}
+		}
+	}
+	return weights
+}
@@ -0,0 +1,111 @@
+package phantomjs
+
+import (
+	"time"
+)
+
+// PerformanceTiming represents the load timing of a page.
+//
+// Navigation timing is read from window.performance.timing. Durations are
+// zero when the browser does not report both of their endpoints, such as
+// Load before the load event has finished.
+type PerformanceTiming struct {
+	// Time the navigation started.
+	NavigationStart time.Time
+
+	// Durations of the DNS lookup and TCP connection.
+	DNS     time.Duration
+	Connect time.Duration
+
+	// Time from sending the request to the first byte of the response and
+	// from the first byte to the last byte.
+	TTFB     time.Duration
+	Download time.Duration
+
+	// Time from the start of navigation to the end of the DOMContentLoaded
+	// and load events.
+	DOMContentLoaded time.Duration
+	Load             time.Duration
+
+	// Wall-clock start and duration of the last call to Open as measured by
+	// PhantomJS. Zero if the page has not been opened with Open.
+	OpenStarted  time.Time
+	OpenDuration time.Duration
+}
+
+// PerformanceTiming returns the load timing of the page's current document.
+func (p *WebPage) PerformanceTiming() (*PerformanceTiming, error) {
+	var v struct {
+		NavigationStart          int64 `json:"navigationStart"`
+		DomainLookupStart        int64 `json:"domainLookupStart"`
+		DomainLookupEnd          int64 `json:"domainLookupEnd"`
+		ConnectStart             int64 `json:"connectStart"`
+		ConnectEnd               int64 `json:"connectEnd"`
+		RequestStart             int64 `json:"requestStart"`
+		ResponseStart            int64 `json:"responseStart"`
+		ResponseEnd              int64 `json:"responseEnd"`
+		DOMContentLoadedEventEnd int64 `json:"domContentLoadedEventEnd"`
+		LoadEventEnd             int64 `json:"loadEventEnd"`
+	}
+	if err := p.evaluateInto(performanceTimingScript, &v); err != nil {
+		return nil, err
+	}
+
+	var open struct {
+		Start int64 `json:"start"`
+		End   int64 `json:"end"`
+	}
+	if err := p.doJSON("POST", "/webpage/OpenTiming", map[string]interface{}{"ref": p.ref.id}, &open); err != nil {
+		return nil, err
+	}
+
+	t := &PerformanceTiming{
+		DNS:              msDuration(v.DomainLookupStart, v.DomainLookupEnd),
+		Connect:          msDuration(v.ConnectStart, v.ConnectEnd),
+		TTFB:             msDuration(v.RequestStart, v.ResponseStart),
+		Download:         msDuration(v.ResponseStart, v.ResponseEnd),
+		DOMContentLoaded: msDuration(v.NavigationStart, v.DOMContentLoadedEventEnd),
+		Load:             msDuration(v.NavigationStart, v.LoadEventEnd),
+		OpenDuration:     msDuration(open.Start, open.End),
+	}
+	if v.NavigationStart > 0 {
+		t.NavigationStart = msTime(v.NavigationStart)
+	}
+	if open.Start > 0 {
+		t.OpenStarted = msTime(open.Start)
+	}
+	return t, nil
+}
+
+// msTime converts milliseconds since the Unix epoch to a time.
+func msTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// msDuration returns the duration between two millisecond timestamps. Returns
+// zero if either timestamp is unset or end precedes start.
+func msDuration(start, end int64) time.Duration {
+	if start <= 0 || end < start {
+		return 0
+	}
+	return time.Duration(end-start) * time.Millisecond
+}
+
+// performanceTimingScript returns the page's navigation timing or null if the
+// browser does not support it.
+const performanceTimingScript = `function() {
+	var t = window.performance && window.performance.timing;
+	if (!t) return null;
+	return {
+		navigationStart: t.navigationStart,
+		domainLookupStart: t.domainLookupStart,
+		domainLookupEnd: t.domainLookupEnd,
+		connectStart: t.connectStart,
+		connectEnd: t.connectEnd,
+		requestStart: t.requestStart,
+		responseStart: t.responseStart,
+		responseEnd: t.responseEnd,
+		domContentLoadedEventEnd: t.domContentLoadedEventEnd,
+		loadEventEnd: t.loadEventEnd
+	};
+}`
@@ -0,0 +1,143 @@
+package phantomjs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RenderTransport is an http.RoundTripper that satisfies requests by opening
+// the URL in a page from a pool and returning the rendered HTML as the
+// response body. It allows existing HTTP clients to render JavaScript by
+// swapping their transport:
+//
+//	client := &http.Client{Transport: &phantomjs.RenderTransport{Pool: pool}}
+//
+// Only GET and HEAD requests are supported. Request headers, including
+// cookies added by the client's jar, are sent as custom headers. Responses
+// have the status and headers of the page's main document, except for
+// hop-by-hop headers and those describing the original body, such as
+// Content-Encoding.
+type RenderTransport struct {
+	// Pool provides the pages used to render requests.
+	Pool *Pool
+
+	// Time to wait after the page loads before capturing its content.
+	Wait time.Duration
+}
+
+// RoundTrip renders the request's URL and returns the page content.
+func (t *RenderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		return nil, fmt.Errorf("phantomjs: unsupported method: %s", req.Method)
+	}
+
+	ctx := req.Context()
+	page, err := t.Pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Pool.Put(page)
+
+	if len(req.Header) > 0 {
+		if err := page.SetCustomHeaders(req.Header); err != nil {
+			return nil, err
+		}
+	}
+	res, err := page.OpenURL(req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for additional scripts to run.
+	if t.Wait > 0 {
+		timer := time.NewTimer(t.Wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	content, err := page.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	// Pages without an HTTP response, such as file URLs, are reported as OK.
+	code, text := res.StatusCode, res.StatusText
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if text == "" {
+		text = http.StatusText(code)
+	}
+
+	resp := &http.Response{
+		Status:        strconv.Itoa(code) + " " + text,
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        responseHeader(res.Header),
+		Body:          io.NopCloser(strings.NewReader(content)),
+		ContentLength: int64(len(content)),
+		Request:       req,
+	}
+	resp.Header.Set("Content-Type", "text/html; charset=utf-8")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(content)))
+
+	// Report the final URL if the page was redirected.
+	if u, err := page.URL(); err == nil && u != "" && u != req.URL.String() {
+		resp.Header.Set("Content-Location", u)
+	}
+
+	if req.Method == "HEAD" {
+		resp.Body = http.NoBody
+	}
+	return resp, nil
+}
+
+// hopHeaders are the headers of the page's response that are not passed on,
+// either because they are hop-by-hop or because they describe the original
+// body rather than the rendered content.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Content-Encoding",
+	"Content-Length",
+	"Content-Md5",
+	"Content-Range",
+	"Etag",
+}
+
+// responseHeader returns the headers of the page's response to pass on.
+func responseHeader(src http.Header) http.Header {
+	h := src.Clone()
+	if h == nil {
+		return make(http.Header)
+	}
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+	return h
+}
@@ -0,0 +1,89 @@
+package phantomjs_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Ensure an HTTP client can fetch rendered content through the transport.
+func TestRenderTransport_RoundTrip(t *testing.T) {
+	var opened string
+	var headers map[string]string
+	p, srv := NewStubProcess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/SetCustomHeaders":
+			var req struct {
+				Headers map[string]string `json:"headers"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			headers = req.Headers
+			w.Write([]byte(`{}`))
+		case "/webpage/Open":
+			var req struct {
+				URL string `json:"url"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			opened = req.URL
+			w.Write([]byte(`{"status":"success","statusCode":404,"statusText":"Not Found","headers":[` +
+				`{"name":"Cache-Control","value":"max-age=60"},` +
+				`{"name":"Content-Encoding","value":"gzip"},` +
+				`{"name":"Connection","value":"X-Hop"},` +
+				`{"name":"X-Hop","value":"1"}]}`))
+		case "/webpage/Content":
+			w.Write([]byte(`{"value":"<html><body>RENDERED</body></html>"}`))
+		case "/webpage/URL":
+			w.Write([]byte(`{"value":"http://example.com/final"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: &phantomjs.RenderTransport{Pool: phantomjs.NewPool(1, p)}}
+	req, _ := http.NewRequest("GET", "http://example.com/start", nil)
+	req.Header.Set("X-Test", "1")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "<html><body>RENDERED</body></html>" {
+		t.Fatalf("unexpected body: %s", body)
+	} else if opened != "http://example.com/start" {
+		t.Fatalf("unexpected url opened: %s", opened)
+	} else if headers["X-Test"] != "1" {
+		t.Fatalf("unexpected headers: %v", headers)
+	} else if v := resp.Header.Get("Content-Location"); v != "http://example.com/final" {
+		t.Fatalf("unexpected content location: %s", v)
+	}
+
+	// The page's status and end-to-end headers are passed on.
+	if resp.StatusCode != http.StatusNotFound || resp.Status != "404 Not Found" {
+		t.Fatalf("unexpected status: %s", resp.Status)
+	} else if v := resp.Header.Get("Cache-Control"); v != "max-age=60" {
+		t.Fatalf("unexpected cache control: %s", v)
+	} else if v := resp.Header.Get("Content-Type"); v != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected content type: %s", v)
+	}
+	for _, name := range []string{"Content-Encoding", "Connection", "X-Hop"} {
+		if v := resp.Header.Get(name); v != "" {
+			t.Fatalf("unexpected %s header: %s", name, v)
+		}
+	}
+}
+
+// Ensure unsupported methods are rejected.
+func TestRenderTransport_RoundTrip_ErrMethod(t *testing.T) {
+	client := &http.Client{Transport: &phantomjs.RenderTransport{Pool: phantomjs.NewPool(1)}}
+	if _, err := client.Post("http://example.com", "text/plain", nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,324 @@
+package phantomjs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrCyclicValue is returned by EvaluateValue when the value contains a
+	// cyclic reference and ValuePolicy.RejectCycles is set.
+	ErrCyclicValue = errors.New("cyclic value")
+)
+
+// DefaultValueDepth is the nesting depth used by EvaluateValue if the policy
+// does not set one.
+const DefaultValueDepth = 32
+
+// Kinds of values returned by EvaluateValue.
+const (
+	KindUndefined  = "undefined"
+	KindNull       = "null"
+	KindBool       = "boolean"
+	KindNumber     = "number"
+	KindString     = "string"
+	KindDate       = "date"
+	KindRegExp     = "regexp"
+	KindFunction   = "function"
+	KindError      = "error"
+	KindNode       = "node"
+	KindArray      = "array"
+	KindTypedArray = "typedarray"
+	KindObject     = "object"
+
+	// KindRef is a cyclic reference to an enclosing value.
+	KindRef = "ref"
+
+	// KindTruncated is a value nested deeper than the policy's MaxDepth.
+	KindTruncated = "truncated"
+)
+
+// Value represents a JavaScript value with its type information, including
+// values that JSON cannot represent.
+type Value struct {
+	// Kind of value, such as KindNumber.
+	Kind string
+
+	Bool bool
+
+	// Number, including NaN, infinities and negative zero.
+	Number float64
+
+	// Text of strings, the source of regular expressions, the name of
+	// functions, the message of errors and the description of DOM nodes
+	// such as "div#main.content".
+	String string
+
+	// Time of dates. Zero for invalid dates.
+	Time time.Time
+
+	// Constructor name of objects, typed arrays and errors, such as
+	// "Uint8Array" or "TypeError".
+	Class string
+
+	// Elements of arrays and typed arrays.
+	Elems []Value
+
+	// Properties of objects, in enumeration order.
+	Fields []Field
+
+	// Path of the enclosing value that a KindRef refers to, such as "$" for
+	// the root or "$.a[0]".
+	Ref string
+}
+
+// Field represents a property of an object value.
+type Field struct {
+	Name  string
+	Value Value
+}
+
+// Get returns the value of an object's property.
+func (v Value) Get(name string) (Value, bool) {
+	for _, f := range v.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// Interface returns the value as plain Go values: nil for undefined and null,
+// float64 for numbers, time.Time for dates, []interface{} for arrays and
+// typed arrays and map[string]interface{} for objects. Regular expressions,
+// functions, errors and nodes are returned as their String. Cyclic and
+// truncated values are returned as nil.
+func (v Value) Interface() interface{} {
+	switch v.Kind {
+	case KindBool:
+		return v.Bool
+	case KindNumber:
+		return v.Number
+	case KindString, KindRegExp, KindFunction, KindError, KindNode:
+		return v.String
+	case KindDate:
+		return v.Time
+	case KindArray, KindTypedArray:
+		a := make([]interface{}, len(v.Elems))
+		for i := range v.Elems {
+			a[i] = v.Elems[i].Interface()
+		}
+		return a
+	case KindObject:
+		m := make(map[string]interface{}, len(v.Fields))
+		for _, f := range v.Fields {
+			m[f.Name] = f.Value.Interface()
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
+// ValuePolicy controls how EvaluateValue encodes values.
+type ValuePolicy struct {
+	// Maximum nesting depth of arrays and objects. Deeper values are
+	// returned as KindTruncated. Defaults to DefaultValueDepth.
+	MaxDepth int
+
+	// If true, EvaluateValue returns ErrCyclicValue for values with cyclic
+	// references instead of returning KindRef values.
+	RejectCycles bool
+}
+
+// ScriptError is returned by EvaluateValue when the script throws.
+type ScriptError struct {
+	Name    string
+	Message string
+}
+
+// Error returns the name and message of the thrown error.
+func (e *ScriptError) Error() string {
+	if e.Name == "" {
+		return e.Message
+	}
+	return e.Name + ": " + e.Message
+}
+
+// EvaluateValue executes a JavaScript function in the context of the web page
+// like Evaluate and returns its value with type information. Values that do
+// not round-trip through JSON, such as undefined, NaN, dates, typed arrays and
+// cyclic structures, are encoded in the page according to policy.
+func (p *WebPage) EvaluateValue(script string, policy ValuePolicy) (Value, error) {
+	depth := policy.MaxDepth
+	if depth <= 0 {
+		depth = DefaultValueDepth
+	}
+
+	var s string
+	if err := p.evaluateInto(fmt.Sprintf(evaluateValueScript, depth, policy.RejectCycles, script), &s); err != nil {
+		return Value{}, err
+	}
+
+	var resp struct {
+		Value  *valueJSON `json:"value"`
+		Cycle  string     `json:"cycle"`
+		Thrown *struct {
+			Name    string `json:"name"`
+			Message string `json:"message"`
+		} `json:"thrown"`
+	}
+	if err := json.Unmarshal([]byte(s), &resp); err != nil {
+		return Value{}, err
+	} else if resp.Cycle != "" {
+		return Value{}, ErrCyclicValue
+	} else if resp.Thrown != nil {
+		return Value{}, &ScriptError{Name: resp.Thrown.Name, Message: resp.Thrown.Message}
+	} else if resp.Value == nil {
+		return Value{Kind: KindUndefined}, nil
+	}
+	return resp.Value.value(), nil
+}
+
+// valueJSON is the tagged encoding of a value produced by evaluateValueScript.
+type valueJSON struct {
+	Type    string          `json:"t"`
+	Class   string          `json:"c"`
+	Special string          `json:"s"`
+	Value   json.RawMessage `json:"v"`
+}
+
+// value decodes the encoded value.
+func (v *valueJSON) value() Value {
+	val := Value{Kind: v.Type, Class: v.Class}
+	switch v.Type {
+	case KindBool:
+		json.Unmarshal(v.Value, &val.Bool)
+	case KindNumber:
+		switch v.Special {
+		case "NaN":
+			val.Number = math.NaN()
+		case "Infinity":
+			val.Number = math.Inf(1)
+		case "-Infinity":
+			val.Number = math.Inf(-1)
+		case "-0":
+			val.Number = math.Copysign(0, -1)
+		default:
+			json.Unmarshal(v.Value, &val.Number)
+		}
+	case KindString, KindRegExp, KindFunction, KindError, KindNode:
+		json.Unmarshal(v.Value, &val.String)
+	case KindDate:
+		var s string
+		if json.Unmarshal(v.Value, &s) == nil {
+			val.Time, _ = time.Parse(time.RFC3339Nano, s)
+		}
+	case KindArray, KindTypedArray:
+		var a []*valueJSON
+		json.Unmarshal(v.Value, &a)
+		for _, e := range a {
+			val.Elems = append(val.Elems, e.value())
+		}
+	case KindObject:
+		var a []struct {
+			Key   string     `json:"k"`
+			Value *valueJSON `json:"v"`
+		}
+		json.Unmarshal(v.Value, &a)
+		for _, f := range a {
+			val.Fields = append(val.Fields, Field{Name: f.Key, Value: f.Value.value()})
+		}
+	case KindRef:
+		json.Unmarshal(v.Value, &val.Ref)
+	}
+	return val
+}
+
+// evaluateValueScript calls a function and returns its value as a JSON string
+// of tagged values, so that type information survives the trip out of the
+// page. Takes the maximum depth, whether to reject cycles and the function.
+const evaluateValueScript = `function() {
+	var maxDepth = %d, rejectCycles = %t;
+	var stack = [], paths = [];
+
+	function describe(node) {
+		var s = (node.nodeName || '').toLowerCase();
+		if (node.id) s += '#' + node.id;
+		if (typeof node.className === 'string' && node.className) s += '.' + node.className.trim().split(/\s+/).join('.');
+		return s;
+	}
+
+	function number(n) {
+		if (n !== n) return {t: 'number', s: 'NaN'};
+		if (n === Infinity) return {t: 'number', s: 'Infinity'};
+		if (n === -Infinity) return {t: 'number', s: '-Infinity'};
+		if (n === 0 && 1 / n < 0) return {t: 'number', s: '-0'};
+		return {t: 'number', v: n};
+	}
+
+	function encode(v, path, depth) {
+		if (v === undefined) return {t: 'undefined'};
+		if (v === null) return {t: 'null'};
+		switch (typeof v) {
+			case 'boolean': return {t: 'boolean', v: v};
+			case 'number': return number(v);
+			case 'string': return {t: 'string', v: v};
+			case 'function': return {t: 'function', v: v.name || ''};
+			case 'object': break;
+			default: return {t: 'string', v: String(v)};
+		}
+
+		var i = stack.indexOf(v);
+		if (i !== -1) {
+			if (rejectCycles) throw {cycle: paths[i]};
+			return {t: 'ref', v: paths[i]};
+		}
+
+		var tag = Object.prototype.toString.call(v).slice(8, -1);
+		if (tag === 'Date') return {t: 'date', v: isNaN(v.getTime()) ? null : v.toISOString()};
+		if (tag === 'RegExp') return {t: 'regexp', v: String(v)};
+		if (v instanceof Error) return {t: 'error', c: v.name, v: String(v.message)};
+		if (typeof Node !== 'undefined' && v instanceof Node) return {t: 'node', v: describe(v)};
+		if (depth >= maxDepth) return {t: 'truncated'};
+		if (/^(Int8|Uint8|Uint8Clamped|Int16|Uint16|Int32|Uint32|Float32|Float64)Array$/.test(tag)) {
+			return {t: 'typedarray', c: tag, v: Array.prototype.slice.call(v).map(number)};
+		}
+
+		stack.push(v);
+		paths.push(path);
+		try {
+			if (Array.isArray(v)) {
+				var a = [];
+				for (var j = 0; j < v.length; j++) a.push(encode(v[j], path + '[' + j + ']', depth + 1));
+				return {t: 'array', v: a};
+			}
+			var fields = Object.keys(v).map(function(k) {
+				var f;
+				try { f = v[k]; } catch (e) { f = undefined; }
+				return {k: k, v: encode(f, path + '.' + k, depth + 1)};
+			});
+			var c = v.constructor && v.constructor.name;
+			return {t: 'object', c: c && c !== 'Object' ? c : undefined, v: fields};
+		} finally {
+			stack.pop();
+			paths.pop();
+		}
+	}
+
+	var value;
+	try {
+		value = (%s)();
+	} catch (e) {
+		return JSON.stringify({thrown: {name: (e && e.name) || '', message: String(e && e.message !== undefined ? e.message : e)}});
+	}
+	try {
+		return JSON.stringify({value: encode(value, '$', 0)});
+	} catch (e) {
+		if (e && e.cycle) return JSON.stringify({cycle: e.cycle});
+		throw e;
+	}
+}`
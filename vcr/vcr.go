@@ -0,0 +1,218 @@
+// Package vcr records the RPC traffic between a phantomjs.Process and the
+// PhantomJS shim and replays it later without a running PhantomJS binary.
+//
+// To record, wrap the process transport before opening it:
+//
+//	f, _ := os.Create("testdata/session.jsonl")
+//	p := phantomjs.NewProcess()
+//	p.Transport = vcr.NewRecorder(f, nil)
+//
+// To replay, set a Replayer as the transport and use the process without
+// calling Open:
+//
+//	r, _ := vcr.Load("testdata/session.jsonl")
+//	p := phantomjs.NewProcess()
+//	p.Transport = r
+package vcr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Interaction represents a single recorded RPC request and its response.
+type Interaction struct {
+	Method       string        `json:"method"`
+	Path         string        `json:"path"`
+	Request      string        `json:"request,omitempty"`
+	StatusCode   int           `json:"statusCode"`
+	Response     string        `json:"response"`
+	Duration     time.Duration `json:"duration"`
+	ResponseTime time.Time     `json:"responseTime"`
+}
+
+// Recorder is an http.RoundTripper that records every request and response
+// as a line of JSON.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+
+	// Transport used to send requests. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// NewRecorder returns a recorder that writes interactions to w.
+// If next is nil then http.DefaultTransport is used.
+func NewRecorder(w io.Writer, next http.RoundTripper) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w), Transport: next}
+}
+
+// RoundTrip sends req through the underlying transport and records it.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Buffer request body so it can be recorded and resent.
+	var reqBody []byte
+	if req.Body != nil {
+		buf, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		reqBody = buf
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	t := time.Now()
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Buffer response body so it can be recorded and returned.
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(Interaction{
+		Method:       req.Method,
+		Path:         req.URL.Path,
+		Request:      string(reqBody),
+		StatusCode:   resp.StatusCode,
+		Response:     string(body),
+		Duration:     time.Since(t),
+		ResponseTime: time.Now().UTC(),
+	}); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Replayer is an http.RoundTripper that serves recorded responses.
+//
+// Requests are matched against unused interactions with the same method,
+// path and request body. If no exact match is found then the next unused
+// interaction with the same method and path is used, unless Strict is set.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+
+	// If true, request bodies must match the recording exactly.
+	Strict bool
+}
+
+// NewReplayer returns a replayer that serves interactions read from r.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	var a []Interaction
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var i Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &i); err != nil {
+			return nil, err
+		}
+		a = append(a, i)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &Replayer{interactions: a, used: make([]bool, len(a))}, nil
+}
+
+// Load returns a replayer for the recording at path.
+func Load(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplayer(f)
+}
+
+// RoundTrip returns the recorded response matching req.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		buf, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		body = buf
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.match(req.Method, req.URL.Path, string(body))
+	if i == -1 {
+		return nil, fmt.Errorf("vcr: no recorded interaction for %s %s: %s", req.Method, req.URL.Path, body)
+	}
+	r.used[i] = true
+
+	interaction := r.interactions[i]
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(interaction.Response))),
+		ContentLength: int64(len(interaction.Response)),
+		Request:       req,
+	}, nil
+}
+
+// match returns the index of the best unused interaction, or -1.
+func (r *Replayer) match(method, path, body string) int {
+	fallback := -1
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Method != method || interaction.Path != path {
+			continue
+		} else if interaction.Request == body {
+			return i
+		} else if fallback == -1 {
+			fallback = i
+		}
+	}
+	if r.Strict {
+		return -1
+	}
+	return fallback
+}
+
+// Remaining returns the number of interactions that have not been replayed.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int
+	for _, used := range r.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
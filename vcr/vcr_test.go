@@ -0,0 +1,69 @@
+package vcr_test
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/vcr"
+)
+
+// Ensure a recorded session can be replayed without a running server.
+func TestRecorder_Replay(t *testing.T) {
+	// Stub shim that serves two endpoints.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/webpage/Create":
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		case "/webpage/Title":
+			w.Write([]byte(`{"value":"TITLE"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+
+	// Record session.
+	var buf bytes.Buffer
+	p := phantomjs.NewProcess(phantomjs.WithPort(portN))
+	p.Transport = vcr.NewRecorder(&buf, nil)
+	if title := mustTitle(t, p); title != "TITLE" {
+		t.Fatalf("unexpected title: %s", title)
+	}
+	s.Close()
+
+	// Replay session against the recording.
+	r, err := vcr.NewReplayer(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Transport = r
+	if title := mustTitle(t, p); title != "TITLE" {
+		t.Fatalf("unexpected replayed title: %s", title)
+	} else if n := r.Remaining(); n != 0 {
+		t.Fatalf("unexpected remaining interactions: %d", n)
+	}
+
+	// Further requests have no recording.
+	if _, err := p.CreateWebPage(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func mustTitle(t *testing.T, p *phantomjs.Process) string {
+	page, err := p.CreateWebPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	title, err := page.Title()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return title
+}
@@ -0,0 +1,308 @@
+// Package warc records the network traffic of page loads to WARC files for
+// web archiving.
+//
+// Each response received by the page, including redirects and subresources,
+// is written as a request/response record pair:
+//
+//	w := warc.NewWriter(f)
+//	if err := w.WriteInfo(map[string]string{"software": "phantomjs"}); err != nil {
+//		log.Fatal(err)
+//	}
+//	if err := warc.RecordPage(w, page, "https://example.com"); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// PhantomJS does not expose response bodies, so bodies are captured with
+// phantomjs.WebPage.SetResourceCapture, which fetches each resource again.
+// Bodies are stored decoded, so Content-Encoding and Transfer-Encoding headers
+// are removed and Content-Length is rewritten to match. Responses whose body
+// cannot be fetched again are written without one.
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/phantomjs"
+)
+
+// Version is the WARC version written to record headers.
+const Version = "WARC/1.1"
+
+// Record types.
+const (
+	TypeInfo     = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+)
+
+// Record represents a single WARC record.
+type Record struct {
+	Type string
+
+	// Unique identifier in "<urn:uuid:...>" form. Generated if blank.
+	ID string
+
+	// Time the content was captured. Defaults to the current time.
+	Date time.Time
+
+	// Target URI of request and response records.
+	TargetURI string
+
+	// MIME type of Block.
+	ContentType string
+
+	// ID of a record captured at the same time, such as the request that
+	// a response answered.
+	ConcurrentTo string
+
+	// Record content.
+	Block []byte
+}
+
+// Writer writes WARC records to an underlying writer.
+type Writer struct {
+	w io.Writer
+
+	// If true, each record is written as a separate gzip member so the
+	// output can be read as a .warc.gz file.
+	Compress bool
+
+	// Receives resources that WritePage writes without a body or skips.
+	// If nil, nothing is logged.
+	Logger *slog.Logger
+}
+
+// NewWriter returns a new writer that writes records to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteInfo writes a warcinfo record with the given fields.
+func (w *Writer) WriteInfo(fields map[string]string) error {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, fields[k])
+	}
+	return w.WriteRecord(&Record{Type: TypeInfo, ContentType: "application/warc-fields", Block: buf.Bytes()})
+}
+
+// WriteRecord writes r. Blank IDs and dates are filled in on r.
+func (w *Writer) WriteRecord(r *Record) error {
+	if r.ID == "" {
+		id, err := NewRecordID()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+	}
+	if r.Date.IsZero() {
+		r.Date = time.Now()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\r\n", Version)
+	fmt.Fprintf(&buf, "WARC-Type: %s\r\n", r.Type)
+	fmt.Fprintf(&buf, "WARC-Record-ID: %s\r\n", r.ID)
+	fmt.Fprintf(&buf, "WARC-Date: %s\r\n", r.Date.UTC().Format(time.RFC3339))
+	if r.TargetURI != "" {
+		fmt.Fprintf(&buf, "WARC-Target-URI: %s\r\n", r.TargetURI)
+	}
+	if r.ConcurrentTo != "" {
+		fmt.Fprintf(&buf, "WARC-Concurrent-To: %s\r\n", r.ConcurrentTo)
+	}
+	if r.ContentType != "" {
+		fmt.Fprintf(&buf, "Content-Type: %s\r\n", r.ContentType)
+	}
+	fmt.Fprintf(&buf, "WARC-Block-Digest: %s\r\n", digest(r.Block))
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n", len(r.Block))
+	buf.WriteString("\r\n")
+	buf.Write(r.Block)
+	buf.WriteString("\r\n\r\n")
+
+	if !w.Compress {
+		_, err := w.w.Write(buf.Bytes())
+		return err
+	}
+
+	gw := gzip.NewWriter(w.w)
+	if _, err := gw.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Start enables capture of every resource on page. It must be called before
+// the page is opened.
+func Start(page *phantomjs.WebPage) error {
+	return page.SetResourceCapture(phantomjs.ResourceCapture{URLPatterns: []string{"."}})
+}
+
+// WritePage writes a request and response record for each resource captured
+// on page since Start was called. Resources that received no response, such as
+// aborted requests, are skipped, and responses whose body cannot be fetched
+// again are written without one.
+func (w *Writer) WritePage(page *phantomjs.WebPage) error {
+	responses, err := page.ResourceResponses()
+	if err != nil {
+		return err
+	}
+
+	for _, resp := range responses {
+		if resp.Status == 0 {
+			w.log("warc resource skipped", "url", resp.URL, "error", "no response")
+			continue
+		}
+
+		var body []byte
+		if resp.RedirectURL == "" {
+			body, _, err = page.Resource(resp.URL)
+			switch {
+			case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded), errors.Is(err, phantomjs.ErrTimeout):
+				return fmt.Errorf("%s: %s", resp.URL, err)
+			case err != nil:
+				w.log("warc resource written without body", "url", resp.URL, "error", err)
+				body = nil
+			}
+		}
+
+		req := &Record{
+			Type:        TypeRequest,
+			Date:        resp.Time,
+			TargetURI:   resp.URL,
+			ContentType: "application/http;msgtype=request",
+			Block:       requestBlock(resp),
+		}
+		if err := w.WriteRecord(req); err != nil {
+			return err
+		}
+
+		if err := w.WriteRecord(&Record{
+			Type:         TypeResponse,
+			Date:         resp.Time,
+			TargetURI:    resp.URL,
+			ContentType:  "application/http;msgtype=response",
+			ConcurrentTo: req.ID,
+			Block:        responseBlock(resp, body),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// log writes a warning to the writer's logger, if set.
+func (w *Writer) log(msg string, args ...interface{}) {
+	if w.Logger == nil {
+		return
+	}
+	w.Logger.Warn(msg, args...)
+}
+
+// RecordPage captures the resources of rawurl while it is opened on page and
+// writes them to w.
+func RecordPage(w *Writer, page *phantomjs.WebPage, rawurl string) error {
+	if err := Start(page); err != nil {
+		return err
+	} else if err := page.Open(rawurl); err != nil {
+		return fmt.Errorf("open %s: %s", rawurl, err)
+	}
+	return w.WritePage(page)
+}
+
+// requestBlock returns the HTTP request message for a response.
+func requestBlock(resp phantomjs.ResourceResponse) []byte {
+	method := resp.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	target, host := resp.URL, ""
+	if u, err := url.Parse(resp.URL); err == nil {
+		target, host = u.RequestURI(), u.Host
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", method, target)
+	if resp.RequestHeader.Get("Host") == "" && host != "" {
+		fmt.Fprintf(&buf, "Host: %s\r\n", host)
+	}
+	writeHeader(&buf, resp.RequestHeader)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// responseBlock returns the HTTP response message for a response. The
+// header is adjusted to match the decoded body.
+func responseBlock(resp phantomjs.ResourceResponse, body []byte) []byte {
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Del("Content-Encoding")
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	statusText := resp.StatusText
+	if statusText == "" {
+		statusText = http.StatusText(resp.Status)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", resp.Status, statusText)
+	writeHeader(&buf, header)
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// writeHeader writes header lines sorted by name.
+func writeHeader(w io.Writer, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(w, "%s: %s\r\n", k, strings.NewReplacer("\r", "", "\n", "").Replace(v))
+		}
+	}
+}
+
+// digest returns the WARC digest of b.
+func digest(b []byte) string {
+	h := sha1.Sum(b)
+	return "sha1:" + base32.StdEncoding.EncodeToString(h[:])
+}
+
+// NewRecordID returns a new random record ID.
+func NewRecordID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,118 @@
+package warc_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/phantomjs"
+	"github.com/benbjohnson/phantomjs/warc"
+)
+
+// Ensure captured responses and redirects are written as record pairs.
+func TestWriter_WritePage(t *testing.T) {
+	page := NewStubPage(t, map[string]string{
+		"/webpage/ResourceResponses": `{"value":[
+			{"url":"http://example.com/old","method":"GET","status":301,"statusText":"Moved Permanently","redirectURL":"http://example.com/","headers":[{"name":"Location","value":"/"}]},
+			{"url":"http://example.com/","method":"GET","status":200,"statusText":"OK","time":"2020-01-02T03:04:05.000Z",
+				"requestHeaders":[{"name":"Accept","value":"text/html"}],
+				"headers":[{"name":"Content-Type","value":"text/html"},{"name":"Content-Encoding","value":"gzip"}]}
+		]}`,
+		"/webpage/Resource": `{"found":true,"body":"PGh0bWw+PC9odG1sPg=="}`,
+	})
+
+	var buf bytes.Buffer
+	if err := warc.NewWriter(&buf).WritePage(page); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+
+	if n := strings.Count(s, "WARC/1.1\r\n"); n != 4 {
+		t.Fatalf("unexpected record count: %d", n)
+	} else if !strings.Contains(s, "HTTP/1.1 301 Moved Permanently\r\nContent-Length: 0\r\nLocation: /\r\n\r\n") {
+		t.Fatalf("expected redirect record: %s", s)
+	} else if !strings.Contains(s, "GET / HTTP/1.1\r\nHost: example.com\r\nAccept: text/html\r\n\r\n") {
+		t.Fatalf("expected request record: %s", s)
+	} else if !strings.Contains(s, "HTTP/1.1 200 OK\r\nContent-Length: 13\r\nContent-Type: text/html\r\n\r\n<html></html>") {
+		t.Fatalf("expected decoded response record: %s", s)
+	} else if !strings.Contains(s, "WARC-Date: 2020-01-02T03:04:05Z\r\n") {
+		t.Fatalf("expected response date: %s", s)
+	}
+}
+
+// Ensure resources without a response or a body do not fail the page.
+func TestWriter_WritePage_ResourceErr(t *testing.T) {
+	page := NewStubPage(t, map[string]string{
+		"/webpage/ResourceResponses": `{"value":[
+			{"url":"http://example.com/aborted","method":"GET","status":0},
+			{"url":"http://example.com/","method":"GET","status":200,"statusText":"OK","headers":[{"name":"Content-Length","value":"13"}]}
+		]}`,
+		"/webpage/Resource": `{"found":true,"fetchError":"Operation canceled"}`,
+	})
+
+	var buf bytes.Buffer
+	if err := warc.NewWriter(&buf).WritePage(page); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+
+	if n := strings.Count(s, "WARC/1.1\r\n"); n != 2 {
+		t.Fatalf("unexpected record count: %d", n)
+	} else if strings.Contains(s, "/aborted") {
+		t.Fatalf("unexpected aborted resource: %s", s)
+	} else if !strings.Contains(s, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n") {
+		t.Fatalf("expected response record without body: %s", s)
+	}
+}
+
+// Ensure compressed records are written as separate gzip members.
+func TestWriter_Compress(t *testing.T) {
+	var buf bytes.Buffer
+	w := warc.NewWriter(&buf)
+	w.Compress = true
+	if err := w.WriteInfo(map[string]string{"software": "test"}); err != nil {
+		t.Fatal(err)
+	} else if err := w.WriteRecord(&warc.Record{Type: warc.TypeResponse, Block: []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Multistream(false)
+	if b, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(string(b), "WARC/1.1\r\nWARC-Type: warcinfo\r\n") || !strings.HasSuffix(string(b), "software: test\r\n\r\n\r\n") {
+		t.Fatalf("unexpected first member: %q", b)
+	}
+}
+
+// NewStubPage returns a page on a stub process that responds to RPC paths
+// with canned JSON bodies.
+func NewStubPage(tb testing.TB, bodies map[string]string) *phantomjs.WebPage {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/webpage/Create" {
+			w.Write([]byte(`{"ref":{"id":"1"}}`))
+		} else if body, ok := bodies[r.URL.Path]; ok {
+			w.Write([]byte(body))
+		} else {
+			w.Write([]byte(`{}`))
+		}
+	}))
+	tb.Cleanup(srv.Close)
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portN, _ := strconv.Atoi(port)
+	page, err := phantomjs.NewProcess(phantomjs.WithPort(portN)).CreateWebPage()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return page
+}
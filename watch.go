@@ -0,0 +1,59 @@
+package phantomjs
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultWatchInterval is how often OnTitleChanged and OnURLChanged check the
+// page.
+const DefaultWatchInterval = 250 * time.Millisecond
+
+// OnTitleChanged calls fn with the new title whenever the page's title
+// changes. The title is checked every DefaultWatchInterval, so rapid changes
+// between checks are not all reported. Watching ends when stop is called, the
+// page's context is done or the page can no longer be reached. Returns an
+// error if the current title cannot be read.
+func (p *WebPage) OnTitleChanged(fn func(title string)) (stop func(), err error) {
+	return p.watch((*WebPage).Title, fn)
+}
+
+// OnURLChanged calls fn with the new URL whenever the page's location
+// changes, including history.pushState route transitions. It is checked the
+// same way as OnTitleChanged.
+func (p *WebPage) OnURLChanged(fn func(url string)) (stop func(), err error) {
+	return p.watch((*WebPage).URL, fn)
+}
+
+// watch polls get in the background and calls fn when its value changes.
+func (p *WebPage) watch(get func(*WebPage) (string, error), fn func(string)) (stop func(), err error) {
+	ctx, cancel := context.WithCancel(p.context())
+	page := p.WithContext(ctx)
+
+	last, err := get(page)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(DefaultWatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			v, err := get(page)
+			if err != nil {
+				return
+			} else if v != last {
+				last = v
+				fn(v)
+			}
+		}
+	}()
+	return cancel, nil
+}
@@ -0,0 +1,486 @@
+// Package webdriver starts PhantomJS in GhostDriver mode and provides a thin
+// client for the WebDriver (JSON Wire) protocol.
+//
+// Use this package instead of the shim-based phantomjs.Process when WebDriver
+// semantics such as element ids and implicit waits are required.
+package webdriver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Default settings.
+const (
+	DefaultPort    = 8910
+	DefaultBinPath = "phantomjs"
+)
+
+// Element location strategies.
+const (
+	ByCSSSelector     = "css selector"
+	ByXPath           = "xpath"
+	ByID              = "id"
+	ByName            = "name"
+	ByClassName       = "class name"
+	ByTagName         = "tag name"
+	ByLinkText        = "link text"
+	ByPartialLinkText = "partial link text"
+)
+
+// Status codes returned by the JSON Wire protocol.
+const (
+	StatusSuccess         = 0
+	StatusNoSuchElement   = 7
+	StatusNoSuchFrame     = 8
+	StatusUnknownCommand  = 9
+	StatusStaleElement    = 10
+	StatusJavaScriptError = 17
+	StatusTimeout         = 21
+	StatusNoSuchWindow    = 23
+	StatusUnknownError    = 13
+)
+
+// Error represents an error returned by the WebDriver server.
+type Error struct {
+	Status  int
+	Message string
+}
+
+// Error returns the error message.
+func (e *Error) Error() string {
+	return fmt.Sprintf("webdriver: status=%d: %s", e.Status, e.Message)
+}
+
+// IsNoSuchElement returns true if err reports a missing element.
+func IsNoSuchElement(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Status == StatusNoSuchElement
+}
+
+// Capabilities represents the desired capabilities of a session.
+type Capabilities map[string]interface{}
+
+// Process represents a PhantomJS process running in WebDriver mode.
+type Process struct {
+	cmd *exec.Cmd
+
+	// Path to the 'phantomjs' binary.
+	BinPath string
+
+	// HTTP port that the WebDriver server listens on.
+	Port int
+
+	// Additional command line arguments passed to phantomjs.
+	Args []string
+
+	// Output from the process.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewProcess returns a new instance of Process.
+func NewProcess(port int) *Process {
+	return &Process{
+		BinPath: DefaultBinPath,
+		Port:    port,
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	}
+}
+
+// Open starts phantomjs with the --webdriver flag.
+func (p *Process) Open() error {
+	if err := func() error {
+		args := append([]string{fmt.Sprintf("--webdriver=%d", p.Port)}, p.Args...)
+		cmd := exec.Command(p.BinPath, args...)
+		cmd.Stdout = p.Stdout
+		cmd.Stderr = p.Stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		p.cmd = cmd
+
+		// Wait until the WebDriver server is available.
+		return p.wait()
+	}(); err != nil {
+		p.Close()
+		return err
+	}
+	return nil
+}
+
+// Close stops the process.
+func (p *Process) Close() error {
+	if p.cmd == nil {
+		return nil
+	}
+	err := p.cmd.Process.Kill()
+	p.cmd.Wait()
+	return err
+}
+
+// URL returns the process' WebDriver URL.
+func (p *Process) URL() string {
+	return fmt.Sprintf("http://localhost:%d", p.Port)
+}
+
+// wait continually checks the process until it gets a response or times out.
+func (p *Process) wait() error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(30 * time.Second)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return errors.New("timeout")
+		case <-ticker.C:
+			if err := doJSON("GET", p.URL()+"/status", nil, nil); err == nil {
+				return nil
+			}
+		}
+	}
+}
+
+// NewSession starts a new session on the process.
+func (p *Process) NewSession(caps Capabilities) (*Session, error) {
+	return NewSession(p.URL(), caps)
+}
+
+// Session represents a WebDriver session.
+type Session struct {
+	url string
+	id  string
+}
+
+// NewSession starts a new session on the WebDriver server at baseURL.
+func NewSession(baseURL string, caps Capabilities) (*Session, error) {
+	if caps == nil {
+		caps = Capabilities{}
+	}
+
+	var resp response
+	if err := doJSON("POST", baseURL+"/session", map[string]interface{}{"desiredCapabilities": caps}, &resp); err != nil {
+		return nil, err
+	} else if resp.SessionID == "" {
+		return nil, errors.New("webdriver: no session id returned")
+	}
+	return &Session{url: baseURL, id: resp.SessionID}, nil
+}
+
+// ID returns the session identifier.
+func (s *Session) ID() string { return s.id }
+
+// Delete ends the session.
+func (s *Session) Delete() error {
+	return s.do("DELETE", "", nil, nil)
+}
+
+// Get navigates to rawurl.
+func (s *Session) Get(rawurl string) error {
+	return s.do("POST", "/url", map[string]interface{}{"url": rawurl}, nil)
+}
+
+// URL returns the current URL.
+func (s *Session) URL() (v string, err error) {
+	err = s.do("GET", "/url", nil, &v)
+	return v, err
+}
+
+// Title returns the title of the current page.
+func (s *Session) Title() (v string, err error) {
+	err = s.do("GET", "/title", nil, &v)
+	return v, err
+}
+
+// Source returns the source of the current page.
+func (s *Session) Source() (v string, err error) {
+	err = s.do("GET", "/source", nil, &v)
+	return v, err
+}
+
+// Back navigates backwards in the browser history.
+func (s *Session) Back() error {
+	return s.do("POST", "/back", nil, nil)
+}
+
+// Forward navigates forwards in the browser history.
+func (s *Session) Forward() error {
+	return s.do("POST", "/forward", nil, nil)
+}
+
+// Refresh reloads the current page.
+func (s *Session) Refresh() error {
+	return s.do("POST", "/refresh", nil, nil)
+}
+
+// SetImplicitWait sets the amount of time the server waits when locating elements.
+func (s *Session) SetImplicitWait(d time.Duration) error {
+	return s.do("POST", "/timeouts/implicit_wait", map[string]interface{}{"ms": int(d / time.Millisecond)}, nil)
+}
+
+// SetScriptTimeout sets the amount of time asynchronous scripts may run.
+func (s *Session) SetScriptTimeout(d time.Duration) error {
+	return s.do("POST", "/timeouts/async_script", map[string]interface{}{"ms": int(d / time.Millisecond)}, nil)
+}
+
+// SetPageLoadTimeout sets the amount of time to wait for a page to load.
+func (s *Session) SetPageLoadTimeout(d time.Duration) error {
+	return s.do("POST", "/timeouts", map[string]interface{}{"type": "page load", "ms": int(d / time.Millisecond)}, nil)
+}
+
+// ExecuteScript executes the body of a JavaScript function with args and
+// returns its return value.
+func (s *Session) ExecuteScript(script string, args ...interface{}) (v interface{}, err error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	err = s.do("POST", "/execute", map[string]interface{}{"script": script, "args": args}, &v)
+	return v, err
+}
+
+// Screenshot returns a PNG screenshot of the current page.
+func (s *Session) Screenshot() ([]byte, error) {
+	var v string
+	if err := s.do("GET", "/screenshot", nil, &v); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(v)
+}
+
+// FindElement returns the first element matching value using the strategy by.
+func (s *Session) FindElement(by, value string) (*Element, error) {
+	return s.findElement("", by, value)
+}
+
+// FindElements returns all elements matching value using the strategy by.
+func (s *Session) FindElements(by, value string) ([]*Element, error) {
+	return s.findElements("", by, value)
+}
+
+// ActiveElement returns the element that currently has focus.
+func (s *Session) ActiveElement() (*Element, error) {
+	var ref elementJSON
+	if err := s.do("POST", "/element/active", nil, &ref); err != nil {
+		return nil, err
+	}
+	return &Element{session: s, id: ref.ID}, nil
+}
+
+func (s *Session) findElement(parent, by, value string) (*Element, error) {
+	var ref elementJSON
+	if err := s.do("POST", parent+"/element", map[string]interface{}{"using": by, "value": value}, &ref); err != nil {
+		return nil, err
+	}
+	return &Element{session: s, id: ref.ID}, nil
+}
+
+func (s *Session) findElements(parent, by, value string) ([]*Element, error) {
+	var refs []elementJSON
+	if err := s.do("POST", parent+"/elements", map[string]interface{}{"using": by, "value": value}, &refs); err != nil {
+		return nil, err
+	}
+
+	a := make([]*Element, len(refs))
+	for i := range refs {
+		a[i] = &Element{session: s, id: refs[i].ID}
+	}
+	return a, nil
+}
+
+// do sends a command to the session and decodes the value into v.
+func (s *Session) do(method, path string, req, v interface{}) error {
+	var resp response
+	if err := doJSON(method, s.url+"/session/"+url.PathEscape(s.id)+path, req, &resp); err != nil {
+		return err
+	}
+	if v != nil && len(resp.Value) > 0 {
+		if err := json.Unmarshal(resp.Value, v); err != nil {
+			return fmt.Errorf("unmarshal error: err=%s, body=%s", err, resp.Value)
+		}
+	}
+	return nil
+}
+
+// Element represents a reference to a DOM element within a session.
+type Element struct {
+	session *Session
+	id      string
+}
+
+// ID returns the WebDriver element identifier.
+func (e *Element) ID() string { return e.id }
+
+// path returns the element's path relative to the session.
+func (e *Element) path() string {
+	return "/element/" + url.PathEscape(e.id)
+}
+
+// Click clicks on the element.
+func (e *Element) Click() error {
+	return e.session.do("POST", e.path()+"/click", nil, nil)
+}
+
+// Clear clears the value of a text input element.
+func (e *Element) Clear() error {
+	return e.session.do("POST", e.path()+"/clear", nil, nil)
+}
+
+// Submit submits the form containing the element.
+func (e *Element) Submit() error {
+	return e.session.do("POST", e.path()+"/submit", nil, nil)
+}
+
+// SendKeys types keys into the element.
+func (e *Element) SendKeys(keys string) error {
+	a := make([]string, 0, len(keys))
+	for _, ch := range keys {
+		a = append(a, string(ch))
+	}
+	return e.session.do("POST", e.path()+"/value", map[string]interface{}{"value": a}, nil)
+}
+
+// Text returns the visible text of the element.
+func (e *Element) Text() (v string, err error) {
+	err = e.session.do("GET", e.path()+"/text", nil, &v)
+	return v, err
+}
+
+// TagName returns the tag name of the element.
+func (e *Element) TagName() (v string, err error) {
+	err = e.session.do("GET", e.path()+"/name", nil, &v)
+	return v, err
+}
+
+// Attribute returns the value of the named attribute.
+func (e *Element) Attribute(name string) (v string, err error) {
+	err = e.session.do("GET", e.path()+"/attribute/"+url.PathEscape(name), nil, &v)
+	return v, err
+}
+
+// IsDisplayed returns true if the element is visible.
+func (e *Element) IsDisplayed() (v bool, err error) {
+	err = e.session.do("GET", e.path()+"/displayed", nil, &v)
+	return v, err
+}
+
+// IsSelected returns true if an option, checkbox or radio is selected.
+func (e *Element) IsSelected() (v bool, err error) {
+	err = e.session.do("GET", e.path()+"/selected", nil, &v)
+	return v, err
+}
+
+// Hover scrolls the element into view and moves the mouse to its center, so
+// mouseover handlers such as those of menus run.
+func (e *Element) Hover() error {
+	if err := e.ScrollIntoView(); err != nil {
+		return err
+	}
+	return e.session.do("POST", "/moveto", map[string]interface{}{"element": e.id}, nil)
+}
+
+// Focus gives the element keyboard focus.
+func (e *Element) Focus() error {
+	return e.execute(`arguments[0].focus();`)
+}
+
+// Blur removes keyboard focus from the element.
+func (e *Element) Blur() error {
+	return e.execute(`arguments[0].blur();`)
+}
+
+// ScrollIntoView scrolls the page until the element is visible, such as to
+// trigger lazy loaded content before a screenshot.
+func (e *Element) ScrollIntoView() error {
+	return e.execute(`arguments[0].scrollIntoView();`)
+}
+
+// execute executes script with the element as its first argument.
+func (e *Element) execute(script string) error {
+	return e.session.do("POST", "/execute", map[string]interface{}{"script": script, "args": []interface{}{elementJSON{ID: e.id}}}, nil)
+}
+
+// FindElement returns the first descendant matching value using the strategy by.
+func (e *Element) FindElement(by, value string) (*Element, error) {
+	return e.session.findElement(e.path(), by, value)
+}
+
+// FindElements returns all descendants matching value using the strategy by.
+func (e *Element) FindElements(by, value string) ([]*Element, error) {
+	return e.session.findElements(e.path(), by, value)
+}
+
+// response represents a JSON Wire protocol response.
+type response struct {
+	SessionID string          `json:"sessionId"`
+	Status    int             `json:"status"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// elementJSON is a struct for decoding element references.
+type elementJSON struct {
+	ID string `json:"ELEMENT"`
+}
+
+// doJSON sends an HTTP request to url and encodes and decodes the req/resp as JSON.
+func doJSON(method, url string, req interface{}, resp *response) error {
+	// Encode request.
+	var r io.Reader
+	if req != nil {
+		buf, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(buf)
+	}
+
+	httpRequest, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json;charset=UTF-8")
+
+	httpResponse, err := http.DefaultClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	body, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return err
+	}
+
+	// Decode response and check for protocol errors.
+	var tmp response
+	if err := json.Unmarshal(body, &tmp); err != nil {
+		if httpResponse.StatusCode != http.StatusOK {
+			return &Error{Status: StatusUnknownError, Message: string(body)}
+		}
+		return fmt.Errorf("unmarshal error: err=%s, body=%s", err, body)
+	} else if tmp.Status != StatusSuccess {
+		var v struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(tmp.Value, &v)
+		return &Error{Status: tmp.Status, Message: v.Message}
+	}
+
+	if resp != nil {
+		*resp = tmp
+	}
+	return nil
+}
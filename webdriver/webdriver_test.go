@@ -0,0 +1,138 @@
+package webdriver_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/phantomjs/webdriver"
+)
+
+// Ensure a session can be created and used to find and interact with elements.
+func TestSession(t *testing.T) {
+	var clicked bool
+	var wait float64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var req map[string]interface{}
+		json.Unmarshal(body, &req)
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /session":
+			w.Write([]byte(`{"sessionId":"S1","status":0,"value":{}}`))
+		case "POST /session/S1/timeouts/implicit_wait":
+			wait = req["ms"].(float64)
+			w.Write([]byte(`{"sessionId":"S1","status":0,"value":null}`))
+		case "GET /session/S1/title":
+			w.Write([]byte(`{"sessionId":"S1","status":0,"value":"TITLE"}`))
+		case "POST /session/S1/element":
+			if req["using"] != webdriver.ByCSSSelector || req["value"] != "#foo" {
+				w.Write([]byte(`{"sessionId":"S1","status":7,"value":{"message":"no such element"}}`))
+				return
+			}
+			w.Write([]byte(`{"sessionId":"S1","status":0,"value":{"ELEMENT":":wdc:1"}}`))
+		case "POST /session/S1/element/:wdc:1/click":
+			clicked = true
+			w.Write([]byte(`{"sessionId":"S1","status":0,"value":null}`))
+		case "DELETE /session/S1":
+			w.Write([]byte(`{"sessionId":"S1","status":0,"value":null}`))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	sess, err := webdriver.NewSession(s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if sess.ID() != "S1" {
+		t.Fatalf("unexpected session id: %s", sess.ID())
+	}
+
+	if err := sess.SetImplicitWait(2 * time.Second); err != nil {
+		t.Fatal(err)
+	} else if wait != 2000 {
+		t.Fatalf("unexpected implicit wait: %v", wait)
+	}
+
+	if title, err := sess.Title(); err != nil {
+		t.Fatal(err)
+	} else if title != "TITLE" {
+		t.Fatalf("unexpected title: %s", title)
+	}
+
+	// Find & click element.
+	el, err := sess.FindElement(webdriver.ByCSSSelector, "#foo")
+	if err != nil {
+		t.Fatal(err)
+	} else if el.ID() != ":wdc:1" {
+		t.Fatalf("unexpected element id: %s", el.ID())
+	} else if err := el.Click(); err != nil {
+		t.Fatal(err)
+	} else if !clicked {
+		t.Fatal("expected click")
+	}
+
+	// Missing elements should return a typed error.
+	if _, err := sess.FindElement(webdriver.ByCSSSelector, "#bar"); !webdriver.IsNoSuchElement(err) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := sess.Delete(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure element interactions execute scripts against the element and move
+// the mouse to it.
+func TestElement_Interactions(t *testing.T) {
+	var calls []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.Method + " " + r.URL.Path {
+		case "POST /session":
+			w.Write([]byte(`{"sessionId":"S1","status":0,"value":{}}`))
+			return
+		case "POST /session/S1/element":
+			w.Write([]byte(`{"sessionId":"S1","status":0,"value":{"ELEMENT":":wdc:1"}}`))
+			return
+		case "POST /session/S1/execute", "POST /session/S1/moveto":
+			calls = append(calls, r.URL.Path[len("/session/S1"):]+" "+string(body))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"sessionId":"S1","status":0,"value":null}`))
+	}))
+	defer s.Close()
+
+	sess, err := webdriver.NewSession(s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	el, err := sess.FindElement(webdriver.ByCSSSelector, "#menu")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := el.Hover(); err != nil {
+		t.Fatal(err)
+	} else if err := el.Focus(); err != nil {
+		t.Fatal(err)
+	} else if err := el.Blur(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(calls, []string{
+		`/execute {"args":[{"ELEMENT":":wdc:1"}],"script":"arguments[0].scrollIntoView();"}`,
+		`/moveto {"element":":wdc:1"}`,
+		`/execute {"args":[{"ELEMENT":":wdc:1"}],"script":"arguments[0].focus();"}`,
+		`/execute {"args":[{"ELEMENT":":wdc:1"}],"script":"arguments[0].blur();"}`,
+	}) {
+		t.Fatalf("unexpected calls: %q", calls)
+	}
+}